@@ -1,20 +1,33 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/gofiber/fiber/v2/middleware/recover"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
+	"github.com/jackc/pgx/v5/pgxpool"
 
+	"linkbio/internal/cache"
+	"linkbio/internal/cdn"
 	"linkbio/internal/config"
 	"linkbio/internal/database"
 	"linkbio/internal/handler"
 	"linkbio/internal/middleware"
+	"linkbio/internal/ogimage"
+	"linkbio/internal/ratelimit"
 	"linkbio/internal/repo"
 	"linkbio/internal/service"
+	"linkbio/internal/targeting"
+	"linkbio/internal/urlpolicy"
 )
 
 func main() {
@@ -26,6 +39,24 @@ func main() {
 	}
 	defer db.Close()
 
+	migrator, err := database.NewMigrator(db)
+	if err != nil {
+		log.Fatal("Failed to load migrations:", err)
+	}
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(db, migrator, os.Args[2:])
+		return
+	}
+	if cfg.AutoMigrate {
+		if _, err := migrator.Up(context.Background()); err != nil {
+			log.Fatal("Failed to migrate database:", err)
+		}
+		backfill(context.Background(), db)
+	}
+	if err := migrator.Check(context.Background()); err != nil {
+		log.Fatal(err)
+	}
+
 	// Repos
 	userRepo := repo.NewUserRepo(db)
 	pageRepo := repo.NewPageRepo(db)
@@ -33,28 +64,114 @@ func main() {
 	themeRepo := repo.NewThemeRepo(db)
 	domainRepo := repo.NewDomainRepo(db)
 	bioRepo := repo.NewBioRepo(db)
+	aggregateRepo := repo.NewPageAggregateRepo(db)
+	assetRepo := repo.NewAssetRepo(db)
+	scheduleRepo := repo.NewScheduleRepo(db)
+	analyticsRepo := repo.NewAnalyticsRepo(db)
+	experimentRepo := repo.NewExperimentRepo(db)
+	shortLinkRepo := repo.NewShortLinkRepo(db)
+
+	// Rate limiting
+	var limitStore ratelimit.Store = ratelimit.NewMemoryStore()
+	if cfg.RateLimitStore == "postgres" {
+		rateLimitRepo := repo.NewRateLimitRepo(db)
+		limitStore = rateLimitRepo
+		go func() {
+			for range time.Tick(10 * time.Minute) {
+				if err := rateLimitRepo.DeleteExpired(context.Background()); err != nil {
+					log.Printf("[RateLimit] cleanup: %v", err)
+				}
+			}
+		}()
+	}
+	limiter := ratelimit.NewLimiter(limitStore)
+	loginLockout := ratelimit.NewLockout(limitStore, ratelimit.LoginLockout)
+	pagePasswordLockout := ratelimit.NewLockout(limitStore, ratelimit.PagePasswordLockout)
+
+	// Public render cache: host+path -> compiled bytes. Invalidations are
+	// sent to every instance over Postgres NOTIFY.
+	renderCache := cache.NewRenderCache(10000, 5*time.Minute, time.Hour)
+	cacheBus, err := repo.NewCacheBusRepo(db)
+	if err != nil {
+		log.Fatal("Failed to start cache bus:", err)
+	}
+	renderCache.Broadcast(cacheBus)
+	go renderCache.Follow(context.Background(), 5*time.Second)
+
+	// CDN purges run in the background and retry on failure
+	var purger cdn.Purger = cdn.NoopPurger{}
+	if cfg.CDNPurgeURL != "" {
+		purger = cdn.NewRetryingPurger(cdn.NewHTTPPurger(cfg.CDNPurgeURL, cfg.CDNPurgeToken), 5, 2*time.Second)
+	}
+
+	// Share images
+	ogRenderer, err := newOGRenderer(cfg)
+	if err != nil {
+		log.Fatal("Failed to load share image fonts:", err)
+	}
+
+	// Link URL policy; the blocklist is reread when the file changes
+	blocklist, err := urlpolicy.LoadBlocklist(cfg.URLBlocklist)
+	if err != nil {
+		log.Fatal("Failed to load URL blocklist:", err)
+	}
+	go blocklist.Watch(context.Background(), 30*time.Second)
+	urlPolicy := urlpolicy.New(blocklist)
+
+	// Country lookup for link targeting
+	geoIP, err := targeting.OpenGeoIP(cfg.GeoIPDB)
+	if err != nil {
+		log.Fatal("Failed to open GeoIP database:", err)
+	}
 
 	// Services
 	authService := service.NewAuthService(userRepo, cfg.JWTSecret)
-	pageService := service.NewPageService(pageRepo, blockRepo)
-	themeService := service.NewThemeService(themeRepo)
-	compilerService := service.NewCompilerService(pageRepo, blockRepo, themeRepo, userRepo)
-	bioService := service.NewBioService(bioRepo, pageRepo, blockRepo, userRepo)
+	pageService := service.NewPageService(pageRepo, blockRepo, aggregateRepo, assetRepo, urlPolicy)
+	marketplaceService := service.NewMarketplaceService(themeRepo, userRepo)
+	ogImageService := service.NewOGImageService(ogRenderer, assetRepo)
+	compilerService := service.NewCompilerService(pageRepo, aggregateRepo, themeRepo, userRepo, assetRepo, ogImageService, urlPolicy, renderCache, purger)
+	themeService := service.NewThemeService(themeRepo, pageRepo, userRepo, assetRepo, compilerService)
+	bioService := service.NewBioService(bioRepo, pageRepo, blockRepo, userRepo, aggregateRepo, urlPolicy)
+	domainService := service.NewDomainService(domainRepo, renderCache, purger)
+	previewService := service.NewPreviewService(compilerService, pageRepo, cfg.PreviewKey(), cfg.AppURL)
+	scheduleService := service.NewScheduleService(scheduleRepo, pageRepo, compilerService)
+	analyticsService := service.NewAnalyticsService(analyticsRepo, pageRepo, bioRepo, experimentRepo, geoIP, renderCache, purger)
+	experimentService := service.NewExperimentService(experimentRepo, pageRepo, aggregateRepo, renderCache, purger)
+	shortLinkService := service.NewShortLinkService(shortLinkRepo, bioRepo, domainRepo)
+	qrService := service.NewQRService(pageRepo, bioRepo, domainRepo, userRepo, assetRepo)
+	assetService := service.NewAssetService(assetRepo)
+
+	// Scheduled publishes; safe to run on every instance
+	go scheduleService.Run(context.Background(), 30*time.Second)
 
 	// Handlers
-	authHandler := handler.NewAuthHandler(authService)
-	pageHandler := handler.NewPageHandler(pageService, compilerService)
+	authHandler := handler.NewAuthHandler(authService, limiter, loginLockout)
+	pageHandler := handler.NewPageHandler(pageService, compilerService, domainService, previewService)
+	scheduleHandler := handler.NewScheduleHandler(scheduleService)
+	analyticsHandler := handler.NewAnalyticsHandler(analyticsService)
+	experimentHandler := handler.NewExperimentHandler(experimentService)
+	shortLinkHandler := handler.NewShortLinkHandler(shortLinkService, analyticsService)
+	qrHandler := handler.NewQRHandler(qrService)
+	assetHandler := handler.NewAssetHandler(assetService)
 	themeHandler := handler.NewThemeHandler(themeService)
-	publicHandler := handler.NewPublicHandler(pageRepo, domainRepo)
+	marketplaceHandler := handler.NewMarketplaceHandler(marketplaceService)
+	publicHandler := handler.NewPublicHandler(pageRepo, domainRepo, themeRepo, assetRepo, bioRepo, experimentRepo, previewService, renderCache, limiter, pagePasswordLockout)
 	bioHandler := handler.NewBioHandler(bioService)
+	domainHandler := handler.NewDomainHandler(domainService)
 
 	// Fiber app
 	app := fiber.New(fiber.Config{
-		ErrorHandler: handler.ErrorHandler,
+		ErrorHandler:            handler.ErrorHandler,
+		ProxyHeader:             cfg.ProxyHeader,
+		EnableTrustedProxyCheck: len(cfg.TrustedProxies) > 0,
+		TrustedProxies:          cfg.TrustedProxies,
 	})
 
+	app.Use(requestid.New())
 	app.Use(recover.New())
-	app.Use(logger.New())
+	app.Use(logger.New(logger.Config{
+		Format: "${time} ${locals:requestid} ${status} - ${latency} ${method} ${path}\n",
+	}))
 	app.Use(cors.New(cors.Config{
 		AllowOrigins:     cfg.CORSOrigins,
 		AllowCredentials: true,
@@ -62,17 +179,30 @@ func main() {
 
 	// Public routes
 	app.Get("/r", publicHandler.Render)
-	app.Post("/r/password", publicHandler.VerifyPassword)
+	app.Post("/r/password", middleware.RateLimit(limiter, ratelimit.PagePasswordIP), publicHandler.VerifyPassword)
+	app.Get("/r/preview/:token", middleware.RateLimit(limiter, ratelimit.PreviewIP), publicHandler.Preview)
+	app.Get("/themes/:hash.css", publicHandler.Stylesheet)
+	app.Get("/og/:key.png", publicHandler.OGImage)
+	app.Get("/assets/:key", assetHandler.Serve)
+	// Clicks on tracked and short links share one bucket, so a bot cannot
+	// use up a link's click cap.
+	app.Get("/l/:id", middleware.RateLimit(limiter, ratelimit.LinkClickIP), analyticsHandler.Click)
+	app.Get("/s/:code", middleware.RateLimit(limiter, ratelimit.LinkClickIP), shortLinkHandler.Redirect)
+	app.Post("/r/views", middleware.RateLimit(limiter, ratelimit.PageViewIP), analyticsHandler.View)
+	app.Get("/sitemap.xml", publicHandler.Sitemap)
+	app.Get("/robots.txt", publicHandler.Robots)
 
 	// API routes
 	api := app.Group("/api")
 
 	// Auth
-	api.Post("/auth/register", authHandler.Register)
-	api.Post("/auth/login", authHandler.Login)
+	api.Post("/auth/register", middleware.RateLimit(limiter, ratelimit.RegisterIP), authHandler.Register)
+	api.Post("/auth/login", middleware.RateLimit(limiter, ratelimit.LoginIP), authHandler.Login)
 	api.Post("/auth/logout", authHandler.Logout)
 	api.Get("/auth/me", middleware.Auth(cfg.JWTSecret), authHandler.Me)
-	api.Get("/auth/check-username", authHandler.CheckUsername)
+	api.Get("/auth/check-username", middleware.RateLimit(limiter, ratelimit.CheckUsernameIP), authHandler.CheckUsername)
+
+	api.Get("/social/platforms", bioHandler.SocialPlatforms)
 
 	// Protected routes
 	protected := api.Group("", middleware.Auth(cfg.JWTSecret))
@@ -89,7 +219,12 @@ func main() {
 	protected.Post("/bio/links", bioHandler.AddLink)
 	protected.Put("/bio/links/:id", bioHandler.UpdateLink)
 	protected.Delete("/bio/links/:id", bioHandler.DeleteLink)
+	protected.Get("/bio/links/:id/qr", qrHandler.Link)
+	protected.Get("/bio/links/:id/short-links", shortLinkHandler.List)
+	protected.Post("/bio/links/:id/short-links", shortLinkHandler.Create)
+	protected.Delete("/bio/links/:id/short-links/:shortId", shortLinkHandler.Delete)
 	protected.Put("/bio/profile", bioHandler.UpdateProfile)
+	protected.Get("/bio/social", bioHandler.GetSocialLinks)
 	protected.Put("/bio/social", bioHandler.UpdateSocialLinks)
 
 	// Pages
@@ -99,15 +234,53 @@ func main() {
 	protected.Get("/pages/:id/draft", pageHandler.GetDraft)
 	protected.Post("/pages/:id/save", pageHandler.Save)
 	protected.Post("/pages/:id/publish", pageHandler.Publish)
+	protected.Post("/pages/:id/unpublish", pageHandler.Unpublish)
+	protected.Put("/pages/:id/seo", pageHandler.UpdateSEO)
+	protected.Put("/pages/:id/utm", pageHandler.UpdateUTM)
+	protected.Post("/pages/:id/preview", pageHandler.CreatePreview)
+	protected.Get("/pages/:id/schedules", scheduleHandler.List)
+	protected.Post("/pages/:id/schedules", scheduleHandler.Create)
+	protected.Delete("/pages/:id/schedules/:scheduleId", scheduleHandler.Cancel)
+	protected.Put("/pages/:id/route", pageHandler.UpdateRoute)
+	protected.Get("/pages/:id/qr", qrHandler.Page)
+	protected.Get("/pages/:id/analytics", analyticsHandler.Summary)
+	protected.Get("/pages/:id/experiments", experimentHandler.List)
+	protected.Post("/pages/:id/experiments", experimentHandler.Create)
+	protected.Get("/pages/:id/experiments/:experimentId", experimentHandler.Results)
+	protected.Post("/pages/:id/experiments/:experimentId/end", experimentHandler.End)
 	protected.Delete("/pages/:id", pageHandler.Delete)
 
+	// Assets
+	protected.Post("/assets", assetHandler.Upload)
+
 	// Themes
 	protected.Get("/themes/presets", themeHandler.ListPresets)
-	protected.Get("/themes/custom", themeHandler.GetUserCustomTheme)
+	protected.Get("/themes/custom", themeHandler.ListCustom)
 	protected.Post("/themes/custom", themeHandler.CreateCustom)
+	protected.Get("/themes/custom/:id", themeHandler.GetCustom)
+	protected.Put("/themes/custom/:id", themeHandler.UpdateCustom)
 	protected.Delete("/themes/custom/:id", themeHandler.DeleteCustom)
+	protected.Post("/themes/custom/:id/duplicate", themeHandler.DuplicateCustom)
+	protected.Get("/themes/custom/:id/upgrade", themeHandler.PreviewUpgrade)
+	protected.Post("/themes/custom/:id/upgrade", themeHandler.Upgrade)
 	protected.Post("/themes/apply", themeHandler.Apply)
 
+	// Theme marketplace: user-authored presets and their review
+	protected.Get("/themes/marketplace", marketplaceHandler.Browse)
+	protected.Get("/themes/presets/mine", marketplaceHandler.ListMine)
+	protected.Get("/themes/presets/review", marketplaceHandler.ReviewQueue)
+	protected.Post("/themes/presets", marketplaceHandler.Create)
+	protected.Get("/themes/presets/:id", marketplaceHandler.Get)
+	protected.Put("/themes/presets/:id", marketplaceHandler.Update)
+	protected.Post("/themes/presets/:id/submit", marketplaceHandler.Submit)
+	protected.Post("/themes/presets/:id/approve", marketplaceHandler.Approve)
+	protected.Post("/themes/presets/:id/reject", marketplaceHandler.Reject)
+	protected.Get("/themes/presets/:id/versions", marketplaceHandler.ListVersions)
+	protected.Post("/themes/presets/:id/versions", marketplaceHandler.PublishVersion)
+
+	// Domains
+	protected.Post("/domains/:id/disable", domainHandler.Disable)
+
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8080"
@@ -116,3 +289,93 @@ func main() {
 	log.Printf("Server starting on :%s", port)
 	log.Fatal(app.Listen(":" + port))
 }
+
+// runMigrate handles `migrate up`, `migrate down [n]` and `migrate status`.
+func runMigrate(db *pgxpool.Pool, migrator *database.Migrator, args []string) {
+	ctx := context.Background()
+	cmd := "up"
+	if len(args) > 0 {
+		cmd = args[0]
+	}
+
+	switch cmd {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, m := range applied {
+			log.Printf("applied %04d_%s", m.Version, m.Name)
+		}
+		if len(applied) == 0 {
+			log.Println("database is up to date")
+		}
+		backfill(ctx, db)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				log.Fatalf("invalid step count %q", args[1])
+			}
+			steps = n
+		}
+		reverted, err := migrator.Down(ctx, steps)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, m := range reverted {
+			log.Printf("reverted %04d_%s", m.Version, m.Name)
+		}
+	case "status":
+		status, err := migrator.Status(ctx)
+		if errors.Is(err, database.ErrNotInitialized) {
+			log.Println("database is not initialized, no migrations applied")
+			return
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, s := range status {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%04d_%-30s %s\n", s.Version, s.Name, applied)
+		}
+	default:
+		log.Fatalf("unknown migrate command %q (want up, down [n] or status)", cmd)
+	}
+}
+
+// backfill fills in data the SQL migrations cannot compute: the dominant
+// color of images uploaded before it was stored on upload.
+func backfill(ctx context.Context, db *pgxpool.Pool) {
+	filled, err := service.NewAssetService(repo.NewAssetRepo(db)).BackfillDominantColors(ctx)
+	if err != nil {
+		log.Fatal("Failed to backfill asset colors:", err)
+	}
+	if filled > 0 {
+		log.Printf("backfilled dominant colors of %d images", filled)
+	}
+}
+
+// newOGRenderer loads the configured share image fonts, falling back to the
+// bundled ones.
+func newOGRenderer(cfg *config.Config) (*ogimage.Renderer, error) {
+	read := func(path string) ([]byte, error) {
+		if path == "" {
+			return nil, nil
+		}
+		return os.ReadFile(path)
+	}
+	regular, err := read(cfg.OGFontRegular)
+	if err != nil {
+		return nil, err
+	}
+	bold, err := read(cfg.OGFontBold)
+	if err != nil {
+		return nil, err
+	}
+	return ogimage.New(regular, bold)
+}
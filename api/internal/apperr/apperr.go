@@ -0,0 +1,128 @@
+// Package apperr defines the typed errors services return and handlers
+// turn into the API error envelope.
+package apperr
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Code is a stable, machine-readable error identifier.
+type Code string
+
+const (
+	CodeBadRequest   Code = "bad_request"
+	CodeValidation   Code = "validation_failed"
+	CodeUnauthorized Code = "unauthorized"
+	CodeForbidden    Code = "forbidden"
+	CodeNotFound     Code = "not_found"
+	CodeConflict     Code = "conflict"
+	CodeRateLimited  Code = "rate_limited"
+	CodeInternal     Code = "internal"
+)
+
+// FieldError points at one invalid request field.
+type FieldError struct {
+	Field      string `json:"field"`
+	Code       string `json:"code"`
+	MessageKey string `json:"message_key"`
+}
+
+// Error is an application error. Message and MessageKey are safe to show
+// to clients; Cause is only logged.
+type Error struct {
+	Code       Code
+	Status     int
+	MessageKey string
+	Message    string
+	Fields     []FieldError
+	RetryAfter time.Duration
+	Cause      error
+}
+
+func (e *Error) Error() string {
+	if e.Cause != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Code, e.Message, e.Cause)
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Cause
+}
+
+// Is matches errors with the same code and message key, so a sentinel
+// still matches after WithCause or WithField.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code && t.MessageKey == e.MessageKey
+}
+
+// WithCause returns a copy of e wrapping cause.
+func (e *Error) WithCause(cause error) *Error {
+	c := *e
+	c.Cause = cause
+	return &c
+}
+
+// WithField returns a copy of e with an extra field error.
+func (e *Error) WithField(field, code, messageKey string) *Error {
+	c := *e
+	c.Fields = append(append([]FieldError(nil), e.Fields...), FieldError{
+		Field:      field,
+		Code:       code,
+		MessageKey: messageKey,
+	})
+	return &c
+}
+
+func New(status int, code Code, messageKey, message string) *Error {
+	return &Error{Code: code, Status: status, MessageKey: messageKey, Message: message}
+}
+
+func BadRequest(messageKey, message string) *Error {
+	return New(http.StatusBadRequest, CodeBadRequest, messageKey, message)
+}
+
+func Validation(messageKey, message string) *Error {
+	return New(http.StatusUnprocessableEntity, CodeValidation, messageKey, message)
+}
+
+func Unauthorized(messageKey, message string) *Error {
+	return New(http.StatusUnauthorized, CodeUnauthorized, messageKey, message)
+}
+
+func Forbidden(messageKey, message string) *Error {
+	return New(http.StatusForbidden, CodeForbidden, messageKey, message)
+}
+
+func NotFound(messageKey, message string) *Error {
+	return New(http.StatusNotFound, CodeNotFound, messageKey, message)
+}
+
+func Conflict(messageKey, message string) *Error {
+	return New(http.StatusConflict, CodeConflict, messageKey, message)
+}
+
+func RateLimited(retryAfter time.Duration) *Error {
+	e := New(http.StatusTooManyRequests, CodeRateLimited, "common.rate_limited", "too many requests")
+	e.RetryAfter = retryAfter
+	return e
+}
+
+// Internal wraps an unexpected error. Its cause is never sent to clients.
+func Internal(cause error) *Error {
+	return New(http.StatusInternalServerError, CodeInternal, "common.internal", "internal server error").WithCause(cause)
+}
+
+// From converts any error into an *Error, treating unknown errors as
+// internal.
+func From(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	return Internal(err)
+}
@@ -0,0 +1,82 @@
+package cache
+
+import (
+	"context"
+	"sync"
+)
+
+// Invalidation names the page or domain whose routes were dropped.
+type Invalidation struct {
+	PageID   int64 `json:"page_id,omitempty"`
+	DomainID int64 `json:"domain_id,omitempty"`
+}
+
+// Bus carries invalidations between API instances. An instance does not
+// receive its own.
+type Bus interface {
+	Publish(ctx context.Context, inv Invalidation) error
+	// Listen calls fn for every invalidation published by another
+	// instance until ctx is done or the connection fails.
+	Listen(ctx context.Context, fn func(Invalidation)) error
+}
+
+// LocalHub connects the buses of caches in one process, standing in for
+// several instances.
+type LocalHub struct {
+	mu    sync.Mutex
+	buses []*LocalBus
+}
+
+func NewLocalHub() *LocalHub {
+	return &LocalHub{}
+}
+
+// Bus returns a new member of the hub.
+func (h *LocalHub) Bus() *LocalBus {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	b := &LocalBus{hub: h}
+	h.buses = append(h.buses, b)
+	return b
+}
+
+// LocalBus is one instance's end of a LocalHub. Publish delivers to the
+// other members' listeners before it returns.
+type LocalBus struct {
+	hub *LocalHub
+
+	mu        sync.Mutex
+	listeners []func(Invalidation)
+}
+
+func (b *LocalBus) Publish(ctx context.Context, inv Invalidation) error {
+	b.hub.mu.Lock()
+	buses := append([]*LocalBus(nil), b.hub.buses...)
+	b.hub.mu.Unlock()
+	for _, other := range buses {
+		if other == b {
+			continue
+		}
+		other.mu.Lock()
+		listeners := make([]func(Invalidation), len(other.listeners))
+		copy(listeners, other.listeners)
+		other.mu.Unlock()
+		for _, fn := range listeners {
+			fn(inv)
+		}
+	}
+	return nil
+}
+
+func (b *LocalBus) Listen(ctx context.Context, fn func(Invalidation)) error {
+	b.mu.Lock()
+	b.listeners = append(b.listeners, fn)
+	i := len(b.listeners) - 1
+	b.mu.Unlock()
+
+	<-ctx.Done()
+	b.mu.Lock()
+	b.listeners[i] = func(Invalidation) {}
+	b.mu.Unlock()
+	return ctx.Err()
+}
@@ -0,0 +1,218 @@
+package cache
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// State tells the caller how to treat a cache lookup.
+type State int
+
+const (
+	Miss  State = iota
+	Fresh       // serve as is
+	Stale       // serve, but revalidate in the background
+)
+
+// Entry is a resolved public page: everything the renderer needs to answer
+// a request without touching the database.
+type Entry struct {
+	PageID    int64
+	DomainID  int64
+	Protected bool // password page; Body is empty
+	Body      []byte
+	ETag      string
+	NoIndex   bool
+	// CanonicalDomainID is the domain named in the page's canonical URL,
+	// when it is not DomainID.
+	CanonicalDomainID int64
+	// SurrogateKeys tag the response for CDN purges.
+	SurrogateKeys []string
+	// ExperimentID and Variants are set while the page runs an experiment;
+	// each visitor is served one variant instead of Body.
+	ExperimentID int64
+	Variants     []Variant
+	// Expires is when the entry stops being servable, such as when a link
+	// on the page expires. Zero means never.
+	Expires  time.Time
+	StoredAt time.Time
+}
+
+// Variant is one arm of a running experiment as served.
+type Variant struct {
+	Key    string
+	Weight int
+	Body   []byte
+	ETag   string
+}
+
+// RenderCache maps host+path to compiled page bytes.
+type RenderCache struct {
+	lru      *LRU[string, *Entry]
+	ttl      time.Duration
+	staleTTL time.Duration
+	now      func() time.Time
+
+	mu         sync.Mutex
+	refreshing map[string]bool
+	// generation counts invalidations. An entry resolved before one is
+	// refused by Set, so a slow resolve cannot write back a page that was
+	// republished or rerouted meanwhile.
+	generation uint64
+
+	bus Bus
+}
+
+// NewRenderCache creates a cache holding up to capacity routes. Entries are
+// fresh for ttl and may be served stale for a further staleTTL while they
+// are revalidated.
+func NewRenderCache(capacity int, ttl, staleTTL time.Duration) *RenderCache {
+	return &RenderCache{
+		lru:        NewLRU[string, *Entry](capacity),
+		ttl:        ttl,
+		staleTTL:   staleTTL,
+		now:        time.Now,
+		refreshing: make(map[string]bool),
+	}
+}
+
+func Key(host, path string) string {
+	return host + path
+}
+
+func (c *RenderCache) Get(key string) (*Entry, State) {
+	e, ok := c.lru.Get(key)
+	if !ok {
+		return nil, Miss
+	}
+
+	now := c.now()
+	if !e.Expires.IsZero() && !now.Before(e.Expires) {
+		c.lru.Remove(key)
+		return nil, Miss
+	}
+
+	age := now.Sub(e.StoredAt)
+	switch {
+	case age < c.ttl:
+		return e, Fresh
+	case age < c.ttl+c.staleTTL:
+		return e, Stale
+	default:
+		c.lru.Remove(key)
+		return nil, Miss
+	}
+}
+
+// Generation returns the stamp to pass to Set. Take it before resolving
+// the entry.
+func (c *RenderCache) Generation() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.generation
+}
+
+// Set stores e unless the cache was invalidated since gen was taken, and
+// reports whether it did.
+func (c *RenderCache) Set(key string, e *Entry, gen uint64) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if gen != c.generation {
+		return false
+	}
+	e.StoredAt = c.now()
+	c.lru.Add(key, e)
+	return true
+}
+
+func (c *RenderCache) Remove(key string) {
+	c.lru.Remove(key)
+}
+
+// Revalidate runs refresh in the background unless a refresh for key is
+// already in flight.
+func (c *RenderCache) Revalidate(key string, refresh func()) {
+	c.mu.Lock()
+	if c.refreshing[key] {
+		c.mu.Unlock()
+		return
+	}
+	c.refreshing[key] = true
+	c.mu.Unlock()
+
+	go func() {
+		defer func() {
+			c.mu.Lock()
+			delete(c.refreshing, key)
+			c.mu.Unlock()
+		}()
+		refresh()
+	}()
+}
+
+// Broadcast sends every later InvalidatePage and InvalidateDomain to the
+// other instances on bus. Call Follow to receive theirs.
+func (c *RenderCache) Broadcast(bus Bus) {
+	c.bus = bus
+}
+
+// Follow applies invalidations from other instances until ctx is done.
+// When the bus fails the whole cache is dropped, since invalidations may
+// have been missed, and it listens again after retry.
+func (c *RenderCache) Follow(ctx context.Context, retry time.Duration) {
+	for {
+		err := c.bus.Listen(ctx, c.apply)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("[RenderCache] listen: %v", err)
+		c.drop(func(*Entry) bool { return true })
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(retry):
+		}
+	}
+}
+
+// InvalidatePage drops every route that resolves to pageID, here and on
+// the other instances.
+func (c *RenderCache) InvalidatePage(pageID int64) {
+	c.invalidate(Invalidation{PageID: pageID})
+}
+
+// InvalidateDomain drops every route served from domainID or pointing its
+// canonical URL at it, here and on the other instances.
+func (c *RenderCache) InvalidateDomain(domainID int64) {
+	c.invalidate(Invalidation{DomainID: domainID})
+}
+
+func (c *RenderCache) invalidate(inv Invalidation) {
+	c.apply(inv)
+	if c.bus == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := c.bus.Publish(ctx, inv); err != nil {
+		log.Printf("[RenderCache] publish %+v: %v", inv, err)
+	}
+}
+
+func (c *RenderCache) apply(inv Invalidation) {
+	c.drop(func(e *Entry) bool {
+		return inv.PageID != 0 && e.PageID == inv.PageID ||
+			inv.DomainID != 0 && (e.DomainID == inv.DomainID || e.CanonicalDomainID == inv.DomainID)
+	})
+}
+
+func (c *RenderCache) drop(match func(*Entry) bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	c.lru.RemoveFunc(func(_ string, e *Entry) bool {
+		return match(e)
+	})
+}
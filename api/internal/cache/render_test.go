@@ -0,0 +1,232 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func newTestCache() (*RenderCache, *time.Time) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewRenderCache(16, time.Minute, time.Hour)
+	c.now = func() time.Time { return now }
+	return c, &now
+}
+
+func TestRenderCacheStates(t *testing.T) {
+	c, now := newTestCache()
+	c.Set("a", &Entry{PageID: 1}, c.Generation())
+
+	if _, state := c.Get("a"); state != Fresh {
+		t.Errorf("new entry: state %v, want Fresh", state)
+	}
+	*now = now.Add(time.Minute)
+	if _, state := c.Get("a"); state != Stale {
+		t.Errorf("after ttl: state %v, want Stale", state)
+	}
+	*now = now.Add(time.Hour)
+	if _, state := c.Get("a"); state != Miss {
+		t.Errorf("after stale ttl: state %v, want Miss", state)
+	}
+
+	c.Set("b", &Entry{PageID: 2, Expires: now.Add(time.Second)}, c.Generation())
+	*now = now.Add(time.Second)
+	if _, state := c.Get("b"); state != Miss {
+		t.Errorf("expired entry: state %v, want Miss", state)
+	}
+}
+
+func TestRenderCacheInvalidate(t *testing.T) {
+	c, _ := newTestCache()
+	gen := c.Generation()
+	c.Set("a.example/", &Entry{PageID: 1, DomainID: 10}, gen)
+	c.Set("a.example/x", &Entry{PageID: 1, DomainID: 10}, gen)
+	c.Set("b.example/", &Entry{PageID: 2, DomainID: 20, CanonicalDomainID: 10}, gen)
+	c.Set("c.example/", &Entry{PageID: 3, DomainID: 30}, gen)
+
+	c.InvalidatePage(1)
+	for key, want := range map[string]State{"a.example/": Miss, "a.example/x": Miss, "b.example/": Fresh, "c.example/": Fresh} {
+		if _, state := c.Get(key); state != want {
+			t.Errorf("after InvalidatePage: %s state %v, want %v", key, state, want)
+		}
+	}
+
+	c.InvalidateDomain(10)
+	for key, want := range map[string]State{"b.example/": Miss, "c.example/": Fresh} {
+		if _, state := c.Get(key); state != want {
+			t.Errorf("after InvalidateDomain: %s state %v, want %v", key, state, want)
+		}
+	}
+}
+
+func TestRenderCacheSetAfterInvalidate(t *testing.T) {
+	c, _ := newTestCache()
+
+	// A miss resolves the old page, then a publish invalidates it.
+	gen := c.Generation()
+	c.InvalidatePage(1)
+	if c.Set("a", &Entry{PageID: 1, ETag: "old"}, gen) {
+		t.Error("Set accepted an entry resolved before the invalidation")
+	}
+	if _, state := c.Get("a"); state != Miss {
+		t.Errorf("state %v, want Miss", state)
+	}
+
+	if !c.Set("a", &Entry{PageID: 1, ETag: "new"}, c.Generation()) {
+		t.Error("Set refused a current entry")
+	}
+}
+
+func TestRenderCacheRevalidateLosesToInvalidate(t *testing.T) {
+	c, now := newTestCache()
+	c.Set("a", &Entry{PageID: 1, ETag: "v1"}, c.Generation())
+	*now = now.Add(time.Minute)
+	if _, state := c.Get("a"); state != Stale {
+		t.Fatalf("state %v, want Stale", state)
+	}
+
+	resolving := make(chan struct{})
+	publish := make(chan struct{})
+	done := make(chan struct{})
+	c.Revalidate("a", func() {
+		defer close(done)
+		gen := c.Generation()
+		// The refresh reads v1 from the database...
+		close(resolving)
+		<-publish
+		// ...and tries to store it after v2 was published and served.
+		c.Set("a", &Entry{PageID: 1, ETag: "v1"}, gen)
+	})
+
+	<-resolving
+	c.InvalidatePage(1)
+	c.Set("a", &Entry{PageID: 1, ETag: "v2"}, c.Generation())
+	close(publish)
+	<-done
+
+	e, state := c.Get("a")
+	if state != Fresh || e.ETag != "v2" {
+		t.Errorf("got %+v (%v), want the republished page", e, state)
+	}
+}
+
+func TestRenderCacheRevalidateOnce(t *testing.T) {
+	c, _ := newTestCache()
+	release := make(chan struct{})
+	done := make(chan struct{})
+	runs := 0
+	c.Revalidate("a", func() {
+		runs++
+		<-release
+		close(done)
+	})
+	c.Revalidate("a", func() { runs++ })
+	close(release)
+	<-done
+	if runs != 1 {
+		t.Errorf("refresh ran %d times, want 1", runs)
+	}
+}
+
+// following starts c.Follow on bus and waits until it listens.
+func following(t *testing.T, c *RenderCache, bus *LocalBus) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.Follow(ctx, time.Millisecond)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	for {
+		bus.mu.Lock()
+		n := len(bus.listeners)
+		bus.mu.Unlock()
+		if n > 0 {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestRenderCacheBroadcast(t *testing.T) {
+	hub := NewLocalHub()
+	a, _ := newTestCache()
+	b, _ := newTestCache()
+	busA, busB := hub.Bus(), hub.Bus()
+	a.Broadcast(busA)
+	b.Broadcast(busB)
+	following(t, a, busA)
+	following(t, b, busB)
+
+	for _, c := range []*RenderCache{a, b} {
+		c.Set("a.example/", &Entry{PageID: 1, DomainID: 10}, c.Generation())
+		c.Set("b.example/", &Entry{PageID: 2, DomainID: 20}, c.Generation())
+	}
+
+	// A click exhausts a capped link on instance a.
+	a.InvalidatePage(1)
+	for name, c := range map[string]*RenderCache{"a": a, "b": b} {
+		if _, state := c.Get("a.example/"); state != Miss {
+			t.Errorf("%s: page 1 state %v, want Miss", name, state)
+		}
+		if _, state := c.Get("b.example/"); state != Fresh {
+			t.Errorf("%s: page 2 state %v, want Fresh", name, state)
+		}
+	}
+
+	b.InvalidateDomain(20)
+	if _, state := a.Get("b.example/"); state != Miss {
+		t.Errorf("a: domain 20 state %v, want Miss", state)
+	}
+}
+
+// failingBus fails every Listen, reporting each call on calls.
+type failingBus struct {
+	calls chan struct{}
+}
+
+func (b *failingBus) Publish(ctx context.Context, inv Invalidation) error { return nil }
+
+func (b *failingBus) Listen(ctx context.Context, fn func(Invalidation)) error {
+	b.calls <- struct{}{}
+	return errors.New("connection reset")
+}
+
+func TestRenderCacheFollowDropsAllOnFailure(t *testing.T) {
+	c, _ := newTestCache()
+	gen := c.Generation()
+	c.Set("a", &Entry{PageID: 1}, gen)
+	c.Set("b", &Entry{PageID: 2}, gen)
+
+	bus := &failingBus{calls: make(chan struct{})}
+	c.Broadcast(bus)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.Follow(ctx, time.Millisecond)
+	}()
+	<-bus.calls // first listen fails
+	<-bus.calls // and is retried after the drop
+	cancel()
+	go func() {
+		for range bus.calls {
+		}
+	}()
+	<-done
+	close(bus.calls)
+
+	for _, key := range []string{"a", "b"} {
+		if _, state := c.Get(key); state != Miss {
+			t.Errorf("%s state %v, want Miss", key, state)
+		}
+	}
+	if c.Set("a", &Entry{PageID: 1}, gen) {
+		t.Error("Set accepted an entry resolved before the failure")
+	}
+}
@@ -0,0 +1,22 @@
+package cdn
+
+import "fmt"
+
+// Surrogate keys tag cached public responses so they can be purged by the
+// page, theme or domain they were built from.
+
+func PageKey(pageID int64) string {
+	return fmt.Sprintf("page-%d", pageID)
+}
+
+func PresetKey(presetID int64) string {
+	return fmt.Sprintf("theme-preset-%d", presetID)
+}
+
+func CustomThemeKey(customID int64) string {
+	return fmt.Sprintf("theme-custom-%d", customID)
+}
+
+func DomainKey(domainID int64) string {
+	return fmt.Sprintf("domain-%d", domainID)
+}
@@ -0,0 +1,91 @@
+package cdn
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Purger invalidates CDN objects tagged with any of the given surrogate keys.
+type Purger interface {
+	Purge(ctx context.Context, keys []string) error
+}
+
+// NoopPurger is used when no CDN is configured.
+type NoopPurger struct{}
+
+func (NoopPurger) Purge(ctx context.Context, keys []string) error {
+	return nil
+}
+
+// HTTPPurger posts keys to a purge endpoint. The keys are sent both as a
+// space separated Surrogate-Key header and as a JSON body {"tags": [...]},
+// which covers the common CDN purge APIs.
+type HTTPPurger struct {
+	endpoint string
+	token    string
+	client   *http.Client
+}
+
+func NewHTTPPurger(endpoint, token string) *HTTPPurger {
+	return &HTTPPurger{
+		endpoint: endpoint,
+		token:    token,
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (p *HTTPPurger) Purge(ctx context.Context, keys []string) error {
+	body, err := json.Marshal(map[string][]string{"tags": keys})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Surrogate-Key", strings.Join(keys, " "))
+	if p.token != "" {
+		req.Header.Set("Authorization", "Bearer "+p.token)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("cdn purge: unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// RecordingPurger remembers every purge. Set Err to simulate failures.
+type RecordingPurger struct {
+	mu    sync.Mutex
+	calls [][]string
+	Err   error
+}
+
+func (p *RecordingPurger) Purge(ctx context.Context, keys []string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.calls = append(p.calls, append([]string(nil), keys...))
+	return p.Err
+}
+
+// Calls returns the keys of every purge in order.
+func (p *RecordingPurger) Calls() [][]string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([][]string(nil), p.calls...)
+}
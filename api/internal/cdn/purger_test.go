@@ -0,0 +1,121 @@
+package cdn
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestHTTPPurger(t *testing.T) {
+	var method, auth, surrogate string
+	var body struct {
+		Tags []string `json:"tags"`
+	}
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method = r.Method
+		auth = r.Header.Get("Authorization")
+		surrogate = r.Header.Get("Surrogate-Key")
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode body: %v", err)
+		}
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	keys := []string{PageKey(1), DomainKey(2)}
+	if err := NewHTTPPurger(srv.URL, "secret").Purge(context.Background(), keys); err != nil {
+		t.Fatalf("Purge: %v", err)
+	}
+	if method != http.MethodPost {
+		t.Errorf("method = %s, want POST", method)
+	}
+	if auth != "Bearer secret" {
+		t.Errorf("Authorization = %q, want the bearer token", auth)
+	}
+	if surrogate != keys[0]+" "+keys[1] {
+		t.Errorf("Surrogate-Key = %q, want %v", surrogate, keys)
+	}
+	if !reflect.DeepEqual(body.Tags, keys) {
+		t.Errorf("tags = %v, want %v", body.Tags, keys)
+	}
+
+	if err := NewHTTPPurger(srv.URL, "").Purge(context.Background(), keys); err != nil {
+		t.Fatalf("Purge without token: %v", err)
+	}
+	if auth != "" {
+		t.Errorf("Authorization = %q without a token", auth)
+	}
+
+	status = http.StatusForbidden
+	if err := NewHTTPPurger(srv.URL, "secret").Purge(context.Background(), keys); err == nil {
+		t.Error("Purge succeeded on a 403")
+	}
+}
+
+// flakyPurger fails its first failures calls and reports every call on
+// calls.
+type flakyPurger struct {
+	failures int
+	calls    chan []string
+}
+
+func (p *flakyPurger) Purge(ctx context.Context, keys []string) error {
+	p.calls <- keys
+	if p.failures > 0 {
+		p.failures--
+		return errors.New("cdn unavailable")
+	}
+	return nil
+}
+
+// countCalls counts calls until none arrive for a while.
+func countCalls(calls chan []string) int {
+	n := 0
+	for {
+		select {
+		case <-calls:
+			n++
+		case <-time.After(50 * time.Millisecond):
+			return n
+		}
+	}
+}
+
+func TestRetryingPurger(t *testing.T) {
+	cases := []struct {
+		name     string
+		failures int
+		want     int
+	}{
+		{"succeeds", 0, 1},
+		{"recovers", 2, 3},
+		{"gives up", 10, 4},
+	}
+	for _, c := range cases {
+		inner := &flakyPurger{failures: c.failures, calls: make(chan []string, 16)}
+		p := NewRetryingPurger(inner, 4, time.Millisecond)
+		if err := p.Purge(context.Background(), []string{"page:1"}); err != nil {
+			t.Fatalf("%s: Purge: %v", c.name, err)
+		}
+		if got := countCalls(inner.calls); got != c.want {
+			t.Errorf("%s: %d attempts, want %d", c.name, got, c.want)
+		}
+	}
+}
+
+func TestRetryingPurgerSkipsEmpty(t *testing.T) {
+	inner := &flakyPurger{calls: make(chan []string, 1)}
+	p := NewRetryingPurger(inner, 4, time.Millisecond)
+	if err := p.Purge(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := countCalls(inner.calls); got != 0 {
+		t.Errorf("%d purges of no keys", got)
+	}
+}
@@ -0,0 +1,75 @@
+package cdn
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+type purgeJob struct {
+	keys    []string
+	attempt int
+}
+
+// RetryingPurger sends purges from a background worker and retries failed
+// ones with exponential backoff, so callers never wait on the CDN.
+type RetryingPurger struct {
+	next        Purger
+	jobs        chan purgeJob
+	maxAttempts int
+	baseDelay   time.Duration
+	timeout     time.Duration
+}
+
+func NewRetryingPurger(next Purger, maxAttempts int, baseDelay time.Duration) *RetryingPurger {
+	p := &RetryingPurger{
+		next:        next,
+		jobs:        make(chan purgeJob, 1024),
+		maxAttempts: maxAttempts,
+		baseDelay:   baseDelay,
+		timeout:     10 * time.Second,
+	}
+	go p.run()
+	return p
+}
+
+// Purge queues keys and returns immediately. If the queue is full the
+// purge is dropped; cached objects then expire through s-maxage.
+func (p *RetryingPurger) Purge(ctx context.Context, keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	select {
+	case p.jobs <- purgeJob{keys: keys}:
+	default:
+		log.Printf("[CDN] purge queue full, dropping %v", keys)
+	}
+	return nil
+}
+
+func (p *RetryingPurger) run() {
+	for job := range p.jobs {
+		ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+		err := p.next.Purge(ctx, job.keys)
+		cancel()
+		if err == nil {
+			continue
+		}
+
+		job.attempt++
+		if job.attempt >= p.maxAttempts {
+			log.Printf("[CDN] giving up purge of %v after %d attempts: %v", job.keys, job.attempt, err)
+			continue
+		}
+
+		delay := p.baseDelay << (job.attempt - 1)
+		log.Printf("[CDN] purge of %v failed, retrying in %s: %v", job.keys, delay, err)
+		time.AfterFunc(delay, func() {
+			select {
+			case p.jobs <- job:
+			default:
+				log.Printf("[CDN] purge queue full, dropping retry of %v", job.keys)
+			}
+		})
+	}
+}
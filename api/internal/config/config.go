@@ -1,12 +1,51 @@
 package config
 
-import "os"
+import (
+	"crypto/sha256"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/hkdf"
+)
 
 type Config struct {
 	DatabaseURL string
 	JWTSecret   string
 	CORSOrigins string
 	Port        string
+	// RateLimitStore is "memory" for a single instance or "postgres" when
+	// several instances share counters.
+	RateLimitStore string
+	// ProxyHeader names the header carrying the client IP when running
+	// behind a trusted proxy, e.g. X-Forwarded-For.
+	ProxyHeader string
+	// TrustedProxies are the IPs or CIDRs whose ProxyHeader and
+	// X-Forwarded-Host are believed, such as the web app's servers. Empty
+	// trusts every client's ProxyHeader and no X-Forwarded-Host.
+	TrustedProxies []string
+	// CDNPurgeURL is the purge endpoint of the CDN; empty disables purges.
+	CDNPurgeURL   string
+	CDNPurgeToken string
+	// AutoMigrate applies pending migrations on startup instead of
+	// refusing to start.
+	AutoMigrate bool
+	// AppURL is the web app's origin, used to build links such as draft
+	// previews.
+	AppURL string
+	// OGFontRegular and OGFontBold are TrueType or OpenType files to draw
+	// share images with; empty uses the bundled DejaVu Sans.
+	OGFontRegular string
+	OGFontBold    string
+	// URLBlocklist is a file of domains links may not point to, one per
+	// line; it is reread when it changes. Empty blocks nothing.
+	URLBlocklist string
+	// GeoIPDB is a MaxMind country or city database used to target links
+	// by country. Empty leaves the country unknown.
+	GeoIPDB string
+	// PreviewSecret signs draft preview links; empty derives a key from
+	// JWTSecret, see PreviewKey.
+	PreviewSecret string
 }
 
 func Load() *Config {
@@ -15,7 +54,45 @@ func Load() *Config {
 		JWTSecret:   getEnv("JWT_SECRET", "dev-secret-change-in-production"),
 		CORSOrigins: getEnv("CORS_ORIGINS", "http://localhost:5173"),
 		Port:        getEnv("PORT", "8080"),
+
+		RateLimitStore: getEnv("RATE_LIMIT_STORE", "memory"),
+		ProxyHeader:    getEnv("PROXY_HEADER", ""),
+		TrustedProxies: splitList(getEnv("TRUSTED_PROXIES", "")),
+		CDNPurgeURL:    getEnv("CDN_PURGE_URL", ""),
+		CDNPurgeToken:  getEnv("CDN_PURGE_TOKEN", ""),
+		AutoMigrate:    getEnv("AUTO_MIGRATE", "false") == "true",
+		AppURL:         getEnv("APP_URL", "http://localhost:5173"),
+		OGFontRegular:  getEnv("OG_FONT_REGULAR", ""),
+		OGFontBold:     getEnv("OG_FONT_BOLD", ""),
+		URLBlocklist:   getEnv("URL_BLOCKLIST", ""),
+		GeoIPDB:        getEnv("GEOIP_DB", ""),
+		PreviewSecret:  getEnv("PREVIEW_SECRET", ""),
+	}
+}
+
+// PreviewKey is the key preview links are signed with: PreviewSecret, or
+// an HKDF subkey of JWTSecret, so a preview token never verifies as a
+// session token or the other way round.
+func (c *Config) PreviewKey() []byte {
+	if c.PreviewSecret != "" {
+		return []byte(c.PreviewSecret)
+	}
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, []byte(c.JWTSecret), nil, []byte("linkbio preview links")), key); err != nil {
+		panic(err) // only when asking for more than 255 hashes of output
+	}
+	return key
+}
+
+// splitList splits a comma-separated setting, dropping empty entries.
+func splitList(v string) []string {
+	var out []string
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
 	}
+	return out
 }
 
 func getEnv(key, fallback string) string {
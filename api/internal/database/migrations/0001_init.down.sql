@@ -0,0 +1,16 @@
+DROP TABLE IF EXISTS page_access_sessions;
+DROP TABLE IF EXISTS page_publish_cache;
+DROP TABLE IF EXISTS blocks;
+DROP TABLE IF EXISTS links;
+DROP TABLE IF EXISTS link_groups;
+DROP TABLE IF EXISTS page_routes;
+DROP TABLE IF EXISTS domains;
+DROP TABLE IF EXISTS bio_pages;
+ALTER TABLE IF EXISTS users DROP CONSTRAINT IF EXISTS fk_users_avatar_asset;
+DROP TABLE IF EXISTS assets;
+DROP TABLE IF EXISTS themes_custom;
+DROP TABLE IF EXISTS theme_presets;
+DROP TABLE IF EXISTS subscriptions;
+DROP TABLE IF EXISTS plans;
+DROP TABLE IF EXISTS oauth_accounts;
+DROP TABLE IF EXISTS users;
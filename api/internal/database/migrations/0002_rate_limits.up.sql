@@ -0,0 +1,14 @@
+-- Rate limit counters and lockouts shared across API instances.
+
+CREATE TABLE IF NOT EXISTS rate_limit_buckets (
+  key TEXT PRIMARY KEY,         -- '<rule>:<ip|account>'
+  count INT NOT NULL,
+  reset_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_rate_limit_buckets_reset ON rate_limit_buckets(reset_at);
+
+CREATE TABLE IF NOT EXISTS rate_limit_locks (
+  key TEXT PRIMARY KEY,
+  locked_until TIMESTAMPTZ NOT NULL
+);
@@ -0,0 +1 @@
+ALTER TABLE page_publish_cache DROP COLUMN IF EXISTS hash;
@@ -0,0 +1,2 @@
+-- sha256 of compiled_json, served as the public ETag.
+ALTER TABLE page_publish_cache ADD COLUMN IF NOT EXISTS hash TEXT NOT NULL DEFAULT '';
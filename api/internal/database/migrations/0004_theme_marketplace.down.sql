@@ -0,0 +1,16 @@
+DROP TRIGGER IF EXISTS trg_theme_presets_immutable ON theme_presets;
+DROP FUNCTION IF EXISTS theme_presets_immutable();
+
+DROP INDEX IF EXISTS idx_pages_theme_preset;
+DROP INDEX IF EXISTS idx_theme_presets_author;
+DROP INDEX IF EXISTS idx_theme_presets_status;
+
+ALTER TABLE theme_presets
+  DROP CONSTRAINT IF EXISTS chk_theme_status,
+  DROP COLUMN IF EXISTS published_at,
+  DROP COLUMN IF EXISTS submitted_at,
+  DROP COLUMN IF EXISTS reviewed_by,
+  DROP COLUMN IF EXISTS review_note,
+  DROP COLUMN IF EXISTS status;
+
+ALTER TABLE users DROP COLUMN IF EXISTS is_admin;
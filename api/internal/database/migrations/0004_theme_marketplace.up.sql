@@ -0,0 +1,41 @@
+-- User-authored presets: review workflow, moderators and usage counts.
+
+ALTER TABLE users ADD COLUMN is_admin BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Existing presets are official and already published.
+ALTER TABLE theme_presets
+  ADD COLUMN status TEXT NOT NULL DEFAULT 'approved',  -- draft|submitted|approved|rejected
+  ADD COLUMN review_note TEXT NULL,
+  ADD COLUMN reviewed_by BIGINT NULL REFERENCES users(id) ON DELETE SET NULL,
+  ADD COLUMN submitted_at TIMESTAMPTZ NULL,
+  ADD COLUMN published_at TIMESTAMPTZ NULL,
+  ADD CONSTRAINT chk_theme_status CHECK (status IN ('draft','submitted','approved','rejected'));
+
+UPDATE theme_presets SET published_at = created_at WHERE status = 'approved';
+
+-- New presets start as drafts.
+ALTER TABLE theme_presets ALTER COLUMN status SET DEFAULT 'draft';
+
+CREATE INDEX idx_theme_presets_status ON theme_presets(status, visibility);
+CREATE INDEX idx_theme_presets_author ON theme_presets(author_user_id);
+CREATE INDEX idx_pages_theme_preset ON bio_pages(theme_preset_id);
+
+-- Published presets are immutable: only review bookkeeping may change.
+CREATE FUNCTION theme_presets_immutable() RETURNS trigger AS $$
+BEGIN
+  IF OLD.status = 'approved' AND (
+       NEW.config IS DISTINCT FROM OLD.config OR
+       NEW.name IS DISTINCT FROM OLD.name OR
+       NEW.key IS DISTINCT FROM OLD.key OR
+       NEW.tier IS DISTINCT FROM OLD.tier OR
+       NEW.status IS DISTINCT FROM OLD.status) THEN
+    RAISE EXCEPTION 'theme preset % is published and cannot be modified', OLD.id
+      USING ERRCODE = 'check_violation';
+  END IF;
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_theme_presets_immutable
+  BEFORE UPDATE ON theme_presets
+  FOR EACH ROW EXECUTE FUNCTION theme_presets_immutable();
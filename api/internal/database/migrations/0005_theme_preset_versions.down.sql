@@ -0,0 +1,24 @@
+CREATE OR REPLACE FUNCTION theme_presets_immutable() RETURNS trigger AS $$
+BEGIN
+  IF OLD.status = 'approved' AND (
+       NEW.config IS DISTINCT FROM OLD.config OR
+       NEW.name IS DISTINCT FROM OLD.name OR
+       NEW.key IS DISTINCT FROM OLD.key OR
+       NEW.tier IS DISTINCT FROM OLD.tier OR
+       NEW.status IS DISTINCT FROM OLD.status) THEN
+    RAISE EXCEPTION 'theme preset % is published and cannot be modified', OLD.id
+      USING ERRCODE = 'check_violation';
+  END IF;
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+ALTER TABLE theme_presets ALTER COLUMN status SET DEFAULT 'draft';
+DROP TRIGGER IF EXISTS trg_theme_presets_publish_version ON theme_presets;
+DROP FUNCTION IF EXISTS theme_presets_publish_version();
+
+ALTER TABLE themes_custom DROP COLUMN IF EXISTS preset_version_id;
+ALTER TABLE theme_presets DROP COLUMN IF EXISTS current_version_id;
+
+DROP TABLE IF EXISTS theme_preset_versions;
+DROP FUNCTION IF EXISTS theme_preset_versions_immutable();
@@ -0,0 +1,96 @@
+-- Immutable preset versions; custom themes pin the version they patch.
+
+CREATE TABLE theme_preset_versions (
+  id BIGSERIAL PRIMARY KEY,
+  preset_id BIGINT NOT NULL REFERENCES theme_presets(id) ON DELETE CASCADE,
+
+  version TEXT NOT NULL,                 -- semver, from config.meta.version
+  schema_version INT NOT NULL DEFAULT 1, -- config.meta.schemaVersion
+  config JSONB NOT NULL,
+
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+  CONSTRAINT uq_preset_version UNIQUE (preset_id, version)
+);
+
+CREATE FUNCTION theme_preset_versions_immutable() RETURNS trigger AS $$
+BEGIN
+  RAISE EXCEPTION 'theme preset version % cannot be modified', OLD.id
+    USING ERRCODE = 'check_violation';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_theme_preset_versions_immutable
+  BEFORE UPDATE ON theme_preset_versions
+  FOR EACH ROW EXECUTE FUNCTION theme_preset_versions_immutable();
+
+-- Every published preset gets its current config as its first version.
+INSERT INTO theme_preset_versions (preset_id, version, schema_version, config, created_at)
+SELECT id,
+       COALESCE(config->'meta'->>'version', '1.0.0'),
+       COALESCE((config->'meta'->>'schemaVersion')::INT, 1),
+       config,
+       COALESCE(published_at, created_at)
+FROM theme_presets
+WHERE status = 'approved';
+
+ALTER TABLE theme_presets
+  ADD COLUMN current_version_id BIGINT NULL REFERENCES theme_preset_versions(id);
+
+UPDATE theme_presets tp SET current_version_id = v.id
+FROM theme_preset_versions v
+WHERE v.preset_id = tp.id;
+
+ALTER TABLE themes_custom
+  ADD COLUMN preset_version_id BIGINT NULL REFERENCES theme_preset_versions(id);
+
+UPDATE themes_custom tc SET preset_version_id = tp.current_version_id
+FROM theme_presets tp
+WHERE tp.id = tc.based_on_preset_id;
+
+-- Publishing (inserting an approved preset, or approving one) records the
+-- config as the preset's first version.
+CREATE FUNCTION theme_presets_publish_version() RETURNS trigger AS $$
+DECLARE
+  vid BIGINT;
+BEGIN
+  IF NEW.status = 'approved' AND NEW.current_version_id IS NULL THEN
+    INSERT INTO theme_preset_versions (preset_id, version, schema_version, config)
+    VALUES (NEW.id,
+            COALESCE(NEW.config->'meta'->>'version', '1.0.0'),
+            COALESCE((NEW.config->'meta'->>'schemaVersion')::INT, 1),
+            NEW.config)
+    RETURNING id INTO vid;
+    UPDATE theme_presets SET current_version_id = vid WHERE id = NEW.id;
+  END IF;
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_theme_presets_publish_version
+  AFTER INSERT OR UPDATE OF status ON theme_presets
+  FOR EACH ROW EXECUTE FUNCTION theme_presets_publish_version();
+
+-- Official presets seeded with plain INSERTs are published; the API
+-- creates user presets as explicit drafts.
+ALTER TABLE theme_presets ALTER COLUMN status SET DEFAULT 'approved';
+
+CREATE INDEX idx_preset_versions_preset ON theme_preset_versions(preset_id, created_at);
+
+-- A published preset's config now only changes together with its current
+-- version, i.e. when a new version is published.
+CREATE OR REPLACE FUNCTION theme_presets_immutable() RETURNS trigger AS $$
+BEGIN
+  IF OLD.status = 'approved' AND (
+       (NEW.config IS DISTINCT FROM OLD.config AND
+        NEW.current_version_id IS NOT DISTINCT FROM OLD.current_version_id) OR
+       NEW.name IS DISTINCT FROM OLD.name OR
+       NEW.key IS DISTINCT FROM OLD.key OR
+       NEW.tier IS DISTINCT FROM OLD.tier OR
+       NEW.status IS DISTINCT FROM OLD.status) THEN
+    RAISE EXCEPTION 'theme preset % is published and cannot be modified', OLD.id
+      USING ERRCODE = 'check_violation';
+  END IF;
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
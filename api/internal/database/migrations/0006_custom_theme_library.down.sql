@@ -0,0 +1,4 @@
+-- Fails while a user still has two themes with the same content.
+DROP INDEX IF EXISTS idx_custom_themes_user_hash;
+
+ALTER TABLE themes_custom ADD CONSTRAINT uq_custom_theme_user_hash UNIQUE (user_id, hash);
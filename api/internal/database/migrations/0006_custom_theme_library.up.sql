@@ -0,0 +1,10 @@
+-- Custom themes become a named library. Copies with the same content are
+-- allowed; the hash is only used to find an existing theme to reuse.
+
+ALTER TABLE themes_custom DROP CONSTRAINT uq_custom_theme_user_hash;
+
+CREATE INDEX idx_custom_themes_user_hash ON themes_custom(user_id, hash);
+
+UPDATE themes_custom tc SET name = tp.name
+FROM theme_presets tp
+WHERE tc.name IS NULL AND tp.id = tc.based_on_preset_id;
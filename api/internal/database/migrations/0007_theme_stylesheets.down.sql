@@ -0,0 +1,5 @@
+UPDATE bio_pages SET theme_mode = 'light' WHERE theme_mode = 'auto';
+ALTER TABLE bio_pages DROP CONSTRAINT chk_theme_mode;
+ALTER TABLE bio_pages ADD CONSTRAINT chk_theme_mode CHECK (theme_mode IN ('light','dark','compact'));
+
+DROP TABLE IF EXISTS theme_stylesheets;
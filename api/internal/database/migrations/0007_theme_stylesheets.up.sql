@@ -0,0 +1,12 @@
+-- Compiled theme stylesheets, addressed by the hash of their content so
+-- their URLs can be cached forever.
+
+CREATE TABLE theme_stylesheets (
+  hash TEXT PRIMARY KEY,
+  css TEXT NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- 'auto' follows the visitor's prefers-color-scheme.
+ALTER TABLE bio_pages DROP CONSTRAINT chk_theme_mode;
+ALTER TABLE bio_pages ADD CONSTRAINT chk_theme_mode CHECK (theme_mode IN ('light','dark','compact','auto'));
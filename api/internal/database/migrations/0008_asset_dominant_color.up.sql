@@ -0,0 +1,3 @@
+-- Average color of an image asset, filled in on upload, so themes can be
+-- checked for readability against their wallpaper.
+ALTER TABLE assets ADD COLUMN dominant_color TEXT NULL;
@@ -0,0 +1,27 @@
+-- Scheduled publish/unpublish. Workers claim due rows with FOR UPDATE SKIP
+-- LOCKED and hold them with a lease, so each schedule runs on one instance
+-- at a time; a crashed worker's lease runs out and the row is retried.
+
+CREATE TABLE page_schedules (
+  id BIGSERIAL PRIMARY KEY,
+  page_id BIGINT NOT NULL REFERENCES bio_pages(id) ON DELETE CASCADE,
+  user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+
+  action TEXT NOT NULL,                    -- publish|unpublish
+  run_at TIMESTAMPTZ NOT NULL,
+  status TEXT NOT NULL DEFAULT 'pending',  -- pending|done|failed|canceled
+
+  locked_until TIMESTAMPTZ NULL,           -- lease of the worker running it
+  attempts INT NOT NULL DEFAULT 0,
+  last_error TEXT NULL,
+  executed_at TIMESTAMPTZ NULL,
+
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+  CONSTRAINT chk_schedule_action CHECK (action IN ('publish','unpublish')),
+  CONSTRAINT chk_schedule_status CHECK (status IN ('pending','done','failed','canceled'))
+);
+
+CREATE INDEX idx_page_schedules_due ON page_schedules(run_at) WHERE status = 'pending';
+CREATE INDEX idx_page_schedules_page ON page_schedules(page_id, run_at);
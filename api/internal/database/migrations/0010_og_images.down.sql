@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS asset_blobs;
+DROP INDEX IF EXISTS uq_assets_generated_key;
+
+DELETE FROM assets WHERE scope = 'generated';
+ALTER TABLE assets DROP CONSTRAINT chk_asset_scope;
+ALTER TABLE assets ADD CONSTRAINT chk_asset_scope CHECK (scope IN ('system_preset','user_upload'));
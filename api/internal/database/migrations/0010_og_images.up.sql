@@ -0,0 +1,14 @@
+-- Generated share images. Their bytes live in asset_blobs under the asset's
+-- storage key, which is the hash of everything drawn, so an unchanged page
+-- reuses its image across publishes.
+
+ALTER TABLE assets DROP CONSTRAINT chk_asset_scope;
+ALTER TABLE assets ADD CONSTRAINT chk_asset_scope CHECK (scope IN ('system_preset','user_upload','generated'));
+
+CREATE UNIQUE INDEX uq_assets_generated_key ON assets(storage_key) WHERE scope = 'generated';
+
+CREATE TABLE asset_blobs (
+  key TEXT PRIMARY KEY,
+  data BYTEA NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS link_clicks;
+DROP TABLE IF EXISTS page_views;
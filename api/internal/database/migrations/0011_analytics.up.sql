@@ -0,0 +1,21 @@
+-- Page views and link clicks. source names where the visitor came from when
+-- the URL said so, e.g. ?src=qr on printed codes.
+
+CREATE TABLE page_views (
+  id BIGSERIAL PRIMARY KEY,
+  page_id BIGINT NOT NULL REFERENCES bio_pages(id) ON DELETE CASCADE,
+  source TEXT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_page_views_page ON page_views(page_id, created_at);
+
+CREATE TABLE link_clicks (
+  id BIGSERIAL PRIMARY KEY,
+  page_id BIGINT NOT NULL REFERENCES bio_pages(id) ON DELETE CASCADE,
+  link_id BIGINT NULL REFERENCES links(id) ON DELETE SET NULL, -- kept in page totals after the link goes
+  source TEXT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_link_clicks_page ON link_clicks(page_id, created_at);
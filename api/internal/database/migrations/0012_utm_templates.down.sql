@@ -0,0 +1,2 @@
+ALTER TABLE links DROP COLUMN IF EXISTS utm_template;
+ALTER TABLE link_groups DROP COLUMN IF EXISTS utm_template;
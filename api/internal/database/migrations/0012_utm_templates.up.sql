@@ -0,0 +1,7 @@
+-- UTM templates: query strings such as utm_source=linkbio&utm_campaign={page}
+-- merged into outbound link URLs at compile time. The page's template lives
+-- in bio_pages.settings; groups and links override it parameter by
+-- parameter.
+
+ALTER TABLE link_groups ADD COLUMN utm_template TEXT NULL;
+ALTER TABLE links ADD COLUMN utm_template TEXT NULL;
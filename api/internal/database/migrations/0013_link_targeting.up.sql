@@ -0,0 +1,5 @@
+-- Targeting rules: an ordered list of {country, os, device, language, url}
+-- checked on each click; the first match wins and links.url is the
+-- fallback.
+
+ALTER TABLE links ADD COLUMN rules JSONB NULL;
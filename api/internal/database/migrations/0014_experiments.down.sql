@@ -0,0 +1,5 @@
+ALTER TABLE link_clicks DROP COLUMN IF EXISTS variant;
+ALTER TABLE link_clicks DROP COLUMN IF EXISTS experiment_id;
+ALTER TABLE page_views DROP COLUMN IF EXISTS variant;
+ALTER TABLE page_views DROP COLUMN IF EXISTS experiment_id;
+DROP TABLE IF EXISTS experiments;
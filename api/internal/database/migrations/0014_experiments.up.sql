@@ -0,0 +1,32 @@
+-- A/B experiments on a page's link titles and block order. variants is the
+-- ordered list of arms, each with a traffic weight and the titles and block
+-- order it shows; the first arm is the baseline results compare against.
+-- Views and clicks are tagged with the arm the visitor saw.
+
+CREATE TABLE experiments (
+  id BIGSERIAL PRIMARY KEY,
+  page_id BIGINT NOT NULL REFERENCES bio_pages(id) ON DELETE CASCADE,
+  name TEXT NOT NULL,
+  status TEXT NOT NULL DEFAULT 'running',  -- running|ended
+  variants JSONB NOT NULL,
+  winner TEXT NULL,                        -- key of the promoted variant
+  started_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  ended_at TIMESTAMPTZ NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+  CONSTRAINT chk_experiment_status CHECK (status IN ('running','ended'))
+);
+
+CREATE INDEX idx_experiments_page ON experiments(page_id, id);
+CREATE UNIQUE INDEX uq_experiments_running ON experiments(page_id) WHERE status = 'running';
+
+ALTER TABLE page_views
+  ADD COLUMN experiment_id BIGINT NULL REFERENCES experiments(id) ON DELETE SET NULL,
+  ADD COLUMN variant TEXT NULL;
+ALTER TABLE link_clicks
+  ADD COLUMN experiment_id BIGINT NULL REFERENCES experiments(id) ON DELETE SET NULL,
+  ADD COLUMN variant TEXT NULL;
+
+CREATE INDEX idx_page_views_experiment ON page_views(experiment_id, variant) WHERE experiment_id IS NOT NULL;
+CREATE INDEX idx_link_clicks_experiment ON link_clicks(experiment_id, variant) WHERE experiment_id IS NOT NULL;
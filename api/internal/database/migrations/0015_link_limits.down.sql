@@ -0,0 +1,5 @@
+ALTER TABLE links DROP COLUMN IF EXISTS fallback_url;
+ALTER TABLE links DROP COLUMN IF EXISTS fallback;
+ALTER TABLE links DROP COLUMN IF EXISTS click_count;
+ALTER TABLE links DROP COLUMN IF EXISTS max_clicks;
+ALTER TABLE links DROP COLUMN IF EXISTS expires_at;
@@ -0,0 +1,14 @@
+-- Limited links stop working after expires_at or once max_clicks clicks
+-- have been counted in click_count. An exhausted link is then hidden, or
+-- with fallback 'redirect' keeps sending clicks to fallback_url.
+
+ALTER TABLE links
+  ADD COLUMN expires_at TIMESTAMPTZ NULL,
+  ADD COLUMN max_clicks INT NULL,
+  ADD COLUMN click_count INT NOT NULL DEFAULT 0,
+  ADD COLUMN fallback TEXT NOT NULL DEFAULT 'hide',  -- hide|redirect
+  ADD COLUMN fallback_url TEXT NULL,
+  ADD CONSTRAINT chk_link_max_clicks CHECK (max_clicks IS NULL OR max_clicks > 0),
+  ADD CONSTRAINT chk_link_fallback CHECK (
+    fallback = 'hide' OR (fallback = 'redirect' AND fallback_url IS NOT NULL)
+  );
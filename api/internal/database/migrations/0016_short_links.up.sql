@@ -0,0 +1,16 @@
+-- Short codes such as /s/abc123 that redirect to one link through the
+-- click path. Codes are lowercase and unique across all domains; custom
+-- aliases share the namespace with generated codes.
+
+CREATE TABLE short_links (
+  id BIGSERIAL PRIMARY KEY,
+  link_id BIGINT NOT NULL REFERENCES links(id) ON DELETE CASCADE,
+  code TEXT NOT NULL,
+  is_custom BOOLEAN NOT NULL DEFAULT FALSE,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+  CONSTRAINT uq_short_links_code UNIQUE (code),
+  CONSTRAINT chk_short_link_code CHECK (code = lower(code))
+);
+
+CREATE INDEX idx_short_links_link ON short_links(link_id, id);
@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"linkbio/internal/middleware"
+	"linkbio/internal/service"
+	"linkbio/internal/targeting"
+	"linkbio/internal/util"
+)
+
+type AnalyticsHandler struct {
+	analyticsService *service.AnalyticsService
+}
+
+func NewAnalyticsHandler(analyticsService *service.AnalyticsService) *AnalyticsHandler {
+	return &AnalyticsHandler{analyticsService: analyticsService}
+}
+
+// Summary counts the page's views and clicks over the last ?days=.
+func (h *AnalyticsHandler) Summary(c *fiber.Ctx) error {
+	userID := middleware.GetUserID(c)
+	pageID, err := parseID(c, "id")
+	if err != nil {
+		return errInvalidID
+	}
+
+	summary, err := h.analyticsService.Summary(c.Context(), userID, pageID, c.QueryInt("days"))
+	if err != nil {
+		return err
+	}
+
+	return util.OK(c, summary)
+}
+
+type RecordViewRequest struct {
+	PageID  int64  `json:"page_id"`
+	Source  string `json:"src"`     // the ?src= the page was opened with
+	Variant string `json:"variant"` // the experiment variant it was served as
+}
+
+// View is the beacon public pages send when shown. Rendered pages are
+// cached, so views cannot be counted where they are served.
+func (h *AnalyticsHandler) View(c *fiber.Ctx) error {
+	var req RecordViewRequest
+	if err := c.BodyParser(&req); err != nil {
+		return errInvalidBody
+	}
+	if req.PageID == 0 {
+		return required("page_id")
+	}
+
+	if err := h.analyticsService.RecordView(c.Context(), req.PageID, req.Source, req.Variant); err != nil {
+		return err
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// Click sends the visitor on to a link's destination and counts the click.
+// Pages under an experiment add their variant as ?v=.
+func (h *AnalyticsHandler) Click(c *fiber.Ctx) error {
+	linkID, err := parseID(c, "id")
+	if err != nil {
+		return service.ErrNotFound
+	}
+
+	return clickThrough(c, h.analyticsService, linkID, c.Query("src"), c.Query("v"))
+}
+
+// clickThrough counts a click on the link and redirects to where it leads.
+func clickThrough(c *fiber.Ctx, analyticsService *service.AnalyticsService, linkID int64, source, variant string) error {
+	url, err := analyticsService.Click(c.Context(), linkID, source, variant, targeting.Request{
+		IP:             c.IP(),
+		UserAgent:      c.Get(fiber.HeaderUserAgent),
+		AcceptLanguage: c.Get(fiber.HeaderAcceptLanguage),
+	})
+	if err != nil {
+		return err
+	}
+
+	// Every click must reach us to be counted.
+	c.Set("Cache-Control", "private, no-store")
+	c.Set("X-Robots-Tag", "noindex")
+	return c.Redirect(url, fiber.StatusFound)
+}
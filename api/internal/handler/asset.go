@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"io"
+
+	"github.com/gofiber/fiber/v2"
+	"linkbio/internal/middleware"
+	"linkbio/internal/service"
+	"linkbio/internal/util"
+)
+
+type AssetHandler struct {
+	assetService *service.AssetService
+}
+
+func NewAssetHandler(assetService *service.AssetService) *AssetHandler {
+	return &AssetHandler{assetService: assetService}
+}
+
+// Upload stores the multipart "file" image for the user.
+func (h *AssetHandler) Upload(c *fiber.Ctx) error {
+	userID := middleware.GetUserID(c)
+	header, err := c.FormFile("file")
+	if err != nil {
+		return required("file")
+	}
+	if header.Size > service.MaxUpload {
+		return service.ErrAssetTooLarge
+	}
+	file, err := header.Open()
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, service.MaxUpload+1))
+	if err != nil {
+		return err
+	}
+
+	asset, err := h.assetService.UploadImage(c.Context(), userID, data)
+	if err != nil {
+		return err
+	}
+	return util.Created(c, asset)
+}
+
+// Serve sends an uploaded image. The URL names the hash of its bytes, so
+// it may be cached forever.
+func (h *AssetHandler) Serve(c *fiber.Ctx) error {
+	key := c.Params("key")
+	if !stylesheetHashRegex.MatchString(key) {
+		return service.ErrNotFound
+	}
+
+	etag := `"` + key + `"`
+	c.Set("Cache-Control", "public, max-age=31536000, immutable")
+	c.Set("ETag", etag)
+	if etagMatches(c.Get("If-None-Match"), etag) {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+
+	asset, data, err := h.assetService.GetUpload(c.Context(), key)
+	if err != nil {
+		return err
+	}
+	if asset.MimeType != nil {
+		c.Set("Content-Type", *asset.MimeType)
+	}
+	c.Set("X-Content-Type-Options", "nosniff")
+	return c.Send(data)
+}
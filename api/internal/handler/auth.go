@@ -1,20 +1,26 @@
 package handler
 
 import (
+	"errors"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"linkbio/internal/apperr"
 	"linkbio/internal/middleware"
+	"linkbio/internal/ratelimit"
 	"linkbio/internal/service"
 	"linkbio/internal/util"
 )
 
 type AuthHandler struct {
 	authService *service.AuthService
+	limiter     *ratelimit.Limiter
+	lockout     *ratelimit.Lockout
 }
 
-func NewAuthHandler(authService *service.AuthService) *AuthHandler {
-	return &AuthHandler{authService: authService}
+func NewAuthHandler(authService *service.AuthService, limiter *ratelimit.Limiter, lockout *ratelimit.Lockout) *AuthHandler {
+	return &AuthHandler{authService: authService, limiter: limiter, lockout: lockout}
 }
 
 type RegisterRequest struct {
@@ -25,23 +31,28 @@ type RegisterRequest struct {
 func (h *AuthHandler) Register(c *fiber.Ctx) error {
 	var req RegisterRequest
 	if err := c.BodyParser(&req); err != nil {
-		return util.BadRequest(c, "invalid request body")
+		return errInvalidBody
 	}
 
-	if req.Email == "" || req.Password == "" {
-		return util.BadRequest(c, "email and password required")
+	var missing []string
+	if req.Email == "" {
+		missing = append(missing, "email")
+	}
+	if req.Password == "" {
+		missing = append(missing, "password")
+	}
+	if len(missing) > 0 {
+		return required(missing...)
 	}
 
 	if len(req.Password) < 6 {
-		return util.BadRequest(c, "password must be at least 6 characters")
+		return apperr.Validation("auth.password_too_short", "password must be at least 6 characters").
+			WithField("password", "too_short", "auth.password_too_short")
 	}
 
 	user, token, err := h.authService.Register(c.Context(), req.Email, req.Password)
 	if err != nil {
-		if err == service.ErrEmailExists {
-			return util.BadRequest(c, "email already exists")
-		}
-		return util.InternalError(c)
+		return err
 	}
 
 	// Set cookie
@@ -68,16 +79,29 @@ type LoginRequest struct {
 func (h *AuthHandler) Login(c *fiber.Ctx) error {
 	var req LoginRequest
 	if err := c.BodyParser(&req); err != nil {
-		return util.BadRequest(c, "invalid request body")
+		return errInvalidBody
+	}
+
+	account := strings.ToLower(strings.TrimSpace(req.Email))
+	if err := allow(c, h.limiter, ratelimit.LoginAccount, account); err != nil {
+		return err
+	}
+	client := account + ":" + c.IP()
+	if err := allow(c, h.limiter, ratelimit.LoginAccountIP, client); err != nil {
+		return err
+	}
+	if err := checkLockout(c, h.lockout, client); err != nil {
+		return err
 	}
 
 	user, token, err := h.authService.Login(c.Context(), req.Email, req.Password)
 	if err != nil {
-		if err == service.ErrInvalidCredentials {
-			return util.Err(c, 401, "invalid email or password")
+		if errors.Is(err, service.ErrInvalidCredentials) {
+			recordFailure(c, h.lockout, client)
 		}
-		return util.InternalError(c)
+		return err
 	}
+	recordSuccess(c, h.lockout, client)
 
 	c.Cookie(&fiber.Cookie{
 		Name:     "token",
@@ -108,12 +132,12 @@ func (h *AuthHandler) Logout(c *fiber.Ctx) error {
 func (h *AuthHandler) Me(c *fiber.Ctx) error {
 	userID := middleware.GetUserID(c)
 	if userID == 0 {
-		return util.Unauthorized(c)
+		return errUnauthorized
 	}
 
 	user, err := h.authService.GetUser(c.Context(), userID)
 	if err != nil {
-		return util.NotFound(c)
+		return err
 	}
 
 	return util.OK(c, user)
@@ -126,23 +150,17 @@ type SetUsernameRequest struct {
 func (h *AuthHandler) SetUsername(c *fiber.Ctx) error {
 	userID := middleware.GetUserID(c)
 	if userID == 0 {
-		return util.Unauthorized(c)
+		return errUnauthorized
 	}
 
 	var req SetUsernameRequest
 	if err := c.BodyParser(&req); err != nil {
-		return util.BadRequest(c, "invalid request body")
+		return errInvalidBody
 	}
 
 	user, err := h.authService.SetUsername(c.Context(), userID, req.Username)
 	if err != nil {
-		if err == service.ErrUsernameExists {
-			return util.BadRequest(c, "username already taken")
-		}
-		if err == service.ErrInvalidUsername {
-			return util.BadRequest(c, "username must be 3-30 characters, lowercase letters, numbers, underscore only")
-		}
-		return util.InternalError(c)
+		return err
 	}
 
 	return util.OK(c, user)
@@ -151,12 +169,12 @@ func (h *AuthHandler) SetUsername(c *fiber.Ctx) error {
 func (h *AuthHandler) CheckUsername(c *fiber.Ctx) error {
 	username := c.Query("username")
 	if username == "" {
-		return util.BadRequest(c, "username required")
+		return required("username")
 	}
 
 	available, err := h.authService.CheckUsername(c.Context(), username)
 	if err != nil {
-		return util.InternalError(c)
+		return err
 	}
 
 	return util.OK(c, fiber.Map{"available": available})
@@ -4,6 +4,7 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"linkbio/internal/middleware"
 	"linkbio/internal/service"
+	"linkbio/internal/social"
 	"linkbio/internal/util"
 )
 
@@ -21,7 +22,7 @@ func (h *BioHandler) Get(c *fiber.Ctx) error {
 
 	data, err := h.bioService.GetBio(c.Context(), userID)
 	if err != nil {
-		return util.InternalError(c)
+		return err
 	}
 
 	return util.OK(c, data)
@@ -38,12 +39,12 @@ func (h *BioHandler) AddBlock(c *fiber.Ctx) error {
 
 	var req AddBlockRequest
 	if err := c.BodyParser(&req); err != nil {
-		return util.BadRequest(c, "invalid request body")
+		return errInvalidBody
 	}
 
 	block, err := h.bioService.AddBlock(c.Context(), userID, req.Type, req.Content)
 	if err != nil {
-		return util.InternalError(c)
+		return err
 	}
 
 	return util.Created(c, block)
@@ -60,27 +61,33 @@ func (h *BioHandler) UpdateProfile(c *fiber.Ctx) error {
 
 	var req UpdateProfileRequest
 	if err := c.BodyParser(&req); err != nil {
-		return util.BadRequest(c, "invalid request body")
+		return errInvalidBody
 	}
 
 	err := h.bioService.UpdateProfile(c.Context(), userID, req.DisplayName, req.Bio)
 	if err != nil {
-		return util.InternalError(c)
+		return err
 	}
 
 	return util.OK(c, fiber.Map{"message": "Profile updated successfully"})
 }
 
-// Update social links
+// Get social links
+func (h *BioHandler) GetSocialLinks(c *fiber.Ctx) error {
+	userID := middleware.GetUserID(c)
+
+	links, err := h.bioService.GetSocialLinks(c.Context(), userID)
+	if err != nil {
+		return err
+	}
+
+	return util.OK(c, fiber.Map{"links": links})
+}
+
+// Update social links: an ordered list of platform keys with a handle or
+// profile URL each
 type UpdateSocialLinksRequest struct {
-	Instagram string `json:"instagram"`
-	Facebook  string `json:"facebook"`
-	Twitter   string `json:"twitter"`
-	TikTok    string `json:"tiktok"`
-	YouTube   string `json:"youtube"`
-	LinkedIn  string `json:"linkedin"`
-	GitHub    string `json:"github"`
-	Website   string `json:"website"`
+	Links []service.SocialLinkInput `json:"links"`
 }
 
 func (h *BioHandler) UpdateSocialLinks(c *fiber.Ctx) error {
@@ -88,15 +95,21 @@ func (h *BioHandler) UpdateSocialLinks(c *fiber.Ctx) error {
 
 	var req UpdateSocialLinksRequest
 	if err := c.BodyParser(&req); err != nil {
-		return util.BadRequest(c, "invalid request body")
+		return errInvalidBody
 	}
 
-	err := h.bioService.UpdateSocialLinks(c.Context(), userID, req)
+	links, err := h.bioService.UpdateSocialLinks(c.Context(), userID, req.Links)
 	if err != nil {
-		return util.InternalError(c)
+		return err
 	}
 
-	return util.OK(c, fiber.Map{"message": "Social links updated successfully"})
+	return util.OK(c, fiber.Map{"links": links})
+}
+
+// List the social platforms the social row supports
+func (h *BioHandler) SocialPlatforms(c *fiber.Ctx) error {
+	c.Set("Cache-Control", "public, max-age=3600")
+	return util.OK(c, social.Platforms())
 }
 
 // Update block
@@ -109,23 +122,17 @@ func (h *BioHandler) UpdateBlock(c *fiber.Ctx) error {
 	userID := middleware.GetUserID(c)
 	blockID, err := parseID(c, "id")
 	if err != nil {
-		return util.BadRequest(c, "invalid block id")
+		return errInvalidID
 	}
 
 	var req UpdateBlockRequest
 	if err := c.BodyParser(&req); err != nil {
-		return util.BadRequest(c, "invalid request body")
+		return errInvalidBody
 	}
 
 	block, err := h.bioService.UpdateBlock(c.Context(), userID, blockID, req.Content, req.IsVisible)
 	if err != nil {
-		if err == service.ErrNotFound {
-			return util.NotFound(c)
-		}
-		if err == service.ErrForbidden {
-			return util.Forbidden(c)
-		}
-		return util.InternalError(c)
+		return err
 	}
 
 	return util.OK(c, block)
@@ -136,18 +143,12 @@ func (h *BioHandler) DeleteBlock(c *fiber.Ctx) error {
 	userID := middleware.GetUserID(c)
 	blockID, err := parseID(c, "id")
 	if err != nil {
-		return util.BadRequest(c, "invalid block id")
+		return errInvalidID
 	}
 
 	err = h.bioService.DeleteBlock(c.Context(), userID, blockID)
 	if err != nil {
-		if err == service.ErrNotFound {
-			return util.NotFound(c)
-		}
-		if err == service.ErrForbidden {
-			return util.Forbidden(c)
-		}
-		return util.InternalError(c)
+		return err
 	}
 
 	return util.OK(c, fiber.Map{"deleted": true})
@@ -165,19 +166,23 @@ func (h *BioHandler) AddLink(c *fiber.Ctx) error {
 
 	var req AddLinkRequest
 	if err := c.BodyParser(&req); err != nil {
-		return util.BadRequest(c, "invalid request body")
+		return errInvalidBody
 	}
 
-	if req.Title == "" || req.URL == "" {
-		return util.BadRequest(c, "title and url required")
+	var missing []string
+	if req.Title == "" {
+		missing = append(missing, "title")
+	}
+	if req.URL == "" {
+		missing = append(missing, "url")
+	}
+	if len(missing) > 0 {
+		return required(missing...)
 	}
 
 	link, err := h.bioService.AddLink(c.Context(), userID, req.GroupID, req.Title, req.URL)
 	if err != nil {
-		if err == service.ErrForbidden {
-			return util.Forbidden(c)
-		}
-		return util.InternalError(c)
+		return err
 	}
 
 	return util.Created(c, link)
@@ -194,23 +199,17 @@ func (h *BioHandler) UpdateLink(c *fiber.Ctx) error {
 	userID := middleware.GetUserID(c)
 	linkID, err := parseID(c, "id")
 	if err != nil {
-		return util.BadRequest(c, "invalid link id")
+		return errInvalidID
 	}
 
 	var req UpdateLinkRequest
 	if err := c.BodyParser(&req); err != nil {
-		return util.BadRequest(c, "invalid request body")
+		return errInvalidBody
 	}
 
 	link, err := h.bioService.UpdateLink(c.Context(), userID, linkID, req.Title, req.URL, req.IsActive)
 	if err != nil {
-		if err == service.ErrNotFound {
-			return util.NotFound(c)
-		}
-		if err == service.ErrForbidden {
-			return util.Forbidden(c)
-		}
-		return util.InternalError(c)
+		return err
 	}
 
 	return util.OK(c, link)
@@ -221,18 +220,12 @@ func (h *BioHandler) DeleteLink(c *fiber.Ctx) error {
 	userID := middleware.GetUserID(c)
 	linkID, err := parseID(c, "id")
 	if err != nil {
-		return util.BadRequest(c, "invalid link id")
+		return errInvalidID
 	}
 
 	err = h.bioService.DeleteLink(c.Context(), userID, linkID)
 	if err != nil {
-		if err == service.ErrNotFound {
-			return util.NotFound(c)
-		}
-		if err == service.ErrForbidden {
-			return util.Forbidden(c)
-		}
-		return util.InternalError(c)
+		return err
 	}
 
 	return util.OK(c, fiber.Map{"deleted": true})
@@ -248,12 +241,12 @@ func (h *BioHandler) ReorderBlocks(c *fiber.Ctx) error {
 
 	var req ReorderBlocksRequest
 	if err := c.BodyParser(&req); err != nil {
-		return util.BadRequest(c, "invalid request body")
+		return errInvalidBody
 	}
 
 	err := h.bioService.ReorderBlocks(c.Context(), userID, req.BlockIDs)
 	if err != nil {
-		return util.InternalError(c)
+		return err
 	}
 
 	return util.OK(c, fiber.Map{"reordered": true})
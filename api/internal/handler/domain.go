@@ -0,0 +1,31 @@
+package handler
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"linkbio/internal/middleware"
+	"linkbio/internal/service"
+	"linkbio/internal/util"
+)
+
+type DomainHandler struct {
+	domainService *service.DomainService
+}
+
+func NewDomainHandler(domainService *service.DomainService) *DomainHandler {
+	return &DomainHandler{domainService: domainService}
+}
+
+func (h *DomainHandler) Disable(c *fiber.Ctx) error {
+	userID := middleware.GetUserID(c)
+	domainID, err := parseID(c, "id")
+	if err != nil {
+		return errInvalidID
+	}
+
+	err = h.domainService.DisableDomain(c.Context(), userID, domainID)
+	if err != nil {
+		return err
+	}
+
+	return util.OK(c, fiber.Map{"disabled": true})
+}
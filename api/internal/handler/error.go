@@ -1,18 +1,58 @@
 package handler
 
 import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
 	"github.com/gofiber/fiber/v2"
+	"linkbio/internal/apperr"
+	"linkbio/internal/middleware"
+	"linkbio/internal/util"
 )
 
+// ErrorHandler turns every error returned by a handler into the standard
+// error envelope. Causes are logged with the request ID, never returned.
 func ErrorHandler(c *fiber.Ctx, err error) error {
-	code := fiber.StatusInternalServerError
+	var e *apperr.Error
+	var fe *fiber.Error
+	if errors.As(err, &fe) {
+		e = apperr.New(fe.Code, codeForStatus(fe.Code), "common.http_error", fe.Message)
+	} else {
+		e = apperr.From(err)
+	}
 
-	if e, ok := err.(*fiber.Error); ok {
-		code = e.Code
+	requestID := middleware.GetRequestID(c)
+	if e.Cause != nil || e.Status >= http.StatusInternalServerError {
+		log.Printf("[Error] request_id=%s %s %s: %v", requestID, c.Method(), c.Path(), e)
+	}
+	if e.RetryAfter > 0 {
+		c.Set("Retry-After", strconv.Itoa(util.Seconds(e.RetryAfter)))
 	}
 
-	return c.Status(code).JSON(fiber.Map{
-		"success": false,
-		"error":   err.Error(),
-	})
+	return util.Fail(c, e, requestID)
+}
+
+func codeForStatus(status int) apperr.Code {
+	switch status {
+	case http.StatusBadRequest:
+		return apperr.CodeBadRequest
+	case http.StatusUnauthorized:
+		return apperr.CodeUnauthorized
+	case http.StatusForbidden:
+		return apperr.CodeForbidden
+	case http.StatusNotFound:
+		return apperr.CodeNotFound
+	case http.StatusConflict:
+		return apperr.CodeConflict
+	case http.StatusUnprocessableEntity:
+		return apperr.CodeValidation
+	case http.StatusTooManyRequests:
+		return apperr.CodeRateLimited
+	}
+	if status >= http.StatusInternalServerError {
+		return apperr.CodeInternal
+	}
+	return apperr.CodeBadRequest
 }
@@ -0,0 +1,18 @@
+package handler
+
+import "linkbio/internal/apperr"
+
+var (
+	errInvalidBody  = apperr.BadRequest("request.invalid_body", "invalid request body")
+	errUnauthorized = apperr.Unauthorized("auth.unauthorized", "unauthorized")
+	errInvalidID    = apperr.BadRequest("request.invalid_id", "invalid id").WithField("id", "invalid", "request.invalid_id")
+)
+
+// required reports missing request fields.
+func required(fields ...string) *apperr.Error {
+	e := apperr.Validation("request.required", "required fields missing")
+	for _, f := range fields {
+		e = e.WithField(f, "required", "request.required")
+	}
+	return e
+}
@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"linkbio/internal/middleware"
+	"linkbio/internal/service"
+	"linkbio/internal/util"
+)
+
+type ExperimentHandler struct {
+	experimentService *service.ExperimentService
+}
+
+func NewExperimentHandler(experimentService *service.ExperimentService) *ExperimentHandler {
+	return &ExperimentHandler{experimentService: experimentService}
+}
+
+func (h *ExperimentHandler) List(c *fiber.Ctx) error {
+	userID := middleware.GetUserID(c)
+	pageID, err := parseID(c, "id")
+	if err != nil {
+		return errInvalidID
+	}
+
+	experiments, err := h.experimentService.List(c.Context(), userID, pageID)
+	if err != nil {
+		return err
+	}
+
+	return util.OK(c, experiments)
+}
+
+func (h *ExperimentHandler) Create(c *fiber.Ctx) error {
+	userID := middleware.GetUserID(c)
+	pageID, err := parseID(c, "id")
+	if err != nil {
+		return errInvalidID
+	}
+
+	var req service.CreateExperimentReq
+	if err := c.BodyParser(&req); err != nil {
+		return errInvalidBody
+	}
+	if req.Name == "" || len(req.Variants) == 0 {
+		return required("name", "variants")
+	}
+
+	experiment, err := h.experimentService.Create(c.Context(), userID, pageID, req)
+	if err != nil {
+		return err
+	}
+
+	return util.Created(c, experiment)
+}
+
+// Results reports each variant's views, clicks and CTR against the
+// baseline.
+func (h *ExperimentHandler) Results(c *fiber.Ctx) error {
+	userID := middleware.GetUserID(c)
+	pageID, err := parseID(c, "id")
+	if err != nil {
+		return errInvalidID
+	}
+	id, err := parseID(c, "experimentId")
+	if err != nil {
+		return errInvalidID
+	}
+
+	results, err := h.experimentService.Results(c.Context(), userID, pageID, id)
+	if err != nil {
+		return err
+	}
+
+	return util.OK(c, results)
+}
+
+// End stops the experiment, optionally promoting the winner into the
+// draft.
+func (h *ExperimentHandler) End(c *fiber.Ctx) error {
+	userID := middleware.GetUserID(c)
+	pageID, err := parseID(c, "id")
+	if err != nil {
+		return errInvalidID
+	}
+	id, err := parseID(c, "experimentId")
+	if err != nil {
+		return errInvalidID
+	}
+
+	var req service.EndExperimentReq
+	if err := c.BodyParser(&req); err != nil {
+		return errInvalidBody
+	}
+
+	experiment, err := h.experimentService.End(c.Context(), userID, pageID, id, req)
+	if err != nil {
+		return err
+	}
+
+	return util.OK(c, experiment)
+}
@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"linkbio/internal/model"
+	"linkbio/internal/repo/memory"
+	"linkbio/internal/service"
+)
+
+// app.Test requests come from 0.0.0.0.
+const testClient = "0.0.0.0"
+
+func hostApp(trusted ...string) *fiber.App {
+	app := fiber.New(fiber.Config{
+		ErrorHandler:            ErrorHandler,
+		EnableTrustedProxyCheck: len(trusted) > 0,
+		TrustedProxies:          trusted,
+	})
+	app.Get("/", func(c *fiber.Ctx) error {
+		host, err := requestHost(c)
+		if err != nil {
+			return err
+		}
+		return c.SendString(host)
+	})
+	return app
+}
+
+func TestRequestHostTrustsOnlyProxies(t *testing.T) {
+	tests := []struct {
+		name    string
+		trusted []string
+		want    string
+	}{
+		{"trusted proxy", []string{testClient}, "alice.example"},
+		{"trusted range", []string{"0.0.0.0/8"}, "alice.example"},
+		{"other proxy", []string{"10.0.0.1"}, "api.linkbio.test"},
+		{"no proxies", nil, "api.linkbio.test"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Host = "API.linkbio.test:8080"
+		req.Header.Set("X-Forwarded-Host", "Alice.Example:443, evil.example")
+		resp, err := hostApp(tt.trusted...).Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		if string(body) != tt.want {
+			t.Errorf("%s: host = %q, want %q", tt.name, body, tt.want)
+		}
+	}
+}
+
+// A short code forwarded by the web app from another user's custom domain
+// is refused.
+func TestShortLinkRefusedOnOtherUsersDomain(t *testing.T) {
+	ctx := context.Background()
+	db := memory.NewDB()
+	users, pages, blocks := memory.NewUserRepo(db), memory.NewPageRepo(db), memory.NewBlockRepo(db)
+	domains, shortLinks := memory.NewDomainRepo(db), memory.NewShortLinkRepo(db)
+
+	alice, err := users.Create(ctx, "alice@example.com", "hash")
+	must(t, err)
+	bob, err := users.Create(ctx, "bob@example.com", "hash")
+	must(t, err)
+	preset, err := db.SeedPreset(model.ThemePreset{Key: "p", Name: "P", Tier: "free", Config: json.RawMessage(`{}`)})
+	must(t, err)
+	page, err := pages.Create(ctx, alice.ID, preset.ID, "Page")
+	must(t, err)
+	group, err := blocks.CreateLinkGroup(ctx, page.ID, nil, "list")
+	must(t, err)
+	_, err = blocks.CreateBlock(ctx, page.ID, "link_group", "a", &group.ID, nil)
+	must(t, err)
+	link, err := blocks.CreateLink(ctx, group.ID, "Shop", "https://example.com/shop", "a")
+	must(t, err)
+	_, err = shortLinks.Create(ctx, link.ID, "shop", true)
+	must(t, err)
+	_, err = domains.Create(ctx, &bob.ID, "bob.example", false)
+	must(t, err)
+
+	h := NewShortLinkHandler(service.NewShortLinkService(shortLinks, memory.NewBioRepo(db), domains), nil)
+	app := fiber.New(fiber.Config{
+		ErrorHandler:            ErrorHandler,
+		EnableTrustedProxyCheck: true,
+		TrustedProxies:          []string{testClient},
+	})
+	app.Get("/s/:code", h.Redirect)
+
+	req := httptest.NewRequest("GET", "/s/shop", nil)
+	req.Host = "api.linkbio.test"
+	req.Header.Set("X-Forwarded-Host", "bob.example")
+	resp, err := app.Test(req)
+	must(t, err)
+	if resp.StatusCode != fiber.StatusNotFound {
+		t.Errorf("status = %d, want 404", resp.StatusCode)
+	}
+}
+
+func must(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
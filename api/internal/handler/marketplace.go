@@ -0,0 +1,220 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/gofiber/fiber/v2"
+	"linkbio/internal/middleware"
+	"linkbio/internal/model"
+	"linkbio/internal/service"
+	"linkbio/internal/util"
+)
+
+type MarketplaceHandler struct {
+	marketplaceService *service.MarketplaceService
+}
+
+func NewMarketplaceHandler(marketplaceService *service.MarketplaceService) *MarketplaceHandler {
+	return &MarketplaceHandler{marketplaceService: marketplaceService}
+}
+
+// Browse lists published presets: ?q=&tier=&sort=popular|newest|name&page=&limit=
+func (h *MarketplaceHandler) Browse(c *fiber.Ctx) error {
+	result, err := h.marketplaceService.Browse(c.Context(),
+		c.Query("q"), c.Query("tier"), c.Query("sort"), c.QueryInt("page", 1), c.QueryInt("limit"))
+	if err != nil {
+		return err
+	}
+
+	return util.OK(c, result)
+}
+
+func (h *MarketplaceHandler) Get(c *fiber.Ctx) error {
+	userID := middleware.GetUserID(c)
+	id, err := parseID(c, "id")
+	if err != nil {
+		return errInvalidID
+	}
+
+	preset, err := h.marketplaceService.Get(c.Context(), userID, id)
+	if err != nil {
+		return err
+	}
+
+	return util.OK(c, preset)
+}
+
+func (h *MarketplaceHandler) ListMine(c *fiber.Ctx) error {
+	userID := middleware.GetUserID(c)
+
+	presets, err := h.marketplaceService.ListMine(c.Context(), userID)
+	if err != nil {
+		return err
+	}
+
+	if presets == nil {
+		presets = []*model.ThemePreset{}
+	}
+
+	return util.OK(c, presets)
+}
+
+type PresetRequest struct {
+	Name       string          `json:"name"`
+	Tier       string          `json:"tier"`
+	Visibility string          `json:"visibility"`
+	Config     json.RawMessage `json:"config"`
+}
+
+func (r PresetRequest) input() service.PresetInput {
+	return service.PresetInput{Name: r.Name, Tier: r.Tier, Visibility: r.Visibility, Config: r.Config}
+}
+
+func (h *MarketplaceHandler) Create(c *fiber.Ctx) error {
+	userID := middleware.GetUserID(c)
+
+	var req PresetRequest
+	if err := c.BodyParser(&req); err != nil {
+		return errInvalidBody
+	}
+	if len(req.Config) == 0 {
+		return required("config")
+	}
+
+	preset, err := h.marketplaceService.CreateDraft(c.Context(), userID, req.input())
+	if err != nil {
+		return err
+	}
+
+	return util.Created(c, preset)
+}
+
+func (h *MarketplaceHandler) Update(c *fiber.Ctx) error {
+	userID := middleware.GetUserID(c)
+	id, err := parseID(c, "id")
+	if err != nil {
+		return errInvalidID
+	}
+
+	var req PresetRequest
+	if err := c.BodyParser(&req); err != nil {
+		return errInvalidBody
+	}
+	if len(req.Config) == 0 {
+		return required("config")
+	}
+
+	preset, err := h.marketplaceService.UpdateDraft(c.Context(), userID, id, req.input())
+	if err != nil {
+		return err
+	}
+
+	return util.OK(c, preset)
+}
+
+func (h *MarketplaceHandler) Submit(c *fiber.Ctx) error {
+	userID := middleware.GetUserID(c)
+	id, err := parseID(c, "id")
+	if err != nil {
+		return errInvalidID
+	}
+
+	preset, err := h.marketplaceService.Submit(c.Context(), userID, id)
+	if err != nil {
+		return err
+	}
+
+	return util.OK(c, preset)
+}
+
+func (h *MarketplaceHandler) ReviewQueue(c *fiber.Ctx) error {
+	userID := middleware.GetUserID(c)
+
+	presets, err := h.marketplaceService.ReviewQueue(c.Context(), userID)
+	if err != nil {
+		return err
+	}
+
+	if presets == nil {
+		presets = []*model.ThemePreset{}
+	}
+
+	return util.OK(c, presets)
+}
+
+type ReviewRequest struct {
+	Note string `json:"note"`
+}
+
+func (h *MarketplaceHandler) Approve(c *fiber.Ctx) error {
+	return h.review(c, h.marketplaceService.Approve)
+}
+
+func (h *MarketplaceHandler) Reject(c *fiber.Ctx) error {
+	return h.review(c, h.marketplaceService.Reject)
+}
+
+func (h *MarketplaceHandler) review(c *fiber.Ctx, action func(ctx context.Context, adminID, id int64, note string) (*model.ThemePreset, error)) error {
+	userID := middleware.GetUserID(c)
+	id, err := parseID(c, "id")
+	if err != nil {
+		return errInvalidID
+	}
+
+	var req ReviewRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return errInvalidBody
+		}
+	}
+
+	preset, err := action(c.Context(), userID, id, req.Note)
+	if err != nil {
+		return err
+	}
+
+	return util.OK(c, preset)
+}
+
+func (h *MarketplaceHandler) ListVersions(c *fiber.Ctx) error {
+	userID := middleware.GetUserID(c)
+	id, err := parseID(c, "id")
+	if err != nil {
+		return errInvalidID
+	}
+
+	versions, err := h.marketplaceService.ListVersions(c.Context(), userID, id)
+	if err != nil {
+		return err
+	}
+
+	return util.OK(c, versions)
+}
+
+type PublishVersionRequest struct {
+	Config json.RawMessage `json:"config"`
+}
+
+func (h *MarketplaceHandler) PublishVersion(c *fiber.Ctx) error {
+	userID := middleware.GetUserID(c)
+	id, err := parseID(c, "id")
+	if err != nil {
+		return errInvalidID
+	}
+
+	var req PublishVersionRequest
+	if err := c.BodyParser(&req); err != nil {
+		return errInvalidBody
+	}
+	if len(req.Config) == 0 {
+		return required("config")
+	}
+
+	version, err := h.marketplaceService.PublishVersion(c.Context(), userID, id, req.Config)
+	if err != nil {
+		return err
+	}
+
+	return util.Created(c, version)
+}
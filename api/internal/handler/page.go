@@ -2,6 +2,7 @@ package handler
 
 import (
 	"strconv"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"linkbio/internal/middleware"
@@ -13,12 +14,16 @@ import (
 type PageHandler struct {
 	pageService     *service.PageService
 	compilerService *service.CompilerService
+	domainService   *service.DomainService
+	previewService  *service.PreviewService
 }
 
-func NewPageHandler(pageService *service.PageService, compilerService *service.CompilerService) *PageHandler {
+func NewPageHandler(pageService *service.PageService, compilerService *service.CompilerService, domainService *service.DomainService, previewService *service.PreviewService) *PageHandler {
 	return &PageHandler{
 		pageService:     pageService,
 		compilerService: compilerService,
+		domainService:   domainService,
+		previewService:  previewService,
 	}
 }
 
@@ -32,7 +37,7 @@ func (h *PageHandler) Create(c *fiber.Ctx) error {
 
 	var req CreatePageRequest
 	if err := c.BodyParser(&req); err != nil {
-		return util.BadRequest(c, "invalid request body")
+		return errInvalidBody
 	}
 
 	if req.ThemePresetID == 0 {
@@ -41,7 +46,7 @@ func (h *PageHandler) Create(c *fiber.Ctx) error {
 
 	page, err := h.pageService.Create(c.Context(), userID, req.Title, req.ThemePresetID)
 	if err != nil {
-		return util.InternalError(c)
+		return err
 	}
 
 	return util.Created(c, page)
@@ -52,7 +57,7 @@ func (h *PageHandler) List(c *fiber.Ctx) error {
 
 	pages, err := h.pageService.List(c.Context(), userID)
 	if err != nil {
-		return util.InternalError(c)
+		return err
 	}
 
 	if pages == nil {
@@ -66,16 +71,16 @@ func (h *PageHandler) Get(c *fiber.Ctx) error {
 	userID := middleware.GetUserID(c)
 	pageID, err := strconv.ParseInt(c.Params("id"), 10, 64)
 	if err != nil {
-		return util.BadRequest(c, "invalid page id")
+		return errInvalidID
 	}
 
 	page, err := h.pageService.Get(c.Context(), pageID)
 	if err != nil {
-		return util.NotFound(c)
+		return err
 	}
 
 	if page.UserID != userID {
-		return util.Forbidden(c)
+		return service.ErrForbidden
 	}
 
 	return util.OK(c, page)
@@ -85,16 +90,16 @@ func (h *PageHandler) GetDraft(c *fiber.Ctx) error {
 	userID := middleware.GetUserID(c)
 	pageID, err := strconv.ParseInt(c.Params("id"), 10, 64)
 	if err != nil {
-		return util.BadRequest(c, "invalid page id")
+		return errInvalidID
 	}
 
 	draft, err := h.pageService.GetDraft(c.Context(), pageID)
 	if err != nil {
-		return util.NotFound(c)
+		return err
 	}
 
 	if draft.Page.UserID != userID {
-		return util.Forbidden(c)
+		return service.ErrForbidden
 	}
 
 	return util.OK(c, draft)
@@ -104,25 +109,25 @@ func (h *PageHandler) Save(c *fiber.Ctx) error {
 	userID := middleware.GetUserID(c)
 	pageID, err := strconv.ParseInt(c.Params("id"), 10, 64)
 	if err != nil {
-		return util.BadRequest(c, "invalid page id")
+		return errInvalidID
 	}
 
 	// Check ownership
 	page, err := h.pageService.Get(c.Context(), pageID)
 	if err != nil {
-		return util.NotFound(c)
+		return err
 	}
 	if page.UserID != userID {
-		return util.Forbidden(c)
+		return service.ErrForbidden
 	}
 
 	var req service.SaveRequest
 	if err := c.BodyParser(&req); err != nil {
-		return util.BadRequest(c, "invalid request body")
+		return errInvalidBody
 	}
 
 	if err := h.pageService.Save(c.Context(), pageID, &req); err != nil {
-		return util.InternalError(c)
+		return err
 	}
 
 	return util.OK(c, fiber.Map{"saved": true})
@@ -132,43 +137,173 @@ func (h *PageHandler) Publish(c *fiber.Ctx) error {
 	userID := middleware.GetUserID(c)
 	pageID, err := strconv.ParseInt(c.Params("id"), 10, 64)
 	if err != nil {
-		return util.BadRequest(c, "invalid page id")
+		return errInvalidID
 	}
 
 	// Check ownership
 	page, err := h.pageService.Get(c.Context(), pageID)
 	if err != nil {
-		return util.NotFound(c)
+		return err
 	}
 	if page.UserID != userID {
-		return util.Forbidden(c)
+		return service.ErrForbidden
 	}
 
-	if err := h.compilerService.Publish(c.Context(), pageID); err != nil {
-		return util.InternalError(c)
+	result, err := h.compilerService.Publish(c.Context(), pageID)
+	if err != nil {
+		return err
+	}
+
+	return util.OK(c, fiber.Map{"published": true, "lint": result.Lint, "skipped_links": result.SkippedLinks})
+}
+
+func (h *PageHandler) Unpublish(c *fiber.Ctx) error {
+	userID := middleware.GetUserID(c)
+	pageID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return errInvalidID
+	}
+
+	// Check ownership
+	page, err := h.pageService.Get(c.Context(), pageID)
+	if err != nil {
+		return err
+	}
+	if page.UserID != userID {
+		return service.ErrForbidden
+	}
+
+	if err := h.compilerService.Unpublish(c.Context(), pageID); err != nil {
+		return err
+	}
+
+	return util.OK(c, fiber.Map{"published": false})
+}
+
+// UpdateSEO stores the page's search and share metadata. It is applied on
+// the next publish.
+func (h *PageHandler) UpdateSEO(c *fiber.Ctx) error {
+	userID := middleware.GetUserID(c)
+	pageID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return errInvalidID
+	}
+
+	var req service.SEOSettings
+	if err := c.BodyParser(&req); err != nil {
+		return errInvalidBody
+	}
+
+	seo, err := h.pageService.UpdateSEO(c.Context(), userID, pageID, req)
+	if err != nil {
+		return err
+	}
+
+	return util.OK(c, seo)
+}
+
+// UpdateUTM stores the page's UTM template. It is applied to links on the
+// next publish.
+func (h *PageHandler) UpdateUTM(c *fiber.Ctx) error {
+	userID := middleware.GetUserID(c)
+	pageID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return errInvalidID
+	}
+
+	var req service.UTMSettings
+	if err := c.BodyParser(&req); err != nil {
+		return errInvalidBody
+	}
+
+	utm, err := h.pageService.UpdateUTM(c.Context(), userID, pageID, req)
+	if err != nil {
+		return err
+	}
+
+	return util.OK(c, utm)
+}
+
+type CreatePreviewRequest struct {
+	TTLMinutes int `json:"ttl_minutes"` // 0 = default (24h)
+}
+
+// CreatePreview signs a link that shows the page's current draft to anyone
+// holding it, until it expires.
+func (h *PageHandler) CreatePreview(c *fiber.Ctx) error {
+	userID := middleware.GetUserID(c)
+	pageID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return errInvalidID
+	}
+
+	var req CreatePreviewRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return errInvalidBody
+		}
+	}
+
+	link, err := h.previewService.Create(c.Context(), userID, pageID, time.Duration(req.TTLMinutes)*time.Minute)
+	if err != nil {
+		return err
+	}
+
+	return util.Created(c, link)
+}
+
+type UpdateRouteRequest struct {
+	DomainID *int64 `json:"domain_id"` // nil = system domain
+	Path     string `json:"path"`
+}
+
+func (h *PageHandler) UpdateRoute(c *fiber.Ctx) error {
+	userID := middleware.GetUserID(c)
+	pageID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return errInvalidID
+	}
+
+	// Check ownership
+	page, err := h.pageService.Get(c.Context(), pageID)
+	if err != nil {
+		return err
+	}
+	if page.UserID != userID {
+		return service.ErrForbidden
+	}
+
+	var req UpdateRouteRequest
+	if err := c.BodyParser(&req); err != nil {
+		return errInvalidBody
+	}
+
+	route, err := h.domainService.ChangeRoute(c.Context(), userID, pageID, req.DomainID, req.Path)
+	if err != nil {
+		return err
 	}
 
-	return util.OK(c, fiber.Map{"published": true})
+	return util.OK(c, route)
 }
 
 func (h *PageHandler) Delete(c *fiber.Ctx) error {
 	userID := middleware.GetUserID(c)
 	pageID, err := strconv.ParseInt(c.Params("id"), 10, 64)
 	if err != nil {
-		return util.BadRequest(c, "invalid page id")
+		return errInvalidID
 	}
 
 	// Check ownership
 	page, err := h.pageService.Get(c.Context(), pageID)
 	if err != nil {
-		return util.NotFound(c)
+		return err
 	}
 	if page.UserID != userID {
-		return util.Forbidden(c)
+		return service.ErrForbidden
 	}
 
 	if err := h.pageService.Delete(c.Context(), pageID); err != nil {
-		return util.InternalError(c)
+		return err
 	}
 
 	return util.OK(c, fiber.Map{"deleted": true})
@@ -1,89 +1,505 @@
 package handler
 
 import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
+	"linkbio/internal/apperr"
+	"linkbio/internal/cache"
+	"linkbio/internal/cdn"
+	"linkbio/internal/model"
+	"linkbio/internal/ratelimit"
 	"linkbio/internal/repo"
+	"linkbio/internal/service"
 	"linkbio/internal/util"
 )
 
+var errPasswordRequired = apperr.Unauthorized("page.password_required", "password required")
+
+// experimentCookieAge is how long a visitor keeps their experiment variant.
+const experimentCookieAge = 90 * 24 * time.Hour
+
 type PublicHandler struct {
-	pageRepo   *repo.PageRepo
-	domainRepo *repo.DomainRepo
+	pageRepo       repo.PageStore
+	domainRepo     repo.DomainStore
+	themeRepo      repo.ThemeStore
+	assetRepo      repo.AssetStore
+	bioRepo        repo.BioStore
+	experimentRepo repo.ExperimentStore
+	preview        *service.PreviewService
+	renderCache    *cache.RenderCache
+	limiter        *ratelimit.Limiter
+	lockout        *ratelimit.Lockout
 }
 
-func NewPublicHandler(pageRepo *repo.PageRepo, domainRepo *repo.DomainRepo) *PublicHandler {
+func NewPublicHandler(pageRepo repo.PageStore, domainRepo repo.DomainStore, themeRepo repo.ThemeStore, assetRepo repo.AssetStore, bioRepo repo.BioStore, experimentRepo repo.ExperimentStore, preview *service.PreviewService, renderCache *cache.RenderCache, limiter *ratelimit.Limiter, lockout *ratelimit.Lockout) *PublicHandler {
 	return &PublicHandler{
-		pageRepo:   pageRepo,
-		domainRepo: domainRepo,
+		pageRepo:       pageRepo,
+		domainRepo:     domainRepo,
+		themeRepo:      themeRepo,
+		assetRepo:      assetRepo,
+		bioRepo:        bioRepo,
+		experimentRepo: experimentRepo,
+		preview:        preview,
+		renderCache:    renderCache,
+		limiter:        limiter,
+		lockout:        lockout,
 	}
 }
 
 func (h *PublicHandler) Render(c *fiber.Ctx) error {
-	// Get hostname from Host header
-	host := c.Get("Host")
-	if host == "" {
-		return util.BadRequest(c, "missing host header")
+	host, err := requestHost(c)
+	if err != nil {
+		return err
 	}
 
-	// Remove port if present
-	if idx := strings.Index(host, ":"); idx != -1 {
-		host = host[:idx]
+	// Get path from query or default to /
+	path := util.CanonicalPath(c.Query("path", "/"))
+
+	key := cache.Key(host, path)
+	entry, state := h.renderCache.Get(key)
+	switch state {
+	case cache.Miss:
+		gen := h.renderCache.Generation()
+		entry, err = h.resolve(c.Context(), host, path)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return service.ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		h.renderCache.Set(key, entry, gen)
+	case cache.Stale:
+		h.renderCache.Revalidate(key, func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			gen := h.renderCache.Generation()
+			fresh, err := h.resolve(ctx, host, path)
+			if errors.Is(err, pgx.ErrNoRows) {
+				h.renderCache.Remove(key)
+				return
+			}
+			if err != nil {
+				log.Printf("[Render] revalidate %s: %v", key, err)
+				return
+			}
+			h.renderCache.Set(key, fresh, gen)
+		})
 	}
 
-	// Get path from query or default to /
-	path := c.Query("path", "/")
-	if !strings.HasPrefix(path, "/") {
-		path = "/" + path
+	// Check password protection
+	if entry.Protected {
+		// TODO: check session cookie
+		return errPasswordRequired
 	}
 
-	// Find domain
-	domain, err := h.domainRepo.GetByHostname(c.Context(), host)
-	if err != nil {
-		// Try system domain
-		domain, err = h.domainRepo.GetSystemDomain(c.Context())
-		if err != nil {
-			return util.NotFound(c)
+	// Set cache headers
+	body, etag := entry.Body, entry.ETag
+	if len(entry.Variants) > 0 {
+		v := assignVariant(c, entry)
+		body, etag = v.Body, v.ETag
+		// Each visitor keeps their variant, so shared caches must not
+		// store it.
+		c.Set("Cache-Control", "private, no-cache")
+		c.Vary("Cookie")
+	} else {
+		c.Set("Cache-Control", publicCacheControl(entry.Expires))
+	}
+	if entry.NoIndex {
+		c.Set("X-Robots-Tag", "noindex, nofollow")
+	}
+	c.Set("ETag", etag)
+	c.Set("Surrogate-Key", strings.Join(entry.SurrogateKeys, " "))
+	c.Set("Cache-Tag", strings.Join(entry.SurrogateKeys, ","))
+
+	if etagMatches(c.Get("If-None-Match"), etag) {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+
+	c.Set("Content-Type", "application/json")
+	return c.Send(body)
+}
+
+// publicCacheControl lets shared caches keep a page no longer than until
+// its next link expires, and never serve it stale past that.
+func publicCacheControl(expires time.Time) string {
+	if expires.IsZero() {
+		return "public, max-age=60, s-maxage=300, stale-while-revalidate=86400"
+	}
+	left := max(int(time.Until(expires).Seconds()), 0)
+	return fmt.Sprintf("public, max-age=%d, s-maxage=%d", min(left, 60), min(left, 300))
+}
+
+// assignVariant returns the visitor's variant of the page's running
+// experiment. Visitors without one, or whose cookie names a variant that
+// no longer exists, are given one by weight and a cookie to keep it.
+func assignVariant(c *fiber.Ctx, entry *cache.Entry) *cache.Variant {
+	name := "lb_exp_" + strconv.FormatInt(entry.ExperimentID, 10)
+	key := c.Cookies(name)
+	total := 0
+	for i := range entry.Variants {
+		if entry.Variants[i].Key == key {
+			return &entry.Variants[i]
 		}
-		// For system domain, path comes from query
+		total += entry.Variants[i].Weight
+	}
+
+	v := &entry.Variants[len(entry.Variants)-1]
+	n := rand.Intn(total)
+	for i := range entry.Variants {
+		if n < entry.Variants[i].Weight {
+			v = &entry.Variants[i]
+			break
+		}
+		n -= entry.Variants[i].Weight
+	}
+	c.Cookie(&fiber.Cookie{
+		Name:     name,
+		Value:    v.Key,
+		Expires:  time.Now().Add(experimentCookieAge),
+		HTTPOnly: true,
+		SameSite: "Lax",
+	})
+	return v
+}
+
+// Preview serves a draft through a signed preview link. Drafts change
+// without a purge, and the link is a secret, so nothing may cache or index
+// the response.
+func (h *PublicHandler) Preview(c *fiber.Ctx) error {
+	c.Set("Cache-Control", "private, no-store, max-age=0")
+	c.Set("X-Robots-Tag", "noindex, nofollow, noarchive")
+	c.Set("Referrer-Policy", "no-referrer")
+
+	compiled, err := h.preview.Render(c.Context(), c.Params("token"))
+	if err != nil {
+		return err
+	}
+	return c.JSON(compiled.Public())
+}
+
+// resolve looks up the published page served at host+path. Missing
+// domains, routes, pages or publish cache rows are reported as
+// pgx.ErrNoRows.
+func (h *PublicHandler) resolve(ctx context.Context, host, path string) (*cache.Entry, error) {
+	domain, err := h.domain(ctx, host)
+	if err != nil {
+		return nil, err
 	}
 
 	// Find route
-	route, err := h.domainRepo.GetRouteByDomainAndPath(c.Context(), domain.ID, path)
+	route, err := h.domainRepo.GetRouteByDomainAndPath(ctx, domain.ID, path)
 	if err != nil {
-		return util.NotFound(c)
+		return nil, err
 	}
 
 	// Check if redirect
 	if route.RedirectToRouteID != nil {
 		// TODO: implement redirect
-		return util.NotFound(c)
+		return nil, pgx.ErrNoRows
 	}
 
 	// Get page
-	page, err := h.pageRepo.GetByID(c.Context(), route.PageID)
+	page, err := h.pageRepo.GetByID(ctx, route.PageID)
 	if err != nil {
-		return util.NotFound(c)
+		return nil, err
 	}
 
-	// Check password protection
+	entry := &cache.Entry{PageID: page.ID, DomainID: domain.ID}
+	entry.SurrogateKeys = []string{
+		cdn.PageKey(page.ID),
+		cdn.PresetKey(page.ThemePresetID),
+		cdn.DomainKey(domain.ID),
+	}
+	if page.ThemeCustomID != nil {
+		entry.SurrogateKeys = append(entry.SurrogateKeys, cdn.CustomThemeKey(*page.ThemeCustomID))
+	}
 	if page.AccessType == "password" {
-		// TODO: check session cookie
-		return util.Err(c, 401, "password required")
+		entry.Protected = true
+		return entry, nil
 	}
 
 	// Get cached compiled JSON
-	cache, err := h.pageRepo.GetPublishCache(c.Context(), page.ID)
+	published, err := h.pageRepo.GetPublishCache(ctx, page.ID)
 	if err != nil {
-		return util.NotFound(c)
+		return nil, err
 	}
 
-	// Set cache headers
-	c.Set("Cache-Control", "public, max-age=60, s-maxage=300, stale-while-revalidate=86400")
-	c.Set("Content-Type", "application/json")
+	// Routes and domains change without a republish, so the canonical URL
+	// is filled in here rather than by the compiler.
+	canonical, canonicalDomainID, err := service.CanonicalURL(ctx, h.domainRepo, page.ID)
+	if err != nil {
+		return nil, err
+	}
+	var stored service.CompiledPage
+	if err := json.Unmarshal(published.CompiledJSON, &stored); err != nil {
+		return nil, err
+	}
+	// Limited links run out without a republish too.
+	compiled, expires, err := service.ApplyLinkLimits(ctx, h.bioRepo, &stored, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	compiled.SEO.SetCanonical(canonical)
+	body, err := json.Marshal(compiled.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	if canonicalDomainID != 0 && canonicalDomainID != domain.ID {
+		entry.CanonicalDomainID = canonicalDomainID
+		entry.SurrogateKeys = append(entry.SurrogateKeys, cdn.DomainKey(canonicalDomainID))
+	}
+	entry.Body = body
+	entry.ETag = pageETag(published.Hash, canonical, compiled, "")
+	entry.NoIndex = compiled.SEO.NoIndex
+	entry.Expires = expires
+
+	// A running experiment is served as one body per variant.
+	experiment, err := h.experimentRepo.GetRunning(ctx, page.ID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return entry, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	entry.ExperimentID = experiment.ID
+	for _, v := range experiment.Variants {
+		body, err := json.Marshal(compiled.WithVariant(experiment.ID, v).Public())
+		if err != nil {
+			return nil, err
+		}
+		entry.Variants = append(entry.Variants, cache.Variant{
+			Key:    v.Key,
+			Weight: v.Weight,
+			Body:   body,
+			ETag:   pageETag(published.Hash, canonical, compiled, fmt.Sprintf("%d:%s", experiment.ID, v.Key)),
+		})
+	}
+	return entry, nil
+}
+
+// pageETag tags a served page by its publish hash plus what resolve fills
+// in without a republish: the canonical URL, the links as their limits
+// leave them and the experiment variant, if any.
+func pageETag(hash, canonical string, p *service.CompiledPage, variant string) string {
+	var served strings.Builder
+	served.WriteString(canonical)
+	served.WriteString("\n" + variant)
+	for _, b := range p.Blocks {
+		if b.Group == nil {
+			continue
+		}
+		for _, l := range b.Group.Links {
+			fmt.Fprintf(&served, "\n%d %s", l.ID, l.URL)
+		}
+	}
+	return `"` + hash + "-" + util.SHA256(served.String())[:16] + `"`
+}
+
+// domain returns the domain serving host, falling back to the system
+// domain for unknown hosts. Disabled domains are reported as pgx.ErrNoRows.
+func (h *PublicHandler) domain(ctx context.Context, host string) (*model.Domain, error) {
+	domain, err := h.domainRepo.GetByHostname(ctx, host)
+	if err != nil {
+		// Try system domain; its pages are addressed by path
+		domain, err = h.domainRepo.GetSystemDomain(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if domain.Status == "disabled" {
+		return nil, pgx.ErrNoRows
+	}
+	return domain, nil
+}
 
-	return c.Send(cache.CompiledJSON)
+// requestHost returns the lower-cased host the visitor asked for, without
+// its port. X-Forwarded-Host is read only from a trusted proxy, such as
+// the web app forwarding short links; anyone else could name a domain
+// they do not own.
+func requestHost(c *fiber.Ctx) (string, error) {
+	host := c.Get("Host")
+	if c.App().Config().EnableTrustedProxyCheck && c.IsProxyTrusted() {
+		if forwarded := c.Get(fiber.HeaderXForwardedHost); forwarded != "" {
+			first, _, _ := strings.Cut(forwarded, ",")
+			host = strings.TrimSpace(first)
+		}
+	}
+	if host == "" {
+		return "", apperr.BadRequest("request.missing_host", "missing host header")
+	}
+	if idx := strings.Index(host, ":"); idx != -1 {
+		host = host[:idx]
+	}
+	return strings.ToLower(host), nil
+}
+
+// maxSitemapURLs is the sitemaps.org limit for a single file.
+const maxSitemapURLs = 50000
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"urlset"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod"`
+}
+
+// Sitemap lists the indexable pages served from the request's domain.
+func (h *PublicHandler) Sitemap(c *fiber.Ctx) error {
+	host, err := requestHost(c)
+	if err != nil {
+		return err
+	}
+	domain, err := h.domain(c.Context(), host)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return service.ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	entries, err := h.domainRepo.ListSitemapEntries(c.Context(), domain.ID, maxSitemapURLs)
+	if err != nil {
+		return err
+	}
+	set := sitemapURLSet{XMLNS: "http://www.sitemaps.org/schemas/sitemap/0.9", URLs: []sitemapURL{}}
+	for _, e := range entries {
+		set.URLs = append(set.URLs, sitemapURL{
+			Loc:     service.PublicURL(domain.Hostname, e.Path),
+			LastMod: e.LastModified.UTC().Format(time.RFC3339),
+		})
+	}
+	body, err := xml.Marshal(set)
+	if err != nil {
+		return err
+	}
+
+	c.Set("Cache-Control", "public, max-age=3600")
+	c.Set("Surrogate-Key", cdn.DomainKey(domain.ID))
+	c.Set("Content-Type", "application/xml; charset=utf-8")
+	return c.Send(append([]byte(xml.Header), body...))
+}
+
+// Robots points crawlers at the domain's sitemap and keeps them out of the
+// API and preview links. Disabled domains disallow everything.
+func (h *PublicHandler) Robots(c *fiber.Ctx) error {
+	host, err := requestHost(c)
+	if err != nil {
+		return err
+	}
+
+	var body string
+	domain, err := h.domain(c.Context(), host)
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		body = "User-agent: *\nDisallow: /\n"
+	case err != nil:
+		return err
+	default:
+		body = "User-agent: *\nDisallow: /api/\nDisallow: /preview/\nDisallow: /r/preview/\n\n" +
+			"Sitemap: " + service.PublicURL(domain.Hostname, "/sitemap.xml") + "\n"
+	}
+
+	c.Set("Cache-Control", "public, max-age=3600")
+	c.Set("Content-Type", "text/plain; charset=utf-8")
+	return c.SendString(body)
+}
+
+// Stylesheet serves a compiled theme stylesheet. The URL names the hash of
+// the content, so it never changes and may be cached forever.
+func (h *PublicHandler) Stylesheet(c *fiber.Ctx) error {
+	hash := c.Params("hash")
+	if !stylesheetHashRegex.MatchString(hash) {
+		return service.ErrNotFound
+	}
+
+	// A client holding this hash already has the content.
+	etag := `"` + hash + `"`
+	if etagMatches(c.Get("If-None-Match"), etag) {
+		c.Set("Cache-Control", "public, max-age=31536000, immutable")
+		c.Set("ETag", etag)
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+
+	css, err := h.themeRepo.GetStylesheet(c.Context(), hash)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return service.ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	c.Set("Cache-Control", "public, max-age=31536000, immutable")
+	c.Set("ETag", etag)
+	c.Set("Content-Type", "text/css; charset=utf-8")
+	return c.Send(css)
+}
+
+var stylesheetHashRegex = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// OGImage serves a generated share image. Like stylesheets, the URL names
+// the hash of what was drawn and may be cached forever.
+func (h *PublicHandler) OGImage(c *fiber.Ctx) error {
+	key := c.Params("key")
+	if !stylesheetHashRegex.MatchString(key) {
+		return service.ErrNotFound
+	}
+
+	etag := `"` + key + `"`
+	if etagMatches(c.Get("If-None-Match"), etag) {
+		c.Set("Cache-Control", "public, max-age=31536000, immutable")
+		c.Set("ETag", etag)
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+
+	// Only blobs of generated assets are public.
+	_, err := h.assetRepo.GetByStorageKey(c.Context(), "generated", key)
+	var data []byte
+	if err == nil {
+		data, err = h.assetRepo.GetBlob(c.Context(), key)
+	}
+	if errors.Is(err, pgx.ErrNoRows) {
+		return service.ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	c.Set("Cache-Control", "public, max-age=31536000, immutable")
+	c.Set("ETag", etag)
+	c.Set("Content-Type", "image/png")
+	return c.Send(data)
+}
+
+// etagMatches reports whether an If-None-Match header matches etag.
+func etagMatches(header, etag string) bool {
+	if header == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
 }
 
 type VerifyPasswordRequest struct {
@@ -94,29 +510,52 @@ type VerifyPasswordRequest struct {
 func (h *PublicHandler) VerifyPassword(c *fiber.Ctx) error {
 	var req VerifyPasswordRequest
 	if err := c.BodyParser(&req); err != nil {
-		return util.BadRequest(c, "invalid request body")
+		return errInvalidBody
+	}
+
+	// The lockout is per page and client so one visitor cannot lock
+	// everybody else out; the page bucket caps distributed guessing.
+	if err := allow(c, h.limiter, ratelimit.PagePasswordPage, strconv.FormatInt(req.PageID, 10)); err != nil {
+		return err
+	}
+	account := fmt.Sprintf("%d:%s", req.PageID, c.IP())
+	if err := allow(c, h.limiter, ratelimit.PagePasswordPageIP, account); err != nil {
+		return err
+	}
+	if err := checkLockout(c, h.lockout, account); err != nil {
+		return err
 	}
 
 	page, err := h.pageRepo.GetByID(c.Context(), req.PageID)
 	if err != nil {
-		return util.NotFound(c)
+		return service.ErrNotFound
 	}
 
 	if page.AccessType != "password" || page.PasswordHash == nil {
-		return util.BadRequest(c, "page is not password protected")
+		return apperr.BadRequest("page.not_protected", "page is not password protected")
 	}
 
 	if !util.CheckPassword(req.Password, *page.PasswordHash) {
-		return util.Err(c, 401, "invalid password")
+		recordFailure(c, h.lockout, account)
+		return apperr.Unauthorized("page.invalid_password", "invalid password").WithField("password", "invalid", "page.invalid_password")
 	}
+	recordSuccess(c, h.lockout, account)
 
 	// TODO: create session and set cookie for 7 days
 
 	// Return compiled JSON
 	cache, err := h.pageRepo.GetPublishCache(c.Context(), page.ID)
 	if err != nil {
-		return util.NotFound(c)
+		return service.ErrNotFound
+	}
+	var stored service.CompiledPage
+	if err := json.Unmarshal(cache.CompiledJSON, &stored); err != nil {
+		return err
+	}
+	compiled, _, err := service.ApplyLinkLimits(c.Context(), h.bioRepo, &stored, time.Now())
+	if err != nil {
+		return err
 	}
 
-	return c.Send(cache.CompiledJSON)
+	return c.JSON(compiled.Public())
 }
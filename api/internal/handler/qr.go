@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"linkbio/internal/middleware"
+	"linkbio/internal/service"
+)
+
+type QRHandler struct {
+	qrService *service.QRService
+}
+
+func NewQRHandler(qrService *service.QRService) *QRHandler {
+	return &QRHandler{qrService: qrService}
+}
+
+// Page draws a QR code for the page's canonical URL.
+func (h *QRHandler) Page(c *fiber.Ctx) error {
+	userID := middleware.GetUserID(c)
+	pageID, err := parseID(c, "id")
+	if err != nil {
+		return errInvalidID
+	}
+	opts, err := qrOptions(c)
+	if err != nil {
+		return err
+	}
+
+	code, err := h.qrService.PageCode(c.Context(), userID, pageID, opts)
+	if err != nil {
+		return err
+	}
+	return sendQR(c, code, fmt.Sprintf("page-%d-qr", pageID), opts.Format)
+}
+
+// Link draws a QR code for the link's tracked redirect.
+func (h *QRHandler) Link(c *fiber.Ctx) error {
+	userID := middleware.GetUserID(c)
+	linkID, err := parseID(c, "id")
+	if err != nil {
+		return errInvalidID
+	}
+	opts, err := qrOptions(c)
+	if err != nil {
+		return err
+	}
+
+	code, err := h.qrService.LinkCode(c.Context(), userID, linkID, opts)
+	if err != nil {
+		return err
+	}
+	return sendQR(c, code, fmt.Sprintf("link-%d-qr", linkID), opts.Format)
+}
+
+// qrOptions reads ?format=png|svg&size=&margin=&level=&fg=&bg=&theme=&avatar=.
+func qrOptions(c *fiber.Ctx) (service.QROptions, error) {
+	opts := service.QROptions{
+		Format:      c.Query("format", "png"),
+		Level:       c.Query("level"),
+		Foreground:  c.Query("fg"),
+		Background:  c.Query("bg"),
+		ThemeColors: c.QueryBool("theme"),
+		Avatar:      c.QueryBool("avatar"),
+	}
+	if v := c.Query("size"); v != "" {
+		size, err := strconv.Atoi(v)
+		if err != nil {
+			return opts, service.ErrQRSize
+		}
+		opts.Size = size
+	}
+	if v := c.Query("margin"); v != "" {
+		margin, err := strconv.Atoi(v)
+		if err != nil {
+			return opts, service.ErrQRMargin
+		}
+		opts.Margin = &margin
+	}
+	return opts, nil
+}
+
+func sendQR(c *fiber.Ctx, code *service.QRCode, name, format string) error {
+	disposition := "inline"
+	if c.QueryBool("download") {
+		disposition = "attachment"
+	}
+	c.Set("Content-Type", code.ContentType)
+	c.Set("Content-Disposition", fmt.Sprintf(`%s; filename="%s.%s"`, disposition, name, format))
+	c.Set("Cache-Control", "private, max-age=60")
+	// The URL the code encodes, for clients showing it next to the image.
+	c.Set("X-QR-URL", code.URL)
+	return c.Send(code.Data)
+}
@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"log"
+
+	"github.com/gofiber/fiber/v2"
+	"linkbio/internal/apperr"
+	"linkbio/internal/middleware"
+	"linkbio/internal/ratelimit"
+)
+
+// allow counts a hit against rule for key and returns a rate limit error
+// when the bucket is exhausted. Store errors fail open.
+func allow(c *fiber.Ctx, limiter *ratelimit.Limiter, rule ratelimit.Rule, key string) error {
+	res, err := limiter.Allow(c.Context(), rule, key)
+	if err != nil {
+		log.Printf("[RateLimit] %s: %v", rule.Name, err)
+		return nil
+	}
+
+	middleware.SetRateLimitHeaders(c, res)
+	if !res.Allowed {
+		return apperr.RateLimited(res.RetryAfter)
+	}
+	return nil
+}
+
+// checkLockout returns a rate limit error if account is locked out.
+func checkLockout(c *fiber.Ctx, lockout *ratelimit.Lockout, account string) error {
+	wait, err := lockout.Check(c.Context(), account)
+	if err != nil {
+		log.Printf("[RateLimit] lockout check: %v", err)
+		return nil
+	}
+	if wait > 0 {
+		return apperr.RateLimited(wait)
+	}
+	return nil
+}
+
+func recordFailure(c *fiber.Ctx, lockout *ratelimit.Lockout, account string) {
+	if _, err := lockout.Fail(c.Context(), account); err != nil {
+		log.Printf("[RateLimit] record failure: %v", err)
+	}
+}
+
+func recordSuccess(c *fiber.Ctx, lockout *ratelimit.Lockout, account string) {
+	if err := lockout.Succeed(c.Context(), account); err != nil {
+		log.Printf("[RateLimit] reset failures: %v", err)
+	}
+}
@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"linkbio/internal/middleware"
+	"linkbio/internal/service"
+	"linkbio/internal/util"
+)
+
+type ScheduleHandler struct {
+	scheduleService *service.ScheduleService
+}
+
+func NewScheduleHandler(scheduleService *service.ScheduleService) *ScheduleHandler {
+	return &ScheduleHandler{scheduleService: scheduleService}
+}
+
+func (h *ScheduleHandler) List(c *fiber.Ctx) error {
+	userID := middleware.GetUserID(c)
+	pageID, err := parseID(c, "id")
+	if err != nil {
+		return errInvalidID
+	}
+
+	schedules, err := h.scheduleService.List(c.Context(), userID, pageID)
+	if err != nil {
+		return err
+	}
+
+	return util.OK(c, schedules)
+}
+
+type CreateScheduleRequest struct {
+	Action string    `json:"action"` // publish | unpublish
+	RunAt  time.Time `json:"run_at"` // RFC 3339
+}
+
+func (h *ScheduleHandler) Create(c *fiber.Ctx) error {
+	userID := middleware.GetUserID(c)
+	pageID, err := parseID(c, "id")
+	if err != nil {
+		return errInvalidID
+	}
+
+	var req CreateScheduleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return errInvalidBody
+	}
+	if req.Action == "" || req.RunAt.IsZero() {
+		return required("action", "run_at")
+	}
+
+	schedule, err := h.scheduleService.Create(c.Context(), userID, pageID, req.Action, req.RunAt)
+	if err != nil {
+		return err
+	}
+
+	return util.Created(c, schedule)
+}
+
+func (h *ScheduleHandler) Cancel(c *fiber.Ctx) error {
+	userID := middleware.GetUserID(c)
+	pageID, err := parseID(c, "id")
+	if err != nil {
+		return errInvalidID
+	}
+	id, err := parseID(c, "scheduleId")
+	if err != nil {
+		return errInvalidID
+	}
+
+	if err := h.scheduleService.Cancel(c.Context(), userID, pageID, id); err != nil {
+		return err
+	}
+
+	return util.OK(c, fiber.Map{"canceled": true})
+}
@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"linkbio/internal/middleware"
+	"linkbio/internal/service"
+	"linkbio/internal/util"
+)
+
+type ShortLinkHandler struct {
+	shortLinkService *service.ShortLinkService
+	analyticsService *service.AnalyticsService
+}
+
+func NewShortLinkHandler(shortLinkService *service.ShortLinkService, analyticsService *service.AnalyticsService) *ShortLinkHandler {
+	return &ShortLinkHandler{shortLinkService: shortLinkService, analyticsService: analyticsService}
+}
+
+func (h *ShortLinkHandler) List(c *fiber.Ctx) error {
+	userID := middleware.GetUserID(c)
+	linkID, err := parseID(c, "id")
+	if err != nil {
+		return errInvalidID
+	}
+
+	links, err := h.shortLinkService.List(c.Context(), userID, linkID)
+	if err != nil {
+		return err
+	}
+
+	return util.OK(c, links)
+}
+
+// Create adds a short link, with a generated code unless an alias is given.
+func (h *ShortLinkHandler) Create(c *fiber.Ctx) error {
+	userID := middleware.GetUserID(c)
+	linkID, err := parseID(c, "id")
+	if err != nil {
+		return errInvalidID
+	}
+
+	var req service.CreateShortLinkReq
+	if err := c.BodyParser(&req); err != nil {
+		return errInvalidBody
+	}
+
+	link, err := h.shortLinkService.Create(c.Context(), userID, linkID, req)
+	if err != nil {
+		return err
+	}
+
+	return util.Created(c, link)
+}
+
+func (h *ShortLinkHandler) Delete(c *fiber.Ctx) error {
+	userID := middleware.GetUserID(c)
+	linkID, err := parseID(c, "id")
+	if err != nil {
+		return errInvalidID
+	}
+	id, err := parseID(c, "shortId")
+	if err != nil {
+		return errInvalidID
+	}
+
+	err = h.shortLinkService.Delete(c.Context(), userID, linkID, id)
+	if err != nil {
+		return err
+	}
+
+	return util.OK(c, fiber.Map{"deleted": true})
+}
+
+// Redirect follows a short code through the click path, counted under
+// ?src= or as a short-link click.
+func (h *ShortLinkHandler) Redirect(c *fiber.Ctx) error {
+	host, err := requestHost(c)
+	if err != nil {
+		return err
+	}
+
+	linkID, err := h.shortLinkService.Resolve(c.Context(), host, c.Params("code"))
+	if err != nil {
+		return err
+	}
+
+	return clickThrough(c, h.analyticsService, linkID, c.Query("src", service.SourceShort), "")
+}
@@ -23,7 +23,7 @@ func (h *ThemeHandler) ListPresets(c *fiber.Ctx) error {
 
 	presets, err := h.themeService.ListPresets(c.Context(), tier)
 	if err != nil {
-		return util.InternalError(c)
+		return err
 	}
 
 	if presets == nil {
@@ -33,13 +33,27 @@ func (h *ThemeHandler) ListPresets(c *fiber.Ctx) error {
 	return util.OK(c, presets)
 }
 
-func (h *ThemeHandler) GetUserCustomTheme(c *fiber.Ctx) error {
+func (h *ThemeHandler) ListCustom(c *fiber.Ctx) error {
 	userID := middleware.GetUserID(c)
 
-	custom, err := h.themeService.GetUserCustomTheme(c.Context(), userID)
+	themes, err := h.themeService.ListCustom(c.Context(), userID)
 	if err != nil {
-		// No custom theme found - return null
-		return util.OK(c, nil)
+		return err
+	}
+
+	return util.OK(c, themes)
+}
+
+func (h *ThemeHandler) GetCustom(c *fiber.Ctx) error {
+	userID := middleware.GetUserID(c)
+	id, err := parseID(c, "id")
+	if err != nil {
+		return errInvalidID
+	}
+
+	custom, err := h.themeService.GetCustom(c.Context(), userID, id)
+	if err != nil {
+		return err
 	}
 
 	return util.OK(c, custom)
@@ -47,6 +61,7 @@ func (h *ThemeHandler) GetUserCustomTheme(c *fiber.Ctx) error {
 
 type CreateCustomRequest struct {
 	PresetID int64           `json:"preset_id"`
+	Name     string          `json:"name"`
 	Patch    json.RawMessage `json:"patch"`
 }
 
@@ -55,35 +70,121 @@ func (h *ThemeHandler) CreateCustom(c *fiber.Ctx) error {
 
 	var req CreateCustomRequest
 	if err := c.BodyParser(&req); err != nil {
-		return util.BadRequest(c, "invalid request body")
+		return errInvalidBody
 	}
 
 	if req.PresetID == 0 {
-		return util.BadRequest(c, "preset_id required")
+		return required("preset_id")
+	}
+
+	custom, err := h.themeService.CreateCustom(c.Context(), userID, service.CustomInput{
+		PresetID: req.PresetID,
+		Name:     req.Name,
+		Patch:    req.Patch,
+	})
+	if err != nil {
+		return err
+	}
+
+	return util.Created(c, custom)
+}
+
+type UpdateCustomRequest struct {
+	Name  *string         `json:"name"`
+	Patch json.RawMessage `json:"patch"`
+}
+
+func (h *ThemeHandler) UpdateCustom(c *fiber.Ctx) error {
+	userID := middleware.GetUserID(c)
+	id, err := parseID(c, "id")
+	if err != nil {
+		return errInvalidID
+	}
+
+	var req UpdateCustomRequest
+	if err := c.BodyParser(&req); err != nil {
+		return errInvalidBody
+	}
+
+	custom, err := h.themeService.UpdateCustom(c.Context(), userID, id, req.Name, req.Patch)
+	if err != nil {
+		return err
+	}
+
+	return util.OK(c, custom)
+}
+
+type DuplicateCustomRequest struct {
+	Name string `json:"name"`
+}
+
+func (h *ThemeHandler) DuplicateCustom(c *fiber.Ctx) error {
+	userID := middleware.GetUserID(c)
+	id, err := parseID(c, "id")
+	if err != nil {
+		return errInvalidID
+	}
+
+	var req DuplicateCustomRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return errInvalidBody
+		}
 	}
 
-	custom, err := h.themeService.CreateOrUpdateCustom(c.Context(), userID, req.PresetID, req.Patch)
+	custom, err := h.themeService.DuplicateCustom(c.Context(), userID, id, req.Name)
 	if err != nil {
-		return util.InternalError(c)
+		return err
 	}
 
 	return util.Created(c, custom)
 }
 
+// DeleteCustom refuses to delete a theme pages still use, unless
+// ?fallback=true switches those pages back to the theme's preset.
 func (h *ThemeHandler) DeleteCustom(c *fiber.Ctx) error {
 	userID := middleware.GetUserID(c)
-	
-	id, err := c.ParamsInt("id")
+	id, err := parseID(c, "id")
 	if err != nil {
-		return util.BadRequest(c, "invalid id")
+		return errInvalidID
 	}
 
-	err = h.themeService.DeleteCustomTheme(c.Context(), int64(id), userID)
+	pageIDs, err := h.themeService.DeleteCustom(c.Context(), userID, id, c.QueryBool("fallback"))
 	if err != nil {
-		return util.InternalError(c)
+		return err
 	}
 
-	return util.OK(c, fiber.Map{"deleted": true})
+	return util.OK(c, fiber.Map{"deleted": true, "fallback_page_ids": pageIDs})
+}
+
+func (h *ThemeHandler) PreviewUpgrade(c *fiber.Ctx) error {
+	userID := middleware.GetUserID(c)
+	id, err := parseID(c, "id")
+	if err != nil {
+		return errInvalidID
+	}
+
+	upgrade, err := h.themeService.PreviewUpgrade(c.Context(), userID, id)
+	if err != nil {
+		return err
+	}
+
+	return util.OK(c, upgrade)
+}
+
+func (h *ThemeHandler) Upgrade(c *fiber.Ctx) error {
+	userID := middleware.GetUserID(c)
+	id, err := parseID(c, "id")
+	if err != nil {
+		return errInvalidID
+	}
+
+	upgrade, err := h.themeService.Upgrade(c.Context(), userID, id)
+	if err != nil {
+		return err
+	}
+
+	return util.OK(c, upgrade)
 }
 
 type ApplyThemeRequest struct {
@@ -94,7 +195,28 @@ type ApplyThemeRequest struct {
 }
 
 func (h *ThemeHandler) Apply(c *fiber.Ctx) error {
-	// This would update the page's theme settings
-	// For now, just return success - actual implementation would use PageService
-	return util.OK(c, fiber.Map{"applied": true})
+	userID := middleware.GetUserID(c)
+
+	var req ApplyThemeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return errInvalidBody
+	}
+	if req.PageID == 0 {
+		return required("page_id")
+	}
+	if req.PresetID == 0 && req.CustomID == nil {
+		return required("preset_id")
+	}
+
+	applied, err := h.themeService.Apply(c.Context(), userID, service.ApplyTheme{
+		PageID:   req.PageID,
+		PresetID: req.PresetID,
+		CustomID: req.CustomID,
+		Mode:     req.Mode,
+	})
+	if err != nil {
+		return err
+	}
+
+	return util.OK(c, applied)
 }
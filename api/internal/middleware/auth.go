@@ -5,6 +5,12 @@ import (
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v5"
+	"linkbio/internal/apperr"
+)
+
+var (
+	errMissingToken = apperr.Unauthorized("auth.unauthorized", "unauthorized")
+	errInvalidToken = apperr.Unauthorized("auth.invalid_token", "invalid token")
 )
 
 func Auth(secret string) fiber.Handler {
@@ -21,7 +27,7 @@ func Auth(secret string) fiber.Handler {
 		}
 
 		if token == "" {
-			return c.Status(401).JSON(fiber.Map{"error": "unauthorized"})
+			return errMissingToken
 		}
 
 		claims := jwt.MapClaims{}
@@ -30,12 +36,12 @@ func Auth(secret string) fiber.Handler {
 		})
 
 		if err != nil || !parsed.Valid {
-			return c.Status(401).JSON(fiber.Map{"error": "invalid token"})
+			return errInvalidToken
 		}
 
 		userID, ok := claims["user_id"].(float64)
 		if !ok {
-			return c.Status(401).JSON(fiber.Map{"error": "invalid token"})
+			return errInvalidToken
 		}
 
 		c.Locals("userID", int64(userID))
@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"linkbio/internal/apperr"
+	"linkbio/internal/ratelimit"
+	"linkbio/internal/util"
+)
+
+// RateLimit throttles requests per client IP. Store errors fail open so a
+// broken store cannot take the auth endpoints down.
+func RateLimit(limiter *ratelimit.Limiter, rule ratelimit.Rule) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		res, err := limiter.Allow(c.Context(), rule, c.IP())
+		if err != nil {
+			log.Printf("[RateLimit] %s: %v", rule.Name, err)
+			return c.Next()
+		}
+
+		SetRateLimitHeaders(c, res)
+		if !res.Allowed {
+			return apperr.RateLimited(res.RetryAfter)
+		}
+		return c.Next()
+	}
+}
+
+// SetRateLimitHeaders writes the RateLimit-* headers for res.
+func SetRateLimitHeaders(c *fiber.Ctx, res *ratelimit.Result) {
+	c.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+	c.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	c.Set("RateLimit-Reset", strconv.Itoa(util.Seconds(time.Until(res.Reset))))
+}
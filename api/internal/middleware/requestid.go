@@ -0,0 +1,11 @@
+package middleware
+
+import "github.com/gofiber/fiber/v2"
+
+// GetRequestID returns the ID assigned by the requestid middleware.
+func GetRequestID(c *fiber.Ctx) string {
+	if id, ok := c.Locals("requestid").(string); ok {
+		return id
+	}
+	return ""
+}
@@ -47,15 +47,44 @@ type ThemePreset struct {
 	IsOfficial   bool            `json:"is_official"`
 	AuthorUserID *int64          `json:"author_user_id"`
 	Config       json.RawMessage `json:"config"`
+	Status       string          `json:"status"`
+	ReviewNote   *string         `json:"review_note"`
+	ReviewedBy   *int64          `json:"reviewed_by"`
+	SubmittedAt  *time.Time      `json:"submitted_at"`
+	PublishedAt  *time.Time      `json:"published_at"`
+	UsageCount   int64           `json:"usage_count"` // pages using the preset
 	CreatedAt    time.Time       `json:"created_at"`
 	UpdatedAt    time.Time       `json:"updated_at"`
+
+	CurrentVersionID *int64  `json:"current_version_id"` // set once published
+	Version          *string `json:"version"`
+}
+
+// ThemePresetVersion is an immutable published config of a preset.
+type ThemePresetVersion struct {
+	ID            int64           `json:"id"`
+	PresetID      int64           `json:"preset_id"`
+	Version       string          `json:"version"`
+	SchemaVersion int             `json:"schema_version"`
+	Config        json.RawMessage `json:"config"`
+	CreatedAt     time.Time       `json:"created_at"`
 }
 
+// Theme preset review workflow: draft -> submitted -> approved, or back to
+// rejected, which the author can edit and resubmit.
+const (
+	PresetDraft     = "draft"
+	PresetSubmitted = "submitted"
+	PresetApproved  = "approved"
+	PresetRejected  = "rejected"
+)
+
 // ThemeCustom
 type ThemeCustom struct {
 	ID              int64           `json:"id"`
 	UserID          int64           `json:"user_id"`
 	BasedOnPresetID int64           `json:"based_on_preset_id"`
+	PresetVersionID *int64          `json:"preset_version_id"` // version the patch applies to
 	Name            *string         `json:"name"`
 	Patch           json.RawMessage `json:"patch"`
 	CompiledConfig  json.RawMessage `json:"compiled_config"`
@@ -64,20 +93,125 @@ type ThemeCustom struct {
 	UpdatedAt       time.Time       `json:"updated_at"`
 }
 
+// PageSchedule is a publish or unpublish queued for a future time.
+type PageSchedule struct {
+	ID          int64      `json:"id"`
+	PageID      int64      `json:"page_id"`
+	UserID      int64      `json:"user_id"`
+	Action      string     `json:"action"`
+	RunAt       time.Time  `json:"run_at"`
+	Status      string     `json:"status"`
+	LockedUntil *time.Time `json:"-"`
+	Attempts    int        `json:"attempts"`
+	LastError   *string    `json:"last_error"`
+	ExecutedAt  *time.Time `json:"executed_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+const (
+	SchedulePublish   = "publish"
+	ScheduleUnpublish = "unpublish"
+
+	SchedulePending  = "pending"
+	ScheduleDone     = "done"
+	ScheduleFailed   = "failed"
+	ScheduleCanceled = "canceled"
+)
+
+// AnalyticsSummary counts a page's views and link clicks since a time.
+type AnalyticsSummary struct {
+	Since   time.Time     `json:"since"`
+	Views   int64         `json:"views"`
+	Clicks  int64         `json:"clicks"`
+	Sources []SourceCount `json:"sources"` // ordered by views, then clicks
+	Links   []LinkClicks  `json:"links"`   // ordered by clicks
+}
+
+// SourceCount is the traffic from one source; "" is traffic without one.
+type SourceCount struct {
+	Source string `json:"source"`
+	Views  int64  `json:"views"`
+	Clicks int64  `json:"clicks"`
+}
+
+type LinkClicks struct {
+	LinkID int64 `json:"link_id"`
+	Clicks int64 `json:"clicks"`
+}
+
+// Experiment tests variants of a page's link titles and block order
+// against each other. A page runs at most one at a time.
+type Experiment struct {
+	ID        int64               `json:"id"`
+	PageID    int64               `json:"page_id"`
+	Name      string              `json:"name"`
+	Status    string              `json:"status"`
+	Variants  []ExperimentVariant `json:"variants"` // the first is the baseline
+	Winner    *string             `json:"winner"`
+	StartedAt time.Time           `json:"started_at"`
+	EndedAt   *time.Time          `json:"ended_at"`
+	CreatedAt time.Time           `json:"created_at"`
+	UpdatedAt time.Time           `json:"updated_at"`
+}
+
+// ExperimentVariant is one arm of an experiment. Weight is relative to the
+// other arms. Links not in LinkTitles keep their title; blocks not in
+// BlockOrder follow the listed ones in their usual order.
+type ExperimentVariant struct {
+	Key        string      `json:"key"`
+	Name       string      `json:"name"`
+	Weight     int         `json:"weight"`
+	LinkTitles []LinkTitle `json:"link_titles,omitempty"`
+	BlockOrder []int64     `json:"block_order,omitempty"`
+}
+
+type LinkTitle struct {
+	LinkID int64  `json:"link_id"`
+	Title  string `json:"title"`
+}
+
+// Promotion is what ending an experiment writes into the draft: the
+// winner's link titles and the blocks' new sort keys by block id.
+type Promotion struct {
+	LinkTitles []LinkTitle
+	SortKeys   map[int64]string
+}
+
+const (
+	ExperimentRunning = "running"
+	ExperimentEnded   = "ended"
+)
+
+// VariantTag ties a view or click to the experiment variant the visitor
+// was shown. The zero value is an untagged hit.
+type VariantTag struct {
+	ExperimentID int64
+	Variant      string
+}
+
+// VariantCount is the traffic one variant of an experiment got.
+type VariantCount struct {
+	Variant string `json:"variant"`
+	Views   int64  `json:"views"`
+	Clicks  int64  `json:"clicks"`
+}
+
 // Asset
 type Asset struct {
-	ID         int64     `json:"id"`
-	UserID     *int64    `json:"user_id"`
-	Scope      string    `json:"scope"`
-	Type       string    `json:"type"`
-	Provider   string    `json:"provider"`
-	StorageKey string    `json:"storage_key"`
-	URL        *string   `json:"url"`
-	MimeType   *string   `json:"mime_type"`
-	SizeBytes  *int64    `json:"size_bytes"`
-	Width      *int      `json:"width"`
-	Height     *int      `json:"height"`
-	CreatedAt  time.Time `json:"created_at"`
+	ID            int64     `json:"id"`
+	UserID        *int64    `json:"user_id"`
+	Scope         string    `json:"scope"`
+	Type          string    `json:"type"`
+	Provider      string    `json:"provider"`
+	StorageKey    string    `json:"storage_key"`
+	URL           *string   `json:"url"`
+	MimeType      *string   `json:"mime_type"`
+	SizeBytes     *int64    `json:"size_bytes"`
+	Width         *int      `json:"width"`
+	Height        *int      `json:"height"`
+	DominantColor *string   `json:"dominant_color"` // "#rrggbb", computed on upload; older images are backfilled by migrate up
+	CreatedAt     time.Time `json:"created_at"`
 }
 
 // BioPage
@@ -119,6 +253,12 @@ type PageRoute struct {
 	CreatedAt         time.Time `json:"created_at"`
 }
 
+// SitemapEntry is an indexable page on a domain.
+type SitemapEntry struct {
+	Path         string
+	LastModified time.Time
+}
+
 // LinkGroup
 type LinkGroup struct {
 	ID            int64           `json:"id"`
@@ -127,21 +267,46 @@ type LinkGroup struct {
 	LayoutType    string          `json:"layout_type"`
 	LayoutConfig  json.RawMessage `json:"layout_config"`
 	StyleOverride json.RawMessage `json:"style_override"`
+	UTMTemplate   *string         `json:"utm_template"`
 	CreatedAt     time.Time       `json:"created_at"`
 	UpdatedAt     time.Time       `json:"updated_at"`
 }
 
 // Link
 type Link struct {
-	ID          int64     `json:"id"`
-	GroupID     int64     `json:"group_id"`
-	Title       string    `json:"title"`
-	URL         string    `json:"url"`
-	IconAssetID *int64    `json:"icon_asset_id"`
-	SortKey     string    `json:"sort_key"`
-	IsActive    bool      `json:"is_active"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID          int64   `json:"id"`
+	GroupID     int64   `json:"group_id"`
+	Title       string  `json:"title"`
+	URL         string  `json:"url"`
+	IconAssetID *int64  `json:"icon_asset_id"`
+	SortKey     string  `json:"sort_key"`
+	IsActive    bool    `json:"is_active"`
+	UTMTemplate *string `json:"utm_template"`
+	// Rules is the ordered targeting rule list; URL is the fallback.
+	Rules json.RawMessage `json:"rules"`
+	// A link is exhausted once ExpiresAt passes or ClickCount reaches
+	// MaxClicks. It is then hidden, or sends clicks to FallbackURL.
+	ExpiresAt   *time.Time `json:"expires_at"`
+	MaxClicks   *int       `json:"max_clicks"`
+	ClickCount  int        `json:"click_count"`
+	Fallback    string     `json:"fallback"` // hide|redirect
+	FallbackURL *string    `json:"fallback_url"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+const (
+	LinkFallbackHide     = "hide"
+	LinkFallbackRedirect = "redirect"
+)
+
+// ShortLink is a short code redirecting to one link, served at /s/{code}.
+type ShortLink struct {
+	ID        int64     `json:"id"`
+	LinkID    int64     `json:"link_id"`
+	Code      string    `json:"code"`
+	IsCustom  bool      `json:"is_custom"` // chosen by the user
+	CreatedAt time.Time `json:"created_at"`
 }
 
 // Block
@@ -161,6 +326,7 @@ type Block struct {
 type PagePublishCache struct {
 	PageID       int64           `json:"page_id"`
 	CompiledJSON json.RawMessage `json:"compiled_json"`
+	Hash         string          `json:"hash"`
 	PublishedAt  time.Time       `json:"published_at"`
 	UpdatedAt    time.Time       `json:"updated_at"`
 }
@@ -173,3 +339,12 @@ type PageAccessSession struct {
 	ExpiresAt time.Time `json:"expires_at"`
 	CreatedAt time.Time `json:"created_at"`
 }
+
+// PageAggregate is a page with all of its blocks, link groups and links,
+// loaded together so callers never query per group.
+type PageAggregate struct {
+	Page   *BioPage
+	Blocks []*Block          // ordered by sort_key
+	Groups []*LinkGroup      // ordered by id
+	Links  map[int64][]*Link // group ID -> links ordered by sort_key
+}
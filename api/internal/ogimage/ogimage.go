@@ -0,0 +1,236 @@
+// Package ogimage draws the share card shown when a page is linked on
+// social networks: the avatar, name and bio on the page's theme.
+package ogimage
+
+import (
+	"bytes"
+	_ "embed"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"unicode"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+	"linkbio/internal/theme"
+)
+
+// Open Graph's recommended size.
+const (
+	Width  = 1200
+	Height = 630
+)
+
+// Version names the layout. Bump it when the drawing changes so cached
+// cards are redrawn.
+const Version = 2
+
+const (
+	margin     = 96
+	avatarSize = 240
+	ringWidth  = 8
+	textLeft   = margin + avatarSize + 64
+	textWidth  = Width - textLeft - margin
+	barHeight  = 16
+)
+
+// Card is what goes on the image.
+type Card struct {
+	Name     string
+	Username string
+	Bio      string
+	// Avatar is drawn in a circle; without one the name's initial is.
+	Avatar image.Image
+	// Wallpaper replaces the palette background when set.
+	Wallpaper image.Image
+	Palette   theme.Palette
+}
+
+// DejaVu Sans covers Vietnamese, which the Go fonts in x/image do not.
+var (
+	//go:embed fonts/DejaVuSans.ttf
+	regularTTF []byte
+	//go:embed fonts/DejaVuSans-Bold.ttf
+	boldTTF []byte
+)
+
+type Renderer struct {
+	regular *sfnt.Font
+	bold    *sfnt.Font
+}
+
+// New loads the fonts to draw with. Nil uses the embedded DejaVu Sans.
+func New(regular, bold []byte) (*Renderer, error) {
+	if regular == nil {
+		regular = regularTTF
+	}
+	if bold == nil {
+		bold = boldTTF
+	}
+	r, err := sfnt.Parse(regular)
+	if err != nil {
+		return nil, err
+	}
+	b, err := sfnt.Parse(bold)
+	if err != nil {
+		return nil, err
+	}
+	return &Renderer{regular: r, bold: b}, nil
+}
+
+// Render draws the card as a PNG.
+func (r *Renderer) Render(card Card) ([]byte, error) {
+	p := card.Palette
+	img := image.NewRGBA(image.Rect(0, 0, Width, Height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(p.Background), image.Point{}, draw.Src)
+	if card.Wallpaper != nil {
+		drawCover(img, img.Bounds(), card.Wallpaper)
+		if p.Dim > 0 {
+			dim := color.NRGBA{A: uint8(p.Dim * 255)}
+			draw.Draw(img, img.Bounds(), image.NewUniform(dim), image.Point{}, draw.Over)
+		}
+		if p.Overlay.A > 0 {
+			draw.Draw(img, img.Bounds(), image.NewUniform(p.Overlay), image.Point{}, draw.Over)
+		}
+	}
+	draw.Draw(img, image.Rect(0, Height-barHeight, Width, Height), image.NewUniform(p.Primary), image.Point{}, draw.Src)
+
+	if err := r.drawAvatar(img, card); err != nil {
+		return nil, err
+	}
+	if err := r.drawText(img, card); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (r *Renderer) drawAvatar(img *image.RGBA, card Card) error {
+	p := card.Palette
+	cx := float64(margin + avatarSize/2)
+	cy := float64(Height / 2)
+	fillCircle(img, image.NewUniform(p.Primary), cx, cy, avatarSize/2+ringWidth)
+
+	box := image.Rect(margin, Height/2-avatarSize/2, margin+avatarSize, Height/2+avatarSize/2)
+	if card.Avatar != nil {
+		avatar := image.NewRGBA(image.Rect(0, 0, avatarSize, avatarSize))
+		drawCover(avatar, avatar.Bounds(), card.Avatar)
+		draw.DrawMask(img, box, avatar, image.Point{}, circleMask(avatarSize), image.Point{}, draw.Over)
+		return nil
+	}
+
+	// The initial, in whichever of black or white reads better on primary.
+	initial := "?"
+	for _, ch := range strings.TrimSpace(card.Name + card.Username) {
+		initial = strings.ToUpper(string(ch))
+		break
+	}
+	ink := color.NRGBA{255, 255, 255, 255}
+	if luma(p.Primary) > 0.6 {
+		ink = color.NRGBA{0, 0, 0, 255}
+	}
+	t, err := newTypesetter(r.bold, 120)
+	if err != nil {
+		return err
+	}
+	w := t.measure(initial)
+	dot := fixed.Point26_6{
+		X: fixed.I(int(cx)) - w/2,
+		Y: fixed.I(int(cy)) + t.em(0.36),
+	}
+	t.draw(img, image.NewUniform(ink), dot, initial)
+	return nil
+}
+
+func (r *Renderer) drawText(img *image.RGBA, card Card) error {
+	p := card.Palette
+	name, err := newTypesetter(r.bold, 68)
+	if err != nil {
+		return err
+	}
+	handle, err := newTypesetter(r.regular, 34)
+	if err != nil {
+		return err
+	}
+	bio, err := newTypesetter(r.regular, 34)
+	if err != nil {
+		return err
+	}
+
+	type line struct {
+		t      *typesetter
+		text   string
+		ink    color.NRGBA
+		height int
+	}
+	var lines []line
+	width := fixed.I(textWidth)
+	for _, s := range name.wrap(clean(card.Name), width, 2) {
+		lines = append(lines, line{name, s, p.Text, 80})
+	}
+	if card.Username != "" {
+		lines = append(lines, line{handle, handle.ellipsize("@"+clean(card.Username), width), p.Muted, 52})
+	}
+	if b := clean(card.Bio); b != "" {
+		lines = append(lines, line{nil, "", p.Text, 20}) // spacing
+		for _, s := range bio.wrap(b, width, 3) {
+			lines = append(lines, line{bio, s, p.Text, 46})
+		}
+	}
+
+	// Center the block vertically, measuring baselines from each line's top.
+	total := 0
+	for _, l := range lines {
+		total += l.height
+	}
+	y := (Height-barHeight)/2 - total/2
+	for _, l := range lines {
+		y += l.height
+		if l.t == nil {
+			continue
+		}
+		baseline := y - l.height/5
+		l.t.draw(img, image.NewUniform(l.ink), fixed.P(textLeft, baseline), l.text)
+	}
+	return nil
+}
+
+// drawCover scales src to cover r, cropping the overflow evenly.
+func drawCover(dst draw.Image, r image.Rectangle, src image.Image) {
+	sb := src.Bounds()
+	if sb.Empty() {
+		return
+	}
+	crop := sb
+	if sb.Dx()*r.Dy() > sb.Dy()*r.Dx() {
+		w := sb.Dy() * r.Dx() / r.Dy()
+		crop.Min.X += (sb.Dx() - w) / 2
+		crop.Max.X = crop.Min.X + w
+	} else {
+		h := sb.Dx() * r.Dy() / r.Dx()
+		crop.Min.Y += (sb.Dy() - h) / 2
+		crop.Max.Y = crop.Min.Y + h
+	}
+	draw.CatmullRom.Scale(dst, r, src, crop, draw.Over, nil)
+}
+
+// clean drops control characters and collapses whitespace.
+func clean(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return ' '
+		}
+		return r
+	}, s)
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func luma(c color.NRGBA) float64 {
+	return (0.299*float64(c.R) + 0.587*float64(c.G) + 0.114*float64(c.B)) / 255
+}
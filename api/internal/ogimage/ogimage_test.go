@@ -0,0 +1,110 @@
+package ogimage
+
+import (
+	"bytes"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/unicode/norm"
+	"linkbio/internal/theme"
+)
+
+// vietnamese returns every Vietnamese letter in both cases, with each tone.
+func vietnamese() string {
+	var b strings.Builder
+	b.WriteString("bcdđghklmnpqrstvx")
+	for _, v := range "aăâeêioôơuưy" {
+		b.WriteRune(v)
+		for _, tone := range "̣̀́̉̃" {
+			b.WriteString(norm.NFC.String(string(v) + string(tone)))
+		}
+	}
+	return b.String() + strings.ToUpper(b.String())
+}
+
+func TestFontsCoverVietnamese(t *testing.T) {
+	r, err := New(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, f := range map[string]*typesetter{"regular": mustTypesetter(t, r, false), "bold": mustTypesetter(t, r, true)} {
+		for _, ch := range vietnamese() {
+			if !f.has(ch) {
+				t.Errorf("%s font lacks %q (%U)", name, ch, ch)
+			}
+		}
+	}
+}
+
+func TestTypesetterComposesMarks(t *testing.T) {
+	r, err := New(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := mustTypesetter(t, r, false)
+
+	nfc := "Nguyễn Thị Ánh"
+	if got := string(ts.runes(norm.NFD.String(nfc))); got != nfc {
+		t.Errorf("runes(NFD) = %q, want %q", got, nfc)
+	}
+	if got := string(ts.runes("a中b")); got != "a?b" {
+		t.Errorf("runes with a missing glyph = %q, want %q", got, "a?b")
+	}
+}
+
+func TestRender(t *testing.T) {
+	r, err := New(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := r.Render(Card{
+		Name:     "Nguyễn Thị Ánh Dương",
+		Username: "anhduong",
+		Bio:      "Nhiếp ảnh gia ở Hà Nội. Chụp cưới, kỷ yếu và sự kiện.",
+		Palette: theme.Palette{
+			Background: color.NRGBA{250, 250, 250, 255},
+			Primary:    color.NRGBA{37, 99, 235, 255},
+			Text:       color.NRGBA{17, 24, 39, 255},
+			Muted:      color.NRGBA{107, 114, 128, 255},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(bytes.NewReader(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != Width || b.Dy() != Height {
+		t.Errorf("size = %dx%d, want %dx%d", b.Dx(), b.Dy(), Width, Height)
+	}
+
+	// Some text was drawn in the text color.
+	inked := false
+	for y := 0; y < Height && !inked; y++ {
+		for x := textLeft; x < Width-margin; x++ {
+			if cr, cg, cb, _ := img.At(x, y).RGBA(); cr>>8 == 17 && cg>>8 == 24 && cb>>8 == 39 {
+				inked = true
+				break
+			}
+		}
+	}
+	if !inked {
+		t.Error("no text drawn")
+	}
+}
+
+func mustTypesetter(t *testing.T, r *Renderer, bold bool) *typesetter {
+	t.Helper()
+	f := r.regular
+	if bold {
+		f = r.bold
+	}
+	ts, err := newTypesetter(f, 34)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ts
+}
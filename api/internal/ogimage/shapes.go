@@ -0,0 +1,60 @@
+package ogimage
+
+import (
+	"image"
+	"image/draw"
+
+	"golang.org/x/image/vector"
+)
+
+type pt struct{ x, y float64 }
+
+// fillCircle draws a disc centered on (cx, cy).
+func fillCircle(dst draw.Image, src image.Image, cx, cy, r float64) {
+	fillPath(dst, src, func(z *vector.Rasterizer, o pt) {
+		circlePath(z, cx-o.x, cy-o.y, r)
+	}, image.Rect(int(cx-r)-1, int(cy-r)-1, int(cx+r)+2, int(cy+r)+2))
+}
+
+// circleMask is an anti-aliased disc filling a d×d square.
+func circleMask(d int) *image.Alpha {
+	mask := image.NewAlpha(image.Rect(0, 0, d, d))
+	z := vector.NewRasterizer(d, d)
+	circlePath(z, float64(d)/2, float64(d)/2, float64(d)/2)
+	z.Draw(mask, mask.Bounds(), image.Opaque, image.Point{})
+	return mask
+}
+
+// circlePath adds a circle made of four cubic Béziers.
+func circlePath(z *vector.Rasterizer, cx, cy, r float64) {
+	const k = 0.5522847498 // control distance for a quarter circle
+	c := func(x, y float64) (float32, float32) { return float32(cx + x*r), float32(cy + y*r) }
+	z.MoveTo(c(1, 0))
+	cubeTo(z, c, 1, k, k, 1, 0, 1)
+	cubeTo(z, c, -k, 1, -1, k, -1, 0)
+	cubeTo(z, c, -1, -k, -k, -1, 0, -1)
+	cubeTo(z, c, k, -1, 1, -k, 1, 0)
+	z.ClosePath()
+}
+
+func cubeTo(z *vector.Rasterizer, c func(x, y float64) (float32, float32), x1, y1, x2, y2, x3, y3 float64) {
+	bx, by := c(x1, y1)
+	cx, cy := c(x2, y2)
+	dx, dy := c(x3, y3)
+	z.CubeTo(bx, by, cx, cy, dx, dy)
+}
+
+// fillPath rasterizes the path added by build, in coordinates relative to
+// box, and draws src through it.
+func fillPath(dst draw.Image, src image.Image, build func(z *vector.Rasterizer, origin pt), box image.Rectangle) {
+	if box.Empty() {
+		return
+	}
+	z := vector.NewRasterizer(box.Dx(), box.Dy())
+	build(z, pt{float64(box.Min.X), float64(box.Min.Y)})
+	mask := image.NewAlpha(image.Rect(0, 0, box.Dx(), box.Dy()))
+	z.Draw(mask, mask.Bounds(), image.Opaque, image.Point{})
+
+	r := box.Intersect(dst.Bounds())
+	draw.DrawMask(dst, r, src, r.Min, mask, r.Min.Sub(box.Min), draw.Over)
+}
@@ -0,0 +1,126 @@
+package ogimage
+
+import (
+	"image"
+	"image/draw"
+	"strings"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/text/unicode/norm"
+)
+
+// typesetter draws text in one font and size. Runes the font lacks are
+// drawn as a question mark.
+type typesetter struct {
+	font *sfnt.Font
+	face font.Face
+	size float64
+	buf  sfnt.Buffer
+}
+
+func newTypesetter(f *sfnt.Font, size float64) (*typesetter, error) {
+	face, err := opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingNone})
+	if err != nil {
+		return nil, err
+	}
+	return &typesetter{font: f, face: face, size: size}, nil
+}
+
+func (t *typesetter) has(r rune) bool {
+	i, err := t.font.GlyphIndex(&t.buf, r)
+	return err == nil && i != 0
+}
+
+// runes returns s composed to NFC, so letters typed as a base and marks
+// use the font's precomposed glyphs.
+func (t *typesetter) runes(s string) []rune {
+	out := []rune(norm.NFC.String(s))
+	for i, r := range out {
+		if !t.has(r) {
+			out[i] = '?'
+		}
+	}
+	return out
+}
+
+func (t *typesetter) em(v float64) fixed.Int26_6 {
+	return fixed.Int26_6(v * t.size * 64)
+}
+
+// measure returns the width of s.
+func (t *typesetter) measure(s string) fixed.Int26_6 {
+	var w fixed.Int26_6
+	for _, r := range t.runes(s) {
+		adv, _ := t.face.GlyphAdvance(r)
+		w += adv
+	}
+	return w
+}
+
+// draw writes s with its baseline starting at dot and returns the dot
+// after it.
+func (t *typesetter) draw(dst draw.Image, src image.Image, dot fixed.Point26_6, s string) fixed.Point26_6 {
+	for _, r := range t.runes(s) {
+		dr, mask, maskp, adv, ok := t.face.Glyph(dot, r)
+		if ok {
+			draw.DrawMask(dst, dr, src, image.Point{}, mask, maskp, draw.Over)
+		}
+		dot.X += adv
+	}
+	return dot
+}
+
+// wrap breaks s into at most maxLines lines no wider than width, ending
+// the last one with an ellipsis when text is left over.
+func (t *typesetter) wrap(s string, width fixed.Int26_6, maxLines int) []string {
+	words := strings.Fields(s)
+	var lines []string
+	for len(words) > 0 {
+		if len(lines) == maxLines-1 {
+			return append(lines, t.ellipsize(strings.Join(words, " "), width))
+		}
+		line := words[0]
+		words = words[1:]
+		if t.measure(line) > width {
+			// A single word wider than the line is broken where it overflows.
+			head, tail := t.split(line, width)
+			line = head
+			words = append([]string{tail}, words...)
+		} else {
+			for len(words) > 0 && t.measure(line+" "+words[0]) <= width {
+				line += " " + words[0]
+				words = words[1:]
+			}
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// split returns the longest prefix of word that fits, and the rest.
+func (t *typesetter) split(word string, width fixed.Int26_6) (string, string) {
+	runes := []rune(word)
+	n := 1
+	for n < len(runes) && t.measure(string(runes[:n+1])) <= width {
+		n++
+	}
+	return string(runes[:n]), string(runes[n:])
+}
+
+func (t *typesetter) ellipsize(s string, width fixed.Int26_6) string {
+	if t.measure(s) <= width {
+		return s
+	}
+	runes := []rune(strings.TrimSpace(s))
+	for len(runes) > 0 {
+		runes = runes[:len(runes)-1]
+		candidate := strings.TrimSpace(string(runes)) + "…"
+		if t.measure(candidate) <= width {
+			return candidate
+		}
+	}
+	return "…"
+}
@@ -0,0 +1,233 @@
+// Package qr draws QR codes as PNG or SVG, optionally with a logo in the
+// middle.
+package qr
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"strings"
+
+	"github.com/skip2/go-qrcode"
+	"golang.org/x/image/draw"
+)
+
+// Level is the error correction level: the share of the code that can be
+// damaged, or covered, and still scan.
+type Level string
+
+const (
+	LevelL Level = "L" // 7%
+	LevelM Level = "M" // 15%
+	LevelQ Level = "Q" // 25%
+	LevelH Level = "H" // 30%
+)
+
+var levels = map[Level]qrcode.RecoveryLevel{
+	LevelL: qrcode.Low,
+	LevelM: qrcode.Medium,
+	LevelQ: qrcode.High,
+	LevelH: qrcode.Highest,
+}
+
+// ValidLevel reports whether l is one of the four levels.
+func ValidLevel(l Level) bool {
+	_, ok := levels[l]
+	return ok
+}
+
+// logoFraction is the logo's diameter relative to the code, small enough
+// for level Q to recover what it covers.
+const logoFraction = 0.22
+
+// ErrTooSmall means the requested size leaves less than a pixel per module.
+var ErrTooSmall = errors.New("qr: size too small for content")
+
+type Options struct {
+	// Size is the width and height in pixels.
+	Size int
+	// Margin is the quiet zone around the code, in modules.
+	Margin     int
+	Level      Level
+	Foreground color.NRGBA
+	Background color.NRGBA
+	// Logo is drawn in a disc at the center. Codes with a logo are encoded
+	// at level Q or higher so the modules it covers can be recovered.
+	Logo image.Image
+}
+
+// Code is an encoded QR code ready to draw.
+type Code struct {
+	modules [][]bool
+	opts    Options
+}
+
+func Encode(content string, opts Options) (*Code, error) {
+	level := opts.Level
+	if !ValidLevel(level) {
+		level = LevelM
+	}
+	if opts.Logo != nil && (level == LevelL || level == LevelM) {
+		level = LevelQ
+	}
+	q, err := qrcode.New(content, levels[level])
+	if err != nil {
+		return nil, err
+	}
+	q.DisableBorder = true
+	opts.Level = level
+	return &Code{modules: q.Bitmap(), opts: opts}, nil
+}
+
+// Level is the level the code was encoded at.
+func (c *Code) Level() Level {
+	return c.opts.Level
+}
+
+// PNG draws the code with whole pixels per module, centered in the
+// requested size.
+func (c *Code) PNG() ([]byte, error) {
+	n := len(c.modules)
+	total := n + 2*c.opts.Margin
+	scale := c.opts.Size / total
+	if scale < 1 {
+		return nil, ErrTooSmall
+	}
+	offset := (c.opts.Size-scale*total)/2 + scale*c.opts.Margin
+
+	img := image.NewNRGBA(image.Rect(0, 0, c.opts.Size, c.opts.Size))
+	draw.Draw(img, img.Bounds(), image.NewUniform(c.opts.Background), image.Point{}, draw.Src)
+	fg := image.NewUniform(c.opts.Foreground)
+	for y, row := range c.modules {
+		for x, dark := range row {
+			if dark {
+				r := image.Rect(offset+x*scale, offset+y*scale, offset+(x+1)*scale, offset+(y+1)*scale)
+				draw.Draw(img, r, fg, image.Point{}, draw.Src)
+			}
+		}
+	}
+
+	if c.opts.Logo != nil {
+		width := float64(n * scale)
+		center := float64(offset) + width/2
+		d := int(width * logoFraction)
+		pad := float64(scale)
+		fillDisc(img, c.opts.Background, center, center, float64(d)/2+pad)
+		logo := circle(c.opts.Logo, d)
+		at := int(math.Round(center - float64(d)/2))
+		draw.Draw(img, image.Rect(at, at, at+d, at+d), logo, image.Point{}, draw.Over)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// SVG draws the code in module units scaled to the requested size. A logo
+// is embedded as a PNG so the file stands alone.
+func (c *Code) SVG() ([]byte, error) {
+	n := len(c.modules)
+	m := c.opts.Margin
+	total := n + 2*m
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`,
+		c.opts.Size, c.opts.Size, total, total)
+	fmt.Fprintf(&b, `<rect width="%d" height="%d" fill="%s"/>`, total, total, hex(c.opts.Background))
+
+	// One path, with runs of dark modules merged into a single rectangle.
+	fmt.Fprintf(&b, `<path fill="%s" d="`, hex(c.opts.Foreground))
+	for y, row := range c.modules {
+		for x := 0; x < n; {
+			if !row[x] {
+				x++
+				continue
+			}
+			run := 1
+			for x+run < n && row[x+run] {
+				run++
+			}
+			fmt.Fprintf(&b, "M%d %dh%dv1h-%dz", x+m, y+m, run, run)
+			x += run
+		}
+	}
+	b.WriteString(`"/>`)
+
+	if c.opts.Logo != nil {
+		center := float64(m) + float64(n)/2
+		d := float64(n) * logoFraction
+		fmt.Fprintf(&b, `<circle cx="%g" cy="%g" r="%g" fill="%s" shape-rendering="auto"/>`,
+			center, center, d/2+1, hex(c.opts.Background))
+		var logo bytes.Buffer
+		if err := png.Encode(&logo, circle(c.opts.Logo, 256)); err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&b, `<image x="%g" y="%g" width="%g" height="%g" href="data:image/png;base64,%s"/>`,
+			center-d/2, center-d/2, d, d, base64.StdEncoding.EncodeToString(logo.Bytes()))
+	}
+	b.WriteString(`</svg>`)
+	return []byte(b.String()), nil
+}
+
+// circle scales src to cover a d×d square and cuts it to a circle.
+func circle(src image.Image, d int) *image.NRGBA {
+	out := image.NewNRGBA(image.Rect(0, 0, d, d))
+	sb := src.Bounds()
+	crop := sb
+	if sb.Dx() > sb.Dy() {
+		crop.Min.X += (sb.Dx() - sb.Dy()) / 2
+		crop.Max.X = crop.Min.X + sb.Dy()
+	} else {
+		crop.Min.Y += (sb.Dy() - sb.Dx()) / 2
+		crop.Max.Y = crop.Min.Y + sb.Dx()
+	}
+	draw.CatmullRom.Scale(out, out.Bounds(), src, crop, draw.Src, nil)
+
+	r := float64(d) / 2
+	for y := 0; y < d; y++ {
+		for x := 0; x < d; x++ {
+			cov := coverage(float64(x)+0.5-r, float64(y)+0.5-r, r)
+			i := out.PixOffset(x, y) + 3
+			out.Pix[i] = uint8(float64(out.Pix[i]) * cov)
+		}
+	}
+	return out
+}
+
+func fillDisc(img *image.NRGBA, c color.NRGBA, cx, cy, r float64) {
+	bounds := image.Rect(int(cx-r)-1, int(cy-r)-1, int(cx+r)+2, int(cy+r)+2).Intersect(img.Bounds())
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			cov := coverage(float64(x)+0.5-cx, float64(y)+0.5-cy, r)
+			if cov == 0 {
+				continue
+			}
+			px := c
+			px.A = uint8(float64(c.A) * cov)
+			img.Set(x, y, blend(img.NRGBAAt(x, y), px))
+		}
+	}
+}
+
+// coverage approximates how much of the pixel at offset (dx, dy) from a
+// circle's center lies inside it.
+func coverage(dx, dy, r float64) float64 {
+	return math.Max(0, math.Min(1, r-math.Hypot(dx, dy)+0.5))
+}
+
+func blend(dst, src color.NRGBA) color.NRGBA {
+	a := float64(src.A) / 255
+	mix := func(d, s uint8) uint8 { return uint8(math.Round(float64(s)*a + float64(d)*(1-a))) }
+	return color.NRGBA{mix(dst.R, src.R), mix(dst.G, src.G), mix(dst.B, src.B), 255 - uint8(float64(255-dst.A)*(1-a))}
+}
+
+func hex(c color.NRGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
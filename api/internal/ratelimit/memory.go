@@ -0,0 +1,98 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepEvery controls how many Incr calls happen between sweeps of expired
+// buckets.
+const sweepEvery = 1024
+
+type bucket struct {
+	count int
+	reset time.Time
+}
+
+// MemoryStore keeps counters in process memory. It is only correct for a
+// single API instance.
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	locks   map[string]time.Time
+	ops     int
+	now     func() time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets: make(map[string]*bucket),
+		locks:   make(map[string]time.Time),
+		now:     time.Now,
+	}
+}
+
+func (s *MemoryStore) Incr(ctx context.Context, key string, window time.Duration) (int, time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.ops++
+	if s.ops%sweepEvery == 0 {
+		s.sweep(now)
+	}
+
+	b, ok := s.buckets[key]
+	if !ok || !b.reset.After(now) {
+		b = &bucket{reset: now.Add(window)}
+		s.buckets[key] = b
+	}
+	b.count++
+	return b.count, b.reset, nil
+}
+
+func (s *MemoryStore) Reset(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.buckets, key)
+	return nil
+}
+
+func (s *MemoryStore) Lock(ctx context.Context, key string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Keep the later deadline, like the Postgres store.
+	if current, ok := s.locks[key]; ok && current.After(until) {
+		return nil
+	}
+	s.locks[key] = until
+	return nil
+}
+
+func (s *MemoryStore) LockedUntil(ctx context.Context, key string) (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	until, ok := s.locks[key]
+	if !ok {
+		return time.Time{}, nil
+	}
+	if !until.After(s.now()) {
+		delete(s.locks, key)
+		return time.Time{}, nil
+	}
+	return until, nil
+}
+
+func (s *MemoryStore) sweep(now time.Time) {
+	for k, b := range s.buckets {
+		if !b.reset.After(now) {
+			delete(s.buckets, k)
+		}
+	}
+	for k, until := range s.locks {
+		if !until.After(now) {
+			delete(s.locks, k)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Store keeps fixed-window counters and lockouts. Implementations must be
+// safe for concurrent use.
+type Store interface {
+	// Incr adds one hit to the bucket for key and returns the hit count in
+	// the current window and when that window resets.
+	Incr(ctx context.Context, key string, window time.Duration) (int, time.Time, error)
+	// Reset removes the bucket for key.
+	Reset(ctx context.Context, key string) error
+	// Lock blocks key until the given time.
+	Lock(ctx context.Context, key string, until time.Time) error
+	// LockedUntil returns when the lock on key expires, or the zero time
+	// if key is not locked.
+	LockedUntil(ctx context.Context, key string) (time.Time, error)
+}
+
+// Rule is a fixed-window limit.
+type Rule struct {
+	Name   string
+	Limit  int
+	Window time.Duration
+}
+
+// Result describes the state of a bucket after a hit.
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Time
+	RetryAfter time.Duration
+}
+
+type Limiter struct {
+	store Store
+	now   func() time.Time
+}
+
+func NewLimiter(store Store) *Limiter {
+	return &Limiter{store: store, now: time.Now}
+}
+
+// Allow counts a hit against rule for key.
+func (l *Limiter) Allow(ctx context.Context, rule Rule, key string) (*Result, error) {
+	count, reset, err := l.store.Incr(ctx, bucketKey(rule.Name, key), rule.Window)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &Result{
+		Allowed:   count <= rule.Limit,
+		Limit:     rule.Limit,
+		Remaining: rule.Limit - count,
+		Reset:     reset,
+	}
+	if res.Remaining < 0 {
+		res.Remaining = 0
+	}
+	if !res.Allowed {
+		res.RetryAfter = reset.Sub(l.now())
+	}
+	return res, nil
+}
+
+// LockoutPolicy locks an account after repeated failures. The first lock
+// lasts BaseDelay and doubles on every further failure up to MaxDelay.
+type LockoutPolicy struct {
+	Name        string
+	MaxFailures int
+	Window      time.Duration // how long failures are remembered
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+type Lockout struct {
+	store  Store
+	policy LockoutPolicy
+	now    func() time.Time
+}
+
+func NewLockout(store Store, policy LockoutPolicy) *Lockout {
+	return &Lockout{store: store, policy: policy, now: time.Now}
+}
+
+// Check returns how long account is still locked for, or zero.
+func (l *Lockout) Check(ctx context.Context, account string) (time.Duration, error) {
+	until, err := l.store.LockedUntil(ctx, lockKey(l.policy.Name, account))
+	if err != nil {
+		return 0, err
+	}
+	if d := until.Sub(l.now()); d > 0 {
+		return d, nil
+	}
+	return 0, nil
+}
+
+// Fail records a failed attempt and locks the account once MaxFailures is
+// reached. It returns the lock duration, or zero if the account is not locked.
+func (l *Lockout) Fail(ctx context.Context, account string) (time.Duration, error) {
+	failures, _, err := l.store.Incr(ctx, bucketKey(l.policy.Name, account), l.policy.Window)
+	if err != nil {
+		return 0, err
+	}
+	if failures < l.policy.MaxFailures {
+		return 0, nil
+	}
+
+	delay := l.delay(failures - l.policy.MaxFailures)
+	if err := l.store.Lock(ctx, lockKey(l.policy.Name, account), l.now().Add(delay)); err != nil {
+		return 0, err
+	}
+	return delay, nil
+}
+
+// Succeed forgets previous failures for account.
+func (l *Lockout) Succeed(ctx context.Context, account string) error {
+	return l.store.Reset(ctx, bucketKey(l.policy.Name, account))
+}
+
+func (l *Lockout) delay(step int) time.Duration {
+	if step > 30 {
+		return l.policy.MaxDelay
+	}
+	d := time.Duration(float64(l.policy.BaseDelay) * math.Pow(2, float64(step)))
+	if d > l.policy.MaxDelay {
+		d = l.policy.MaxDelay
+	}
+	return d
+}
+
+func bucketKey(name, key string) string {
+	return fmt.Sprintf("%s:%s", name, key)
+}
+
+func lockKey(name, key string) string {
+	return fmt.Sprintf("lock:%s:%s", name, key)
+}
@@ -0,0 +1,135 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// clock is a settable time source shared by a store and its users.
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time          { return c.t }
+func (c *clock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newTestStore() (*MemoryStore, *clock) {
+	clk := &clock{t: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	store := NewMemoryStore()
+	store.now = clk.now
+	return store, clk
+}
+
+func TestLimiterWindowRollover(t *testing.T) {
+	ctx := context.Background()
+	store, clk := newTestStore()
+	limiter := NewLimiter(store)
+	limiter.now = clk.now
+	rule := Rule{Name: "test", Limit: 3, Window: time.Minute}
+
+	for i := 1; i <= 3; i++ {
+		res, err := limiter.Allow(ctx, rule, "k")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !res.Allowed || res.Remaining != 3-i {
+			t.Fatalf("hit %d: allowed=%v remaining=%d", i, res.Allowed, res.Remaining)
+		}
+	}
+
+	clk.advance(20 * time.Second)
+	res, err := limiter.Allow(ctx, rule, "k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Allowed || res.Remaining != 0 {
+		t.Fatalf("over limit: allowed=%v remaining=%d", res.Allowed, res.Remaining)
+	}
+	if res.RetryAfter != 40*time.Second {
+		t.Errorf("RetryAfter = %v, want 40s", res.RetryAfter)
+	}
+
+	// Other keys have their own bucket.
+	if res, _ := limiter.Allow(ctx, rule, "other"); !res.Allowed {
+		t.Error("other key limited")
+	}
+
+	clk.advance(40 * time.Second)
+	res, err = limiter.Allow(ctx, rule, "k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Allowed || res.Remaining != 2 {
+		t.Errorf("after rollover: allowed=%v remaining=%d", res.Allowed, res.Remaining)
+	}
+	if want := clk.now().Add(time.Minute); !res.Reset.Equal(want) {
+		t.Errorf("Reset = %v, want %v", res.Reset, want)
+	}
+}
+
+func TestLockoutDoublesAndCaps(t *testing.T) {
+	ctx := context.Background()
+	store, clk := newTestStore()
+	lockout := NewLockout(store, LockoutPolicy{
+		Name:        "test",
+		MaxFailures: 3,
+		Window:      24 * time.Hour,
+		BaseDelay:   time.Minute,
+		MaxDelay:    5 * time.Minute,
+	})
+	lockout.now = clk.now
+
+	want := []time.Duration{0, 0, time.Minute, 2 * time.Minute, 4 * time.Minute, 5 * time.Minute, 5 * time.Minute}
+	for i, w := range want {
+		got, err := lockout.Fail(ctx, "a")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != w {
+			t.Errorf("failure %d: delay %v, want %v", i+1, got, w)
+		}
+		wait, err := lockout.Check(ctx, "a")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if wait != w {
+			t.Errorf("failure %d: Check = %v, want %v", i+1, wait, w)
+		}
+		clk.advance(w)
+	}
+
+	if wait, _ := lockout.Check(ctx, "b"); wait != 0 {
+		t.Errorf("other account locked for %v", wait)
+	}
+
+	if err := lockout.Succeed(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := lockout.Fail(ctx, "a"); got != 0 {
+		t.Errorf("failure after success locked for %v", got)
+	}
+}
+
+func TestMemoryStoreLockKeepsLaterDeadline(t *testing.T) {
+	ctx := context.Background()
+	store, clk := newTestStore()
+	later := clk.now().Add(time.Hour)
+
+	if err := store.Lock(ctx, "k", later); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Lock(ctx, "k", clk.now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	until, err := store.LockedUntil(ctx, "k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !until.Equal(later) {
+		t.Errorf("LockedUntil = %v, want %v", until, later)
+	}
+
+	clk.advance(time.Hour)
+	if until, _ := store.LockedUntil(ctx, "k"); !until.IsZero() {
+		t.Errorf("expired lock still held until %v", until)
+	}
+}
@@ -0,0 +1,39 @@
+package ratelimit
+
+import "time"
+
+// Buckets applied to the auth and public endpoints. The per-account and
+// per-page caps are loose on purpose: they only slow distributed guessing,
+// while the per-client buckets and lockouts stop a single attacker.
+var (
+	LoginIP            = Rule{Name: "login_ip", Limit: 20, Window: time.Minute}
+	LoginAccountIP     = Rule{Name: "login_account_ip", Limit: 10, Window: 15 * time.Minute}
+	LoginAccount       = Rule{Name: "login_account", Limit: 100, Window: time.Hour}
+	RegisterIP         = Rule{Name: "register_ip", Limit: 10, Window: time.Hour}
+	CheckUsernameIP    = Rule{Name: "check_username_ip", Limit: 60, Window: time.Minute}
+	PagePasswordIP     = Rule{Name: "page_password_ip", Limit: 20, Window: time.Minute}
+	PagePasswordPageIP = Rule{Name: "page_password_page_ip", Limit: 100, Window: 15 * time.Minute}
+	PagePasswordPage   = Rule{Name: "page_password_page", Limit: 1000, Window: 15 * time.Minute}
+	PageViewIP         = Rule{Name: "page_view_ip", Limit: 60, Window: time.Minute}
+	PreviewIP          = Rule{Name: "preview_ip", Limit: 30, Window: time.Minute}
+	LinkClickIP        = Rule{Name: "link_click_ip", Limit: 60, Window: time.Minute}
+)
+
+// Progressive lockouts after repeated failed attempts, keyed per account
+// (or page) and client so a stranger cannot lock the owner out.
+var (
+	LoginLockout = LockoutPolicy{
+		Name:        "login_fail",
+		MaxFailures: 5,
+		Window:      time.Hour,
+		BaseDelay:   time.Minute,
+		MaxDelay:    time.Hour,
+	}
+	PagePasswordLockout = LockoutPolicy{
+		Name:        "page_password_fail",
+		MaxFailures: 5,
+		Window:      time.Hour,
+		BaseDelay:   30 * time.Second,
+		MaxDelay:    30 * time.Minute,
+	}
+)
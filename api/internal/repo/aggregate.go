@@ -0,0 +1,131 @@
+package repo
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"linkbio/internal/model"
+)
+
+// PageAggregateRepo loads a page together with its blocks, groups and
+// links in a single round trip, whatever the number of groups.
+type PageAggregateRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewPageAggregateRepo(db *pgxpool.Pool) *PageAggregateRepo {
+	return &PageAggregateRepo{db: db}
+}
+
+// Load fetches the page and everything on it.
+func (r *PageAggregateRepo) Load(ctx context.Context, pageID int64) (*model.PageAggregate, error) {
+	return r.load(ctx, pageID, nil)
+}
+
+// LoadForPage fetches everything on an already loaded page.
+func (r *PageAggregateRepo) LoadForPage(ctx context.Context, page *model.BioPage) (*model.PageAggregate, error) {
+	return r.load(ctx, page.ID, page)
+}
+
+func (r *PageAggregateRepo) load(ctx context.Context, pageID int64, page *model.BioPage) (*model.PageAggregate, error) {
+	batch := &pgx.Batch{}
+	if page == nil {
+		batch.Queue(`
+			SELECT id, user_id, locale, title, status, access_type, password_hash,
+			       theme_preset_id, theme_custom_id, theme_mode, settings, created_at, updated_at
+			FROM bio_pages WHERE id = $1
+		`, pageID)
+	}
+	batch.Queue(`
+		SELECT id, page_id, type, sort_key, ref_id, content, is_visible, created_at, updated_at
+		FROM blocks WHERE page_id = $1 ORDER BY sort_key
+	`, pageID)
+	batch.Queue(`
+		SELECT id, page_id, title, layout_type, layout_config, style_override, utm_template, created_at, updated_at
+		FROM link_groups WHERE page_id = $1 ORDER BY id
+	`, pageID)
+	batch.Queue(`
+		SELECT l.id, l.group_id, l.title, l.url, l.icon_asset_id, l.sort_key, l.is_active, l.utm_template, l.rules,
+			l.expires_at, l.max_clicks, l.click_count, l.fallback, l.fallback_url, l.created_at, l.updated_at
+		FROM links l
+		JOIN link_groups lg ON l.group_id = lg.id
+		WHERE lg.page_id = $1
+		ORDER BY l.group_id, l.sort_key
+	`, pageID)
+
+	results := r.db.SendBatch(ctx, batch)
+	defer results.Close()
+
+	if page == nil {
+		var p model.BioPage
+		err := results.QueryRow().Scan(
+			&p.ID, &p.UserID, &p.Locale, &p.Title, &p.Status,
+			&p.AccessType, &p.PasswordHash, &p.ThemePresetID, &p.ThemeCustomID,
+			&p.ThemeMode, &p.Settings, &p.CreatedAt, &p.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		page = &p
+	}
+	agg := &model.PageAggregate{Page: page, Links: make(map[int64][]*model.Link)}
+
+	rows, err := results.Query()
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var b model.Block
+		if err := rows.Scan(&b.ID, &b.PageID, &b.Type, &b.SortKey, &b.RefID,
+			&b.Content, &b.IsVisible, &b.CreatedAt, &b.UpdatedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		agg.Blocks = append(agg.Blocks, &b)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = results.Query()
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var g model.LinkGroup
+		if err := rows.Scan(&g.ID, &g.PageID, &g.Title, &g.LayoutType,
+			&g.LayoutConfig, &g.StyleOverride, &g.UTMTemplate, &g.CreatedAt, &g.UpdatedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		agg.Groups = append(agg.Groups, &g)
+		agg.Links[g.ID] = []*model.Link{}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = results.Query()
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var l model.Link
+		if err := rows.Scan(&l.ID, &l.GroupID, &l.Title, &l.URL, &l.IconAssetID,
+			&l.SortKey, &l.IsActive, &l.UTMTemplate, &l.Rules,
+			&l.ExpiresAt, &l.MaxClicks, &l.ClickCount, &l.Fallback, &l.FallbackURL, &l.CreatedAt, &l.UpdatedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		agg.Links[l.GroupID] = append(agg.Links[l.GroupID], &l)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return agg, nil
+}
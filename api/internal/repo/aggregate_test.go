@@ -0,0 +1,151 @@
+package repo_test
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"linkbio/internal/database"
+	"linkbio/internal/repo"
+)
+
+// roundTrips counts the queries and batches sent to Postgres; a batch is
+// one round trip however many statements it holds.
+type roundTrips struct {
+	n atomic.Int64
+}
+
+func (r *roundTrips) TraceQueryStart(ctx context.Context, _ *pgx.Conn, _ pgx.TraceQueryStartData) context.Context {
+	r.n.Add(1)
+	return ctx
+}
+
+func (r *roundTrips) TraceQueryEnd(context.Context, *pgx.Conn, pgx.TraceQueryEndData) {}
+
+func (r *roundTrips) TraceBatchStart(ctx context.Context, _ *pgx.Conn, _ pgx.TraceBatchStartData) context.Context {
+	r.n.Add(1)
+	return ctx
+}
+
+func (r *roundTrips) TraceBatchQuery(context.Context, *pgx.Conn, pgx.TraceBatchQueryData) {}
+
+func (r *roundTrips) TraceBatchEnd(context.Context, *pgx.Conn, pgx.TraceBatchEndData) {}
+
+// BenchmarkPageAggregateLoad loads pages with 1, 10 and 100 link groups
+// and fails unless each load is a single round trip.
+func BenchmarkPageAggregateLoad(b *testing.B) {
+	url := os.Getenv("DATABASE_URL")
+	if url == "" {
+		b.Skip("DATABASE_URL not set")
+	}
+
+	ctx := context.Background()
+	trips := &roundTrips{}
+	db := connectTraced(b, url, trips)
+	aggregates := repo.NewPageAggregateRepo(db)
+
+	for _, groups := range []int{1, 10, 100} {
+		pageID := seedAggregatePage(b, db, groups)
+		b.Run(fmt.Sprintf("groups=%d", groups), func(b *testing.B) {
+			trips.n.Store(0)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := aggregates.Load(ctx, pageID); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.StopTimer()
+
+			perLoad := float64(trips.n.Load()) / float64(b.N)
+			b.ReportMetric(perLoad, "roundtrips/op")
+			if perLoad != 1 {
+				b.Errorf("%d groups: %v round trips per load, want 1", groups, perLoad)
+			}
+		})
+	}
+}
+
+func connectTraced(b *testing.B, url string, tracer pgx.QueryTracer) *pgxpool.Pool {
+	ctx := context.Background()
+	migrations, err := database.Connect(url)
+	if err != nil {
+		b.Fatalf("connect: %v", err)
+	}
+	defer migrations.Close()
+	migrator, err := database.NewMigrator(migrations)
+	if err != nil {
+		b.Fatalf("load migrations: %v", err)
+	}
+	if _, err := migrator.Up(ctx); err != nil {
+		b.Fatalf("migrate: %v", err)
+	}
+
+	config, err := pgxpool.ParseConfig(url)
+	if err != nil {
+		b.Fatalf("parse url: %v", err)
+	}
+	config.ConnConfig.Tracer = tracer
+	db, err := pgxpool.NewWithConfig(ctx, config)
+	if err != nil {
+		b.Fatalf("connect: %v", err)
+	}
+	b.Cleanup(db.Close)
+	return db
+}
+
+// seedAggregatePage creates a page with the given number of link groups,
+// two links each, under a throwaway user removed when the benchmark ends.
+func seedAggregatePage(b *testing.B, db *pgxpool.Pool, groups int) int64 {
+	ctx := context.Background()
+	token := make([]byte, 6)
+	if _, err := rand.Read(token); err != nil {
+		b.Fatal(err)
+	}
+	key := "bench-" + hex.EncodeToString(token)
+
+	user, err := repo.NewUserRepo(db).Create(ctx, key+"@example.com", "hash")
+	if err != nil {
+		b.Fatal(err)
+	}
+	var presetID int64
+	err = db.QueryRow(ctx, `
+		INSERT INTO theme_presets (key, name, tier, visibility, is_official, config, status, published_at)
+		VALUES ($1, $1, 'free', 'public', true, '{}', 'approved', NOW())
+		RETURNING id
+	`, key).Scan(&presetID)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() {
+		db.Exec(ctx, `DELETE FROM users WHERE id = $1`, user.ID)
+		db.Exec(ctx, `DELETE FROM theme_presets WHERE id = $1`, presetID)
+	})
+
+	page, err := repo.NewPageRepo(db).Create(ctx, user.ID, presetID, key)
+	if err != nil {
+		b.Fatal(err)
+	}
+	blocks := repo.NewBlockRepo(db)
+	for i := 0; i < groups; i++ {
+		sortKey := fmt.Sprintf("%03d", i)
+		group, err := blocks.CreateLinkGroup(ctx, page.ID, nil, "list")
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := blocks.CreateBlock(ctx, page.ID, "link_group", sortKey, &group.ID, nil); err != nil {
+			b.Fatal(err)
+		}
+		for _, k := range []string{"a", "b"} {
+			if _, err := blocks.CreateLink(ctx, group.ID, k, "https://example.com/"+k, k); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+	return page.ID
+}
@@ -0,0 +1,86 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"linkbio/internal/model"
+)
+
+type AnalyticsRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewAnalyticsRepo(db *pgxpool.Pool) *AnalyticsRepo {
+	return &AnalyticsRepo{db: db}
+}
+
+func (r *AnalyticsRepo) RecordView(ctx context.Context, pageID int64, source string, tag model.VariantTag) error {
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO page_views (page_id, source, experiment_id, variant)
+		VALUES ($1, NULLIF($2, ''), NULLIF($3, 0), NULLIF($4, ''))
+	`, pageID, source, tag.ExperimentID, tag.Variant)
+	return err
+}
+
+func (r *AnalyticsRepo) RecordClick(ctx context.Context, pageID, linkID int64, source string, tag model.VariantTag) error {
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO link_clicks (page_id, link_id, source, experiment_id, variant)
+		VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, 0), NULLIF($5, ''))
+	`, pageID, linkID, source, tag.ExperimentID, tag.Variant)
+	return err
+}
+
+func (r *AnalyticsRepo) Summary(ctx context.Context, pageID int64, since time.Time) (*model.AnalyticsSummary, error) {
+	summary := &model.AnalyticsSummary{Since: since, Sources: []model.SourceCount{}, Links: []model.LinkClicks{}}
+
+	rows, err := r.db.Query(ctx, `
+		SELECT COALESCE(source, ''), SUM(views), SUM(clicks)
+		FROM (
+			SELECT source, 1 AS views, 0 AS clicks FROM page_views
+			WHERE page_id = $1 AND created_at >= $2
+			UNION ALL
+			SELECT source, 0, 1 FROM link_clicks
+			WHERE page_id = $1 AND created_at >= $2
+		) t
+		GROUP BY 1
+		ORDER BY 2 DESC, 3 DESC, 1
+	`, pageID, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var s model.SourceCount
+		if err := rows.Scan(&s.Source, &s.Views, &s.Clicks); err != nil {
+			return nil, err
+		}
+		summary.Sources = append(summary.Sources, s)
+		summary.Views += s.Views
+		summary.Clicks += s.Clicks
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = r.db.Query(ctx, `
+		SELECT link_id, COUNT(*)
+		FROM link_clicks
+		WHERE page_id = $1 AND created_at >= $2 AND link_id IS NOT NULL
+		GROUP BY link_id
+		ORDER BY 2 DESC, 1
+	`, pageID, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var l model.LinkClicks
+		if err := rows.Scan(&l.LinkID, &l.Clicks); err != nil {
+			return nil, err
+		}
+		summary.Links = append(summary.Links, l)
+	}
+	return summary, rows.Err()
+}
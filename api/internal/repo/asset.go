@@ -0,0 +1,106 @@
+package repo
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"linkbio/internal/model"
+)
+
+type AssetRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewAssetRepo(db *pgxpool.Pool) *AssetRepo {
+	return &AssetRepo{db: db}
+}
+
+const assetColumns = `id, user_id, scope, type, provider, storage_key, url, mime_type,
+	size_bytes, width, height, dominant_color, created_at`
+
+func scanAsset(row pgx.Row) (*model.Asset, error) {
+	var a model.Asset
+	err := row.Scan(&a.ID, &a.UserID, &a.Scope, &a.Type, &a.Provider, &a.StorageKey, &a.URL,
+		&a.MimeType, &a.SizeBytes, &a.Width, &a.Height, &a.DominantColor, &a.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+func (r *AssetRepo) Create(ctx context.Context, asset *model.Asset) (*model.Asset, error) {
+	return scanAsset(r.db.QueryRow(ctx, `
+		INSERT INTO assets (user_id, scope, type, provider, storage_key, url, mime_type,
+			size_bytes, width, height, dominant_color)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING `+assetColumns,
+		asset.UserID, asset.Scope, asset.Type, asset.Provider, asset.StorageKey, asset.URL,
+		asset.MimeType, asset.SizeBytes, asset.Width, asset.Height, asset.DominantColor))
+}
+
+func (r *AssetRepo) GetByID(ctx context.Context, id int64) (*model.Asset, error) {
+	return scanAsset(r.db.QueryRow(ctx, `SELECT `+assetColumns+` FROM assets WHERE id = $1`, id))
+}
+
+func (r *AssetRepo) GetByStorageKey(ctx context.Context, scope, key string) (*model.Asset, error) {
+	return scanAsset(r.db.QueryRow(ctx, `
+		SELECT `+assetColumns+` FROM assets
+		WHERE scope = $1 AND storage_key = $2
+		ORDER BY id LIMIT 1
+	`, scope, key))
+}
+
+// SaveBlob stores the bytes of an asset kept in the database; saving the
+// same key again is a no-op.
+func (r *AssetRepo) SaveBlob(ctx context.Context, key string, data []byte) error {
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO asset_blobs (key, data) VALUES ($1, $2)
+		ON CONFLICT (key) DO NOTHING
+	`, key, data)
+	return err
+}
+
+func (r *AssetRepo) GetBlob(ctx context.Context, key string) ([]byte, error) {
+	var data []byte
+	err := r.db.QueryRow(ctx, `SELECT data FROM asset_blobs WHERE key = $1`, key).Scan(&data)
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// ListMissingColor returns uploaded images without a dominant color, in id
+// order after afterID.
+func (r *AssetRepo) ListMissingColor(ctx context.Context, afterID int64, limit int) ([]*model.Asset, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT `+assetColumns+` FROM assets
+		WHERE type = 'image' AND scope <> 'generated' AND dominant_color IS NULL AND id > $1
+		ORDER BY id LIMIT $2
+	`, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var assets []*model.Asset
+	for rows.Next() {
+		a, err := scanAsset(rows)
+		if err != nil {
+			return nil, err
+		}
+		assets = append(assets, a)
+	}
+	return assets, rows.Err()
+}
+
+func (r *AssetRepo) SetDominantColor(ctx context.Context, id int64, color string) error {
+	tag, err := r.db.Exec(ctx, `UPDATE assets SET dominant_color = $2 WHERE id = $1`, id, color)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
@@ -104,13 +104,26 @@ func (r *BioRepo) GetLinkOwnerID(ctx context.Context, linkID int64) (int64, erro
 	return ownerID, err
 }
 
+func (r *BioRepo) GetLinkPageID(ctx context.Context, linkID int64) (int64, error) {
+	var pageID int64
+	err := r.db.QueryRow(ctx, `
+		SELECT lg.page_id
+		FROM links l
+		JOIN link_groups lg ON l.group_id = lg.id
+		WHERE l.id = $1
+	`, linkID).Scan(&pageID)
+	return pageID, err
+}
+
 func (r *BioRepo) GetLinkByID(ctx context.Context, id int64) (*model.Link, error) {
 	var l model.Link
 	err := r.db.QueryRow(ctx, `
-		SELECT id, group_id, title, url, icon_asset_id, sort_key, is_active, created_at, updated_at
+		SELECT id, group_id, title, url, icon_asset_id, sort_key, is_active, utm_template, rules,
+			expires_at, max_clicks, click_count, fallback, fallback_url, created_at, updated_at
 		FROM links WHERE id = $1
 	`, id).Scan(&l.ID, &l.GroupID, &l.Title, &l.URL, &l.IconAssetID,
-		&l.SortKey, &l.IsActive, &l.CreatedAt, &l.UpdatedAt)
+		&l.SortKey, &l.IsActive, &l.UTMTemplate, &l.Rules,
+		&l.ExpiresAt, &l.MaxClicks, &l.ClickCount, &l.Fallback, &l.FallbackURL, &l.CreatedAt, &l.UpdatedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -145,3 +158,34 @@ func (r *BioRepo) UpdateBlockSortKey(ctx context.Context, blockID int64, sortKey
 	`, blockID, sortKey)
 	return err
 }
+
+func (r *BioRepo) ClaimLinkClick(ctx context.Context, linkID int64, maxClicks int) (int, error) {
+	var count int
+	err := r.db.QueryRow(ctx, `
+		UPDATE links SET click_count = click_count + 1
+		WHERE id = $1 AND click_count < $2
+		RETURNING click_count
+	`, linkID, maxClicks).Scan(&count)
+	return count, err
+}
+
+func (r *BioRepo) GetLinkClickCounts(ctx context.Context, linkIDs []int64) (map[int64]int, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT id, click_count FROM links WHERE id = ANY($1)
+	`, linkIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[int64]int, len(linkIDs))
+	for rows.Next() {
+		var id int64
+		var count int
+		if err := rows.Scan(&id, &count); err != nil {
+			return nil, err
+		}
+		counts[id] = count
+	}
+	return counts, rows.Err()
+}
@@ -75,10 +75,10 @@ func (r *BlockRepo) CreateLinkGroup(ctx context.Context, pageID int64, title *st
 	err := r.db.QueryRow(ctx, `
 		INSERT INTO link_groups (page_id, title, layout_type)
 		VALUES ($1, $2, $3)
-		RETURNING id, page_id, title, layout_type, layout_config, style_override, created_at, updated_at
+		RETURNING id, page_id, title, layout_type, layout_config, style_override, utm_template, created_at, updated_at
 	`, pageID, title, layoutType).Scan(
 		&group.ID, &group.PageID, &group.Title, &group.LayoutType,
-		&group.LayoutConfig, &group.StyleOverride, &group.CreatedAt, &group.UpdatedAt,
+		&group.LayoutConfig, &group.StyleOverride, &group.UTMTemplate, &group.CreatedAt, &group.UpdatedAt,
 	)
 	if err != nil {
 		return nil, err
@@ -88,8 +88,8 @@ func (r *BlockRepo) CreateLinkGroup(ctx context.Context, pageID int64, title *st
 
 func (r *BlockRepo) GetLinkGroupsByPage(ctx context.Context, pageID int64) ([]*model.LinkGroup, error) {
 	rows, err := r.db.Query(ctx, `
-		SELECT id, page_id, title, layout_type, layout_config, style_override, created_at, updated_at
-		FROM link_groups WHERE page_id = $1
+		SELECT id, page_id, title, layout_type, layout_config, style_override, utm_template, created_at, updated_at
+		FROM link_groups WHERE page_id = $1 ORDER BY id
 	`, pageID)
 	if err != nil {
 		return nil, err
@@ -100,7 +100,7 @@ func (r *BlockRepo) GetLinkGroupsByPage(ctx context.Context, pageID int64) ([]*m
 	for rows.Next() {
 		var g model.LinkGroup
 		err := rows.Scan(&g.ID, &g.PageID, &g.Title, &g.LayoutType,
-			&g.LayoutConfig, &g.StyleOverride, &g.CreatedAt, &g.UpdatedAt)
+			&g.LayoutConfig, &g.StyleOverride, &g.UTMTemplate, &g.CreatedAt, &g.UpdatedAt)
 		if err != nil {
 			return nil, err
 		}
@@ -112,9 +112,9 @@ func (r *BlockRepo) GetLinkGroupsByPage(ctx context.Context, pageID int64) ([]*m
 func (r *BlockRepo) UpdateLinkGroup(ctx context.Context, group *model.LinkGroup) error {
 	_, err := r.db.Exec(ctx, `
 		UPDATE link_groups SET title = $2, layout_type = $3, layout_config = $4, 
-		       style_override = $5, updated_at = NOW()
+		       style_override = $5, utm_template = $6, updated_at = NOW()
 		WHERE id = $1
-	`, group.ID, group.Title, group.LayoutType, group.LayoutConfig, group.StyleOverride)
+	`, group.ID, group.Title, group.LayoutType, group.LayoutConfig, group.StyleOverride, group.UTMTemplate)
 	return err
 }
 
@@ -129,10 +129,12 @@ func (r *BlockRepo) CreateLink(ctx context.Context, groupID int64, title, url, s
 	err := r.db.QueryRow(ctx, `
 		INSERT INTO links (group_id, title, url, sort_key)
 		VALUES ($1, $2, $3, $4)
-		RETURNING id, group_id, title, url, icon_asset_id, sort_key, is_active, created_at, updated_at
+		RETURNING id, group_id, title, url, icon_asset_id, sort_key, is_active, utm_template, rules,
+			expires_at, max_clicks, click_count, fallback, fallback_url, created_at, updated_at
 	`, groupID, title, url, sortKey).Scan(
 		&link.ID, &link.GroupID, &link.Title, &link.URL, &link.IconAssetID,
-		&link.SortKey, &link.IsActive, &link.CreatedAt, &link.UpdatedAt,
+		&link.SortKey, &link.IsActive, &link.UTMTemplate, &link.Rules,
+		&link.ExpiresAt, &link.MaxClicks, &link.ClickCount, &link.Fallback, &link.FallbackURL, &link.CreatedAt, &link.UpdatedAt,
 	)
 	if err != nil {
 		return nil, err
@@ -142,7 +144,8 @@ func (r *BlockRepo) CreateLink(ctx context.Context, groupID int64, title, url, s
 
 func (r *BlockRepo) GetLinksByGroup(ctx context.Context, groupID int64) ([]*model.Link, error) {
 	rows, err := r.db.Query(ctx, `
-		SELECT id, group_id, title, url, icon_asset_id, sort_key, is_active, created_at, updated_at
+		SELECT id, group_id, title, url, icon_asset_id, sort_key, is_active, utm_template, rules,
+			expires_at, max_clicks, click_count, fallback, fallback_url, created_at, updated_at
 		FROM links WHERE group_id = $1 ORDER BY sort_key
 	`, groupID)
 	if err != nil {
@@ -154,7 +157,8 @@ func (r *BlockRepo) GetLinksByGroup(ctx context.Context, groupID int64) ([]*mode
 	for rows.Next() {
 		var l model.Link
 		err := rows.Scan(&l.ID, &l.GroupID, &l.Title, &l.URL, &l.IconAssetID,
-			&l.SortKey, &l.IsActive, &l.CreatedAt, &l.UpdatedAt)
+			&l.SortKey, &l.IsActive, &l.UTMTemplate, &l.Rules,
+			&l.ExpiresAt, &l.MaxClicks, &l.ClickCount, &l.Fallback, &l.FallbackURL, &l.CreatedAt, &l.UpdatedAt)
 		if err != nil {
 			return nil, err
 		}
@@ -165,9 +169,11 @@ func (r *BlockRepo) GetLinksByGroup(ctx context.Context, groupID int64) ([]*mode
 
 func (r *BlockRepo) UpdateLink(ctx context.Context, link *model.Link) error {
 	_, err := r.db.Exec(ctx, `
-		UPDATE links SET title = $2, url = $3, sort_key = $4, is_active = $5, updated_at = NOW()
+		UPDATE links SET title = $2, url = $3, sort_key = $4, is_active = $5, utm_template = $6, rules = $7,
+			expires_at = $8, max_clicks = $9, fallback = $10, fallback_url = $11, updated_at = NOW()
 		WHERE id = $1
-	`, link.ID, link.Title, link.URL, link.SortKey, link.IsActive)
+	`, link.ID, link.Title, link.URL, link.SortKey, link.IsActive, link.UTMTemplate, link.Rules,
+		link.ExpiresAt, link.MaxClicks, link.Fallback, link.FallbackURL)
 	return err
 }
 
@@ -0,0 +1,68 @@
+package repo
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"linkbio/internal/cache"
+	"linkbio/internal/util"
+)
+
+const cacheChannel = "render_cache"
+
+// CacheBusRepo is a cache.Bus over Postgres LISTEN/NOTIFY, shared by every
+// API instance on the database.
+type CacheBusRepo struct {
+	db *pgxpool.Pool
+	// instance tags this process's messages so it can skip them.
+	instance string
+}
+
+type cacheMessage struct {
+	From string `json:"from"`
+	cache.Invalidation
+}
+
+func NewCacheBusRepo(db *pgxpool.Pool) (*CacheBusRepo, error) {
+	instance, err := util.RandomHex(8)
+	if err != nil {
+		return nil, err
+	}
+	return &CacheBusRepo{db: db, instance: instance}, nil
+}
+
+func (r *CacheBusRepo) Publish(ctx context.Context, inv cache.Invalidation) error {
+	payload, err := json.Marshal(cacheMessage{From: r.instance, Invalidation: inv})
+	if err != nil {
+		return err
+	}
+	_, err = r.db.Exec(ctx, `SELECT pg_notify($1, $2)`, cacheChannel, string(payload))
+	return err
+}
+
+// Listen holds a connection of its own for as long as it runs.
+func (r *CacheBusRepo) Listen(ctx context.Context, fn func(cache.Invalidation)) error {
+	pooled, err := r.db.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	// Taken out of the pool, so its LISTEN never leaks to other queries.
+	conn := pooled.Hijack()
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+cacheChannel); err != nil {
+		return err
+	}
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		var msg cacheMessage
+		if err := json.Unmarshal([]byte(n.Payload), &msg); err != nil || msg.From == r.instance {
+			continue
+		}
+		fn(msg.Invalidation)
+	}
+}
@@ -0,0 +1,63 @@
+package repo_test
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"linkbio/internal/cache"
+	"linkbio/internal/database"
+	"linkbio/internal/repo"
+)
+
+// TestCacheBusRepo skips unless DATABASE_URL is set.
+func TestCacheBusRepo(t *testing.T) {
+	url := os.Getenv("DATABASE_URL")
+	if url == "" {
+		t.Skip("DATABASE_URL not set")
+	}
+	db, err := database.Connect(url)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer db.Close()
+
+	a, err := repo.NewCacheBusRepo(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := repo.NewCacheBusRepo(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	got := make(chan cache.Invalidation, 4)
+	done := make(chan error, 1)
+	go func() { done <- b.Listen(ctx, func(inv cache.Invalidation) { got <- inv }) }()
+
+	// Publish until the listener is up; its own messages never arrive.
+	want := cache.Invalidation{PageID: 42}
+	for {
+		if err := b.Publish(ctx, cache.Invalidation{PageID: 1}); err != nil {
+			t.Fatal(err)
+		}
+		if err := a.Publish(ctx, want); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case inv := <-got:
+			if inv != want {
+				t.Fatalf("received %+v, want %+v", inv, want)
+			}
+			cancel()
+			<-done
+			return
+		case err := <-done:
+			t.Fatalf("Listen: %v", err)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
@@ -27,6 +27,25 @@ func (r *DomainRepo) GetByHostname(ctx context.Context, hostname string) (*model
 	return &d, nil
 }
 
+func (r *DomainRepo) GetByID(ctx context.Context, id int64) (*model.Domain, error) {
+	var d model.Domain
+	err := r.db.QueryRow(ctx, `
+		SELECT id, user_id, hostname, status, is_system, created_at, updated_at
+		FROM domains WHERE id = $1
+	`, id).Scan(&d.ID, &d.UserID, &d.Hostname, &d.Status, &d.IsSystem, &d.CreatedAt, &d.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+func (r *DomainRepo) UpdateStatus(ctx context.Context, id int64, status string) error {
+	_, err := r.db.Exec(ctx, `
+		UPDATE domains SET status = $2, updated_at = NOW() WHERE id = $1
+	`, id, status)
+	return err
+}
+
 func (r *DomainRepo) GetSystemDomain(ctx context.Context) (*model.Domain, error) {
 	var d model.Domain
 	err := r.db.QueryRow(ctx, `
@@ -97,3 +116,118 @@ func (r *DomainRepo) GetCurrentRouteByPage(ctx context.Context, pageID int64) (*
 	}
 	return &route, nil
 }
+
+// ListCurrentRoutesByPage returns the page's current route on every domain
+// it is served from, oldest first.
+func (r *DomainRepo) ListCurrentRoutesByPage(ctx context.Context, pageID int64) ([]*model.PageRoute, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT id, page_id, domain_id, path, is_current, redirect_to_route_id, created_at
+		FROM page_routes WHERE page_id = $1 AND is_current = true
+		ORDER BY id
+	`, pageID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var routes []*model.PageRoute
+	for rows.Next() {
+		var route model.PageRoute
+		if err := rows.Scan(
+			&route.ID, &route.PageID, &route.DomainID, &route.Path,
+			&route.IsCurrent, &route.RedirectToRouteID, &route.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		routes = append(routes, &route)
+	}
+	return routes, rows.Err()
+}
+
+// ListSitemapEntries returns the current routes on domainID whose pages are
+// published, public and not marked noindex, ordered by path. The noindex
+// flag is read from the published page, not the draft. On the system domain
+// pages that also live on an active custom domain are left out, since their
+// canonical URL is there.
+func (r *DomainRepo) ListSitemapEntries(ctx context.Context, domainID int64, limit int) ([]*model.SitemapEntry, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT r.path, c.updated_at
+		FROM page_routes r
+		JOIN domains d ON d.id = r.domain_id
+		JOIN bio_pages p ON p.id = r.page_id
+		JOIN page_publish_cache c ON c.page_id = r.page_id
+		WHERE r.domain_id = $1 AND r.is_current = true
+		  AND p.status = 'published' AND p.access_type = 'public'
+		  AND COALESCE(c.compiled_json #>> '{seo,noindex}', 'false') <> 'true'
+		  AND NOT (d.is_system AND EXISTS (
+			SELECT 1 FROM page_routes cr
+			JOIN domains cd ON cd.id = cr.domain_id
+			WHERE cr.page_id = r.page_id AND cr.is_current = true
+			  AND NOT cd.is_system AND cd.status = 'active'
+		  ))
+		ORDER BY r.path
+		LIMIT $2
+	`, domainID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []*model.SitemapEntry
+	for rows.Next() {
+		var e model.SitemapEntry
+		if err := rows.Scan(&e.Path, &e.LastModified); err != nil {
+			return nil, err
+		}
+		entries = append(entries, &e)
+	}
+	return entries, rows.Err()
+}
+
+// ReplaceCurrentRoute makes path the page's current route on domainID. The
+// page's previous routes on that domain stop being current and redirect to
+// the new one.
+func (r *DomainRepo) ReplaceCurrentRoute(ctx context.Context, pageID, domainID int64, path string) (*model.PageRoute, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	// Retire first: the partial unique index only covers current routes,
+	// so re-using one of the page's old paths must not collide with itself.
+	_, err = tx.Exec(ctx, `
+		UPDATE page_routes SET is_current = false
+		WHERE page_id = $1 AND domain_id = $2 AND is_current = true
+	`, pageID, domainID)
+	if err != nil {
+		return nil, err
+	}
+
+	var route model.PageRoute
+	err = tx.QueryRow(ctx, `
+		INSERT INTO page_routes (page_id, domain_id, path)
+		VALUES ($1, $2, $3)
+		RETURNING id, page_id, domain_id, path, is_current, redirect_to_route_id, created_at
+	`, pageID, domainID, path).Scan(
+		&route.ID, &route.PageID, &route.DomainID, &route.Path,
+		&route.IsCurrent, &route.RedirectToRouteID, &route.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// Point the whole history at the new route so redirects never chain.
+	_, err = tx.Exec(ctx, `
+		UPDATE page_routes SET redirect_to_route_id = $3
+		WHERE page_id = $1 AND domain_id = $2 AND id <> $3
+	`, pageID, domainID, route.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return &route, nil
+}
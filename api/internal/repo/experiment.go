@@ -0,0 +1,145 @@
+package repo
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"linkbio/internal/model"
+)
+
+type ExperimentRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewExperimentRepo(db *pgxpool.Pool) *ExperimentRepo {
+	return &ExperimentRepo{db: db}
+}
+
+const experimentColumns = `id, page_id, name, status, variants, winner, started_at, ended_at,
+	created_at, updated_at`
+
+func scanExperiment(row pgx.Row) (*model.Experiment, error) {
+	var e model.Experiment
+	var variants []byte
+	err := row.Scan(&e.ID, &e.PageID, &e.Name, &e.Status, &variants, &e.Winner, &e.StartedAt,
+		&e.EndedAt, &e.CreatedAt, &e.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(variants, &e.Variants); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+func (r *ExperimentRepo) Create(ctx context.Context, pageID int64, name string, variants []model.ExperimentVariant) (*model.Experiment, error) {
+	raw, err := json.Marshal(variants)
+	if err != nil {
+		return nil, err
+	}
+	return scanExperiment(r.db.QueryRow(ctx, `
+		INSERT INTO experiments (page_id, name, variants)
+		VALUES ($1, $2, $3)
+		RETURNING `+experimentColumns,
+		pageID, name, raw))
+}
+
+func (r *ExperimentRepo) GetByID(ctx context.Context, id int64) (*model.Experiment, error) {
+	return scanExperiment(r.db.QueryRow(ctx, `
+		SELECT `+experimentColumns+` FROM experiments WHERE id = $1
+	`, id))
+}
+
+func (r *ExperimentRepo) GetRunning(ctx context.Context, pageID int64) (*model.Experiment, error) {
+	return scanExperiment(r.db.QueryRow(ctx, `
+		SELECT `+experimentColumns+` FROM experiments WHERE page_id = $1 AND status = 'running'
+	`, pageID))
+}
+
+// ListByPage returns the page's experiments, newest first.
+func (r *ExperimentRepo) ListByPage(ctx context.Context, pageID int64) ([]*model.Experiment, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT `+experimentColumns+`
+		FROM experiments WHERE page_id = $1
+		ORDER BY id DESC
+	`, pageID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var experiments []*model.Experiment
+	for rows.Next() {
+		e, err := scanExperiment(rows)
+		if err != nil {
+			return nil, err
+		}
+		experiments = append(experiments, e)
+	}
+	return experiments, rows.Err()
+}
+
+func (r *ExperimentRepo) End(ctx context.Context, id int64, winner *string, promote *model.Promotion) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// Ending first locks the row, so a concurrent End finds it ended and
+	// promotes nothing.
+	tag, err := tx.Exec(ctx, `
+		UPDATE experiments SET status = 'ended', winner = $2, ended_at = NOW(), updated_at = NOW()
+		WHERE id = $1 AND status = 'running'
+	`, id, winner)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	if promote != nil {
+		for _, t := range promote.LinkTitles {
+			_, err := tx.Exec(ctx, `UPDATE links SET title = $2, updated_at = NOW() WHERE id = $1`, t.LinkID, t.Title)
+			if err != nil {
+				return err
+			}
+		}
+		for blockID, sortKey := range promote.SortKeys {
+			_, err := tx.Exec(ctx, `UPDATE blocks SET sort_key = $2, updated_at = NOW() WHERE id = $1`, blockID, sortKey)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+func (r *ExperimentRepo) Results(ctx context.Context, id int64) ([]model.VariantCount, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT variant, SUM(views), SUM(clicks)
+		FROM (
+			SELECT variant, 1 AS views, 0 AS clicks FROM page_views WHERE experiment_id = $1
+			UNION ALL
+			SELECT variant, 0, 1 FROM link_clicks WHERE experiment_id = $1
+		) t
+		WHERE variant IS NOT NULL
+		GROUP BY 1
+		ORDER BY 1
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var counts []model.VariantCount
+	for rows.Next() {
+		var c model.VariantCount
+		if err := rows.Scan(&c.Variant, &c.Views, &c.Clicks); err != nil {
+			return nil, err
+		}
+		counts = append(counts, c)
+	}
+	return counts, rows.Err()
+}
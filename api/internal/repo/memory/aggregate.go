@@ -0,0 +1,51 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"linkbio/internal/model"
+)
+
+type PageAggregateRepo struct {
+	db *DB
+}
+
+func NewPageAggregateRepo(db *DB) *PageAggregateRepo {
+	return &PageAggregateRepo{db: db}
+}
+
+func (r *PageAggregateRepo) Load(ctx context.Context, pageID int64) (*model.PageAggregate, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	page, ok := r.db.pages[pageID]
+	if !ok {
+		return nil, pgx.ErrNoRows
+	}
+	return r.db.aggregate(copyPage(page, true)), nil
+}
+
+func (r *PageAggregateRepo) LoadForPage(ctx context.Context, page *model.BioPage) (*model.PageAggregate, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	return r.db.aggregate(page), nil
+}
+
+// aggregate reads a consistent snapshot of the page. Callers hold mu.
+func (db *DB) aggregate(page *model.BioPage) *model.PageAggregate {
+	agg := &model.PageAggregate{
+		Page:   page,
+		Blocks: db.blocksByPage(page.ID),
+		Groups: db.groupsByPage(page.ID),
+		Links:  make(map[int64][]*model.Link),
+	}
+	for _, g := range agg.Groups {
+		agg.Links[g.ID] = db.linksByGroup(g.ID)
+		if agg.Links[g.ID] == nil {
+			agg.Links[g.ID] = []*model.Link{}
+		}
+	}
+	return agg
+}
@@ -0,0 +1,115 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"linkbio/internal/model"
+)
+
+// event is a page view or link click; linkID is 0 for views.
+type event struct {
+	pageID int64
+	linkID int64
+	source string
+	tag    model.VariantTag
+	at     time.Time
+}
+
+type AnalyticsRepo struct {
+	db *DB
+}
+
+func NewAnalyticsRepo(db *DB) *AnalyticsRepo {
+	return &AnalyticsRepo{db: db}
+}
+
+func (r *AnalyticsRepo) RecordView(ctx context.Context, pageID int64, source string, tag model.VariantTag) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.pages[pageID]; !ok {
+		return foreignKeyViolation("page_views_page_id_fkey")
+	}
+	if _, ok := r.db.experiments[tag.ExperimentID]; tag.ExperimentID != 0 && !ok {
+		return foreignKeyViolation("page_views_experiment_id_fkey")
+	}
+	r.db.views = append(r.db.views, event{pageID: pageID, source: source, tag: tag, at: r.db.now()})
+	return nil
+}
+
+func (r *AnalyticsRepo) RecordClick(ctx context.Context, pageID, linkID int64, source string, tag model.VariantTag) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.pages[pageID]; !ok {
+		return foreignKeyViolation("link_clicks_page_id_fkey")
+	}
+	if _, ok := r.db.links[linkID]; !ok {
+		return foreignKeyViolation("link_clicks_link_id_fkey")
+	}
+	if _, ok := r.db.experiments[tag.ExperimentID]; tag.ExperimentID != 0 && !ok {
+		return foreignKeyViolation("link_clicks_experiment_id_fkey")
+	}
+	r.db.clicks = append(r.db.clicks, event{pageID: pageID, linkID: linkID, source: source, tag: tag, at: r.db.now()})
+	return nil
+}
+
+func (r *AnalyticsRepo) Summary(ctx context.Context, pageID int64, since time.Time) (*model.AnalyticsSummary, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	summary := &model.AnalyticsSummary{Since: since, Sources: []model.SourceCount{}, Links: []model.LinkClicks{}}
+	sources := map[string]*model.SourceCount{}
+	source := func(name string) *model.SourceCount {
+		if sources[name] == nil {
+			sources[name] = &model.SourceCount{Source: name}
+		}
+		return sources[name]
+	}
+	links := map[int64]int64{}
+
+	for _, e := range r.db.views {
+		if e.pageID == pageID && !e.at.Before(since) {
+			source(e.source).Views++
+			summary.Views++
+		}
+	}
+	for _, e := range r.db.clicks {
+		if e.pageID == pageID && !e.at.Before(since) {
+			source(e.source).Clicks++
+			summary.Clicks++
+			// Deleted links keep counting towards the page, as link_id
+			// goes NULL in Postgres.
+			if _, ok := r.db.links[e.linkID]; ok {
+				links[e.linkID]++
+			}
+		}
+	}
+
+	for _, s := range sources {
+		summary.Sources = append(summary.Sources, *s)
+	}
+	sort.Slice(summary.Sources, func(i, j int) bool {
+		a, b := summary.Sources[i], summary.Sources[j]
+		if a.Views != b.Views {
+			return a.Views > b.Views
+		}
+		if a.Clicks != b.Clicks {
+			return a.Clicks > b.Clicks
+		}
+		return a.Source < b.Source
+	})
+	for id, n := range links {
+		summary.Links = append(summary.Links, model.LinkClicks{LinkID: id, Clicks: n})
+	}
+	sort.Slice(summary.Links, func(i, j int) bool {
+		a, b := summary.Links[i], summary.Links[j]
+		if a.Clicks != b.Clicks {
+			return a.Clicks > b.Clicks
+		}
+		return a.LinkID < b.LinkID
+	})
+	return summary, nil
+}
@@ -0,0 +1,126 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"github.com/jackc/pgx/v5"
+	"linkbio/internal/model"
+)
+
+type AssetRepo struct {
+	db *DB
+}
+
+func NewAssetRepo(db *DB) *AssetRepo {
+	return &AssetRepo{db: db}
+}
+
+func (r *AssetRepo) Create(ctx context.Context, asset *model.Asset) (*model.Asset, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if asset.UserID != nil {
+		if _, ok := r.db.users[*asset.UserID]; !ok {
+			return nil, foreignKeyViolation("assets_user_id_fkey")
+		}
+	}
+	switch asset.Scope {
+	case "system_preset", "user_upload":
+	case "generated":
+		for _, a := range r.db.assets {
+			if a.Scope == "generated" && a.StorageKey == asset.StorageKey {
+				return nil, uniqueViolation("uq_assets_generated_key")
+			}
+		}
+	default:
+		return nil, checkViolation("chk_asset_scope")
+	}
+
+	a := *asset
+	a.ID = r.db.nextID("assets")
+	a.CreatedAt = r.db.now()
+	r.db.assets[a.ID] = &a
+	out := a
+	return &out, nil
+}
+
+func (r *AssetRepo) GetByID(ctx context.Context, id int64) (*model.Asset, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	a, ok := r.db.assets[id]
+	if !ok {
+		return nil, pgx.ErrNoRows
+	}
+	out := *a
+	return &out, nil
+}
+
+func (r *AssetRepo) GetByStorageKey(ctx context.Context, scope, key string) (*model.Asset, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	var found *model.Asset
+	for _, a := range r.db.assets {
+		if a.Scope == scope && a.StorageKey == key && (found == nil || a.ID < found.ID) {
+			found = a
+		}
+	}
+	if found == nil {
+		return nil, pgx.ErrNoRows
+	}
+	out := *found
+	return &out, nil
+}
+
+func (r *AssetRepo) SaveBlob(ctx context.Context, key string, data []byte) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.blobs[key]; !ok {
+		r.db.blobs[key] = append([]byte(nil), data...)
+	}
+	return nil
+}
+
+func (r *AssetRepo) GetBlob(ctx context.Context, key string) ([]byte, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	data, ok := r.db.blobs[key]
+	if !ok {
+		return nil, pgx.ErrNoRows
+	}
+	return append([]byte(nil), data...), nil
+}
+
+func (r *AssetRepo) ListMissingColor(ctx context.Context, afterID int64, limit int) ([]*model.Asset, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	var assets []*model.Asset
+	for _, a := range r.db.assets {
+		if a.Type == "image" && a.Scope != "generated" && a.DominantColor == nil && a.ID > afterID {
+			out := *a
+			assets = append(assets, &out)
+		}
+	}
+	sort.Slice(assets, func(i, j int) bool { return assets[i].ID < assets[j].ID })
+	if len(assets) > limit {
+		assets = assets[:limit]
+	}
+	return assets, nil
+}
+
+func (r *AssetRepo) SetDominantColor(ctx context.Context, id int64, color string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	a, ok := r.db.assets[id]
+	if !ok {
+		return pgx.ErrNoRows
+	}
+	a.DominantColor = &color
+	return nil
+}
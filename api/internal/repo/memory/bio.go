@@ -0,0 +1,189 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"linkbio/internal/model"
+)
+
+type BioRepo struct {
+	db *DB
+}
+
+func NewBioRepo(db *DB) *BioRepo {
+	return &BioRepo{db: db}
+}
+
+func (r *BioRepo) GetOrCreatePage(ctx context.Context, userID int64) (*model.BioPage, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	var first *model.BioPage
+	for _, p := range r.db.pages {
+		if p.UserID == userID && (first == nil || p.ID < first.ID) {
+			first = p
+		}
+	}
+	if first != nil {
+		return copyPage(first, false), nil
+	}
+
+	// Same fallback as Postgres: the first preset, else id 1.
+	var presetID int64
+	for id := range r.db.presets {
+		if presetID == 0 || id < presetID {
+			presetID = id
+		}
+	}
+	if presetID == 0 {
+		presetID = 1
+	}
+
+	page, err := r.db.insertPage(userID, presetID, "My Bio")
+	if err != nil {
+		return nil, err
+	}
+	return copyPage(page, false), nil
+}
+
+func (r *BioRepo) GetBlockByID(ctx context.Context, id int64) (*model.Block, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	b, ok := r.db.blocks[id]
+	if !ok {
+		return nil, pgx.ErrNoRows
+	}
+	return copyBlock(b), nil
+}
+
+func (r *BioRepo) GetBlockOwnerID(ctx context.Context, blockID int64) (int64, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	b, ok := r.db.blocks[blockID]
+	if !ok {
+		return 0, pgx.ErrNoRows
+	}
+	return r.db.pageOwner(b.PageID)
+}
+
+func (r *BioRepo) GetGroupOwnerID(ctx context.Context, groupID int64) (int64, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	g, ok := r.db.groups[groupID]
+	if !ok {
+		return 0, pgx.ErrNoRows
+	}
+	return r.db.pageOwner(g.PageID)
+}
+
+func (r *BioRepo) GetLinkOwnerID(ctx context.Context, linkID int64) (int64, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	l, ok := r.db.links[linkID]
+	if !ok {
+		return 0, pgx.ErrNoRows
+	}
+	g, ok := r.db.groups[l.GroupID]
+	if !ok {
+		return 0, pgx.ErrNoRows
+	}
+	return r.db.pageOwner(g.PageID)
+}
+
+func (r *BioRepo) GetLinkPageID(ctx context.Context, linkID int64) (int64, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	l, ok := r.db.links[linkID]
+	if !ok {
+		return 0, pgx.ErrNoRows
+	}
+	g, ok := r.db.groups[l.GroupID]
+	if !ok {
+		return 0, pgx.ErrNoRows
+	}
+	return g.PageID, nil
+}
+
+func (r *BioRepo) GetLinkByID(ctx context.Context, id int64) (*model.Link, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	l, ok := r.db.links[id]
+	if !ok {
+		return nil, pgx.ErrNoRows
+	}
+	return copyLink(l), nil
+}
+
+func (r *BioRepo) GetLastBlockSortKey(ctx context.Context, pageID int64) (string, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	blocks := r.db.blocksByPage(pageID)
+	if len(blocks) == 0 {
+		return "", pgx.ErrNoRows
+	}
+	return blocks[len(blocks)-1].SortKey, nil
+}
+
+func (r *BioRepo) GetLastLinkSortKey(ctx context.Context, groupID int64) (string, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	links := r.db.linksByGroup(groupID)
+	if len(links) == 0 {
+		return "", pgx.ErrNoRows
+	}
+	return links[len(links)-1].SortKey, nil
+}
+
+func (r *BioRepo) UpdateBlockSortKey(ctx context.Context, blockID int64, sortKey string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if b, ok := r.db.blocks[blockID]; ok {
+		b.SortKey = sortKey
+		b.UpdatedAt = r.db.now()
+	}
+	return nil
+}
+
+func (r *BioRepo) ClaimLinkClick(ctx context.Context, linkID int64, maxClicks int) (int, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	l, ok := r.db.links[linkID]
+	if !ok || l.ClickCount >= maxClicks {
+		return 0, pgx.ErrNoRows
+	}
+	l.ClickCount++
+	return l.ClickCount, nil
+}
+
+func (r *BioRepo) GetLinkClickCounts(ctx context.Context, linkIDs []int64) (map[int64]int, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	counts := make(map[int64]int, len(linkIDs))
+	for _, id := range linkIDs {
+		if l, ok := r.db.links[id]; ok {
+			counts[id] = l.ClickCount
+		}
+	}
+	return counts, nil
+}
+
+// pageOwner returns the page's user. Callers hold mu.
+func (db *DB) pageOwner(pageID int64) (int64, error) {
+	p, ok := db.pages[pageID]
+	if !ok {
+		return 0, pgx.ErrNoRows
+	}
+	return p.UserID, nil
+}
@@ -0,0 +1,323 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+
+	"linkbio/internal/model"
+)
+
+type BlockRepo struct {
+	db *DB
+}
+
+func NewBlockRepo(db *DB) *BlockRepo {
+	return &BlockRepo{db: db}
+}
+
+// Blocks
+func (r *BlockRepo) CreateBlock(ctx context.Context, pageID int64, blockType, sortKey string, refID *int64, content json.RawMessage) (*model.Block, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.pages[pageID]; !ok {
+		return nil, foreignKeyViolation("blocks_page_id_fkey")
+	}
+	if refID != nil {
+		if _, ok := r.db.groups[*refID]; !ok {
+			return nil, foreignKeyViolation("blocks_ref_id_fkey")
+		}
+	}
+	if (blockType == "link_group") != (refID != nil) {
+		return nil, checkViolation("chk_block_ref_link_group")
+	}
+	if content == nil {
+		content = json.RawMessage(`{}`)
+	}
+
+	now := r.db.now()
+	b := &model.Block{
+		ID:        r.db.nextID("blocks"),
+		PageID:    pageID,
+		Type:      blockType,
+		SortKey:   sortKey,
+		RefID:     cloneInt64(refID),
+		Content:   cloneJSON(content),
+		IsVisible: true,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	r.db.blocks[b.ID] = b
+	return copyBlock(b), nil
+}
+
+func (r *BlockRepo) GetBlocksByPage(ctx context.Context, pageID int64) ([]*model.Block, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	return r.db.blocksByPage(pageID), nil
+}
+
+func (r *BlockRepo) UpdateBlock(ctx context.Context, block *model.Block) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if b, ok := r.db.blocks[block.ID]; ok {
+		b.SortKey = block.SortKey
+		b.Content = cloneJSON(block.Content)
+		b.IsVisible = block.IsVisible
+		b.UpdatedAt = r.db.now()
+	}
+	return nil
+}
+
+func (r *BlockRepo) DeleteBlock(ctx context.Context, id int64) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	delete(r.db.blocks, id)
+	return nil
+}
+
+// Link Groups
+func (r *BlockRepo) CreateLinkGroup(ctx context.Context, pageID int64, title *string, layoutType string) (*model.LinkGroup, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.pages[pageID]; !ok {
+		return nil, foreignKeyViolation("link_groups_page_id_fkey")
+	}
+	if err := checkLayoutType(layoutType); err != nil {
+		return nil, err
+	}
+
+	now := r.db.now()
+	g := &model.LinkGroup{
+		ID:           r.db.nextID("link_groups"),
+		PageID:       pageID,
+		Title:        cloneString(title),
+		LayoutType:   layoutType,
+		LayoutConfig: json.RawMessage(`{}`),
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	r.db.groups[g.ID] = g
+	return copyGroup(g), nil
+}
+
+func (r *BlockRepo) GetLinkGroupsByPage(ctx context.Context, pageID int64) ([]*model.LinkGroup, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	return r.db.groupsByPage(pageID), nil
+}
+
+func (r *BlockRepo) UpdateLinkGroup(ctx context.Context, group *model.LinkGroup) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	g, ok := r.db.groups[group.ID]
+	if !ok {
+		return nil
+	}
+	if err := checkLayoutType(group.LayoutType); err != nil {
+		return err
+	}
+	g.Title = cloneString(group.Title)
+	g.LayoutType = group.LayoutType
+	g.LayoutConfig = cloneJSON(group.LayoutConfig)
+	g.StyleOverride = cloneJSON(group.StyleOverride)
+	g.UTMTemplate = cloneString(group.UTMTemplate)
+	g.UpdatedAt = r.db.now()
+	return nil
+}
+
+// DeleteLinkGroup removes the group and its links. Like Postgres, it fails
+// while a link_group block still points at it: the FK would null ref_id,
+// which the block check constraint rejects.
+func (r *BlockRepo) DeleteLinkGroup(ctx context.Context, id int64) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for _, b := range r.db.blocks {
+		if b.RefID != nil && *b.RefID == id {
+			return checkViolation("chk_block_ref_link_group")
+		}
+	}
+	r.db.deleteGroup(id)
+	return nil
+}
+
+// Links
+func (r *BlockRepo) CreateLink(ctx context.Context, groupID int64, title, url, sortKey string) (*model.Link, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.groups[groupID]; !ok {
+		return nil, foreignKeyViolation("links_group_id_fkey")
+	}
+
+	now := r.db.now()
+	l := &model.Link{
+		ID:        r.db.nextID("links"),
+		GroupID:   groupID,
+		Title:     title,
+		URL:       url,
+		SortKey:   sortKey,
+		IsActive:  true,
+		Fallback:  model.LinkFallbackHide,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	r.db.links[l.ID] = l
+	return copyLink(l), nil
+}
+
+func (r *BlockRepo) GetLinksByGroup(ctx context.Context, groupID int64) ([]*model.Link, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	return r.db.linksByGroup(groupID), nil
+}
+
+func (r *BlockRepo) UpdateLink(ctx context.Context, link *model.Link) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if err := checkLinkLimits(link); err != nil {
+		return err
+	}
+	if l, ok := r.db.links[link.ID]; ok {
+		l.Title = link.Title
+		l.URL = link.URL
+		l.SortKey = link.SortKey
+		l.IsActive = link.IsActive
+		l.UTMTemplate = cloneString(link.UTMTemplate)
+		l.Rules = cloneJSON(link.Rules)
+		l.ExpiresAt = cloneTime(link.ExpiresAt)
+		l.MaxClicks = cloneInt(link.MaxClicks)
+		l.Fallback = link.Fallback
+		l.FallbackURL = cloneString(link.FallbackURL)
+		l.UpdatedAt = r.db.now()
+	}
+	return nil
+}
+
+func (r *BlockRepo) DeleteLink(ctx context.Context, id int64) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	r.db.deleteLink(id)
+	return nil
+}
+
+// deleteGroup cascades to the group's links. Callers hold mu.
+func (db *DB) deleteGroup(id int64) {
+	delete(db.groups, id)
+	for lid, l := range db.links {
+		if l.GroupID == id {
+			db.deleteLink(lid)
+		}
+	}
+}
+
+// deleteLink cascades to the link's short links. Callers hold mu.
+func (db *DB) deleteLink(id int64) {
+	delete(db.links, id)
+	for sid, s := range db.shortLinks {
+		if s.LinkID == id {
+			delete(db.shortLinks, sid)
+		}
+	}
+}
+
+// blocksByPage returns copies ordered by sort_key. Callers hold mu.
+func (db *DB) blocksByPage(pageID int64) []*model.Block {
+	var blocks []*model.Block
+	for _, b := range db.blocks {
+		if b.PageID == pageID {
+			blocks = append(blocks, copyBlock(b))
+		}
+	}
+	sort.Slice(blocks, func(i, j int) bool {
+		if blocks[i].SortKey != blocks[j].SortKey {
+			return blocks[i].SortKey < blocks[j].SortKey
+		}
+		return blocks[i].ID < blocks[j].ID
+	})
+	return blocks
+}
+
+// groupsByPage returns copies ordered by id. Callers hold mu.
+func (db *DB) groupsByPage(pageID int64) []*model.LinkGroup {
+	var groups []*model.LinkGroup
+	for _, g := range db.groups {
+		if g.PageID == pageID {
+			groups = append(groups, copyGroup(g))
+		}
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].ID < groups[j].ID })
+	return groups
+}
+
+// linksByGroup returns copies ordered by sort_key. Callers hold mu.
+func (db *DB) linksByGroup(groupID int64) []*model.Link {
+	var links []*model.Link
+	for _, l := range db.links {
+		if l.GroupID == groupID {
+			links = append(links, copyLink(l))
+		}
+	}
+	sort.Slice(links, func(i, j int) bool {
+		if links[i].SortKey != links[j].SortKey {
+			return links[i].SortKey < links[j].SortKey
+		}
+		return links[i].ID < links[j].ID
+	})
+	return links
+}
+
+func checkLayoutType(layoutType string) error {
+	if layoutType != "list" && layoutType != "cards" && layoutType != "grid" {
+		return checkViolation("chk_layout_type")
+	}
+	return nil
+}
+
+func checkLinkLimits(l *model.Link) error {
+	if l.MaxClicks != nil && *l.MaxClicks <= 0 {
+		return checkViolation("chk_link_max_clicks")
+	}
+	if l.Fallback != model.LinkFallbackHide && (l.Fallback != model.LinkFallbackRedirect || l.FallbackURL == nil) {
+		return checkViolation("chk_link_fallback")
+	}
+	return nil
+}
+
+func copyBlock(b *model.Block) *model.Block {
+	out := *b
+	out.RefID = cloneInt64(b.RefID)
+	out.Content = cloneJSON(b.Content)
+	return &out
+}
+
+func copyGroup(g *model.LinkGroup) *model.LinkGroup {
+	out := *g
+	out.Title = cloneString(g.Title)
+	out.LayoutConfig = cloneJSON(g.LayoutConfig)
+	out.StyleOverride = cloneJSON(g.StyleOverride)
+	out.UTMTemplate = cloneString(g.UTMTemplate)
+	return &out
+}
+
+func copyLink(l *model.Link) *model.Link {
+	out := *l
+	out.IconAssetID = cloneInt64(l.IconAssetID)
+	out.UTMTemplate = cloneString(l.UTMTemplate)
+	out.Rules = cloneJSON(l.Rules)
+	out.ExpiresAt = cloneTime(l.ExpiresAt)
+	out.MaxClicks = cloneInt(l.MaxClicks)
+	out.FallbackURL = cloneString(l.FallbackURL)
+	return &out
+}
@@ -0,0 +1,191 @@
+// Package memory holds in-memory implementations of the repo interfaces
+// for service tests. They keep the same constraints, cascades and sort
+// orders as the Postgres schema and are safe for concurrent use.
+package memory
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"linkbio/internal/model"
+	"linkbio/internal/repo"
+)
+
+// DB is the shared state behind every fake repo, so deleting a page in
+// PageRepo also removes its blocks, groups, links and routes.
+type DB struct {
+	mu  sync.RWMutex
+	seq map[string]int64
+	now func() time.Time
+
+	users    map[int64]*model.User
+	presets  map[int64]*model.ThemePreset
+	versions map[int64]*model.ThemePresetVersion
+	customs  map[int64]*model.ThemeCustom
+	pages    map[int64]*model.BioPage
+	publish  map[int64]*model.PagePublishCache
+	domains  map[int64]*model.Domain
+	routes   map[int64]*model.PageRoute
+	groups   map[int64]*model.LinkGroup
+	links    map[int64]*model.Link
+	blocks   map[int64]*model.Block
+	admins   map[int64]bool
+	pro      map[int64]bool
+
+	stylesheets map[string][]byte
+	assets      map[int64]*model.Asset
+	blobs       map[string][]byte
+	schedules   map[int64]*model.PageSchedule
+	experiments map[int64]*model.Experiment
+	shortLinks  map[int64]*model.ShortLink
+	views       []event
+	clicks      []event
+}
+
+func NewDB() *DB {
+	return &DB{
+		seq:      make(map[string]int64),
+		now:      time.Now,
+		users:    make(map[int64]*model.User),
+		presets:  make(map[int64]*model.ThemePreset),
+		versions: make(map[int64]*model.ThemePresetVersion),
+		customs:  make(map[int64]*model.ThemeCustom),
+		pages:    make(map[int64]*model.BioPage),
+		publish:  make(map[int64]*model.PagePublishCache),
+		domains:  make(map[int64]*model.Domain),
+		routes:   make(map[int64]*model.PageRoute),
+		groups:   make(map[int64]*model.LinkGroup),
+		links:    make(map[int64]*model.Link),
+		blocks:   make(map[int64]*model.Block),
+		admins:   make(map[int64]bool),
+		pro:      make(map[int64]bool),
+
+		stylesheets: make(map[string][]byte),
+		assets:      make(map[int64]*model.Asset),
+		blobs:       make(map[string][]byte),
+		schedules:   make(map[int64]*model.PageSchedule),
+		experiments: make(map[int64]*model.Experiment),
+		shortLinks:  make(map[int64]*model.ShortLink),
+	}
+}
+
+// SeedPreset inserts a theme preset the way the schema seed does: official
+// and approved unless p says otherwise.
+func (db *DB) SeedPreset(p model.ThemePreset) (*model.ThemePreset, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if p.Status == "" {
+		p.Status = model.PresetApproved
+	}
+	inserted, err := db.insertPreset(p)
+	if err != nil {
+		return nil, err
+	}
+	return db.copyPreset(inserted), nil
+}
+
+// SetAdmin marks a user as a marketplace moderator.
+func (db *DB) SetAdmin(userID int64, isAdmin bool) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.admins[userID] = isAdmin
+}
+
+// SetPro gives a user an active Pro subscription, or takes it away.
+func (db *DB) SetPro(userID int64, isPro bool) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.pro[userID] = isPro
+}
+
+// nextID mimics a BIGSERIAL per table. Callers hold mu.
+func (db *DB) nextID(table string) int64 {
+	db.seq[table]++
+	return db.seq[table]
+}
+
+// Errors carry the same SQLSTATE codes Postgres would return.
+
+func uniqueViolation(constraint string) error {
+	return &pgconn.PgError{
+		Severity:       "ERROR",
+		Code:           "23505",
+		ConstraintName: constraint,
+		Message:        fmt.Sprintf("duplicate key value violates unique constraint %q", constraint),
+	}
+}
+
+func foreignKeyViolation(constraint string) error {
+	return &pgconn.PgError{
+		Severity:       "ERROR",
+		Code:           "23503",
+		ConstraintName: constraint,
+		Message:        fmt.Sprintf("violates foreign key constraint %q", constraint),
+	}
+}
+
+func checkViolation(constraint string) error {
+	return &pgconn.PgError{
+		Severity:       "ERROR",
+		Code:           "23514",
+		ConstraintName: constraint,
+		Message:        fmt.Sprintf("violates check constraint %q", constraint),
+	}
+}
+
+func cloneJSON(raw json.RawMessage) json.RawMessage {
+	if raw == nil {
+		return nil
+	}
+	return append(json.RawMessage(nil), raw...)
+}
+
+func cloneInt64(v *int64) *int64 {
+	if v == nil {
+		return nil
+	}
+	out := *v
+	return &out
+}
+
+func cloneString(v *string) *string {
+	if v == nil {
+		return nil
+	}
+	out := *v
+	return &out
+}
+
+func cloneInt(v *int) *int {
+	if v == nil {
+		return nil
+	}
+	out := *v
+	return &out
+}
+
+func cloneTime(v *time.Time) *time.Time {
+	if v == nil {
+		return nil
+	}
+	out := *v
+	return &out
+}
+
+var (
+	_ repo.UserStore          = (*UserRepo)(nil)
+	_ repo.PageStore          = (*PageRepo)(nil)
+	_ repo.BlockStore         = (*BlockRepo)(nil)
+	_ repo.BioStore           = (*BioRepo)(nil)
+	_ repo.ThemeStore         = (*ThemeRepo)(nil)
+	_ repo.DomainStore        = (*DomainRepo)(nil)
+	_ repo.PageAggregateStore = (*PageAggregateRepo)(nil)
+	_ repo.ExperimentStore    = (*ExperimentRepo)(nil)
+	_ repo.ShortLinkStore     = (*ShortLinkRepo)(nil)
+)
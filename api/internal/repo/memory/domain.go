@@ -0,0 +1,257 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+
+	"github.com/jackc/pgx/v5"
+	"linkbio/internal/model"
+)
+
+type DomainRepo struct {
+	db *DB
+}
+
+func NewDomainRepo(db *DB) *DomainRepo {
+	return &DomainRepo{db: db}
+}
+
+func (r *DomainRepo) GetByHostname(ctx context.Context, hostname string) (*model.Domain, error) {
+	return r.find(func(d *model.Domain) bool { return d.Hostname == hostname })
+}
+
+func (r *DomainRepo) GetByID(ctx context.Context, id int64) (*model.Domain, error) {
+	return r.find(func(d *model.Domain) bool { return d.ID == id })
+}
+
+func (r *DomainRepo) UpdateStatus(ctx context.Context, id int64, status string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if status != "pending" && status != "active" && status != "disabled" {
+		return checkViolation("chk_domain_status")
+	}
+	if d, ok := r.db.domains[id]; ok {
+		d.Status = status
+		d.UpdatedAt = r.db.now()
+	}
+	return nil
+}
+
+func (r *DomainRepo) GetSystemDomain(ctx context.Context) (*model.Domain, error) {
+	return r.find(func(d *model.Domain) bool { return d.IsSystem })
+}
+
+func (r *DomainRepo) Create(ctx context.Context, userID *int64, hostname string, isSystem bool) (*model.Domain, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if userID != nil {
+		if _, ok := r.db.users[*userID]; !ok {
+			return nil, foreignKeyViolation("domains_user_id_fkey")
+		}
+	}
+	for _, d := range r.db.domains {
+		if d.Hostname == hostname {
+			return nil, uniqueViolation("domains_hostname_key")
+		}
+	}
+
+	now := r.db.now()
+	d := &model.Domain{
+		ID:        r.db.nextID("domains"),
+		UserID:    cloneInt64(userID),
+		Hostname:  hostname,
+		Status:    "active",
+		IsSystem:  isSystem,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	r.db.domains[d.ID] = d
+	return copyDomain(d), nil
+}
+
+func (r *DomainRepo) GetRouteByDomainAndPath(ctx context.Context, domainID int64, path string) (*model.PageRoute, error) {
+	return r.findRoute(func(rt *model.PageRoute) bool {
+		return rt.DomainID == domainID && rt.Path == path && rt.IsCurrent
+	})
+}
+
+func (r *DomainRepo) CreateRoute(ctx context.Context, pageID, domainID int64, path string) (*model.PageRoute, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	route, err := r.db.insertRoute(pageID, domainID, path)
+	if err != nil {
+		return nil, err
+	}
+	return copyRoute(route), nil
+}
+
+func (r *DomainRepo) GetCurrentRouteByPage(ctx context.Context, pageID int64) (*model.PageRoute, error) {
+	return r.findRoute(func(rt *model.PageRoute) bool { return rt.PageID == pageID && rt.IsCurrent })
+}
+
+func (r *DomainRepo) ListCurrentRoutesByPage(ctx context.Context, pageID int64) ([]*model.PageRoute, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	var routes []*model.PageRoute
+	for _, rt := range r.db.routes {
+		if rt.PageID == pageID && rt.IsCurrent {
+			routes = append(routes, copyRoute(rt))
+		}
+	}
+	sort.Slice(routes, func(i, j int) bool { return routes[i].ID < routes[j].ID })
+	return routes, nil
+}
+
+func (r *DomainRepo) ListSitemapEntries(ctx context.Context, domainID int64, limit int) ([]*model.SitemapEntry, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	domain, ok := r.db.domains[domainID]
+	if !ok {
+		return nil, nil
+	}
+	// Pages on an active custom domain are canonical there.
+	onCustom := make(map[int64]bool)
+	for _, rt := range r.db.routes {
+		if d := r.db.domains[rt.DomainID]; rt.IsCurrent && !d.IsSystem && d.Status == "active" {
+			onCustom[rt.PageID] = true
+		}
+	}
+
+	var entries []*model.SitemapEntry
+	for _, rt := range r.db.routes {
+		if rt.DomainID != domainID || !rt.IsCurrent || (domain.IsSystem && onCustom[rt.PageID]) {
+			continue
+		}
+		p, ok := r.db.pages[rt.PageID]
+		if !ok || p.Status != "published" || p.AccessType != "public" {
+			continue
+		}
+		c, ok := r.db.publish[rt.PageID]
+		if !ok {
+			continue
+		}
+		var compiled struct {
+			SEO struct {
+				NoIndex bool `json:"noindex"`
+			} `json:"seo"`
+		}
+		// JSONB is always valid in Postgres; treat anything else as indexable.
+		_ = json.Unmarshal(c.CompiledJSON, &compiled)
+		if compiled.SEO.NoIndex {
+			continue
+		}
+		entries = append(entries, &model.SitemapEntry{Path: rt.Path, LastModified: c.UpdatedAt})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+	return entries, nil
+}
+
+// ReplaceCurrentRoute makes path the page's current route on domainID and
+// points the page's older routes on that domain at it. Nothing changes if
+// the new path is taken.
+func (r *DomainRepo) ReplaceCurrentRoute(ctx context.Context, pageID, domainID int64, path string) (*model.PageRoute, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	var retired []*model.PageRoute
+	for _, rt := range r.db.routes {
+		if rt.PageID == pageID && rt.DomainID == domainID && rt.IsCurrent {
+			rt.IsCurrent = false
+			retired = append(retired, rt)
+		}
+	}
+
+	route, err := r.db.insertRoute(pageID, domainID, path)
+	if err != nil {
+		for _, rt := range retired {
+			rt.IsCurrent = true
+		}
+		return nil, err
+	}
+
+	for _, rt := range r.db.routes {
+		if rt.PageID == pageID && rt.DomainID == domainID && rt.ID != route.ID {
+			rt.RedirectToRouteID = &route.ID
+		}
+	}
+	return copyRoute(route), nil
+}
+
+// insertRoute enforces uq_domain_current_path. Callers hold mu.
+func (db *DB) insertRoute(pageID, domainID int64, path string) (*model.PageRoute, error) {
+	if _, ok := db.pages[pageID]; !ok {
+		return nil, foreignKeyViolation("page_routes_page_id_fkey")
+	}
+	if _, ok := db.domains[domainID]; !ok {
+		return nil, foreignKeyViolation("page_routes_domain_id_fkey")
+	}
+	for _, rt := range db.routes {
+		if rt.DomainID == domainID && rt.Path == path && rt.IsCurrent {
+			return nil, uniqueViolation("uq_domain_current_path")
+		}
+	}
+
+	route := &model.PageRoute{
+		ID:        db.nextID("page_routes"),
+		PageID:    pageID,
+		DomainID:  domainID,
+		Path:      path,
+		IsCurrent: true,
+		CreatedAt: db.now(),
+	}
+	db.routes[route.ID] = route
+	return route, nil
+}
+
+func (r *DomainRepo) find(match func(*model.Domain) bool) (*model.Domain, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	var found *model.Domain
+	for _, d := range r.db.domains {
+		if match(d) && (found == nil || d.ID < found.ID) {
+			found = d
+		}
+	}
+	if found == nil {
+		return nil, pgx.ErrNoRows
+	}
+	return copyDomain(found), nil
+}
+
+func (r *DomainRepo) findRoute(match func(*model.PageRoute) bool) (*model.PageRoute, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	var found *model.PageRoute
+	for _, rt := range r.db.routes {
+		if match(rt) && (found == nil || rt.ID < found.ID) {
+			found = rt
+		}
+	}
+	if found == nil {
+		return nil, pgx.ErrNoRows
+	}
+	return copyRoute(found), nil
+}
+
+func copyDomain(d *model.Domain) *model.Domain {
+	out := *d
+	out.UserID = cloneInt64(d.UserID)
+	return &out
+}
+
+func copyRoute(rt *model.PageRoute) *model.PageRoute {
+	out := *rt
+	out.RedirectToRouteID = cloneInt64(rt.RedirectToRouteID)
+	return &out
+}
@@ -0,0 +1,161 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"github.com/jackc/pgx/v5"
+	"linkbio/internal/model"
+)
+
+type ExperimentRepo struct {
+	db *DB
+}
+
+func NewExperimentRepo(db *DB) *ExperimentRepo {
+	return &ExperimentRepo{db: db}
+}
+
+func (r *ExperimentRepo) Create(ctx context.Context, pageID int64, name string, variants []model.ExperimentVariant) (*model.Experiment, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.pages[pageID]; !ok {
+		return nil, foreignKeyViolation("experiments_page_id_fkey")
+	}
+	for _, e := range r.db.experiments {
+		if e.PageID == pageID && e.Status == model.ExperimentRunning {
+			return nil, uniqueViolation("uq_experiments_running")
+		}
+	}
+
+	now := r.db.now()
+	e := &model.Experiment{
+		ID:        r.db.nextID("experiments"),
+		PageID:    pageID,
+		Name:      name,
+		Status:    model.ExperimentRunning,
+		Variants:  cloneVariants(variants),
+		StartedAt: now,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	r.db.experiments[e.ID] = e
+	return copyExperiment(e), nil
+}
+
+func (r *ExperimentRepo) GetByID(ctx context.Context, id int64) (*model.Experiment, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	e, ok := r.db.experiments[id]
+	if !ok {
+		return nil, pgx.ErrNoRows
+	}
+	return copyExperiment(e), nil
+}
+
+func (r *ExperimentRepo) GetRunning(ctx context.Context, pageID int64) (*model.Experiment, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	for _, e := range r.db.experiments {
+		if e.PageID == pageID && e.Status == model.ExperimentRunning {
+			return copyExperiment(e), nil
+		}
+	}
+	return nil, pgx.ErrNoRows
+}
+
+func (r *ExperimentRepo) ListByPage(ctx context.Context, pageID int64) ([]*model.Experiment, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	var out []*model.Experiment
+	for _, e := range r.db.experiments {
+		if e.PageID == pageID {
+			out = append(out, copyExperiment(e))
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID > out[j].ID })
+	return out, nil
+}
+
+func (r *ExperimentRepo) End(ctx context.Context, id int64, winner *string, promote *model.Promotion) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	e, ok := r.db.experiments[id]
+	if !ok || e.Status != model.ExperimentRunning {
+		return pgx.ErrNoRows
+	}
+	now := r.db.now()
+	e.Status = model.ExperimentEnded
+	e.Winner = cloneString(winner)
+	e.EndedAt = &now
+	e.UpdatedAt = now
+
+	if promote != nil {
+		for _, t := range promote.LinkTitles {
+			if l, ok := r.db.links[t.LinkID]; ok {
+				l.Title = t.Title
+				l.UpdatedAt = now
+			}
+		}
+		for blockID, sortKey := range promote.SortKeys {
+			if b, ok := r.db.blocks[blockID]; ok {
+				b.SortKey = sortKey
+				b.UpdatedAt = now
+			}
+		}
+	}
+	return nil
+}
+
+func (r *ExperimentRepo) Results(ctx context.Context, id int64) ([]model.VariantCount, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	counts := map[string]*model.VariantCount{}
+	count := func(e event) *model.VariantCount {
+		if counts[e.tag.Variant] == nil {
+			counts[e.tag.Variant] = &model.VariantCount{Variant: e.tag.Variant}
+		}
+		return counts[e.tag.Variant]
+	}
+	for _, e := range r.db.views {
+		if e.tag.ExperimentID == id && e.tag.Variant != "" {
+			count(e).Views++
+		}
+	}
+	for _, e := range r.db.clicks {
+		if e.tag.ExperimentID == id && e.tag.Variant != "" {
+			count(e).Clicks++
+		}
+	}
+
+	var out []model.VariantCount
+	for _, c := range counts {
+		out = append(out, *c)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Variant < out[j].Variant })
+	return out, nil
+}
+
+func copyExperiment(e *model.Experiment) *model.Experiment {
+	out := *e
+	out.Variants = cloneVariants(e.Variants)
+	out.Winner = cloneString(e.Winner)
+	out.EndedAt = cloneTime(e.EndedAt)
+	return &out
+}
+
+func cloneVariants(variants []model.ExperimentVariant) []model.ExperimentVariant {
+	out := make([]model.ExperimentVariant, len(variants))
+	for i, v := range variants {
+		v.LinkTitles = append([]model.LinkTitle(nil), v.LinkTitles...)
+		v.BlockOrder = append([]int64(nil), v.BlockOrder...)
+		out[i] = v
+	}
+	return out
+}
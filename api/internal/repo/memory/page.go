@@ -0,0 +1,283 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"sort"
+
+	"github.com/jackc/pgx/v5"
+	"linkbio/internal/model"
+)
+
+type PageRepo struct {
+	db *DB
+}
+
+func NewPageRepo(db *DB) *PageRepo {
+	return &PageRepo{db: db}
+}
+
+func (r *PageRepo) Create(ctx context.Context, userID, themePresetID int64, title string) (*model.BioPage, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	page, err := r.db.insertPage(userID, themePresetID, title)
+	if err != nil {
+		return nil, err
+	}
+	return copyPage(page, false), nil
+}
+
+func (r *PageRepo) GetByID(ctx context.Context, id int64) (*model.BioPage, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	page, ok := r.db.pages[id]
+	if !ok {
+		return nil, pgx.ErrNoRows
+	}
+	return copyPage(page, true), nil
+}
+
+func (r *PageRepo) ListByUser(ctx context.Context, userID int64) ([]*model.BioPage, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	var pages []*model.BioPage
+	for _, p := range r.db.pages {
+		if p.UserID == userID {
+			pages = append(pages, copyPage(p, false))
+		}
+	}
+	sort.Slice(pages, func(i, j int) bool {
+		if !pages[i].CreatedAt.Equal(pages[j].CreatedAt) {
+			return pages[i].CreatedAt.After(pages[j].CreatedAt)
+		}
+		return pages[i].ID > pages[j].ID
+	})
+	return pages, nil
+}
+
+func (r *PageRepo) Update(ctx context.Context, page *model.BioPage) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	existing, ok := r.db.pages[page.ID]
+	if !ok {
+		return nil
+	}
+	if err := checkPage(page.Locale, page.AccessType, page.ThemeMode); err != nil {
+		return err
+	}
+	if _, ok := r.db.presets[page.ThemePresetID]; !ok {
+		return foreignKeyViolation("bio_pages_theme_preset_id_fkey")
+	}
+	if page.ThemeCustomID != nil {
+		if _, ok := r.db.customs[*page.ThemeCustomID]; !ok {
+			return foreignKeyViolation("bio_pages_theme_custom_id_fkey")
+		}
+	}
+
+	existing.Locale = page.Locale
+	existing.Title = cloneString(page.Title)
+	existing.Status = page.Status
+	existing.AccessType = page.AccessType
+	existing.ThemePresetID = page.ThemePresetID
+	existing.ThemeCustomID = cloneInt64(page.ThemeCustomID)
+	existing.ThemeMode = page.ThemeMode
+	existing.Settings = cloneJSON(page.Settings)
+	existing.UpdatedAt = r.db.now()
+	return nil
+}
+
+// Delete removes the page with everything that cascades from it.
+func (r *PageRepo) Delete(ctx context.Context, id int64) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	r.db.deletePage(id)
+	return nil
+}
+
+func (r *PageRepo) SavePublishCache(ctx context.Context, pageID int64, compiled json.RawMessage, hash string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.pages[pageID]; !ok {
+		return foreignKeyViolation("page_publish_cache_page_id_fkey")
+	}
+
+	now := r.db.now()
+	if c, ok := r.db.publish[pageID]; ok {
+		c.CompiledJSON = cloneJSON(compiled)
+		c.Hash = hash
+		c.UpdatedAt = now
+		return nil
+	}
+	r.db.publish[pageID] = &model.PagePublishCache{
+		PageID:       pageID,
+		CompiledJSON: cloneJSON(compiled),
+		Hash:         hash,
+		PublishedAt:  now,
+		UpdatedAt:    now,
+	}
+	return nil
+}
+
+func (r *PageRepo) GetPublishCache(ctx context.Context, pageID int64) (*model.PagePublishCache, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	c, ok := r.db.publish[pageID]
+	if !ok {
+		return nil, pgx.ErrNoRows
+	}
+	out := *c
+	out.CompiledJSON = cloneJSON(c.CompiledJSON)
+	return &out, nil
+}
+
+func (r *PageRepo) Unpublish(ctx context.Context, pageID int64) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	p, ok := r.db.pages[pageID]
+	if !ok {
+		return pgx.ErrNoRows
+	}
+	p.Status = "draft"
+	p.UpdatedAt = r.db.now()
+	delete(r.db.publish, pageID)
+	return nil
+}
+
+func (r *PageRepo) ApplyTheme(ctx context.Context, pageID, userID, presetID int64, customID *int64, mode string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	page, ok := r.db.pages[pageID]
+	if !ok || page.UserID != userID {
+		return pgx.ErrNoRows
+	}
+	if customID != nil {
+		t, ok := r.db.customs[*customID]
+		if !ok || t.UserID != userID || t.BasedOnPresetID != presetID {
+			return pgx.ErrNoRows
+		}
+	}
+	if err := checkPage(page.Locale, page.AccessType, mode); err != nil {
+		return err
+	}
+	if _, ok := r.db.presets[presetID]; !ok {
+		return foreignKeyViolation("bio_pages_theme_preset_id_fkey")
+	}
+
+	page.ThemePresetID = presetID
+	page.ThemeCustomID = cloneInt64(customID)
+	page.ThemeMode = mode
+	page.UpdatedAt = r.db.now()
+	return nil
+}
+
+func (r *PageRepo) UpdateSettings(ctx context.Context, pageID int64, settings []byte) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if p, ok := r.db.pages[pageID]; ok {
+		p.Settings = cloneJSON(settings)
+		p.UpdatedAt = r.db.now()
+	}
+	return nil
+}
+
+// insertPage applies the bio_pages defaults. Callers hold mu.
+func (db *DB) insertPage(userID, themePresetID int64, title string) (*model.BioPage, error) {
+	if _, ok := db.users[userID]; !ok {
+		return nil, foreignKeyViolation("bio_pages_user_id_fkey")
+	}
+	if _, ok := db.presets[themePresetID]; !ok {
+		return nil, foreignKeyViolation("bio_pages_theme_preset_id_fkey")
+	}
+
+	now := db.now()
+	page := &model.BioPage{
+		ID:            db.nextID("bio_pages"),
+		UserID:        userID,
+		Locale:        "vi",
+		Title:         &title,
+		Status:        "draft",
+		AccessType:    "public",
+		ThemePresetID: themePresetID,
+		ThemeMode:     "light",
+		Settings:      json.RawMessage(`{}`),
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	db.pages[page.ID] = page
+	return page, nil
+}
+
+// deletePage cascades to routes, groups, links, blocks and the publish
+// cache. Callers hold mu.
+func (db *DB) deletePage(id int64) {
+	if _, ok := db.pages[id]; !ok {
+		return
+	}
+	delete(db.pages, id)
+	delete(db.publish, id)
+	for sid, s := range db.schedules {
+		if s.PageID == id {
+			delete(db.schedules, sid)
+		}
+	}
+	for eid, e := range db.experiments {
+		if e.PageID == id {
+			delete(db.experiments, eid)
+		}
+	}
+	db.views = slices.DeleteFunc(db.views, func(e event) bool { return e.pageID == id })
+	db.clicks = slices.DeleteFunc(db.clicks, func(e event) bool { return e.pageID == id })
+
+	for rid, route := range db.routes {
+		if route.PageID == id {
+			delete(db.routes, rid)
+		}
+	}
+	for bid, b := range db.blocks {
+		if b.PageID == id {
+			delete(db.blocks, bid)
+		}
+	}
+	for gid, g := range db.groups {
+		if g.PageID == id {
+			db.deleteGroup(gid)
+		}
+	}
+}
+
+func checkPage(locale, accessType, themeMode string) error {
+	switch {
+	case locale != "vi" && locale != "en":
+		return checkViolation("chk_locale")
+	case accessType != "public" && accessType != "password":
+		return checkViolation("chk_access_type")
+	case themeMode != "light" && themeMode != "dark" && themeMode != "compact" && themeMode != "auto":
+		return checkViolation("chk_theme_mode")
+	}
+	return nil
+}
+
+// copyPage returns a detached copy. The Postgres repo only selects
+// password_hash in GetByID, so withPassword mirrors that.
+func copyPage(p *model.BioPage, withPassword bool) *model.BioPage {
+	out := *p
+	out.Title = cloneString(p.Title)
+	out.PasswordHash = nil
+	if withPassword {
+		out.PasswordHash = cloneString(p.PasswordHash)
+	}
+	out.ThemeCustomID = cloneInt64(p.ThemeCustomID)
+	out.Settings = cloneJSON(p.Settings)
+	return &out
+}
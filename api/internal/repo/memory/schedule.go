@@ -0,0 +1,165 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"linkbio/internal/model"
+)
+
+type ScheduleRepo struct {
+	db *DB
+}
+
+func NewScheduleRepo(db *DB) *ScheduleRepo {
+	return &ScheduleRepo{db: db}
+}
+
+func (r *ScheduleRepo) Create(ctx context.Context, pageID, userID int64, action string, runAt time.Time) (*model.PageSchedule, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.pages[pageID]; !ok {
+		return nil, foreignKeyViolation("page_schedules_page_id_fkey")
+	}
+	if _, ok := r.db.users[userID]; !ok {
+		return nil, foreignKeyViolation("page_schedules_user_id_fkey")
+	}
+	if action != model.SchedulePublish && action != model.ScheduleUnpublish {
+		return nil, checkViolation("chk_schedule_action")
+	}
+
+	now := r.db.now()
+	s := &model.PageSchedule{
+		ID:        r.db.nextID("page_schedules"),
+		PageID:    pageID,
+		UserID:    userID,
+		Action:    action,
+		RunAt:     runAt,
+		Status:    model.SchedulePending,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	r.db.schedules[s.ID] = s
+	return copySchedule(s), nil
+}
+
+func (r *ScheduleRepo) ListPendingByPage(ctx context.Context, pageID int64) ([]*model.PageSchedule, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	var out []*model.PageSchedule
+	for _, s := range r.db.schedules {
+		if s.PageID == pageID && s.Status == model.SchedulePending {
+			out = append(out, copySchedule(s))
+		}
+	}
+	sortSchedules(out)
+	return out, nil
+}
+
+func (r *ScheduleRepo) Cancel(ctx context.Context, id, userID int64) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	s, ok := r.db.schedules[id]
+	if !ok || s.UserID != userID || s.Status != model.SchedulePending || r.leased(s) {
+		return pgx.ErrNoRows
+	}
+	s.Status = model.ScheduleCanceled
+	s.UpdatedAt = r.db.now()
+	return nil
+}
+
+// ClaimDue holds the write lock for the whole claim, which gives the same
+// one-claimer guarantee as SKIP LOCKED.
+func (r *ScheduleRepo) ClaimDue(ctx context.Context, lease time.Duration) (*model.PageSchedule, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	now := r.db.now()
+	var due []*model.PageSchedule
+	for _, s := range r.db.schedules {
+		if s.Status == model.SchedulePending && !s.RunAt.After(now) && !r.leased(s) {
+			due = append(due, s)
+		}
+	}
+	if len(due) == 0 {
+		return nil, pgx.ErrNoRows
+	}
+	sortSchedules(due)
+
+	s := due[0]
+	until := now.Add(lease)
+	s.LockedUntil = &until
+	s.Attempts++
+	s.UpdatedAt = now
+	return copySchedule(s), nil
+}
+
+func (r *ScheduleRepo) Complete(ctx context.Context, id int64, attempt int) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	s, ok := r.claim(id, attempt)
+	if !ok {
+		return pgx.ErrNoRows
+	}
+	now := r.db.now()
+	s.Status = model.ScheduleDone
+	s.ExecutedAt = &now
+	s.LockedUntil = nil
+	s.LastError = nil
+	s.UpdatedAt = now
+	return nil
+}
+
+func (r *ScheduleRepo) Fail(ctx context.Context, id int64, attempt int, reason string, retryAt *time.Time) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	s, ok := r.claim(id, attempt)
+	if !ok {
+		return pgx.ErrNoRows
+	}
+	if retryAt == nil {
+		s.Status = model.ScheduleFailed
+	} else {
+		s.Status = model.SchedulePending
+		s.RunAt = *retryAt
+	}
+	s.LockedUntil = nil
+	s.LastError = &reason
+	s.UpdatedAt = r.db.now()
+	return nil
+}
+
+// claim returns the schedule if it is still held by the claim made at the
+// given attempt.
+func (r *ScheduleRepo) claim(id int64, attempt int) (*model.PageSchedule, bool) {
+	s, ok := r.db.schedules[id]
+	if !ok || s.Attempts != attempt || s.Status != model.SchedulePending || s.LockedUntil == nil {
+		return nil, false
+	}
+	return s, true
+}
+
+func (r *ScheduleRepo) leased(s *model.PageSchedule) bool {
+	return s.LockedUntil != nil && !s.LockedUntil.Before(r.db.now())
+}
+
+func sortSchedules(list []*model.PageSchedule) {
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].RunAt.Equal(list[j].RunAt) {
+			return list[i].RunAt.Before(list[j].RunAt)
+		}
+		return list[i].ID < list[j].ID
+	})
+}
+
+func copySchedule(s *model.PageSchedule) *model.PageSchedule {
+	out := *s
+	return &out
+}
@@ -0,0 +1,94 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"linkbio/internal/model"
+)
+
+type ShortLinkRepo struct {
+	db *DB
+}
+
+func NewShortLinkRepo(db *DB) *ShortLinkRepo {
+	return &ShortLinkRepo{db: db}
+}
+
+func (r *ShortLinkRepo) Create(ctx context.Context, linkID int64, code string, custom bool) (*model.ShortLink, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.links[linkID]; !ok {
+		return nil, foreignKeyViolation("short_links_link_id_fkey")
+	}
+	if code != strings.ToLower(code) {
+		return nil, checkViolation("chk_short_link_code")
+	}
+	for _, s := range r.db.shortLinks {
+		if s.Code == code {
+			return nil, uniqueViolation("uq_short_links_code")
+		}
+	}
+
+	s := &model.ShortLink{
+		ID:        r.db.nextID("short_links"),
+		LinkID:    linkID,
+		Code:      code,
+		IsCustom:  custom,
+		CreatedAt: r.db.now(),
+	}
+	r.db.shortLinks[s.ID] = s
+	out := *s
+	return &out, nil
+}
+
+func (r *ShortLinkRepo) GetByID(ctx context.Context, id int64) (*model.ShortLink, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	s, ok := r.db.shortLinks[id]
+	if !ok {
+		return nil, pgx.ErrNoRows
+	}
+	out := *s
+	return &out, nil
+}
+
+func (r *ShortLinkRepo) GetByCode(ctx context.Context, code string) (*model.ShortLink, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	for _, s := range r.db.shortLinks {
+		if s.Code == code {
+			out := *s
+			return &out, nil
+		}
+	}
+	return nil, pgx.ErrNoRows
+}
+
+func (r *ShortLinkRepo) ListByLink(ctx context.Context, linkID int64) ([]*model.ShortLink, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	var links []*model.ShortLink
+	for _, s := range r.db.shortLinks {
+		if s.LinkID == linkID {
+			out := *s
+			links = append(links, &out)
+		}
+	}
+	sort.Slice(links, func(i, j int) bool { return links[i].ID < links[j].ID })
+	return links, nil
+}
+
+func (r *ShortLinkRepo) Delete(ctx context.Context, id int64) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	delete(r.db.shortLinks, id)
+	return nil
+}
@@ -0,0 +1,578 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"linkbio/internal/model"
+	"linkbio/internal/repo"
+	"linkbio/internal/theme"
+)
+
+type ThemeRepo struct {
+	db *DB
+}
+
+func NewThemeRepo(db *DB) *ThemeRepo {
+	return &ThemeRepo{db: db}
+}
+
+func (r *ThemeRepo) GetPresets(ctx context.Context, tier string) ([]*model.ThemePreset, error) {
+	presets, _, err := r.SearchPresets(ctx, repo.PresetQuery{Tier: tier, Sort: repo.PresetSortName})
+	return presets, err
+}
+
+func (r *ThemeRepo) SearchPresets(ctx context.Context, q repo.PresetQuery) ([]*model.ThemePreset, int, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	search := strings.ToLower(q.Search)
+	var presets []*model.ThemePreset
+	for _, p := range r.db.presets {
+		if p.Visibility != "public" || p.Status != model.PresetApproved || (q.Tier != "" && p.Tier != q.Tier) {
+			continue
+		}
+		if search != "" && !strings.Contains(strings.ToLower(p.Name), search) && !strings.Contains(strings.ToLower(p.Key), search) {
+			continue
+		}
+		presets = append(presets, r.db.copyPreset(p))
+	}
+
+	sort.Slice(presets, func(i, j int) bool {
+		a, b := presets[i], presets[j]
+		switch q.Sort {
+		case repo.PresetSortNewest:
+			if (a.PublishedAt == nil) != (b.PublishedAt == nil) {
+				return a.PublishedAt != nil
+			}
+			if a.PublishedAt != nil && !a.PublishedAt.Equal(*b.PublishedAt) {
+				return a.PublishedAt.After(*b.PublishedAt)
+			}
+			return a.ID > b.ID
+		case repo.PresetSortName:
+			if a.Name != b.Name {
+				return a.Name < b.Name
+			}
+		default:
+			if a.UsageCount != b.UsageCount {
+				return a.UsageCount > b.UsageCount
+			}
+		}
+		return a.ID < b.ID
+	})
+
+	total := len(presets)
+	if q.Limit > 0 {
+		if q.Offset >= len(presets) {
+			return nil, total, nil
+		}
+		presets = presets[q.Offset:]
+		if len(presets) > q.Limit {
+			presets = presets[:q.Limit]
+		}
+	}
+	return presets, total, nil
+}
+
+func (r *ThemeRepo) ListPresetsByAuthor(ctx context.Context, authorID int64) ([]*model.ThemePreset, error) {
+	presets := r.filterPresets(func(p *model.ThemePreset) bool {
+		return p.AuthorUserID != nil && *p.AuthorUserID == authorID
+	})
+	sort.Slice(presets, func(i, j int) bool {
+		if !presets[i].CreatedAt.Equal(presets[j].CreatedAt) {
+			return presets[i].CreatedAt.After(presets[j].CreatedAt)
+		}
+		return presets[i].ID > presets[j].ID
+	})
+	return presets, nil
+}
+
+func (r *ThemeRepo) ListPresetsByStatus(ctx context.Context, status string) ([]*model.ThemePreset, error) {
+	presets := r.filterPresets(func(p *model.ThemePreset) bool { return p.Status == status })
+	sort.Slice(presets, func(i, j int) bool {
+		a, b := presets[i], presets[j]
+		if (a.SubmittedAt == nil) != (b.SubmittedAt == nil) {
+			return a.SubmittedAt != nil
+		}
+		if a.SubmittedAt != nil && !a.SubmittedAt.Equal(*b.SubmittedAt) {
+			return a.SubmittedAt.Before(*b.SubmittedAt)
+		}
+		return a.ID < b.ID
+	})
+	return presets, nil
+}
+
+func (r *ThemeRepo) GetPresetByID(ctx context.Context, id int64) (*model.ThemePreset, error) {
+	return r.findPreset(func(p *model.ThemePreset) bool { return p.ID == id })
+}
+
+func (r *ThemeRepo) GetPresetByKey(ctx context.Context, key string) (*model.ThemePreset, error) {
+	return r.findPreset(func(p *model.ThemePreset) bool { return p.Key == key })
+}
+
+func (r *ThemeRepo) CreatePreset(ctx context.Context, authorID int64, key, name, tier, visibility string, config json.RawMessage) (*model.ThemePreset, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.users[authorID]; !ok {
+		return nil, foreignKeyViolation("theme_presets_author_user_id_fkey")
+	}
+	p, err := r.db.insertPreset(model.ThemePreset{
+		Key:          key,
+		Name:         name,
+		Tier:         tier,
+		Visibility:   visibility,
+		AuthorUserID: &authorID,
+		Config:       config,
+		Status:       model.PresetDraft,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r.db.copyPreset(p), nil
+}
+
+func (r *ThemeRepo) UpdatePresetDraft(ctx context.Context, id, authorID int64, name, tier, visibility string, config json.RawMessage) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	p, ok := r.db.presets[id]
+	if !ok || p.AuthorUserID == nil || *p.AuthorUserID != authorID ||
+		(p.Status != model.PresetDraft && p.Status != model.PresetRejected) {
+		return pgx.ErrNoRows
+	}
+	if err := checkPreset(tier, visibility, p.Status); err != nil {
+		return err
+	}
+	p.Name = name
+	p.Tier = tier
+	p.Visibility = visibility
+	p.Config = cloneJSON(config)
+	p.UpdatedAt = r.db.now()
+	return nil
+}
+
+func (r *ThemeRepo) TransitionPreset(ctx context.Context, id int64, from []string, status string, reviewerID *int64, note *string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	p, ok := r.db.presets[id]
+	if !ok {
+		return pgx.ErrNoRows
+	}
+	allowed := false
+	for _, f := range from {
+		allowed = allowed || p.Status == f
+	}
+	if !allowed {
+		return pgx.ErrNoRows
+	}
+	// Same as the trg_theme_presets_immutable trigger.
+	if p.Status == model.PresetApproved && status != model.PresetApproved {
+		return checkViolation("trg_theme_presets_immutable")
+	}
+	if err := checkPreset(p.Tier, p.Visibility, status); err != nil {
+		return err
+	}
+
+	now := r.db.now()
+	p.Status = status
+	p.ReviewedBy = cloneInt64(reviewerID)
+	p.ReviewNote = cloneString(note)
+	if status == model.PresetSubmitted {
+		p.SubmittedAt = &now
+	}
+	if status == model.PresetApproved {
+		p.PublishedAt = &now
+	}
+	p.UpdatedAt = now
+	return r.db.publishFirstVersion(p)
+}
+
+func (r *ThemeRepo) GetPresetVersion(ctx context.Context, id int64) (*model.ThemePresetVersion, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	v, ok := r.db.versions[id]
+	if !ok {
+		return nil, pgx.ErrNoRows
+	}
+	return copyVersion(v), nil
+}
+
+func (r *ThemeRepo) ListPresetVersions(ctx context.Context, presetID int64) ([]*model.ThemePresetVersion, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	var versions []*model.ThemePresetVersion
+	for _, v := range r.db.versions {
+		if v.PresetID == presetID {
+			versions = append(versions, copyVersion(v))
+		}
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		a, b := versions[i], versions[j]
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.After(b.CreatedAt)
+		}
+		return a.ID > b.ID
+	})
+	return versions, nil
+}
+
+func (r *ThemeRepo) PublishPresetVersion(ctx context.Context, presetID int64, version string, schemaVersion int, config json.RawMessage) (*model.ThemePresetVersion, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.presets[presetID]; !ok {
+		return nil, foreignKeyViolation("theme_preset_versions_preset_id_fkey")
+	}
+	v, err := r.db.insertVersion(presetID, version, schemaVersion, config)
+	if err != nil {
+		return nil, err
+	}
+	p := r.db.presets[presetID]
+	if p.Status != model.PresetApproved {
+		delete(r.db.versions, v.ID)
+		return nil, pgx.ErrNoRows
+	}
+	p.Config = cloneJSON(config)
+	p.CurrentVersionID = &v.ID
+	p.UpdatedAt = r.db.now()
+	return copyVersion(v), nil
+}
+
+func (r *ThemeRepo) CreateCustom(ctx context.Context, userID, presetID int64, presetVersionID *int64, name string, patch, compiled json.RawMessage, hash string) (*model.ThemeCustom, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.users[userID]; !ok {
+		return nil, foreignKeyViolation("themes_custom_user_id_fkey")
+	}
+	if _, ok := r.db.presets[presetID]; !ok {
+		return nil, foreignKeyViolation("themes_custom_based_on_preset_id_fkey")
+	}
+	if presetVersionID != nil {
+		if _, ok := r.db.versions[*presetVersionID]; !ok {
+			return nil, foreignKeyViolation("themes_custom_preset_version_id_fkey")
+		}
+	}
+	now := r.db.now()
+	t := &model.ThemeCustom{
+		ID:              r.db.nextID("themes_custom"),
+		UserID:          userID,
+		BasedOnPresetID: presetID,
+		PresetVersionID: cloneInt64(presetVersionID),
+		Name:            &name,
+		Patch:           cloneJSON(patch),
+		CompiledConfig:  cloneJSON(compiled),
+		Hash:            hash,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+	r.db.customs[t.ID] = t
+	return copyCustom(t), nil
+}
+
+func (r *ThemeRepo) GetCustomByHash(ctx context.Context, userID int64, hash string) (*model.ThemeCustom, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	var oldest *model.ThemeCustom
+	for _, t := range r.db.customs {
+		if t.UserID == userID && t.Hash == hash && (oldest == nil || t.ID < oldest.ID) {
+			oldest = t
+		}
+	}
+	if oldest == nil {
+		return nil, pgx.ErrNoRows
+	}
+	return copyCustom(oldest), nil
+}
+
+func (r *ThemeRepo) ListCustomByUser(ctx context.Context, userID int64) ([]*model.ThemeCustom, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	var themes []*model.ThemeCustom
+	for _, t := range r.db.customs {
+		if t.UserID == userID {
+			themes = append(themes, copyCustom(t))
+		}
+	}
+	sort.Slice(themes, func(i, j int) bool {
+		if !themes[i].UpdatedAt.Equal(themes[j].UpdatedAt) {
+			return themes[i].UpdatedAt.After(themes[j].UpdatedAt)
+		}
+		return themes[i].ID > themes[j].ID
+	})
+	return themes, nil
+}
+
+func (r *ThemeRepo) RenameCustom(ctx context.Context, id, userID int64, name string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	t, ok := r.db.customs[id]
+	if !ok || t.UserID != userID {
+		return pgx.ErrNoRows
+	}
+	t.Name = &name
+	t.UpdatedAt = r.db.now()
+	return nil
+}
+
+func (r *ThemeRepo) GetCustomByID(ctx context.Context, id int64) (*model.ThemeCustom, error) {
+	return r.findCustom(func(t *model.ThemeCustom) bool { return t.ID == id })
+}
+
+func (r *ThemeRepo) UpdateCustom(ctx context.Context, id int64, patch, compiled json.RawMessage, hash string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	t, ok := r.db.customs[id]
+	if !ok {
+		return nil
+	}
+	t.Patch = cloneJSON(patch)
+	t.CompiledConfig = cloneJSON(compiled)
+	t.Hash = hash
+	t.UpdatedAt = r.db.now()
+	return nil
+}
+
+func (r *ThemeRepo) RebaseCustom(ctx context.Context, id, presetVersionID int64, patch, compiled json.RawMessage, hash string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	t, ok := r.db.customs[id]
+	v, vok := r.db.versions[presetVersionID]
+	if !ok || !vok || v.PresetID != t.BasedOnPresetID {
+		return pgx.ErrNoRows
+	}
+	t.PresetVersionID = &v.ID
+	t.Patch = cloneJSON(patch)
+	t.CompiledConfig = cloneJSON(compiled)
+	t.Hash = hash
+	t.UpdatedAt = r.db.now()
+	return nil
+}
+
+// DeleteCustom fails while a page still uses the theme, as the
+// bio_pages.theme_custom_id foreign key has no ON DELETE action, unless
+// fallback moves those pages back to the theme's preset.
+func (r *ThemeRepo) DeleteCustom(ctx context.Context, id, userID int64, fallback bool) ([]int64, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	t, ok := r.db.customs[id]
+	if !ok || t.UserID != userID {
+		return nil, pgx.ErrNoRows
+	}
+	var using []*model.BioPage
+	for _, p := range r.db.pages {
+		if p.ThemeCustomID != nil && *p.ThemeCustomID == id {
+			using = append(using, p)
+		}
+	}
+	if len(using) > 0 && !fallback {
+		return nil, foreignKeyViolation("bio_pages_theme_custom_id_fkey")
+	}
+
+	var pageIDs []int64
+	for _, p := range using {
+		p.ThemeCustomID = nil
+		p.ThemePresetID = t.BasedOnPresetID
+		p.UpdatedAt = r.db.now()
+		pageIDs = append(pageIDs, p.ID)
+	}
+	delete(r.db.customs, id)
+	return pageIDs, nil
+}
+
+func (r *ThemeRepo) SaveStylesheet(ctx context.Context, hash string, css []byte) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.stylesheets[hash]; !ok {
+		r.db.stylesheets[hash] = append([]byte(nil), css...)
+	}
+	return nil
+}
+
+func (r *ThemeRepo) GetStylesheet(ctx context.Context, hash string) ([]byte, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	css, ok := r.db.stylesheets[hash]
+	if !ok {
+		return nil, pgx.ErrNoRows
+	}
+	return append([]byte(nil), css...), nil
+}
+
+func (r *ThemeRepo) findPreset(match func(*model.ThemePreset) bool) (*model.ThemePreset, error) {
+	presets := r.filterPresets(match)
+	if len(presets) == 0 {
+		return nil, pgx.ErrNoRows
+	}
+	return presets[0], nil
+}
+
+func (r *ThemeRepo) filterPresets(match func(*model.ThemePreset) bool) []*model.ThemePreset {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	var presets []*model.ThemePreset
+	for _, p := range r.db.presets {
+		if match(p) {
+			presets = append(presets, r.db.copyPreset(p))
+		}
+	}
+	return presets
+}
+
+func (r *ThemeRepo) findCustom(match func(*model.ThemeCustom) bool) (*model.ThemeCustom, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	for _, t := range r.db.customs {
+		if match(t) {
+			return copyCustom(t), nil
+		}
+	}
+	return nil, pgx.ErrNoRows
+}
+
+// copyPreset returns a detached copy with its usage count. Callers hold
+// mu.
+func (db *DB) copyPreset(p *model.ThemePreset) *model.ThemePreset {
+	out := *p
+	out.AuthorUserID = cloneInt64(p.AuthorUserID)
+	out.Config = cloneJSON(p.Config)
+	out.ReviewNote = cloneString(p.ReviewNote)
+	out.ReviewedBy = cloneInt64(p.ReviewedBy)
+	out.CurrentVersionID = cloneInt64(p.CurrentVersionID)
+	out.Version = nil
+	if p.CurrentVersionID != nil {
+		out.Version = cloneString(&db.versions[*p.CurrentVersionID].Version)
+	}
+	out.UsageCount = 0
+	for _, page := range db.pages {
+		if page.ThemePresetID == p.ID {
+			out.UsageCount++
+		}
+	}
+	return &out
+}
+
+// insertPreset applies the theme_presets defaults and constraints.
+// Callers hold mu.
+func (db *DB) insertPreset(p model.ThemePreset) (*model.ThemePreset, error) {
+	for _, existing := range db.presets {
+		if existing.Key == p.Key {
+			return nil, uniqueViolation("theme_presets_key_key")
+		}
+	}
+	if p.Tier == "" {
+		p.Tier = "free"
+	}
+	if p.Visibility == "" {
+		p.Visibility = "public"
+	}
+	if p.Status == "" {
+		p.Status = model.PresetDraft
+	}
+	if err := checkPreset(p.Tier, p.Visibility, p.Status); err != nil {
+		return nil, err
+	}
+
+	now := db.now()
+	p.ID = db.nextID("theme_presets")
+	p.Config = cloneJSON(p.Config)
+	p.AuthorUserID = cloneInt64(p.AuthorUserID)
+	p.CreatedAt, p.UpdatedAt = now, now
+	if p.Status == model.PresetApproved && p.PublishedAt == nil {
+		p.PublishedAt = &now
+	}
+	db.presets[p.ID] = &p
+	if err := db.publishFirstVersion(&p); err != nil {
+		delete(db.presets, p.ID)
+		return nil, err
+	}
+	return &p, nil
+}
+
+// publishFirstVersion mirrors the trg_theme_presets_publish_version
+// trigger: a preset's config becomes its first version when it is
+// published. Callers hold mu.
+func (db *DB) publishFirstVersion(p *model.ThemePreset) error {
+	if p.Status != model.PresetApproved || p.CurrentVersionID != nil {
+		return nil
+	}
+	meta, err := theme.ReadMeta(p.Config)
+	if err != nil {
+		return checkViolation("theme_preset_versions_config")
+	}
+	version := meta.Version
+	if version == "" {
+		version = "1.0.0"
+	}
+	v, err := db.insertVersion(p.ID, version, meta.SchemaVersion, p.Config)
+	if err != nil {
+		return err
+	}
+	p.CurrentVersionID = &v.ID
+	return nil
+}
+
+// insertVersion applies the theme_preset_versions constraints. Callers
+// hold mu.
+func (db *DB) insertVersion(presetID int64, version string, schemaVersion int, config json.RawMessage) (*model.ThemePresetVersion, error) {
+	for _, v := range db.versions {
+		if v.PresetID == presetID && v.Version == version {
+			return nil, uniqueViolation("uq_preset_version")
+		}
+	}
+	v := &model.ThemePresetVersion{
+		ID:            db.nextID("theme_preset_versions"),
+		PresetID:      presetID,
+		Version:       version,
+		SchemaVersion: schemaVersion,
+		Config:        cloneJSON(config),
+		CreatedAt:     db.now(),
+	}
+	db.versions[v.ID] = v
+	return v, nil
+}
+
+func checkPreset(tier, visibility, status string) error {
+	switch {
+	case tier != "free" && tier != "pro":
+		return checkViolation("chk_theme_tier")
+	case visibility != "public" && visibility != "unlisted" && visibility != "private":
+		return checkViolation("chk_theme_visibility")
+	case status != model.PresetDraft && status != model.PresetSubmitted &&
+		status != model.PresetApproved && status != model.PresetRejected:
+		return checkViolation("chk_theme_status")
+	}
+	return nil
+}
+
+func copyCustom(t *model.ThemeCustom) *model.ThemeCustom {
+	out := *t
+	out.PresetVersionID = cloneInt64(t.PresetVersionID)
+	out.Name = cloneString(t.Name)
+	out.Patch = cloneJSON(t.Patch)
+	out.CompiledConfig = cloneJSON(t.CompiledConfig)
+	return &out
+}
+
+func copyVersion(v *model.ThemePresetVersion) *model.ThemePresetVersion {
+	out := *v
+	out.Config = cloneJSON(v.Config)
+	return &out
+}
@@ -0,0 +1,126 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"linkbio/internal/model"
+)
+
+type UserRepo struct {
+	db *DB
+}
+
+func NewUserRepo(db *DB) *UserRepo {
+	return &UserRepo{db: db}
+}
+
+func (r *UserRepo) Create(ctx context.Context, email, passwordHash string) (*model.User, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for _, u := range r.db.users {
+		if u.Email == email {
+			return nil, uniqueViolation("users_email_key")
+		}
+	}
+
+	now := r.db.now()
+	u := &model.User{
+		ID:           r.db.nextID("users"),
+		Email:        email,
+		PasswordHash: passwordHash,
+		IsActive:     true,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	r.db.users[u.ID] = u
+
+	out := copyUser(u)
+	out.PasswordHash = ""
+	return out, nil
+}
+
+func (r *UserRepo) GetByEmail(ctx context.Context, email string) (*model.User, error) {
+	return r.find(func(u *model.User) bool { return u.Email == email })
+}
+
+func (r *UserRepo) GetByID(ctx context.Context, id int64) (*model.User, error) {
+	return r.find(func(u *model.User) bool { return u.ID == id })
+}
+
+func (r *UserRepo) GetByUsername(ctx context.Context, username string) (*model.User, error) {
+	return r.find(func(u *model.User) bool { return u.Username != nil && *u.Username == username })
+}
+
+func (r *UserRepo) SetUsername(ctx context.Context, userID int64, username string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for _, u := range r.db.users {
+		if u.ID != userID && u.Username != nil && *u.Username == username {
+			return uniqueViolation("users_username_key")
+		}
+	}
+	if u, ok := r.db.users[userID]; ok {
+		u.Username = &username
+		u.UpdatedAt = r.db.now()
+	}
+	return nil
+}
+
+func (r *UserRepo) UsernameExists(ctx context.Context, username string) (bool, error) {
+	_, err := r.GetByUsername(ctx, username)
+	if err == pgx.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (r *UserRepo) UpdateDisplayName(ctx context.Context, userID int64, displayName string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if u, ok := r.db.users[userID]; ok {
+		u.DisplayName = &displayName
+		u.UpdatedAt = r.db.now()
+	}
+	return nil
+}
+
+func (r *UserRepo) IsAdmin(ctx context.Context, userID int64) (bool, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	if _, ok := r.db.users[userID]; !ok {
+		return false, pgx.ErrNoRows
+	}
+	return r.db.admins[userID], nil
+}
+
+func (r *UserRepo) IsPro(ctx context.Context, userID int64) (bool, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	return r.db.pro[userID], nil
+}
+
+func (r *UserRepo) find(match func(*model.User) bool) (*model.User, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	for _, u := range r.db.users {
+		if match(u) {
+			return copyUser(u), nil
+		}
+	}
+	return nil, pgx.ErrNoRows
+}
+
+func copyUser(u *model.User) *model.User {
+	out := *u
+	out.Username = cloneString(u.Username)
+	out.DisplayName = cloneString(u.DisplayName)
+	out.AvatarAssetID = cloneInt64(u.AvatarAssetID)
+	return &out
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"linkbio/internal/model"
 )
@@ -95,28 +96,90 @@ func (r *PageRepo) Delete(ctx context.Context, id int64) error {
 	return err
 }
 
-func (r *PageRepo) SavePublishCache(ctx context.Context, pageID int64, compiled json.RawMessage) error {
+func (r *PageRepo) SavePublishCache(ctx context.Context, pageID int64, compiled json.RawMessage, hash string) error {
 	_, err := r.db.Exec(ctx, `
-		INSERT INTO page_publish_cache (page_id, compiled_json)
-		VALUES ($1, $2)
+		INSERT INTO page_publish_cache (page_id, compiled_json, hash)
+		VALUES ($1, $2, $3)
 		ON CONFLICT (page_id) DO UPDATE SET
-			compiled_json = $2, updated_at = NOW()
-	`, pageID, compiled)
+			compiled_json = $2, hash = $3, updated_at = NOW()
+	`, pageID, compiled, hash)
 	return err
 }
 
 func (r *PageRepo) GetPublishCache(ctx context.Context, pageID int64) (*model.PagePublishCache, error) {
 	var cache model.PagePublishCache
 	err := r.db.QueryRow(ctx, `
-		SELECT page_id, compiled_json, published_at, updated_at
+		SELECT page_id, compiled_json, hash, published_at, updated_at
 		FROM page_publish_cache WHERE page_id = $1
-	`, pageID).Scan(&cache.PageID, &cache.CompiledJSON, &cache.PublishedAt, &cache.UpdatedAt)
+	`, pageID).Scan(&cache.PageID, &cache.CompiledJSON, &cache.Hash, &cache.PublishedAt, &cache.UpdatedAt)
 	if err != nil {
 		return nil, err
 	}
 	return &cache, nil
 }
 
+// Unpublish takes a page offline: it goes back to draft and loses its
+// publish cache in one transaction.
+func (r *PageRepo) Unpublish(ctx context.Context, pageID int64) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, `UPDATE bio_pages SET status = 'draft', updated_at = NOW() WHERE id = $1`, pageID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM page_publish_cache WHERE page_id = $1`, pageID); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// ApplyTheme switches a page's theme in one transaction. The page and the
+// custom theme are locked and must both belong to userID, and the custom
+// theme must be based on presetID; otherwise it returns pgx.ErrNoRows.
+func (r *PageRepo) ApplyTheme(ctx context.Context, pageID, userID, presetID int64, customID *int64, mode string) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var ok bool
+	err = tx.QueryRow(ctx, `
+		SELECT true FROM bio_pages WHERE id = $1 AND user_id = $2 FOR UPDATE
+	`, pageID, userID).Scan(&ok)
+	if err != nil {
+		return err
+	}
+	if customID != nil {
+		// FOR SHARE keeps the theme from being deleted or rebased until
+		// the page points at it.
+		err = tx.QueryRow(ctx, `
+			SELECT true FROM themes_custom
+			WHERE id = $1 AND user_id = $2 AND based_on_preset_id = $3
+			FOR SHARE
+		`, *customID, userID, presetID).Scan(&ok)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE bio_pages SET theme_preset_id = $2, theme_custom_id = $3, theme_mode = $4, updated_at = NOW()
+		WHERE id = $1
+	`, pageID, presetID, customID, mode)
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
 // UpdateSettings updates page settings
 func (r *PageRepo) UpdateSettings(ctx context.Context, pageID int64, settings []byte) error {
 	query := `UPDATE bio_pages SET settings = $1, updated_at = NOW() WHERE id = $2`
@@ -0,0 +1,75 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// RateLimitRepo is a ratelimit.Store shared by every API instance.
+type RateLimitRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewRateLimitRepo(db *pgxpool.Pool) *RateLimitRepo {
+	return &RateLimitRepo{db: db}
+}
+
+func (r *RateLimitRepo) Incr(ctx context.Context, key string, window time.Duration) (int, time.Time, error) {
+	var count int
+	var resetAt time.Time
+	err := r.db.QueryRow(ctx, `
+		INSERT INTO rate_limit_buckets (key, count, reset_at)
+		VALUES ($1, 1, NOW() + make_interval(secs => $2))
+		ON CONFLICT (key) DO UPDATE SET
+			count = CASE WHEN rate_limit_buckets.reset_at <= NOW()
+			             THEN 1 ELSE rate_limit_buckets.count + 1 END,
+			reset_at = CASE WHEN rate_limit_buckets.reset_at <= NOW()
+			                THEN EXCLUDED.reset_at ELSE rate_limit_buckets.reset_at END
+		RETURNING count, reset_at
+	`, key, window.Seconds()).Scan(&count, &resetAt)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	return count, resetAt, nil
+}
+
+func (r *RateLimitRepo) Reset(ctx context.Context, key string) error {
+	_, err := r.db.Exec(ctx, `DELETE FROM rate_limit_buckets WHERE key = $1`, key)
+	return err
+}
+
+func (r *RateLimitRepo) Lock(ctx context.Context, key string, until time.Time) error {
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO rate_limit_locks (key, locked_until)
+		VALUES ($1, $2)
+		ON CONFLICT (key) DO UPDATE SET locked_until = GREATEST(rate_limit_locks.locked_until, $2)
+	`, key, until)
+	return err
+}
+
+func (r *RateLimitRepo) LockedUntil(ctx context.Context, key string) (time.Time, error) {
+	var until time.Time
+	err := r.db.QueryRow(ctx, `
+		SELECT locked_until FROM rate_limit_locks WHERE key = $1 AND locked_until > NOW()
+	`, key).Scan(&until)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return until, nil
+}
+
+// DeleteExpired removes stale buckets and locks.
+func (r *RateLimitRepo) DeleteExpired(ctx context.Context) error {
+	if _, err := r.db.Exec(ctx, `DELETE FROM rate_limit_buckets WHERE reset_at <= NOW()`); err != nil {
+		return err
+	}
+	_, err := r.db.Exec(ctx, `DELETE FROM rate_limit_locks WHERE locked_until <= NOW()`)
+	return err
+}
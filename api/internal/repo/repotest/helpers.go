@@ -0,0 +1,122 @@
+package repotest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"linkbio/internal/model"
+)
+
+func (s *Stores) email(name string) string {
+	return name + "@" + s.Token + ".test"
+}
+
+func (s *Stores) hostname(name string) string {
+	return name + "." + s.Token + ".test"
+}
+
+func (s *Stores) user(t *testing.T, name string) *model.User {
+	t.Helper()
+	u, err := s.Users.Create(context.Background(), s.email(name), "hash")
+	must(t, err)
+	return u
+}
+
+func (s *Stores) preset(t *testing.T, key, name, tier string) *model.ThemePreset {
+	t.Helper()
+	p, err := s.SeedPreset(context.Background(), model.ThemePreset{
+		Key: key + "_" + s.Token, Name: name, Tier: tier, Config: json.RawMessage(`{}`),
+	})
+	must(t, err)
+	return p
+}
+
+func (s *Stores) page(t *testing.T, name string) *model.BioPage {
+	t.Helper()
+	user := s.user(t, name)
+	preset := s.preset(t, name, name, "free")
+	page, err := s.Pages.Create(context.Background(), user.ID, preset.ID, name)
+	must(t, err)
+	return page
+}
+
+func must(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// wantCode checks for a Postgres error with the given SQLSTATE.
+func wantCode(t *testing.T, err error, code string) {
+	t.Helper()
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != code {
+		t.Errorf("err = %v, want SQLSTATE %s", err, code)
+	}
+}
+
+func wantNoRows(t *testing.T, err error) {
+	t.Helper()
+	if !errors.Is(err, pgx.ErrNoRows) {
+		t.Errorf("err = %v, want pgx.ErrNoRows", err)
+	}
+}
+
+// wantJSON compares semantically, since JSONB normalizes formatting.
+func wantJSON(t *testing.T, got json.RawMessage, want string) {
+	t.Helper()
+	var g, w interface{}
+	if err := json.Unmarshal(got, &g); err != nil {
+		t.Errorf("invalid JSON %q: %v", got, err)
+		return
+	}
+	_ = json.Unmarshal([]byte(want), &w)
+	if !reflect.DeepEqual(g, w) {
+		t.Errorf("JSON = %s, want %s", got, want)
+	}
+}
+
+func pageIDs(pages []*model.BioPage) []int64 {
+	ids := make([]int64, len(pages))
+	for i, p := range pages {
+		ids[i] = p.ID
+	}
+	return ids
+}
+
+// presetIDs keeps only the given ids, in result order, so presets already
+// in a shared database do not matter.
+func presetIDs(presets []*model.ThemePreset, keep ...int64) []int64 {
+	var ids []int64
+	for _, p := range presets {
+		for _, id := range keep {
+			if p.ID == id {
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids
+}
+
+func blockKeys(blocks []*model.Block) string {
+	var b strings.Builder
+	for _, block := range blocks {
+		b.WriteString(block.SortKey)
+	}
+	return b.String()
+}
+
+func linkKeys(links []*model.Link) string {
+	var b strings.Builder
+	for _, l := range links {
+		b.WriteString(l.SortKey)
+	}
+	return b.String()
+}
@@ -0,0 +1,172 @@
+// Package repotest is a conformance suite for the repo interfaces. It runs
+// the same checks against the in-memory fakes and, when DATABASE_URL is
+// set, against Postgres:
+//
+//	func TestMemoryRepos(t *testing.T)   { repotest.Run(t, repotest.Memory) }
+//	func TestPostgresRepos(t *testing.T) { repotest.Run(t, repotest.Postgres) }
+package repotest
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"os"
+	"testing"
+
+	"linkbio/internal/database"
+	"linkbio/internal/model"
+	"linkbio/internal/repo"
+	"linkbio/internal/repo/memory"
+)
+
+// Stores is one set of repos sharing a database.
+type Stores struct {
+	Users       repo.UserStore
+	Pages       repo.PageStore
+	Blocks      repo.BlockStore
+	Bio         repo.BioStore
+	Themes      repo.ThemeStore
+	Domains     repo.DomainStore
+	Assets      repo.AssetStore
+	Schedules   repo.ScheduleStore
+	Analytics   repo.AnalyticsStore
+	Experiments repo.ExperimentStore
+	ShortLinks  repo.ShortLinkStore
+	Aggregates  repo.PageAggregateStore
+
+	// SeedPreset inserts an official preset, approved unless p.Status says
+	// otherwise.
+	SeedPreset func(ctx context.Context, p model.ThemePreset) (*model.ThemePreset, error)
+	// SetPro gives the user an active Pro subscription.
+	SetPro func(ctx context.Context, userID int64) error
+
+	// Token is unique per Stores so rows from concurrent runs on a shared
+	// database never collide.
+	Token string
+}
+
+// Memory returns fresh in-memory stores.
+func Memory(t *testing.T) *Stores {
+	db := memory.NewDB()
+	return &Stores{
+		Users:       memory.NewUserRepo(db),
+		Pages:       memory.NewPageRepo(db),
+		Blocks:      memory.NewBlockRepo(db),
+		Bio:         memory.NewBioRepo(db),
+		Themes:      memory.NewThemeRepo(db),
+		Domains:     memory.NewDomainRepo(db),
+		Assets:      memory.NewAssetRepo(db),
+		Schedules:   memory.NewScheduleRepo(db),
+		Analytics:   memory.NewAnalyticsRepo(db),
+		Experiments: memory.NewExperimentRepo(db),
+		ShortLinks:  memory.NewShortLinkRepo(db),
+		Aggregates:  memory.NewPageAggregateRepo(db),
+		SeedPreset: func(ctx context.Context, p model.ThemePreset) (*model.ThemePreset, error) {
+			return db.SeedPreset(p)
+		},
+		SetPro: func(ctx context.Context, userID int64) error {
+			db.SetPro(userID, true)
+			return nil
+		},
+		Token: newToken(t),
+	}
+}
+
+// Postgres returns stores on DATABASE_URL after migrating it, or skips the
+// test when the variable is unset. Rows created under Token are removed
+// when the test ends.
+func Postgres(t *testing.T) *Stores {
+	url := os.Getenv("DATABASE_URL")
+	if url == "" {
+		t.Skip("DATABASE_URL not set")
+	}
+
+	ctx := context.Background()
+	db, err := database.Connect(url)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	migrator, err := database.NewMigrator(db)
+	if err != nil {
+		t.Fatalf("load migrations: %v", err)
+	}
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	token := newToken(t)
+	t.Cleanup(func() {
+		defer db.Close()
+		// Domains first (routes cascade), then users (pages, custom themes
+		// cascade), then the presets those pages referenced.
+		for _, q := range []string{
+			`DELETE FROM domains WHERE hostname LIKE '%' || $1 || '%'`,
+			`DELETE FROM users WHERE email LIKE '%' || $1 || '%'`,
+			`DELETE FROM theme_presets WHERE key LIKE '%' || $1 || '%'`,
+			`DELETE FROM theme_stylesheets WHERE hash LIKE '%' || $1 || '%'`,
+		} {
+			if _, err := db.Exec(ctx, q, token); err != nil {
+				t.Errorf("cleanup: %v", err)
+			}
+		}
+	})
+
+	return &Stores{
+		Users:       repo.NewUserRepo(db),
+		Pages:       repo.NewPageRepo(db),
+		Blocks:      repo.NewBlockRepo(db),
+		Bio:         repo.NewBioRepo(db),
+		Themes:      repo.NewThemeRepo(db),
+		Domains:     repo.NewDomainRepo(db),
+		Assets:      repo.NewAssetRepo(db),
+		Schedules:   repo.NewScheduleRepo(db),
+		Analytics:   repo.NewAnalyticsRepo(db),
+		Experiments: repo.NewExperimentRepo(db),
+		ShortLinks:  repo.NewShortLinkRepo(db),
+		Aggregates:  repo.NewPageAggregateRepo(db),
+		SeedPreset: func(ctx context.Context, p model.ThemePreset) (*model.ThemePreset, error) {
+			if p.Tier == "" {
+				p.Tier = "free"
+			}
+			if p.Visibility == "" {
+				p.Visibility = "public"
+			}
+			if p.Status == "" {
+				p.Status = model.PresetApproved
+			}
+			err := db.QueryRow(ctx, `
+				INSERT INTO theme_presets (key, name, tier, visibility, is_official, author_user_id, config, status, published_at)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8, CASE WHEN $8 = 'approved' THEN NOW() END)
+				RETURNING id, published_at, created_at, updated_at
+			`, p.Key, p.Name, p.Tier, p.Visibility, p.IsOfficial, p.AuthorUserID, p.Config, p.Status).Scan(
+				&p.ID, &p.PublishedAt, &p.CreatedAt, &p.UpdatedAt,
+			)
+			if err != nil {
+				return nil, err
+			}
+			return &p, nil
+		},
+		SetPro: func(ctx context.Context, userID int64) error {
+			_, err := db.Exec(ctx, `
+				INSERT INTO plans (code, name) VALUES ('PRO', 'Pro') ON CONFLICT (code) DO NOTHING
+			`)
+			if err != nil {
+				return err
+			}
+			_, err = db.Exec(ctx, `
+				INSERT INTO subscriptions (user_id, plan_id, status)
+				SELECT $1, id, 'active' FROM plans WHERE code = 'PRO'
+			`, userID)
+			return err
+		},
+		Token: token,
+	}
+}
+
+func newToken(t *testing.T) string {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		t.Fatalf("token: %v", err)
+	}
+	return hex.EncodeToString(b)
+}
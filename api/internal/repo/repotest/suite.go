@@ -0,0 +1,1256 @@
+package repotest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"linkbio/internal/model"
+	"linkbio/internal/repo"
+)
+
+// Run checks that the stores returned by newStores behave like the
+// Postgres schema: defaults, constraints, cascades and sort orders.
+func Run(t *testing.T, newStores func(t *testing.T) *Stores) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, s *Stores)
+	}{
+		{"Users", testUsers},
+		{"Pages", testPages},
+		{"PageDeleteCascades", testPageDeleteCascades},
+		{"BlocksAndLinks", testBlocksAndLinks},
+		{"Bio", testBio},
+		{"LinkLimits", testLinkLimits},
+		{"Themes", testThemes},
+		{"PresetReview", testPresetReview},
+		{"PresetVersions", testPresetVersions},
+		{"ApplyTheme", testApplyTheme},
+		{"Stylesheets", testStylesheets},
+		{"Assets", testAssets},
+		{"Schedules", testSchedules},
+		{"Analytics", testAnalytics},
+		{"Experiments", testExperiments},
+		{"ShortLinks", testShortLinks},
+		{"Routes", testRoutes},
+		{"Sitemap", testSitemap},
+		{"Aggregate", testAggregate},
+		{"Concurrency", testConcurrency},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newStores(t))
+		})
+	}
+}
+
+func testUsers(t *testing.T, s *Stores) {
+	ctx := context.Background()
+	email := s.email("alice")
+
+	u, err := s.Users.Create(ctx, email, "hash")
+	must(t, err)
+	if !u.IsActive || u.Username != nil {
+		t.Errorf("new user = %+v, want active without username", u)
+	}
+
+	got, err := s.Users.GetByEmail(ctx, email)
+	must(t, err)
+	if got.ID != u.ID || got.PasswordHash != "hash" {
+		t.Errorf("GetByEmail = %+v", got)
+	}
+
+	_, err = s.Users.Create(ctx, email, "hash")
+	wantCode(t, err, "23505")
+
+	name := "u" + s.Token
+	must(t, s.Users.SetUsername(ctx, u.ID, name))
+	exists, err := s.Users.UsernameExists(ctx, name)
+	must(t, err)
+	if !exists {
+		t.Error("UsernameExists = false after SetUsername")
+	}
+	byName, err := s.Users.GetByUsername(ctx, name)
+	must(t, err)
+	if byName.ID != u.ID {
+		t.Errorf("GetByUsername = %d, want %d", byName.ID, u.ID)
+	}
+
+	other := s.user(t, "bob")
+	wantCode(t, s.Users.SetUsername(ctx, other.ID, name), "23505")
+
+	must(t, s.Users.UpdateDisplayName(ctx, u.ID, "Alice"))
+	got, err = s.Users.GetByID(ctx, u.ID)
+	must(t, err)
+	if got.DisplayName == nil || *got.DisplayName != "Alice" {
+		t.Errorf("display name = %v", got.DisplayName)
+	}
+
+	_, err = s.Users.GetByID(ctx, -1)
+	wantNoRows(t, err)
+}
+
+func testPages(t *testing.T, s *Stores) {
+	ctx := context.Background()
+	user := s.user(t, "pages")
+	preset := s.preset(t, "pages", "Pages", "free")
+
+	first, err := s.Pages.Create(ctx, user.ID, preset.ID, "First")
+	must(t, err)
+	if first.Locale != "vi" || first.Status != "draft" || first.AccessType != "public" || first.ThemeMode != "light" {
+		t.Errorf("page defaults = %+v", first)
+	}
+	wantJSON(t, first.Settings, `{}`)
+
+	second, err := s.Pages.Create(ctx, user.ID, preset.ID, "Second")
+	must(t, err)
+
+	pages, err := s.Pages.ListByUser(ctx, user.ID)
+	must(t, err)
+	if len(pages) != 2 || pages[0].ID != second.ID || pages[1].ID != first.ID {
+		t.Errorf("ListByUser order = %v, want newest first", pageIDs(pages))
+	}
+
+	_, err = s.Pages.Create(ctx, -1, preset.ID, "Orphan")
+	wantCode(t, err, "23503")
+
+	first.ThemeMode = "dark"
+	first.Status = "published"
+	must(t, s.Pages.Update(ctx, first))
+	first.ThemeMode = "sepia"
+	wantCode(t, s.Pages.Update(ctx, first), "23514")
+
+	must(t, s.Pages.UpdateSettings(ctx, first.ID, []byte(`{"bio":"hi"}`)))
+	got, err := s.Pages.GetByID(ctx, first.ID)
+	must(t, err)
+	if got.ThemeMode != "dark" || got.Status != "published" {
+		t.Errorf("after Update = %+v", got)
+	}
+	wantJSON(t, got.Settings, `{"bio":"hi"}`)
+
+	_, err = s.Pages.GetPublishCache(ctx, first.ID)
+	wantNoRows(t, err)
+	must(t, s.Pages.SavePublishCache(ctx, first.ID, json.RawMessage(`{"v":1}`), "h1"))
+	cache1, err := s.Pages.GetPublishCache(ctx, first.ID)
+	must(t, err)
+	must(t, s.Pages.SavePublishCache(ctx, first.ID, json.RawMessage(`{"v":2}`), "h2"))
+	cache2, err := s.Pages.GetPublishCache(ctx, first.ID)
+	must(t, err)
+	if cache2.Hash != "h2" || !cache2.PublishedAt.Equal(cache1.PublishedAt) {
+		t.Errorf("publish cache upsert = %+v, first = %+v", cache2, cache1)
+	}
+	wantJSON(t, cache2.CompiledJSON, `{"v":2}`)
+}
+
+func testPageDeleteCascades(t *testing.T, s *Stores) {
+	ctx := context.Background()
+	user := s.user(t, "cascade")
+	preset := s.preset(t, "cascade", "Cascade", "free")
+	page, err := s.Pages.Create(ctx, user.ID, preset.ID, "Doomed")
+	must(t, err)
+
+	group, err := s.Blocks.CreateLinkGroup(ctx, page.ID, nil, "list")
+	must(t, err)
+	block, err := s.Blocks.CreateBlock(ctx, page.ID, "link_group", "a", &group.ID, nil)
+	must(t, err)
+	link, err := s.Blocks.CreateLink(ctx, group.ID, "Site", "https://example.com", "a")
+	must(t, err)
+	must(t, s.Pages.SavePublishCache(ctx, page.ID, json.RawMessage(`{}`), "h"))
+	domain, err := s.Domains.Create(ctx, &user.ID, s.hostname("cascade"), false)
+	must(t, err)
+	_, err = s.Domains.CreateRoute(ctx, page.ID, domain.ID, "/")
+	must(t, err)
+
+	must(t, s.Pages.Delete(ctx, page.ID))
+
+	_, err = s.Pages.GetByID(ctx, page.ID)
+	wantNoRows(t, err)
+	_, err = s.Bio.GetBlockByID(ctx, block.ID)
+	wantNoRows(t, err)
+	_, err = s.Bio.GetLinkByID(ctx, link.ID)
+	wantNoRows(t, err)
+	_, err = s.Bio.GetGroupOwnerID(ctx, group.ID)
+	wantNoRows(t, err)
+	_, err = s.Pages.GetPublishCache(ctx, page.ID)
+	wantNoRows(t, err)
+	_, err = s.Domains.GetCurrentRouteByPage(ctx, page.ID)
+	wantNoRows(t, err)
+	if _, err := s.Domains.GetByID(ctx, domain.ID); err != nil {
+		t.Errorf("domain removed with page: %v", err)
+	}
+}
+
+func testBlocksAndLinks(t *testing.T, s *Stores) {
+	ctx := context.Background()
+	page := s.page(t, "blocks")
+
+	for _, key := range []string{"c", "a", "b"} {
+		_, err := s.Blocks.CreateBlock(ctx, page.ID, "text", key, nil, json.RawMessage(`{"text":"`+key+`"}`))
+		must(t, err)
+	}
+	blocks, err := s.Blocks.GetBlocksByPage(ctx, page.ID)
+	must(t, err)
+	if got := blockKeys(blocks); got != "abc" {
+		t.Errorf("block order = %q, want abc", got)
+	}
+
+	_, err = s.Blocks.CreateBlock(ctx, page.ID, "link_group", "d", nil, nil)
+	wantCode(t, err, "23514")
+	_, err = s.Blocks.CreateLinkGroup(ctx, page.ID, nil, "carousel")
+	wantCode(t, err, "23514")
+
+	blocks[0].SortKey = "z"
+	blocks[0].IsVisible = false
+	must(t, s.Blocks.UpdateBlock(ctx, blocks[0]))
+	blocks, err = s.Blocks.GetBlocksByPage(ctx, page.ID)
+	must(t, err)
+	if got := blockKeys(blocks); got != "bcz" || blocks[2].IsVisible {
+		t.Errorf("after UpdateBlock order = %q", got)
+	}
+
+	group, err := s.Blocks.CreateLinkGroup(ctx, page.ID, nil, "list")
+	must(t, err)
+	wantJSON(t, group.LayoutConfig, `{}`)
+	for _, key := range []string{"b", "c", "a"} {
+		_, err := s.Blocks.CreateLink(ctx, group.ID, "Link "+key, "https://example.com/"+key, key)
+		must(t, err)
+	}
+	links, err := s.Blocks.GetLinksByGroup(ctx, group.ID)
+	must(t, err)
+	if got := linkKeys(links); got != "abc" {
+		t.Errorf("link order = %q, want abc", got)
+	}
+	if !links[0].IsActive {
+		t.Error("new link inactive")
+	}
+	if links[0].UTMTemplate != nil || group.UTMTemplate != nil {
+		t.Error("new link or group has a UTM template")
+	}
+
+	utm := "utm_source=linkbio&utm_campaign={page}"
+	links[0].UTMTemplate = &utm
+	must(t, s.Blocks.UpdateLink(ctx, links[0]))
+	group.UTMTemplate = &utm
+	must(t, s.Blocks.UpdateLinkGroup(ctx, group))
+	links, err = s.Blocks.GetLinksByGroup(ctx, group.ID)
+	must(t, err)
+	groups, err := s.Blocks.GetLinkGroupsByPage(ctx, page.ID)
+	must(t, err)
+	if links[0].UTMTemplate == nil || *links[0].UTMTemplate != utm || groups[0].UTMTemplate == nil || *groups[0].UTMTemplate != utm {
+		t.Errorf("UTM templates not saved: link %v, group %v", links[0].UTMTemplate, groups[0].UTMTemplate)
+	}
+
+	rules := `[{"country":["VN"],"url":"https://example.vn/"}]`
+	links[0].Rules = json.RawMessage(rules)
+	must(t, s.Blocks.UpdateLink(ctx, links[0]))
+	link, err := s.Bio.GetLinkByID(ctx, links[0].ID)
+	must(t, err)
+	wantJSON(t, link.Rules, rules)
+
+	ref, err := s.Blocks.CreateBlock(ctx, page.ID, "link_group", "zz", &group.ID, nil)
+	must(t, err)
+	// The FK would null ref_id, which the check constraint rejects.
+	wantCode(t, s.Blocks.DeleteLinkGroup(ctx, group.ID), "23514")
+
+	must(t, s.Blocks.DeleteBlock(ctx, ref.ID))
+	must(t, s.Blocks.DeleteLinkGroup(ctx, group.ID))
+	links, err = s.Blocks.GetLinksByGroup(ctx, group.ID)
+	must(t, err)
+	if len(links) != 0 {
+		t.Errorf("links survived their group: %d", len(links))
+	}
+}
+
+func testBio(t *testing.T, s *Stores) {
+	ctx := context.Background()
+	s.preset(t, "bio", "Bio", "free")
+	user := s.user(t, "bio")
+
+	page, err := s.Bio.GetOrCreatePage(ctx, user.ID)
+	must(t, err)
+	again, err := s.Bio.GetOrCreatePage(ctx, user.ID)
+	must(t, err)
+	if again.ID != page.ID {
+		t.Errorf("GetOrCreatePage created a second page: %d, %d", page.ID, again.ID)
+	}
+	if page.Title == nil || *page.Title != "My Bio" {
+		t.Errorf("default title = %v", page.Title)
+	}
+
+	_, err = s.Bio.GetLastBlockSortKey(ctx, page.ID)
+	wantNoRows(t, err)
+
+	group, err := s.Blocks.CreateLinkGroup(ctx, page.ID, nil, "list")
+	must(t, err)
+	block, err := s.Blocks.CreateBlock(ctx, page.ID, "link_group", "m", &group.ID, nil)
+	must(t, err)
+	_, err = s.Blocks.CreateBlock(ctx, page.ID, "text", "b", nil, nil)
+	must(t, err)
+	link, err := s.Blocks.CreateLink(ctx, group.ID, "A", "https://a.example", "k")
+	must(t, err)
+
+	last, err := s.Bio.GetLastBlockSortKey(ctx, page.ID)
+	must(t, err)
+	if last != "m" {
+		t.Errorf("last block key = %q, want m", last)
+	}
+	lastLink, err := s.Bio.GetLastLinkSortKey(ctx, group.ID)
+	must(t, err)
+	if lastLink != "k" {
+		t.Errorf("last link key = %q, want k", lastLink)
+	}
+
+	for name, get := range map[string]func() (int64, error){
+		"block": func() (int64, error) { return s.Bio.GetBlockOwnerID(ctx, block.ID) },
+		"group": func() (int64, error) { return s.Bio.GetGroupOwnerID(ctx, group.ID) },
+		"link":  func() (int64, error) { return s.Bio.GetLinkOwnerID(ctx, link.ID) },
+	} {
+		owner, err := get()
+		must(t, err)
+		if owner != user.ID {
+			t.Errorf("%s owner = %d, want %d", name, owner, user.ID)
+		}
+	}
+	_, err = s.Bio.GetLinkOwnerID(ctx, -1)
+	wantNoRows(t, err)
+
+	must(t, s.Bio.UpdateBlockSortKey(ctx, block.ID, "a"))
+	got, err := s.Bio.GetBlockByID(ctx, block.ID)
+	must(t, err)
+	if got.SortKey != "a" {
+		t.Errorf("sort key = %q, want a", got.SortKey)
+	}
+}
+
+func testLinkLimits(t *testing.T, s *Stores) {
+	ctx := context.Background()
+	page := s.page(t, "limits")
+	group, err := s.Blocks.CreateLinkGroup(ctx, page.ID, nil, "list")
+	must(t, err)
+	link, err := s.Blocks.CreateLink(ctx, group.ID, "Drop", "https://example.com/drop", "a")
+	must(t, err)
+	if link.Fallback != model.LinkFallbackHide || link.MaxClicks != nil || link.ExpiresAt != nil || link.ClickCount != 0 {
+		t.Errorf("new link limits = %+v", link)
+	}
+
+	link.Fallback = model.LinkFallbackRedirect
+	wantCode(t, s.Blocks.UpdateLink(ctx, link), "23514")
+	zero := 0
+	link.Fallback, link.MaxClicks = model.LinkFallbackHide, &zero
+	wantCode(t, s.Blocks.UpdateLink(ctx, link), "23514")
+
+	expires := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	max, fallback := 5, "https://example.com/sold-out"
+	link.ExpiresAt, link.MaxClicks = &expires, &max
+	link.Fallback, link.FallbackURL = model.LinkFallbackRedirect, &fallback
+	must(t, s.Blocks.UpdateLink(ctx, link))
+	got, err := s.Bio.GetLinkByID(ctx, link.ID)
+	must(t, err)
+	if got.ExpiresAt == nil || !got.ExpiresAt.Equal(expires) || got.MaxClicks == nil || *got.MaxClicks != max ||
+		got.Fallback != model.LinkFallbackRedirect || got.FallbackURL == nil || *got.FallbackURL != fallback {
+		t.Errorf("limits not saved: %+v", got)
+	}
+
+	const n = 20
+	var wg sync.WaitGroup
+	var claimed atomic.Int32
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := s.Bio.ClaimLinkClick(ctx, link.ID, max)
+			switch {
+			case err == nil:
+				claimed.Add(1)
+			case !errors.Is(err, pgx.ErrNoRows):
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+	if claimed.Load() != int32(max) {
+		t.Errorf("claimed %d clicks, want %d", claimed.Load(), max)
+	}
+
+	// Raising the cap lets clicks through again; edits keep the count.
+	must(t, s.Blocks.UpdateLink(ctx, link))
+	count, err := s.Bio.ClaimLinkClick(ctx, link.ID, max+1)
+	must(t, err)
+	if count != max+1 {
+		t.Errorf("count = %d, want %d", count, max+1)
+	}
+	counts, err := s.Bio.GetLinkClickCounts(ctx, []int64{link.ID, link.ID + 1000000})
+	must(t, err)
+	if len(counts) != 1 || counts[link.ID] != max+1 {
+		t.Errorf("counts = %v", counts)
+	}
+}
+
+func testThemes(t *testing.T, s *Stores) {
+	ctx := context.Background()
+	zeta := s.preset(t, "zeta", "Zeta "+s.Token, "pro")
+	alpha := s.preset(t, "alpha", "Alpha "+s.Token, "free")
+	hidden, err := s.SeedPreset(ctx, model.ThemePreset{
+		Key: "hidden_" + s.Token, Name: "Hidden", Visibility: "private", Config: json.RawMessage(`{}`),
+	})
+	must(t, err)
+
+	all, err := s.Themes.GetPresets(ctx, "")
+	must(t, err)
+	if got := presetIDs(all, alpha.ID, zeta.ID, hidden.ID); fmt.Sprint(got) != fmt.Sprint([]int64{alpha.ID, zeta.ID}) {
+		t.Errorf("GetPresets = %v, want public presets by name", got)
+	}
+	pro, err := s.Themes.GetPresets(ctx, "pro")
+	must(t, err)
+	if got := presetIDs(pro, alpha.ID, zeta.ID); fmt.Sprint(got) != fmt.Sprint([]int64{zeta.ID}) {
+		t.Errorf("GetPresets(pro) = %v", got)
+	}
+	byKey, err := s.Themes.GetPresetByKey(ctx, alpha.Key)
+	must(t, err)
+	if byKey.ID != alpha.ID {
+		t.Errorf("GetPresetByKey = %d, want %d", byKey.ID, alpha.ID)
+	}
+
+	user := s.user(t, "themes")
+	a, err := s.Themes.CreateCustom(ctx, user.ID, alpha.ID, nil, "A", json.RawMessage(`{"a":1}`), nil, "ha")
+	must(t, err)
+	if a.Name == nil || *a.Name != "A" {
+		t.Errorf("custom name = %v, want A", a.Name)
+	}
+	// Copies with the same content are allowed; the hash finds the oldest.
+	copyA, err := s.Themes.CreateCustom(ctx, user.ID, alpha.ID, nil, "A (copy)", json.RawMessage(`{"a":1}`), nil, "ha")
+	must(t, err)
+	byHash, err := s.Themes.GetCustomByHash(ctx, user.ID, "ha")
+	must(t, err)
+	if byHash.ID != a.ID {
+		t.Errorf("GetCustomByHash = %d, want oldest %d", byHash.ID, a.ID)
+	}
+	b, err := s.Themes.CreateCustom(ctx, user.ID, zeta.ID, nil, "B", json.RawMessage(`{"b":1}`), json.RawMessage(`{"b":1}`), "hb")
+	must(t, err)
+	wantJSON(t, b.CompiledConfig, `{"b":1}`)
+
+	library, err := s.Themes.ListCustomByUser(ctx, user.ID)
+	must(t, err)
+	if len(library) != 3 || library[0].ID != b.ID {
+		t.Errorf("library = %v, want newest first", library)
+	}
+	must(t, s.Themes.UpdateCustom(ctx, a.ID, json.RawMessage(`{"a":2}`), json.RawMessage(`{}`), "ha2"))
+	library, err = s.Themes.ListCustomByUser(ctx, user.ID)
+	must(t, err)
+	if len(library) != 3 || library[0].ID != a.ID || library[0].Hash != "ha2" {
+		t.Errorf("library after update = %v, want the updated theme first", library)
+	}
+
+	must(t, s.Themes.RenameCustom(ctx, copyA.ID, user.ID, "Renamed"))
+	wantNoRows(t, s.Themes.RenameCustom(ctx, copyA.ID, -1, "Stolen"))
+	library, err = s.Themes.ListCustomByUser(ctx, user.ID)
+	must(t, err)
+	if len(library) != 3 || library[0].ID != copyA.ID || *library[0].Name != "Renamed" || library[1].ID != a.ID {
+		t.Errorf("library = %v, want most recently edited first", library)
+	}
+
+	page, err := s.Pages.Create(ctx, user.ID, alpha.ID, "Themed")
+	must(t, err)
+	page.ThemeCustomID = &a.ID
+	must(t, s.Pages.Update(ctx, page))
+	_, err = s.Themes.DeleteCustom(ctx, a.ID, user.ID, false)
+	wantCode(t, err, "23503")
+
+	fellBack, err := s.Themes.DeleteCustom(ctx, a.ID, user.ID, true)
+	must(t, err)
+	if fmt.Sprint(fellBack) != fmt.Sprint([]int64{page.ID}) {
+		t.Errorf("fallback pages = %v, want [%d]", fellBack, page.ID)
+	}
+	page, err = s.Pages.GetByID(ctx, page.ID)
+	must(t, err)
+	if page.ThemeCustomID != nil || page.ThemePresetID != alpha.ID {
+		t.Errorf("page after fallback = %+v", page)
+	}
+
+	// Someone else's id, or a missing one, deletes nothing.
+	_, err = s.Themes.DeleteCustom(ctx, b.ID, -1, true)
+	wantNoRows(t, err)
+	_, err = s.Themes.DeleteCustom(ctx, b.ID, user.ID, false)
+	must(t, err)
+	_, err = s.Themes.GetCustomByID(ctx, b.ID)
+	wantNoRows(t, err)
+	_, err = s.Themes.DeleteCustom(ctx, b.ID, user.ID, false)
+	wantNoRows(t, err)
+}
+
+func testPresetVersions(t *testing.T, s *Stores) {
+	ctx := context.Background()
+	preset, err := s.SeedPreset(ctx, model.ThemePreset{
+		Key: "versioned_" + s.Token, Name: "Versioned",
+		Config: json.RawMessage(`{"meta":{"version":"1.2.0","schemaVersion":1},"tokens":{"a":1}}`),
+	})
+	must(t, err)
+	if preset.CurrentVersionID == nil || preset.Version == nil || *preset.Version != "1.2.0" {
+		t.Fatalf("seeded preset = %+v, want first version 1.2.0", preset)
+	}
+	first, err := s.Themes.GetPresetVersion(ctx, *preset.CurrentVersionID)
+	must(t, err)
+	wantJSON(t, first.Config, `{"meta":{"version":"1.2.0","schemaVersion":1},"tokens":{"a":1}}`)
+
+	user := s.user(t, "versions")
+	custom, err := s.Themes.CreateCustom(ctx, user.ID, preset.ID, &first.ID, "V", json.RawMessage(`{"tokens":{"a":2}}`), nil, "hv")
+	must(t, err)
+	if custom.PresetVersionID == nil || *custom.PresetVersionID != first.ID {
+		t.Errorf("custom pinned to %v, want %d", custom.PresetVersionID, first.ID)
+	}
+
+	next := json.RawMessage(`{"meta":{"version":"2.0.0","schemaVersion":1},"tokens":{"b":1}}`)
+	second, err := s.Themes.PublishPresetVersion(ctx, preset.ID, "2.0.0", 1, next)
+	must(t, err)
+	_, err = s.Themes.PublishPresetVersion(ctx, preset.ID, "2.0.0", 1, next)
+	wantCode(t, err, "23505")
+
+	current, err := s.Themes.GetPresetByID(ctx, preset.ID)
+	must(t, err)
+	if current.CurrentVersionID == nil || *current.CurrentVersionID != second.ID || *current.Version != "2.0.0" {
+		t.Errorf("current version = %v, want %d", current.CurrentVersionID, second.ID)
+	}
+	wantJSON(t, current.Config, string(next))
+	versions, err := s.Themes.ListPresetVersions(ctx, preset.ID)
+	must(t, err)
+	if len(versions) != 2 || versions[0].ID != second.ID {
+		t.Errorf("versions = %v, want newest first", versions)
+	}
+
+	// Customs only move between versions of their own preset.
+	other := s.preset(t, "unrelated", "Unrelated", "free")
+	wantNoRows(t, s.Themes.RebaseCustom(ctx, custom.ID, *other.CurrentVersionID, json.RawMessage(`{}`), nil, "hx"))
+	must(t, s.Themes.RebaseCustom(ctx, custom.ID, second.ID, json.RawMessage(`{}`), json.RawMessage(`{}`), "hv2"))
+	rebased, err := s.Themes.GetCustomByID(ctx, custom.ID)
+	must(t, err)
+	if *rebased.PresetVersionID != second.ID || rebased.Hash != "hv2" {
+		t.Errorf("rebased custom = %+v", rebased)
+	}
+
+	draft, err := s.Themes.CreatePreset(ctx, user.ID, "unpublished_"+s.Token, "Draft", "free", "public", json.RawMessage(`{}`))
+	must(t, err)
+	if draft.CurrentVersionID != nil {
+		t.Errorf("draft has version %d", *draft.CurrentVersionID)
+	}
+	_, err = s.Themes.PublishPresetVersion(ctx, draft.ID, "1.0.0", 1, json.RawMessage(`{}`))
+	wantNoRows(t, err)
+}
+
+func testApplyTheme(t *testing.T, s *Stores) {
+	ctx := context.Background()
+	owner := s.user(t, "owner")
+	other := s.user(t, "other")
+	base := s.preset(t, "base", "Base", "free")
+	pro := s.preset(t, "fancy", "Fancy", "pro")
+	page, err := s.Pages.Create(ctx, owner.ID, base.ID, "Apply")
+	must(t, err)
+
+	mine, err := s.Themes.CreateCustom(ctx, owner.ID, pro.ID, nil, "Mine", json.RawMessage(`{}`), nil, "mine")
+	must(t, err)
+	theirs, err := s.Themes.CreateCustom(ctx, other.ID, pro.ID, nil, "Theirs", json.RawMessage(`{}`), nil, "theirs")
+	must(t, err)
+
+	wantNoRows(t, s.Pages.ApplyTheme(ctx, page.ID, other.ID, pro.ID, nil, "dark"))
+	wantNoRows(t, s.Pages.ApplyTheme(ctx, page.ID, owner.ID, pro.ID, &theirs.ID, "dark"))
+	wantNoRows(t, s.Pages.ApplyTheme(ctx, page.ID, owner.ID, base.ID, &mine.ID, "dark"))
+	wantCode(t, s.Pages.ApplyTheme(ctx, page.ID, owner.ID, pro.ID, &mine.ID, "neon"), "23514")
+
+	must(t, s.Pages.ApplyTheme(ctx, page.ID, owner.ID, pro.ID, &mine.ID, "auto"))
+	must(t, s.Pages.ApplyTheme(ctx, page.ID, owner.ID, pro.ID, &mine.ID, "dark"))
+	got, err := s.Pages.GetByID(ctx, page.ID)
+	must(t, err)
+	if got.ThemePresetID != pro.ID || got.ThemeCustomID == nil || *got.ThemeCustomID != mine.ID || got.ThemeMode != "dark" {
+		t.Errorf("applied page = %+v", got)
+	}
+
+	isPro, err := s.Users.IsPro(ctx, owner.ID)
+	must(t, err)
+	if isPro {
+		t.Error("new user is Pro")
+	}
+	must(t, s.SetPro(ctx, owner.ID))
+	isPro, err = s.Users.IsPro(ctx, owner.ID)
+	must(t, err)
+	if !isPro {
+		t.Error("subscribed user is not Pro")
+	}
+}
+
+func testStylesheets(t *testing.T, s *Stores) {
+	ctx := context.Background()
+	hash := "css-" + s.Token
+
+	_, err := s.Themes.GetStylesheet(ctx, hash)
+	wantNoRows(t, err)
+
+	must(t, s.Themes.SaveStylesheet(ctx, hash, []byte(":root { --a: 1; }")))
+	// Content-addressed: a second save under the same hash keeps the first.
+	must(t, s.Themes.SaveStylesheet(ctx, hash, []byte(":root { --a: 2; }")))
+	css, err := s.Themes.GetStylesheet(ctx, hash)
+	must(t, err)
+	if string(css) != ":root { --a: 1; }" {
+		t.Errorf("stylesheet = %q", css)
+	}
+}
+
+func testAssets(t *testing.T, s *Stores) {
+	ctx := context.Background()
+	owner := s.user(t, "owner")
+	color := "#336699"
+
+	_, err := s.Assets.Create(ctx, &model.Asset{UserID: &owner.ID, Scope: "elsewhere", Type: "image", Provider: "s3", StorageKey: "k"})
+	wantCode(t, err, "23514")
+
+	asset, err := s.Assets.Create(ctx, &model.Asset{
+		UserID: &owner.ID, Scope: "user_upload", Type: "image", Provider: "s3",
+		StorageKey: "wallpapers/" + s.Token, DominantColor: &color,
+	})
+	must(t, err)
+	got, err := s.Assets.GetByID(ctx, asset.ID)
+	must(t, err)
+	if got.DominantColor == nil || *got.DominantColor != color || got.StorageKey != asset.StorageKey {
+		t.Errorf("asset = %+v", got)
+	}
+
+	_, err = s.Assets.GetByID(ctx, asset.ID+1000000)
+	wantNoRows(t, err)
+
+	// Generated assets are unique by key; uploads may share one.
+	key := "og-" + s.Token
+	generated, err := s.Assets.Create(ctx, &model.Asset{UserID: &owner.ID, Scope: "generated", Type: "image", Provider: "db", StorageKey: key})
+	must(t, err)
+	_, err = s.Assets.Create(ctx, &model.Asset{UserID: &owner.ID, Scope: "generated", Type: "image", Provider: "db", StorageKey: key})
+	wantCode(t, err, "23505")
+	_, err = s.Assets.Create(ctx, &model.Asset{UserID: &owner.ID, Scope: "user_upload", Type: "image", Provider: "s3", StorageKey: key})
+	must(t, err)
+	got, err = s.Assets.GetByStorageKey(ctx, "generated", key)
+	must(t, err)
+	if got.ID != generated.ID {
+		t.Errorf("GetByStorageKey = %d, want %d", got.ID, generated.ID)
+	}
+	_, err = s.Assets.GetByStorageKey(ctx, "generated", key+"-missing")
+	wantNoRows(t, err)
+
+	must(t, s.Assets.SaveBlob(ctx, key, []byte("first")))
+	must(t, s.Assets.SaveBlob(ctx, key, []byte("second")))
+	data, err := s.Assets.GetBlob(ctx, key)
+	must(t, err)
+	if string(data) != "first" {
+		t.Errorf("blob = %q", data)
+	}
+	_, err = s.Assets.GetBlob(ctx, key+"-missing")
+	wantNoRows(t, err)
+
+	// Uploads without a color are listed for the backfill; generated
+	// images and colored uploads are not.
+	blank, err := s.Assets.Create(ctx, &model.Asset{UserID: &owner.ID, Scope: "user_upload", Type: "image", Provider: "db", StorageKey: "blank-" + s.Token})
+	must(t, err)
+	missing, err := s.Assets.ListMissingColor(ctx, generated.ID, 1000)
+	must(t, err)
+	var listed []int64
+	for _, a := range missing {
+		if a.ID == generated.ID || a.ID == asset.ID {
+			t.Errorf("ListMissingColor listed asset %d", a.ID)
+		}
+		if a.ID == blank.ID {
+			listed = append(listed, a.ID)
+		}
+	}
+	if len(listed) != 1 {
+		t.Errorf("ListMissingColor missed asset %d", blank.ID)
+	}
+	must(t, s.Assets.SetDominantColor(ctx, blank.ID, color))
+	got, err = s.Assets.GetByID(ctx, blank.ID)
+	must(t, err)
+	if got.DominantColor == nil || *got.DominantColor != color {
+		t.Errorf("dominant color = %v", got.DominantColor)
+	}
+	wantNoRows(t, s.Assets.SetDominantColor(ctx, blank.ID+1000000, color))
+}
+
+func testAnalytics(t *testing.T, s *Stores) {
+	ctx := context.Background()
+	page := s.page(t, "analytics")
+	group, err := s.Blocks.CreateLinkGroup(ctx, page.ID, nil, "list")
+	must(t, err)
+	kept, err := s.Blocks.CreateLink(ctx, group.ID, "Kept", "https://example.com/kept", "a")
+	must(t, err)
+	gone, err := s.Blocks.CreateLink(ctx, group.ID, "Gone", "https://example.com/gone", "b")
+	must(t, err)
+
+	pageID, err := s.Bio.GetLinkPageID(ctx, kept.ID)
+	must(t, err)
+	if pageID != page.ID {
+		t.Errorf("GetLinkPageID = %d, want %d", pageID, page.ID)
+	}
+	_, err = s.Bio.GetLinkPageID(ctx, gone.ID+1000000)
+	wantNoRows(t, err)
+
+	since := time.Now().Add(-time.Minute)
+	must(t, s.Analytics.RecordView(ctx, page.ID, "", model.VariantTag{}))
+	must(t, s.Analytics.RecordView(ctx, page.ID, "qr", model.VariantTag{}))
+	must(t, s.Analytics.RecordView(ctx, page.ID, "qr", model.VariantTag{}))
+	must(t, s.Analytics.RecordClick(ctx, page.ID, kept.ID, "qr", model.VariantTag{}))
+	must(t, s.Analytics.RecordClick(ctx, page.ID, kept.ID, "", model.VariantTag{}))
+	must(t, s.Analytics.RecordClick(ctx, page.ID, gone.ID, "", model.VariantTag{}))
+	wantCode(t, s.Analytics.RecordView(ctx, page.ID+1000000, "", model.VariantTag{}), "23503")
+
+	// Deleting a link keeps its clicks in the page totals.
+	must(t, s.Blocks.DeleteLink(ctx, gone.ID))
+
+	summary, err := s.Analytics.Summary(ctx, page.ID, since)
+	must(t, err)
+	if summary.Views != 3 || summary.Clicks != 3 {
+		t.Errorf("totals = %d views, %d clicks", summary.Views, summary.Clicks)
+	}
+	wantSources := []model.SourceCount{{Source: "qr", Views: 2, Clicks: 1}, {Source: "", Views: 1, Clicks: 2}}
+	if fmt.Sprint(summary.Sources) != fmt.Sprint(wantSources) {
+		t.Errorf("sources = %v, want %v", summary.Sources, wantSources)
+	}
+	if len(summary.Links) != 1 || summary.Links[0] != (model.LinkClicks{LinkID: kept.ID, Clicks: 2}) {
+		t.Errorf("links = %v", summary.Links)
+	}
+
+	later, err := s.Analytics.Summary(ctx, page.ID, time.Now().Add(time.Hour))
+	must(t, err)
+	if later.Views != 0 || later.Clicks != 0 || len(later.Sources) != 0 || len(later.Links) != 0 {
+		t.Errorf("summary after all events = %+v", later)
+	}
+}
+
+func testExperiments(t *testing.T, s *Stores) {
+	ctx := context.Background()
+	page := s.page(t, "experiment")
+	group, err := s.Blocks.CreateLinkGroup(ctx, page.ID, nil, "list")
+	must(t, err)
+	link, err := s.Blocks.CreateLink(ctx, group.ID, "Shop", "https://example.com/shop", "a")
+	must(t, err)
+
+	_, err = s.Experiments.GetRunning(ctx, page.ID)
+	wantNoRows(t, err)
+
+	variants := []model.ExperimentVariant{
+		{Key: "a", Name: "Control", Weight: 50},
+		{Key: "b", Name: "Urgent", Weight: 50, LinkTitles: []model.LinkTitle{{LinkID: link.ID, Title: "Shop now"}}, BlockOrder: []int64{2, 1}},
+	}
+	first, err := s.Experiments.Create(ctx, page.ID, "Copy", variants)
+	must(t, err)
+	if first.Status != model.ExperimentRunning || first.Winner != nil || first.EndedAt != nil {
+		t.Errorf("new experiment = %+v", first)
+	}
+	if fmt.Sprint(first.Variants) != fmt.Sprint(variants) {
+		t.Errorf("variants = %v, want %v", first.Variants, variants)
+	}
+	_, err = s.Experiments.Create(ctx, page.ID, "Second", variants)
+	wantCode(t, err, "23505")
+	_, err = s.Experiments.Create(ctx, page.ID+1000000, "Orphan", variants)
+	wantCode(t, err, "23503")
+
+	running, err := s.Experiments.GetRunning(ctx, page.ID)
+	must(t, err)
+	if running.ID != first.ID {
+		t.Errorf("running = %d, want %d", running.ID, first.ID)
+	}
+
+	tagA := model.VariantTag{ExperimentID: first.ID, Variant: "a"}
+	tagB := model.VariantTag{ExperimentID: first.ID, Variant: "b"}
+	must(t, s.Analytics.RecordView(ctx, page.ID, "", tagA))
+	must(t, s.Analytics.RecordView(ctx, page.ID, "", tagA))
+	must(t, s.Analytics.RecordView(ctx, page.ID, "qr", tagB))
+	must(t, s.Analytics.RecordView(ctx, page.ID, "", model.VariantTag{}))
+	must(t, s.Analytics.RecordClick(ctx, page.ID, link.ID, "", tagB))
+	must(t, s.Analytics.RecordClick(ctx, page.ID, link.ID, "", model.VariantTag{}))
+	wantCode(t, s.Analytics.RecordView(ctx, page.ID, "", model.VariantTag{ExperimentID: first.ID + 1000000, Variant: "a"}), "23503")
+
+	results, err := s.Experiments.Results(ctx, first.ID)
+	must(t, err)
+	want := []model.VariantCount{{Variant: "a", Views: 2}, {Variant: "b", Views: 1, Clicks: 1}}
+	if fmt.Sprint(results) != fmt.Sprint(want) {
+		t.Errorf("results = %v, want %v", results, want)
+	}
+
+	block, err := s.Blocks.CreateBlock(ctx, page.ID, "link_group", "a", &group.ID, nil)
+	must(t, err)
+	winner := "b"
+	must(t, s.Experiments.End(ctx, first.ID, &winner, &model.Promotion{
+		LinkTitles: []model.LinkTitle{{LinkID: link.ID, Title: "Shop now"}},
+		SortKeys:   map[int64]string{block.ID: "b"},
+	}))
+	// Ending again fails and promotes nothing.
+	wantNoRows(t, s.Experiments.End(ctx, first.ID, nil, &model.Promotion{
+		LinkTitles: []model.LinkTitle{{LinkID: link.ID, Title: "Too late"}},
+	}))
+	links, err := s.Blocks.GetLinksByGroup(ctx, group.ID)
+	must(t, err)
+	if len(links) != 1 || links[0].Title != "Shop now" {
+		t.Errorf("promoted links = %+v", links)
+	}
+	promoted, err := s.Bio.GetBlockByID(ctx, block.ID)
+	must(t, err)
+	if promoted.SortKey != "b" {
+		t.Errorf("promoted sort key = %q", promoted.SortKey)
+	}
+	ended, err := s.Experiments.GetByID(ctx, first.ID)
+	must(t, err)
+	if ended.Status != model.ExperimentEnded || ended.Winner == nil || *ended.Winner != "b" || ended.EndedAt == nil {
+		t.Errorf("ended experiment = %+v", ended)
+	}
+	_, err = s.Experiments.GetRunning(ctx, page.ID)
+	wantNoRows(t, err)
+
+	second, err := s.Experiments.Create(ctx, page.ID, "Second", variants[:1])
+	must(t, err)
+	list, err := s.Experiments.ListByPage(ctx, page.ID)
+	must(t, err)
+	if len(list) != 2 || list[0].ID != second.ID || list[1].ID != first.ID {
+		t.Errorf("list = %v", list)
+	}
+
+	must(t, s.Pages.Delete(ctx, page.ID))
+	_, err = s.Experiments.GetByID(ctx, first.ID)
+	wantNoRows(t, err)
+}
+
+func testShortLinks(t *testing.T, s *Stores) {
+	ctx := context.Background()
+	page := s.page(t, "short")
+	group, err := s.Blocks.CreateLinkGroup(ctx, page.ID, nil, "list")
+	must(t, err)
+	link, err := s.Blocks.CreateLink(ctx, group.ID, "Shop", "https://example.com/shop", "a")
+	must(t, err)
+	other, err := s.Blocks.CreateLink(ctx, group.ID, "Blog", "https://example.com/blog", "b")
+	must(t, err)
+
+	_, err = s.ShortLinks.GetByCode(ctx, "s"+s.Token)
+	wantNoRows(t, err)
+
+	first, err := s.ShortLinks.Create(ctx, link.ID, "s"+s.Token, false)
+	must(t, err)
+	if first.LinkID != link.ID || first.Code != "s"+s.Token || first.IsCustom {
+		t.Errorf("short link = %+v", first)
+	}
+	alias, err := s.ShortLinks.Create(ctx, link.ID, "shop-"+s.Token, true)
+	must(t, err)
+	_, err = s.ShortLinks.Create(ctx, other.ID, "s"+s.Token, true)
+	wantCode(t, err, "23505")
+	_, err = s.ShortLinks.Create(ctx, other.ID, "Upper"+s.Token, true)
+	wantCode(t, err, "23514")
+	_, err = s.ShortLinks.Create(ctx, other.ID+1000000, "orphan-"+s.Token, true)
+	wantCode(t, err, "23503")
+
+	got, err := s.ShortLinks.GetByCode(ctx, "shop-"+s.Token)
+	must(t, err)
+	if got.ID != alias.ID || !got.IsCustom {
+		t.Errorf("GetByCode = %+v, want %+v", got, alias)
+	}
+	list, err := s.ShortLinks.ListByLink(ctx, link.ID)
+	must(t, err)
+	if len(list) != 2 || list[0].ID != first.ID || list[1].ID != alias.ID {
+		t.Errorf("ListByLink = %v", list)
+	}
+
+	must(t, s.ShortLinks.Delete(ctx, first.ID))
+	_, err = s.ShortLinks.GetByID(ctx, first.ID)
+	wantNoRows(t, err)
+
+	// Deleting the link takes its short links with it.
+	must(t, s.Blocks.DeleteLink(ctx, link.ID))
+	_, err = s.ShortLinks.GetByID(ctx, alias.ID)
+	wantNoRows(t, err)
+}
+
+func testSchedules(t *testing.T, s *Stores) {
+	ctx := context.Background()
+	page := s.page(t, "scheduled")
+	other := s.user(t, "other")
+	past := time.Now().Add(-time.Minute)
+
+	_, err := s.Schedules.Create(ctx, page.ID, page.UserID, "archive", past)
+	wantCode(t, err, "23514")
+
+	later, err := s.Schedules.Create(ctx, page.ID, page.UserID, model.ScheduleUnpublish, time.Now().Add(time.Hour))
+	must(t, err)
+	due, err := s.Schedules.Create(ctx, page.ID, page.UserID, model.SchedulePublish, past)
+	must(t, err)
+
+	pending, err := s.Schedules.ListPendingByPage(ctx, page.ID)
+	must(t, err)
+	if len(pending) != 2 || pending[0].ID != due.ID || pending[1].ID != later.ID {
+		t.Fatalf("pending = %+v", pending)
+	}
+
+	// Only the due schedule is claimed, and only once while leased. The
+	// suite may share a database, so claim until our row comes up.
+	claimed := claimSchedule(t, s, due.ID)
+	if claimed == nil || claimed.Attempts != 1 {
+		t.Fatalf("claimed = %+v", claimed)
+	}
+	if again := claimSchedule(t, s, due.ID); again != nil {
+		t.Error("leased schedule claimed twice")
+	}
+	wantNoRows(t, s.Schedules.Cancel(ctx, due.ID, page.UserID))
+
+	// A failure with a retry puts it back in the queue.
+	must(t, s.Schedules.Fail(ctx, due.ID, claimed.Attempts, "boom", &past))
+	wantNoRows(t, s.Schedules.Fail(ctx, due.ID, claimed.Attempts, "again", nil))
+	retried := claimSchedule(t, s, due.ID)
+	if retried == nil || retried.Attempts != 2 || retried.LastError == nil || *retried.LastError != "boom" {
+		t.Fatalf("retried schedule = %+v", retried)
+	}
+	// The first claim is stale: it may not finish the second.
+	wantNoRows(t, s.Schedules.Complete(ctx, due.ID, claimed.Attempts))
+	must(t, s.Schedules.Complete(ctx, due.ID, retried.Attempts))
+	wantNoRows(t, s.Schedules.Complete(ctx, due.ID, retried.Attempts))
+
+	wantNoRows(t, s.Schedules.Cancel(ctx, later.ID, other.ID))
+	must(t, s.Schedules.Cancel(ctx, later.ID, page.UserID))
+	wantNoRows(t, s.Schedules.Cancel(ctx, later.ID, page.UserID))
+
+	pending, err = s.Schedules.ListPendingByPage(ctx, page.ID)
+	must(t, err)
+	if len(pending) != 0 {
+		t.Errorf("pending after run and cancel = %+v", pending)
+	}
+
+	must(t, s.Pages.SavePublishCache(ctx, page.ID, json.RawMessage(`{}`), "h"))
+	must(t, s.Pages.Unpublish(ctx, page.ID))
+	_, err = s.Pages.GetPublishCache(ctx, page.ID)
+	wantNoRows(t, err)
+	got, err := s.Pages.GetByID(ctx, page.ID)
+	must(t, err)
+	if got.Status != "draft" {
+		t.Errorf("unpublished status = %q", got.Status)
+	}
+}
+
+// claimSchedule claims due schedules until it gets id, and returns nil if
+// the queue runs dry first.
+func claimSchedule(t *testing.T, s *Stores, id int64) *model.PageSchedule {
+	t.Helper()
+	for {
+		claimed, err := s.Schedules.ClaimDue(context.Background(), time.Minute)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+		must(t, err)
+		if claimed.ID == id {
+			return claimed
+		}
+	}
+}
+
+func testPresetReview(t *testing.T, s *Stores) {
+	ctx := context.Background()
+	author := s.user(t, "author")
+	admin := s.user(t, "admin")
+	config := json.RawMessage(`{"meta":{"name":"Mine"}}`)
+
+	draft, err := s.Themes.CreatePreset(ctx, author.ID, "mine_"+s.Token, "Mine", "free", "public", config)
+	must(t, err)
+	if draft.Status != model.PresetDraft || draft.IsOfficial || draft.PublishedAt != nil {
+		t.Errorf("new preset = %+v, want unpublished draft", draft)
+	}
+	must(t, s.Themes.UpdatePresetDraft(ctx, draft.ID, author.ID, "Mine v2", "pro", "public", config))
+	wantNoRows(t, s.Themes.UpdatePresetDraft(ctx, draft.ID, admin.ID, "Stolen", "free", "public", config))
+
+	found, total, err := s.Themes.SearchPresets(ctx, repo.PresetQuery{Search: s.Token})
+	must(t, err)
+	if total != 0 || len(found) != 0 {
+		t.Errorf("draft listed in marketplace: %d", total)
+	}
+
+	must(t, s.Themes.TransitionPreset(ctx, draft.ID, []string{model.PresetDraft, model.PresetRejected}, model.PresetSubmitted, nil, nil))
+	wantNoRows(t, s.Themes.UpdatePresetDraft(ctx, draft.ID, author.ID, "Sneaky", "free", "public", config))
+	queue, err := s.Themes.ListPresetsByStatus(ctx, model.PresetSubmitted)
+	must(t, err)
+	if len(presetIDs(queue, draft.ID)) != 1 {
+		t.Error("submitted preset missing from review queue")
+	}
+
+	// A second reviewer acting on a stale view must lose.
+	must(t, s.Themes.TransitionPreset(ctx, draft.ID, []string{model.PresetSubmitted}, model.PresetApproved, &admin.ID, nil))
+	wantNoRows(t, s.Themes.TransitionPreset(ctx, draft.ID, []string{model.PresetSubmitted}, model.PresetRejected, &admin.ID, nil))
+	wantCode(t, s.Themes.TransitionPreset(ctx, draft.ID, []string{model.PresetApproved}, model.PresetRejected, &admin.ID, nil), "23514")
+
+	published, err := s.Themes.GetPresetByID(ctx, draft.ID)
+	must(t, err)
+	if published.Status != model.PresetApproved || published.PublishedAt == nil || published.Name != "Mine v2" ||
+		published.ReviewedBy == nil || *published.ReviewedBy != admin.ID {
+		t.Errorf("approved preset = %+v", published)
+	}
+
+	other, err := s.Themes.CreatePreset(ctx, author.ID, "other_"+s.Token, "Other", "free", "public", config)
+	must(t, err)
+	must(t, s.Themes.TransitionPreset(ctx, other.ID, []string{model.PresetDraft}, model.PresetSubmitted, nil, nil))
+	must(t, s.Themes.TransitionPreset(ctx, other.ID, []string{model.PresetSubmitted}, model.PresetApproved, &admin.ID, nil))
+	for i := 0; i < 2; i++ {
+		_, err := s.Pages.Create(ctx, author.ID, other.ID, "Uses other")
+		must(t, err)
+	}
+
+	found, total, err = s.Themes.SearchPresets(ctx, repo.PresetQuery{Search: s.Token, Sort: repo.PresetSortPopular, Limit: 1})
+	must(t, err)
+	if total != 2 || len(found) != 1 || found[0].ID != other.ID || found[0].UsageCount != 2 {
+		t.Errorf("popular page 1 = %v (total %d), want preset %d used twice", found, total, other.ID)
+	}
+	found, _, err = s.Themes.SearchPresets(ctx, repo.PresetQuery{Search: s.Token, Sort: repo.PresetSortPopular, Limit: 1, Offset: 1})
+	must(t, err)
+	if len(found) != 1 || found[0].ID != draft.ID {
+		t.Errorf("popular page 2 = %v, want preset %d", found, draft.ID)
+	}
+	found, _, err = s.Themes.SearchPresets(ctx, repo.PresetQuery{Search: s.Token, Tier: "free"})
+	must(t, err)
+	if len(found) != 1 || found[0].ID != other.ID {
+		t.Errorf("free presets = %v", found)
+	}
+
+	mine, err := s.Themes.ListPresetsByAuthor(ctx, author.ID)
+	must(t, err)
+	if len(mine) != 2 || mine[0].ID != other.ID {
+		t.Errorf("ListPresetsByAuthor = %v, want newest first", mine)
+	}
+}
+
+func testRoutes(t *testing.T, s *Stores) {
+	ctx := context.Background()
+	page := s.page(t, "routes")
+	other := s.page(t, "routes2")
+	domain, err := s.Domains.Create(ctx, nil, s.hostname("routes"), false)
+	must(t, err)
+	_, err = s.Domains.Create(ctx, nil, domain.Hostname, false)
+	wantCode(t, err, "23505")
+
+	first, err := s.Domains.CreateRoute(ctx, page.ID, domain.ID, "/one")
+	must(t, err)
+	_, err = s.Domains.CreateRoute(ctx, other.ID, domain.ID, "/one")
+	wantCode(t, err, "23505")
+	_, err = s.Domains.CreateRoute(ctx, other.ID, domain.ID, "/taken")
+	must(t, err)
+
+	second, err := s.Domains.ReplaceCurrentRoute(ctx, page.ID, domain.ID, "/two")
+	must(t, err)
+	third, err := s.Domains.ReplaceCurrentRoute(ctx, page.ID, domain.ID, "/three")
+	must(t, err)
+
+	current, err := s.Domains.GetCurrentRouteByPage(ctx, page.ID)
+	must(t, err)
+	if current.ID != third.ID {
+		t.Errorf("current route = %d, want %d", current.ID, third.ID)
+	}
+	_, err = s.Domains.GetRouteByDomainAndPath(ctx, domain.ID, "/one")
+	wantNoRows(t, err)
+
+	// A taken path leaves the current route in place.
+	_, err = s.Domains.ReplaceCurrentRoute(ctx, page.ID, domain.ID, "/taken")
+	wantCode(t, err, "23505")
+	current, err = s.Domains.GetCurrentRouteByPage(ctx, page.ID)
+	must(t, err)
+	if current.ID != third.ID {
+		t.Errorf("current route after failed replace = %d, want %d", current.ID, third.ID)
+	}
+
+	// Old paths are free again and can be reused by the same page.
+	back, err := s.Domains.ReplaceCurrentRoute(ctx, page.ID, domain.ID, "/one")
+	must(t, err)
+	if back.ID == first.ID || back.ID == second.ID {
+		t.Error("ReplaceCurrentRoute reused a retired row")
+	}
+
+	must(t, s.Domains.UpdateStatus(ctx, domain.ID, "disabled"))
+	wantCode(t, s.Domains.UpdateStatus(ctx, domain.ID, "gone"), "23514")
+	got, err := s.Domains.GetByHostname(ctx, domain.Hostname)
+	must(t, err)
+	if got.Status != "disabled" {
+		t.Errorf("status = %q, want disabled", got.Status)
+	}
+}
+
+func testSitemap(t *testing.T, s *Stores) {
+	ctx := context.Background()
+	domain, err := s.Domains.Create(ctx, nil, s.hostname("sitemap"), false)
+	must(t, err)
+	other, err := s.Domains.Create(ctx, nil, s.hostname("sitemap2"), false)
+	must(t, err)
+
+	publish := func(name, path, compiled string) *model.BioPage {
+		t.Helper()
+		page := s.page(t, name)
+		page.Status = "published"
+		must(t, s.Pages.Update(ctx, page))
+		must(t, s.Pages.SavePublishCache(ctx, page.ID, json.RawMessage(compiled), "h"))
+		_, err := s.Domains.CreateRoute(ctx, page.ID, domain.ID, path)
+		must(t, err)
+		return page
+	}
+	publish("sm_b", "/b", `{"seo":{"noindex":false}}`)
+	publish("sm_a", "/a", `{}`)
+	publish("sm_hidden", "/hidden", `{"seo":{"noindex":true}}`)
+	locked := publish("sm_locked", "/locked", `{}`)
+	locked.AccessType = "password"
+	must(t, s.Pages.Update(ctx, locked))
+	draft := s.page(t, "sm_draft")
+	_, err = s.Domains.CreateRoute(ctx, draft.ID, domain.ID, "/draft")
+	must(t, err)
+
+	// A page served from two domains lists both current routes.
+	moved := publish("sm_moved", "/old", `{}`)
+	_, err = s.Domains.ReplaceCurrentRoute(ctx, moved.ID, domain.ID, "/new")
+	must(t, err)
+	_, err = s.Domains.CreateRoute(ctx, moved.ID, other.ID, "/")
+	must(t, err)
+	routes, err := s.Domains.ListCurrentRoutesByPage(ctx, moved.ID)
+	must(t, err)
+	if len(routes) != 2 || routes[0].DomainID != domain.ID || routes[0].Path != "/new" || routes[1].DomainID != other.ID {
+		t.Errorf("current routes = %+v, want /new then the second domain", routes)
+	}
+
+	entries, err := s.Domains.ListSitemapEntries(ctx, domain.ID, 10)
+	must(t, err)
+	var paths []string
+	for _, e := range entries {
+		paths = append(paths, e.Path)
+		if e.LastModified.IsZero() {
+			t.Errorf("%s: zero last modified", e.Path)
+		}
+	}
+	if want := []string{"/a", "/b", "/new"}; fmt.Sprint(paths) != fmt.Sprint(want) {
+		t.Errorf("sitemap = %v, want %v", paths, want)
+	}
+
+	entries, err = s.Domains.ListSitemapEntries(ctx, domain.ID, 2)
+	must(t, err)
+	if len(entries) != 2 {
+		t.Errorf("limited sitemap has %d entries, want 2", len(entries))
+	}
+
+	// The system domain leaves out pages that are canonical on an active
+	// custom domain.
+	system, err := s.Domains.GetSystemDomain(ctx)
+	if err != nil {
+		system, err = s.Domains.Create(ctx, nil, s.hostname("system"), true)
+		must(t, err)
+	}
+	must(t, s.Domains.UpdateStatus(ctx, other.ID, "active"))
+	_, err = s.Domains.CreateRoute(ctx, moved.ID, system.ID, "/sm-moved-"+s.Token)
+	must(t, err)
+	entries, err = s.Domains.ListSitemapEntries(ctx, system.ID, 50000)
+	must(t, err)
+	if hasSitemapPath(entries, "/sm-moved-"+s.Token) {
+		t.Error("system sitemap lists a page that is canonical on a custom domain")
+	}
+	must(t, s.Domains.UpdateStatus(ctx, domain.ID, "disabled"))
+	must(t, s.Domains.UpdateStatus(ctx, other.ID, "disabled"))
+	entries, err = s.Domains.ListSitemapEntries(ctx, system.ID, 50000)
+	must(t, err)
+	if !hasSitemapPath(entries, "/sm-moved-"+s.Token) {
+		t.Error("system sitemap is missing a page whose custom domains are disabled")
+	}
+}
+
+func hasSitemapPath(entries []*model.SitemapEntry, path string) bool {
+	for _, e := range entries {
+		if e.Path == path {
+			return true
+		}
+	}
+	return false
+}
+
+func testAggregate(t *testing.T, s *Stores) {
+	ctx := context.Background()
+	page := s.page(t, "aggregate")
+
+	g1, err := s.Blocks.CreateLinkGroup(ctx, page.ID, nil, "list")
+	must(t, err)
+	g2, err := s.Blocks.CreateLinkGroup(ctx, page.ID, nil, "grid")
+	must(t, err)
+	_, err = s.Blocks.CreateBlock(ctx, page.ID, "link_group", "b", &g2.ID, nil)
+	must(t, err)
+	_, err = s.Blocks.CreateBlock(ctx, page.ID, "link_group", "a", &g1.ID, nil)
+	must(t, err)
+	for _, key := range []string{"y", "x"} {
+		_, err := s.Blocks.CreateLink(ctx, g1.ID, key, "https://example.com", key)
+		must(t, err)
+	}
+
+	agg, err := s.Aggregates.Load(ctx, page.ID)
+	must(t, err)
+	if agg.Page.ID != page.ID {
+		t.Errorf("aggregate page = %d", agg.Page.ID)
+	}
+	if got := blockKeys(agg.Blocks); got != "ab" {
+		t.Errorf("aggregate blocks = %q, want ab", got)
+	}
+	if len(agg.Groups) != 2 || agg.Groups[0].ID != g1.ID || agg.Groups[1].ID != g2.ID {
+		t.Errorf("aggregate groups not ordered by id")
+	}
+	if got := linkKeys(agg.Links[g1.ID]); got != "xy" {
+		t.Errorf("aggregate links = %q, want xy", got)
+	}
+	if links, ok := agg.Links[g2.ID]; !ok || len(links) != 0 {
+		t.Errorf("empty group links = %v, %v", links, ok)
+	}
+
+	fromPage, err := s.Aggregates.LoadForPage(ctx, page)
+	must(t, err)
+	if len(fromPage.Blocks) != 2 || fromPage.Page != page {
+		t.Error("LoadForPage did not reuse the given page")
+	}
+
+	_, err = s.Aggregates.Load(ctx, -1)
+	wantNoRows(t, err)
+}
+
+func testConcurrency(t *testing.T, s *Stores) {
+	ctx := context.Background()
+	page := s.page(t, "concurrency")
+	group, err := s.Blocks.CreateLinkGroup(ctx, page.ID, nil, "list")
+	must(t, err)
+
+	const n = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			key := fmt.Sprintf("%03d", i)
+			if _, err := s.Blocks.CreateLink(ctx, group.ID, key, "https://example.com", key); err != nil {
+				errs <- err
+				return
+			}
+			if _, err := s.Aggregates.Load(ctx, page.ID); err != nil {
+				errs <- err
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	links, err := s.Blocks.GetLinksByGroup(ctx, group.ID)
+	must(t, err)
+	if len(links) != n {
+		t.Fatalf("links = %d, want %d", len(links), n)
+	}
+	seen := make(map[int64]bool)
+	for _, l := range links {
+		if seen[l.ID] {
+			t.Errorf("duplicate link id %d", l.ID)
+		}
+		seen[l.ID] = true
+	}
+}
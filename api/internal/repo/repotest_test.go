@@ -0,0 +1,12 @@
+package repo_test
+
+import (
+	"testing"
+
+	"linkbio/internal/repo/repotest"
+)
+
+func TestMemoryRepos(t *testing.T) { repotest.Run(t, repotest.Memory) }
+
+// TestPostgresRepos skips unless DATABASE_URL is set.
+func TestPostgresRepos(t *testing.T) { repotest.Run(t, repotest.Postgres) }
@@ -0,0 +1,142 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"linkbio/internal/model"
+)
+
+type ScheduleRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewScheduleRepo(db *pgxpool.Pool) *ScheduleRepo {
+	return &ScheduleRepo{db: db}
+}
+
+const scheduleColumns = `id, page_id, user_id, action, run_at, status, locked_until, attempts,
+	last_error, executed_at, created_at, updated_at`
+
+func scanSchedule(row pgx.Row) (*model.PageSchedule, error) {
+	var s model.PageSchedule
+	err := row.Scan(&s.ID, &s.PageID, &s.UserID, &s.Action, &s.RunAt, &s.Status, &s.LockedUntil,
+		&s.Attempts, &s.LastError, &s.ExecutedAt, &s.CreatedAt, &s.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func scanSchedules(rows pgx.Rows) ([]*model.PageSchedule, error) {
+	defer rows.Close()
+	var schedules []*model.PageSchedule
+	for rows.Next() {
+		s, err := scanSchedule(rows)
+		if err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, s)
+	}
+	return schedules, rows.Err()
+}
+
+func (r *ScheduleRepo) Create(ctx context.Context, pageID, userID int64, action string, runAt time.Time) (*model.PageSchedule, error) {
+	return scanSchedule(r.db.QueryRow(ctx, `
+		INSERT INTO page_schedules (page_id, user_id, action, run_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING `+scheduleColumns,
+		pageID, userID, action, runAt))
+}
+
+// ListPendingByPage returns the page's pending schedules, soonest first.
+func (r *ScheduleRepo) ListPendingByPage(ctx context.Context, pageID int64) ([]*model.PageSchedule, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT `+scheduleColumns+`
+		FROM page_schedules
+		WHERE page_id = $1 AND status = 'pending'
+		ORDER BY run_at, id
+	`, pageID)
+	if err != nil {
+		return nil, err
+	}
+	return scanSchedules(rows)
+}
+
+// Cancel cancels one of the user's pending schedules. A schedule a worker
+// currently holds cannot be canceled; that, and anything not pending,
+// returns pgx.ErrNoRows.
+func (r *ScheduleRepo) Cancel(ctx context.Context, id, userID int64) error {
+	tag, err := r.db.Exec(ctx, `
+		UPDATE page_schedules SET status = 'canceled', updated_at = NOW()
+		WHERE id = $1 AND user_id = $2 AND status = 'pending'
+		  AND (locked_until IS NULL OR locked_until < NOW())
+	`, id, userID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// ClaimDue leases the oldest due schedule, or returns pgx.ErrNoRows when
+// none is due. SKIP LOCKED keeps concurrent workers from claiming the same
+// row; the lease keeps it claimed after the statement commits, and the
+// bumped attempts count identifies this claim to Complete and Fail.
+func (r *ScheduleRepo) ClaimDue(ctx context.Context, lease time.Duration) (*model.PageSchedule, error) {
+	return scanSchedule(r.db.QueryRow(ctx, `
+		UPDATE page_schedules SET locked_until = NOW() + $1 * INTERVAL '1 millisecond',
+		                          attempts = attempts + 1, updated_at = NOW()
+		WHERE id = (
+			SELECT id FROM page_schedules
+			WHERE status = 'pending' AND run_at <= NOW()
+			  AND (locked_until IS NULL OR locked_until < NOW())
+			ORDER BY run_at, id
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+scheduleColumns,
+		lease.Milliseconds()))
+}
+
+// Complete marks the claim made at the given attempt done. A claim that
+// was since canceled, finished or taken over by another worker returns
+// pgx.ErrNoRows and changes nothing.
+func (r *ScheduleRepo) Complete(ctx context.Context, id int64, attempt int) error {
+	tag, err := r.db.Exec(ctx, `
+		UPDATE page_schedules
+		SET status = 'done', executed_at = NOW(), locked_until = NULL, last_error = NULL, updated_at = NOW()
+		WHERE id = $1 AND attempts = $2 AND status = 'pending' AND locked_until IS NOT NULL
+	`, id, attempt)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// Fail records a failed run of the claim made at the given attempt. With
+// retryAt the schedule stays pending and runs again then; without it the
+// schedule is given up. A lost claim returns pgx.ErrNoRows, as in Complete.
+func (r *ScheduleRepo) Fail(ctx context.Context, id int64, attempt int, reason string, retryAt *time.Time) error {
+	tag, err := r.db.Exec(ctx, `
+		UPDATE page_schedules
+		SET status = CASE WHEN $4::timestamptz IS NULL THEN 'failed' ELSE 'pending' END,
+		    run_at = COALESCE($4, run_at),
+		    locked_until = NULL, last_error = $3, updated_at = NOW()
+		WHERE id = $1 AND attempts = $2 AND status = 'pending' AND locked_until IS NOT NULL
+	`, id, attempt, reason, retryAt)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package repo
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"linkbio/internal/model"
+)
+
+type ShortLinkRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewShortLinkRepo(db *pgxpool.Pool) *ShortLinkRepo {
+	return &ShortLinkRepo{db: db}
+}
+
+const shortLinkColumns = `id, link_id, code, is_custom, created_at`
+
+func scanShortLink(row pgx.Row) (*model.ShortLink, error) {
+	var s model.ShortLink
+	if err := row.Scan(&s.ID, &s.LinkID, &s.Code, &s.IsCustom, &s.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (r *ShortLinkRepo) Create(ctx context.Context, linkID int64, code string, custom bool) (*model.ShortLink, error) {
+	return scanShortLink(r.db.QueryRow(ctx, `
+		INSERT INTO short_links (link_id, code, is_custom)
+		VALUES ($1, $2, $3)
+		RETURNING `+shortLinkColumns,
+		linkID, code, custom))
+}
+
+func (r *ShortLinkRepo) GetByID(ctx context.Context, id int64) (*model.ShortLink, error) {
+	return scanShortLink(r.db.QueryRow(ctx, `
+		SELECT `+shortLinkColumns+` FROM short_links WHERE id = $1
+	`, id))
+}
+
+func (r *ShortLinkRepo) GetByCode(ctx context.Context, code string) (*model.ShortLink, error) {
+	return scanShortLink(r.db.QueryRow(ctx, `
+		SELECT `+shortLinkColumns+` FROM short_links WHERE code = $1
+	`, code))
+}
+
+func (r *ShortLinkRepo) ListByLink(ctx context.Context, linkID int64) ([]*model.ShortLink, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT `+shortLinkColumns+`
+		FROM short_links WHERE link_id = $1
+		ORDER BY id
+	`, linkID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var links []*model.ShortLink
+	for rows.Next() {
+		s, err := scanShortLink(rows)
+		if err != nil {
+			return nil, err
+		}
+		links = append(links, s)
+	}
+	return links, rows.Err()
+}
+
+func (r *ShortLinkRepo) Delete(ctx context.Context, id int64) error {
+	_, err := r.db.Exec(ctx, `DELETE FROM short_links WHERE id = $1`, id)
+	return err
+}
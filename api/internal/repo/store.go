@@ -0,0 +1,183 @@
+package repo
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"linkbio/internal/model"
+)
+
+// The interfaces below are what services depend on. The Postgres repos in
+// this package implement them, and so do the in-memory fakes in
+// repo/memory. Lookups that find nothing return pgx.ErrNoRows.
+
+type UserStore interface {
+	Create(ctx context.Context, email, passwordHash string) (*model.User, error)
+	GetByEmail(ctx context.Context, email string) (*model.User, error)
+	GetByID(ctx context.Context, id int64) (*model.User, error)
+	GetByUsername(ctx context.Context, username string) (*model.User, error)
+	SetUsername(ctx context.Context, userID int64, username string) error
+	UsernameExists(ctx context.Context, username string) (bool, error)
+	UpdateDisplayName(ctx context.Context, userID int64, displayName string) error
+	IsAdmin(ctx context.Context, userID int64) (bool, error)
+	IsPro(ctx context.Context, userID int64) (bool, error)
+}
+
+type PageStore interface {
+	Create(ctx context.Context, userID, themePresetID int64, title string) (*model.BioPage, error)
+	GetByID(ctx context.Context, id int64) (*model.BioPage, error)
+	ListByUser(ctx context.Context, userID int64) ([]*model.BioPage, error)
+	Update(ctx context.Context, page *model.BioPage) error
+	Delete(ctx context.Context, id int64) error
+	SavePublishCache(ctx context.Context, pageID int64, compiled json.RawMessage, hash string) error
+	GetPublishCache(ctx context.Context, pageID int64) (*model.PagePublishCache, error)
+	UpdateSettings(ctx context.Context, pageID int64, settings []byte) error
+	ApplyTheme(ctx context.Context, pageID, userID, presetID int64, customID *int64, mode string) error
+	Unpublish(ctx context.Context, pageID int64) error
+}
+
+type BlockStore interface {
+	CreateBlock(ctx context.Context, pageID int64, blockType, sortKey string, refID *int64, content json.RawMessage) (*model.Block, error)
+	GetBlocksByPage(ctx context.Context, pageID int64) ([]*model.Block, error)
+	UpdateBlock(ctx context.Context, block *model.Block) error
+	DeleteBlock(ctx context.Context, id int64) error
+
+	CreateLinkGroup(ctx context.Context, pageID int64, title *string, layoutType string) (*model.LinkGroup, error)
+	GetLinkGroupsByPage(ctx context.Context, pageID int64) ([]*model.LinkGroup, error)
+	UpdateLinkGroup(ctx context.Context, group *model.LinkGroup) error
+	DeleteLinkGroup(ctx context.Context, id int64) error
+
+	CreateLink(ctx context.Context, groupID int64, title, url, sortKey string) (*model.Link, error)
+	GetLinksByGroup(ctx context.Context, groupID int64) ([]*model.Link, error)
+	UpdateLink(ctx context.Context, link *model.Link) error
+	DeleteLink(ctx context.Context, id int64) error
+}
+
+type BioStore interface {
+	GetOrCreatePage(ctx context.Context, userID int64) (*model.BioPage, error)
+	GetBlockByID(ctx context.Context, id int64) (*model.Block, error)
+	GetBlockOwnerID(ctx context.Context, blockID int64) (int64, error)
+	GetGroupOwnerID(ctx context.Context, groupID int64) (int64, error)
+	GetLinkOwnerID(ctx context.Context, linkID int64) (int64, error)
+	GetLinkByID(ctx context.Context, id int64) (*model.Link, error)
+	GetLinkPageID(ctx context.Context, linkID int64) (int64, error)
+	GetLastBlockSortKey(ctx context.Context, pageID int64) (string, error)
+	GetLastLinkSortKey(ctx context.Context, groupID int64) (string, error)
+	UpdateBlockSortKey(ctx context.Context, blockID int64, sortKey string) error
+	// ClaimLinkClick counts a click towards a link's cap and returns the
+	// new count. Once maxClicks are counted it returns pgx.ErrNoRows;
+	// concurrent claims never count past the cap.
+	ClaimLinkClick(ctx context.Context, linkID int64, maxClicks int) (int, error)
+	GetLinkClickCounts(ctx context.Context, linkIDs []int64) (map[int64]int, error)
+}
+
+type ThemeStore interface {
+	GetPresets(ctx context.Context, tier string) ([]*model.ThemePreset, error)
+	GetPresetByID(ctx context.Context, id int64) (*model.ThemePreset, error)
+	GetPresetByKey(ctx context.Context, key string) (*model.ThemePreset, error)
+	SearchPresets(ctx context.Context, q PresetQuery) ([]*model.ThemePreset, int, error)
+	ListPresetsByAuthor(ctx context.Context, authorID int64) ([]*model.ThemePreset, error)
+	ListPresetsByStatus(ctx context.Context, status string) ([]*model.ThemePreset, error)
+	CreatePreset(ctx context.Context, authorID int64, key, name, tier, visibility string, config json.RawMessage) (*model.ThemePreset, error)
+	UpdatePresetDraft(ctx context.Context, id, authorID int64, name, tier, visibility string, config json.RawMessage) error
+	TransitionPreset(ctx context.Context, id int64, from []string, status string, reviewerID *int64, note *string) error
+	GetPresetVersion(ctx context.Context, id int64) (*model.ThemePresetVersion, error)
+	ListPresetVersions(ctx context.Context, presetID int64) ([]*model.ThemePresetVersion, error)
+	PublishPresetVersion(ctx context.Context, presetID int64, version string, schemaVersion int, config json.RawMessage) (*model.ThemePresetVersion, error)
+	CreateCustom(ctx context.Context, userID, presetID int64, presetVersionID *int64, name string, patch, compiled json.RawMessage, hash string) (*model.ThemeCustom, error)
+	GetCustomByHash(ctx context.Context, userID int64, hash string) (*model.ThemeCustom, error)
+	GetCustomByID(ctx context.Context, id int64) (*model.ThemeCustom, error)
+	UpdateCustom(ctx context.Context, id int64, patch, compiled json.RawMessage, hash string) error
+	RebaseCustom(ctx context.Context, id, presetVersionID int64, patch, compiled json.RawMessage, hash string) error
+	ListCustomByUser(ctx context.Context, userID int64) ([]*model.ThemeCustom, error)
+	RenameCustom(ctx context.Context, id, userID int64, name string) error
+	DeleteCustom(ctx context.Context, id, userID int64, fallback bool) ([]int64, error)
+	SaveStylesheet(ctx context.Context, hash string, css []byte) error
+	GetStylesheet(ctx context.Context, hash string) ([]byte, error)
+}
+
+// ScheduleStore queues page publishes and unpublishes. ClaimDue leases one
+// due schedule to one caller, which must Complete or Fail it, passing the
+// claimed attempt, before the lease runs out.
+type ScheduleStore interface {
+	Create(ctx context.Context, pageID, userID int64, action string, runAt time.Time) (*model.PageSchedule, error)
+	ListPendingByPage(ctx context.Context, pageID int64) ([]*model.PageSchedule, error)
+	Cancel(ctx context.Context, id, userID int64) error
+	ClaimDue(ctx context.Context, lease time.Duration) (*model.PageSchedule, error)
+	Complete(ctx context.Context, id int64, attempt int) error
+	Fail(ctx context.Context, id int64, attempt int, reason string, retryAt *time.Time) error
+}
+
+// AnalyticsStore records page views and link clicks. Sources are stored as
+// given; an empty one is stored as NULL, as is a zero variant tag.
+type AnalyticsStore interface {
+	RecordView(ctx context.Context, pageID int64, source string, tag model.VariantTag) error
+	RecordClick(ctx context.Context, pageID, linkID int64, source string, tag model.VariantTag) error
+	Summary(ctx context.Context, pageID int64, since time.Time) (*model.AnalyticsSummary, error)
+}
+
+// ExperimentStore keeps a page's A/B experiments. Create fails with a
+// unique violation while the page has another experiment running.
+type ExperimentStore interface {
+	Create(ctx context.Context, pageID int64, name string, variants []model.ExperimentVariant) (*model.Experiment, error)
+	GetByID(ctx context.Context, id int64) (*model.Experiment, error)
+	GetRunning(ctx context.Context, pageID int64) (*model.Experiment, error)
+	ListByPage(ctx context.Context, pageID int64) ([]*model.Experiment, error)
+	// End stops a running experiment and applies promote, if any, in the
+	// same transaction; one that is not running returns pgx.ErrNoRows.
+	End(ctx context.Context, id int64, winner *string, promote *model.Promotion) error
+	// Results counts the tagged views and clicks of each variant that has
+	// any, ordered by variant.
+	Results(ctx context.Context, id int64) ([]model.VariantCount, error)
+}
+
+// ShortLinkStore keeps the short codes of links. Create fails with a
+// unique violation when the code is taken.
+type ShortLinkStore interface {
+	Create(ctx context.Context, linkID int64, code string, custom bool) (*model.ShortLink, error)
+	GetByID(ctx context.Context, id int64) (*model.ShortLink, error)
+	GetByCode(ctx context.Context, code string) (*model.ShortLink, error)
+	// ListByLink returns the link's short links, oldest first.
+	ListByLink(ctx context.Context, linkID int64) ([]*model.ShortLink, error)
+	Delete(ctx context.Context, id int64) error
+}
+
+type AssetStore interface {
+	Create(ctx context.Context, asset *model.Asset) (*model.Asset, error)
+	GetByID(ctx context.Context, id int64) (*model.Asset, error)
+	GetByStorageKey(ctx context.Context, scope, key string) (*model.Asset, error)
+	SaveBlob(ctx context.Context, key string, data []byte) error
+	GetBlob(ctx context.Context, key string) ([]byte, error)
+	ListMissingColor(ctx context.Context, afterID int64, limit int) ([]*model.Asset, error)
+	SetDominantColor(ctx context.Context, id int64, color string) error
+}
+
+type DomainStore interface {
+	GetByHostname(ctx context.Context, hostname string) (*model.Domain, error)
+	GetByID(ctx context.Context, id int64) (*model.Domain, error)
+	UpdateStatus(ctx context.Context, id int64, status string) error
+	GetSystemDomain(ctx context.Context) (*model.Domain, error)
+	Create(ctx context.Context, userID *int64, hostname string, isSystem bool) (*model.Domain, error)
+	GetRouteByDomainAndPath(ctx context.Context, domainID int64, path string) (*model.PageRoute, error)
+	CreateRoute(ctx context.Context, pageID, domainID int64, path string) (*model.PageRoute, error)
+	GetCurrentRouteByPage(ctx context.Context, pageID int64) (*model.PageRoute, error)
+	ListCurrentRoutesByPage(ctx context.Context, pageID int64) ([]*model.PageRoute, error)
+	ListSitemapEntries(ctx context.Context, domainID int64, limit int) ([]*model.SitemapEntry, error)
+	ReplaceCurrentRoute(ctx context.Context, pageID, domainID int64, path string) (*model.PageRoute, error)
+}
+
+type PageAggregateStore interface {
+	Load(ctx context.Context, pageID int64) (*model.PageAggregate, error)
+	LoadForPage(ctx context.Context, page *model.BioPage) (*model.PageAggregate, error)
+}
+
+var (
+	_ UserStore          = (*UserRepo)(nil)
+	_ PageStore          = (*PageRepo)(nil)
+	_ BlockStore         = (*BlockRepo)(nil)
+	_ BioStore           = (*BioRepo)(nil)
+	_ ThemeStore         = (*ThemeRepo)(nil)
+	_ DomainStore        = (*DomainRepo)(nil)
+	_ PageAggregateStore = (*PageAggregateRepo)(nil)
+)
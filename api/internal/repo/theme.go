@@ -3,7 +3,10 @@ package repo
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"strings"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"linkbio/internal/model"
 )
@@ -16,19 +19,28 @@ func NewThemeRepo(db *pgxpool.Pool) *ThemeRepo {
 	return &ThemeRepo{db: db}
 }
 
-func (r *ThemeRepo) GetPresets(ctx context.Context, tier string) ([]*model.ThemePreset, error) {
-	query := `
-		SELECT id, key, name, tier, visibility, is_official, author_user_id, config, created_at, updated_at
-		FROM theme_presets WHERE visibility = 'public'
-	`
-	args := []interface{}{}
+// presetColumns selects a preset with its usage count and current
+// version; scan with scanPreset.
+const presetColumns = `
+	tp.id, tp.key, tp.name, tp.tier, tp.visibility, tp.is_official, tp.author_user_id, tp.config,
+	tp.status, tp.review_note, tp.reviewed_by, tp.submitted_at, tp.published_at, tp.created_at, tp.updated_at,
+	(SELECT COUNT(*) FROM bio_pages bp WHERE bp.theme_preset_id = tp.id) AS usage_count,
+	tp.current_version_id,
+	(SELECT v.version FROM theme_preset_versions v WHERE v.id = tp.current_version_id) AS version
+`
 
-	if tier != "" {
-		query += " AND tier = $1"
-		args = append(args, tier)
+func scanPreset(row pgx.Row) (*model.ThemePreset, error) {
+	var p model.ThemePreset
+	err := row.Scan(&p.ID, &p.Key, &p.Name, &p.Tier, &p.Visibility, &p.IsOfficial, &p.AuthorUserID, &p.Config,
+		&p.Status, &p.ReviewNote, &p.ReviewedBy, &p.SubmittedAt, &p.PublishedAt, &p.CreatedAt, &p.UpdatedAt,
+		&p.UsageCount, &p.CurrentVersionID, &p.Version)
+	if err != nil {
+		return nil, err
 	}
-	query += " ORDER BY name"
+	return &p, nil
+}
 
+func (r *ThemeRepo) queryPresets(ctx context.Context, query string, args ...interface{}) ([]*model.ThemePreset, error) {
 	rows, err := r.db.Query(ctx, query, args...)
 	if err != nil {
 		return nil, err
@@ -37,51 +49,171 @@ func (r *ThemeRepo) GetPresets(ctx context.Context, tier string) ([]*model.Theme
 
 	var presets []*model.ThemePreset
 	for rows.Next() {
-		var p model.ThemePreset
-		err := rows.Scan(&p.ID, &p.Key, &p.Name, &p.Tier, &p.Visibility,
-			&p.IsOfficial, &p.AuthorUserID, &p.Config, &p.CreatedAt, &p.UpdatedAt)
+		p, err := scanPreset(rows)
 		if err != nil {
 			return nil, err
 		}
-		presets = append(presets, &p)
+		presets = append(presets, p)
 	}
-	return presets, nil
+	return presets, rows.Err()
 }
 
-func (r *ThemeRepo) GetPresetByID(ctx context.Context, id int64) (*model.ThemePreset, error) {
-	var p model.ThemePreset
-	err := r.db.QueryRow(ctx, `
-		SELECT id, key, name, tier, visibility, is_official, author_user_id, config, created_at, updated_at
-		FROM theme_presets WHERE id = $1
-	`, id).Scan(&p.ID, &p.Key, &p.Name, &p.Tier, &p.Visibility,
-		&p.IsOfficial, &p.AuthorUserID, &p.Config, &p.CreatedAt, &p.UpdatedAt)
+// GetPresets lists published public presets by name.
+func (r *ThemeRepo) GetPresets(ctx context.Context, tier string) ([]*model.ThemePreset, error) {
+	presets, _, err := r.SearchPresets(ctx, PresetQuery{Tier: tier, Sort: PresetSortName})
+	return presets, err
+}
+
+// Marketplace sort orders.
+const (
+	PresetSortPopular = "popular"
+	PresetSortNewest  = "newest"
+	PresetSortName    = "name"
+)
+
+// PresetQuery filters the marketplace. Limit 0 means no limit.
+type PresetQuery struct {
+	Search string
+	Tier   string
+	Sort   string
+	Limit  int
+	Offset int
+}
+
+// SearchPresets lists published public presets matching q and returns the
+// total number of matches for pagination.
+func (r *ThemeRepo) SearchPresets(ctx context.Context, q PresetQuery) ([]*model.ThemePreset, int, error) {
+	where := ` WHERE tp.visibility = 'public' AND tp.status = 'approved'`
+	args := []interface{}{}
+	if q.Tier != "" {
+		args = append(args, q.Tier)
+		where += fmt.Sprintf(" AND tp.tier = $%d", len(args))
+	}
+	if q.Search != "" {
+		args = append(args, "%"+escapeLike(q.Search)+"%")
+		where += fmt.Sprintf(" AND (tp.name ILIKE $%d OR tp.key ILIKE $%d)", len(args), len(args))
+	}
+
+	var total int
+	if err := r.db.QueryRow(ctx, `SELECT COUNT(*) FROM theme_presets tp`+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := `SELECT ` + presetColumns + ` FROM theme_presets tp` + where
+	switch q.Sort {
+	case PresetSortNewest:
+		query += ` ORDER BY tp.published_at DESC NULLS LAST, tp.id DESC`
+	case PresetSortName:
+		query += ` ORDER BY tp.name, tp.id`
+	default:
+		query += ` ORDER BY usage_count DESC, tp.id`
+	}
+	if q.Limit > 0 {
+		args = append(args, q.Limit, q.Offset)
+		query += fmt.Sprintf(" LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+	}
+
+	presets, err := r.queryPresets(ctx, query, args...)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
-	return &p, nil
+	return presets, total, nil
+}
+
+// ListPresetsByAuthor returns every preset the user wrote, newest first.
+func (r *ThemeRepo) ListPresetsByAuthor(ctx context.Context, authorID int64) ([]*model.ThemePreset, error) {
+	return r.queryPresets(ctx, `
+		SELECT `+presetColumns+` FROM theme_presets tp
+		WHERE tp.author_user_id = $1 ORDER BY tp.created_at DESC, tp.id DESC
+	`, authorID)
+}
+
+// ListPresetsByStatus returns presets in a review state, oldest submission
+// first.
+func (r *ThemeRepo) ListPresetsByStatus(ctx context.Context, status string) ([]*model.ThemePreset, error) {
+	return r.queryPresets(ctx, `
+		SELECT `+presetColumns+` FROM theme_presets tp
+		WHERE tp.status = $1 ORDER BY tp.submitted_at NULLS LAST, tp.id
+	`, status)
+}
+
+func (r *ThemeRepo) GetPresetByID(ctx context.Context, id int64) (*model.ThemePreset, error) {
+	return scanPreset(r.db.QueryRow(ctx, `
+		SELECT `+presetColumns+` FROM theme_presets tp WHERE tp.id = $1
+	`, id))
 }
 
 func (r *ThemeRepo) GetPresetByKey(ctx context.Context, key string) (*model.ThemePreset, error) {
-	var p model.ThemePreset
+	return scanPreset(r.db.QueryRow(ctx, `
+		SELECT `+presetColumns+` FROM theme_presets tp WHERE tp.key = $1
+	`, key))
+}
+
+// CreatePreset stores a user-authored draft preset.
+func (r *ThemeRepo) CreatePreset(ctx context.Context, authorID int64, key, name, tier, visibility string, config json.RawMessage) (*model.ThemePreset, error) {
+	var id int64
 	err := r.db.QueryRow(ctx, `
-		SELECT id, key, name, tier, visibility, is_official, author_user_id, config, created_at, updated_at
-		FROM theme_presets WHERE key = $1
-	`, key).Scan(&p.ID, &p.Key, &p.Name, &p.Tier, &p.Visibility,
-		&p.IsOfficial, &p.AuthorUserID, &p.Config, &p.CreatedAt, &p.UpdatedAt)
+		INSERT INTO theme_presets (key, name, tier, visibility, is_official, author_user_id, config, status)
+		VALUES ($1, $2, $3, $4, false, $5, $6, 'draft')
+		RETURNING id
+	`, key, name, tier, visibility, authorID, config).Scan(&id)
 	if err != nil {
 		return nil, err
 	}
-	return &p, nil
+	return r.GetPresetByID(ctx, id)
 }
 
-func (r *ThemeRepo) CreateCustom(ctx context.Context, userID, presetID int64, patch json.RawMessage, hash string) (*model.ThemeCustom, error) {
+// UpdatePresetDraft edits a draft or rejected preset of the author. It
+// returns pgx.ErrNoRows when there is no such editable preset.
+func (r *ThemeRepo) UpdatePresetDraft(ctx context.Context, id, authorID int64, name, tier, visibility string, config json.RawMessage) error {
+	tag, err := r.db.Exec(ctx, `
+		UPDATE theme_presets SET name = $3, tier = $4, visibility = $5, config = $6, updated_at = NOW()
+		WHERE id = $1 AND author_user_id = $2 AND status IN ('draft', 'rejected')
+	`, id, authorID, name, tier, visibility, config)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// TransitionPreset moves a preset to status if it is currently in one of
+// from, recording the reviewer and note. It returns pgx.ErrNoRows when the
+// preset is not in an expected state, so concurrent reviews cannot both
+// win.
+func (r *ThemeRepo) TransitionPreset(ctx context.Context, id int64, from []string, status string, reviewerID *int64, note *string) error {
+	tag, err := r.db.Exec(ctx, `
+		UPDATE theme_presets SET
+			status = $2,
+			reviewed_by = $4,
+			review_note = $5,
+			submitted_at = CASE WHEN $2 = 'submitted' THEN NOW() ELSE submitted_at END,
+			published_at = CASE WHEN $2 = 'approved' THEN NOW() ELSE published_at END,
+			updated_at = NOW()
+		WHERE id = $1 AND status = ANY($3)
+	`, id, status, from, reviewerID, note)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// escapeLike escapes LIKE wildcards in user input.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+const customColumns = `id, user_id, based_on_preset_id, preset_version_id, name, patch, compiled_config, hash, created_at, updated_at`
+
+func scanCustom(row pgx.Row) (*model.ThemeCustom, error) {
 	var t model.ThemeCustom
-	err := r.db.QueryRow(ctx, `
-		INSERT INTO themes_custom (user_id, based_on_preset_id, patch, hash)
-		VALUES ($1, $2, $3, $4)
-		RETURNING id, user_id, based_on_preset_id, name, patch, compiled_config, hash, created_at, updated_at
-	`, userID, presetID, patch, hash).Scan(
-		&t.ID, &t.UserID, &t.BasedOnPresetID, &t.Name, &t.Patch,
+	err := row.Scan(
+		&t.ID, &t.UserID, &t.BasedOnPresetID, &t.PresetVersionID, &t.Name, &t.Patch,
 		&t.CompiledConfig, &t.Hash, &t.CreatedAt, &t.UpdatedAt,
 	)
 	if err != nil {
@@ -90,34 +222,70 @@ func (r *ThemeRepo) CreateCustom(ctx context.Context, userID, presetID int64, pa
 	return &t, nil
 }
 
+// CreateCustom stores a named patch against presetVersionID of the
+// preset; a nil version means the preset's unversioned config.
+func (r *ThemeRepo) CreateCustom(ctx context.Context, userID, presetID int64, presetVersionID *int64, name string, patch, compiled json.RawMessage, hash string) (*model.ThemeCustom, error) {
+	return scanCustom(r.db.QueryRow(ctx, `
+		INSERT INTO themes_custom (user_id, based_on_preset_id, preset_version_id, name, patch, compiled_config, hash)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING `+customColumns, userID, presetID, presetVersionID, name, patch, compiled, hash))
+}
+
+// GetCustomByHash returns the user's oldest theme with this content.
 func (r *ThemeRepo) GetCustomByHash(ctx context.Context, userID int64, hash string) (*model.ThemeCustom, error) {
-	var t model.ThemeCustom
-	err := r.db.QueryRow(ctx, `
-		SELECT id, user_id, based_on_preset_id, name, patch, compiled_config, hash, created_at, updated_at
+	return scanCustom(r.db.QueryRow(ctx, `
+		SELECT `+customColumns+`
 		FROM themes_custom WHERE user_id = $1 AND hash = $2
-	`, userID, hash).Scan(
-		&t.ID, &t.UserID, &t.BasedOnPresetID, &t.Name, &t.Patch,
-		&t.CompiledConfig, &t.Hash, &t.CreatedAt, &t.UpdatedAt,
-	)
+		ORDER BY id
+		LIMIT 1
+	`, userID, hash))
+}
+
+// ListCustomByUser returns the user's theme library, most recently edited
+// first.
+func (r *ThemeRepo) ListCustomByUser(ctx context.Context, userID int64) ([]*model.ThemeCustom, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT `+customColumns+`
+		FROM themes_custom WHERE user_id = $1
+		ORDER BY updated_at DESC, id DESC
+	`, userID)
 	if err != nil {
 		return nil, err
 	}
-	return &t, nil
+	defer rows.Close()
+
+	var themes []*model.ThemeCustom
+	for rows.Next() {
+		t, err := scanCustom(rows)
+		if err != nil {
+			return nil, err
+		}
+		themes = append(themes, t)
+	}
+	return themes, rows.Err()
 }
 
-func (r *ThemeRepo) GetCustomByID(ctx context.Context, id int64) (*model.ThemeCustom, error) {
-	var t model.ThemeCustom
-	err := r.db.QueryRow(ctx, `
-		SELECT id, user_id, based_on_preset_id, name, patch, compiled_config, hash, created_at, updated_at
-		FROM themes_custom WHERE id = $1
-	`, id).Scan(
-		&t.ID, &t.UserID, &t.BasedOnPresetID, &t.Name, &t.Patch,
-		&t.CompiledConfig, &t.Hash, &t.CreatedAt, &t.UpdatedAt,
-	)
+// RenameCustom renames one of the user's themes. It returns
+// pgx.ErrNoRows when the user has no such theme.
+func (r *ThemeRepo) RenameCustom(ctx context.Context, id, userID int64, name string) error {
+	tag, err := r.db.Exec(ctx, `
+		UPDATE themes_custom SET name = $3, updated_at = NOW()
+		WHERE id = $1 AND user_id = $2
+	`, id, userID, name)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	return &t, nil
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+func (r *ThemeRepo) GetCustomByID(ctx context.Context, id int64) (*model.ThemeCustom, error) {
+	return scanCustom(r.db.QueryRow(ctx, `
+		SELECT `+customColumns+`
+		FROM themes_custom WHERE id = $1
+	`, id))
 }
 
 func (r *ThemeRepo) UpdateCustom(ctx context.Context, id int64, patch, compiled json.RawMessage, hash string) error {
@@ -128,26 +296,157 @@ func (r *ThemeRepo) UpdateCustom(ctx context.Context, id int64, patch, compiled
 	return err
 }
 
-func (r *ThemeRepo) GetCustomByUserID(ctx context.Context, userID int64) (*model.ThemeCustom, error) {
-	var t model.ThemeCustom
-	err := r.db.QueryRow(ctx, `
-		SELECT id, user_id, based_on_preset_id, name, patch, compiled_config, hash, created_at, updated_at
-		FROM themes_custom WHERE user_id = $1
-		ORDER BY updated_at DESC
-		LIMIT 1
-	`, userID).Scan(
-		&t.ID, &t.UserID, &t.BasedOnPresetID, &t.Name, &t.Patch,
-		&t.CompiledConfig, &t.Hash, &t.CreatedAt, &t.UpdatedAt,
-	)
+// RebaseCustom moves a custom theme onto another version of its preset.
+func (r *ThemeRepo) RebaseCustom(ctx context.Context, id, presetVersionID int64, patch, compiled json.RawMessage, hash string) error {
+	tag, err := r.db.Exec(ctx, `
+		UPDATE themes_custom tc SET preset_version_id = $2, patch = $3, compiled_config = $4, hash = $5, updated_at = NOW()
+		FROM theme_preset_versions v
+		WHERE tc.id = $1 AND v.id = $2 AND v.preset_id = tc.based_on_preset_id
+	`, id, presetVersionID, patch, compiled, hash)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	return &t, nil
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
 }
 
-func (r *ThemeRepo) DeleteCustom(ctx context.Context, id, userID int64) error {
-	_, err := r.db.Exec(ctx, `
+// DeleteCustom deletes one of the user's themes, or returns pgx.ErrNoRows.
+// While pages use it the delete fails on the bio_pages foreign key, unless
+// fallback is set: then those pages go back to the theme's preset in the
+// same transaction. It returns the ids of the pages that fell back.
+func (r *ThemeRepo) DeleteCustom(ctx context.Context, id, userID int64, fallback bool) ([]int64, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	var pageIDs []int64
+	if fallback {
+		rows, err := tx.Query(ctx, `
+			UPDATE bio_pages bp SET theme_custom_id = NULL, theme_preset_id = tc.based_on_preset_id, updated_at = NOW()
+			FROM themes_custom tc
+			WHERE bp.theme_custom_id = tc.id AND tc.id = $1 AND tc.user_id = $2
+			RETURNING bp.id
+		`, id, userID)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var pageID int64
+			if err := rows.Scan(&pageID); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			pageIDs = append(pageIDs, pageID)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	tag, err := tx.Exec(ctx, `
 		DELETE FROM themes_custom WHERE id = $1 AND user_id = $2
 	`, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if tag.RowsAffected() == 0 {
+		return nil, pgx.ErrNoRows
+	}
+	return pageIDs, tx.Commit(ctx)
+}
+
+const versionColumns = `id, preset_id, version, schema_version, config, created_at`
+
+// SaveStylesheet stores a compiled stylesheet under the hash of its
+// content; saving the same content again is a no-op.
+func (r *ThemeRepo) SaveStylesheet(ctx context.Context, hash string, css []byte) error {
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO theme_stylesheets (hash, css) VALUES ($1, $2)
+		ON CONFLICT (hash) DO NOTHING
+	`, hash, string(css))
 	return err
 }
+
+func (r *ThemeRepo) GetStylesheet(ctx context.Context, hash string) ([]byte, error) {
+	var css string
+	err := r.db.QueryRow(ctx, `SELECT css FROM theme_stylesheets WHERE hash = $1`, hash).Scan(&css)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(css), nil
+}
+
+func scanVersion(row pgx.Row) (*model.ThemePresetVersion, error) {
+	var v model.ThemePresetVersion
+	if err := row.Scan(&v.ID, &v.PresetID, &v.Version, &v.SchemaVersion, &v.Config, &v.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+func (r *ThemeRepo) GetPresetVersion(ctx context.Context, id int64) (*model.ThemePresetVersion, error) {
+	return scanVersion(r.db.QueryRow(ctx, `
+		SELECT `+versionColumns+` FROM theme_preset_versions WHERE id = $1
+	`, id))
+}
+
+// ListPresetVersions lists a preset's versions, newest first.
+func (r *ThemeRepo) ListPresetVersions(ctx context.Context, presetID int64) ([]*model.ThemePresetVersion, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT `+versionColumns+` FROM theme_preset_versions
+		WHERE preset_id = $1
+		ORDER BY created_at DESC, id DESC
+	`, presetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var versions []*model.ThemePresetVersion
+	for rows.Next() {
+		v, err := scanVersion(rows)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, v)
+	}
+	return versions, rows.Err()
+}
+
+// PublishPresetVersion records config as a new version of an approved
+// preset and makes it current. A duplicate version string fails with the
+// uq_preset_version unique violation.
+func (r *ThemeRepo) PublishPresetVersion(ctx context.Context, presetID int64, version string, schemaVersion int, config json.RawMessage) (*model.ThemePresetVersion, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	v, err := scanVersion(tx.QueryRow(ctx, `
+		INSERT INTO theme_preset_versions (preset_id, version, schema_version, config)
+		VALUES ($1, $2, $3, $4)
+		RETURNING `+versionColumns, presetID, version, schemaVersion, config))
+	if err != nil {
+		return nil, err
+	}
+	tag, err := tx.Exec(ctx, `
+		UPDATE theme_presets SET config = $2, current_version_id = $3, updated_at = NOW()
+		WHERE id = $1 AND status = 'approved'
+	`, presetID, config, v.ID)
+	if err != nil {
+		return nil, err
+	}
+	if tag.RowsAffected() == 0 {
+		return nil, pgx.ErrNoRows
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
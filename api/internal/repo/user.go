@@ -97,3 +97,25 @@ func (r *UserRepo) UpdateDisplayName(ctx context.Context, userID int64, displayN
 	`, userID, displayName)
 	return err
 }
+
+// IsAdmin reports whether the user may moderate the theme marketplace.
+func (r *UserRepo) IsAdmin(ctx context.Context, userID int64) (bool, error) {
+	var isAdmin bool
+	err := r.db.QueryRow(ctx, `SELECT is_admin FROM users WHERE id = $1`, userID).Scan(&isAdmin)
+	return isAdmin, err
+}
+
+// IsPro reports whether the user has an active, unexpired Pro
+// subscription.
+func (r *UserRepo) IsPro(ctx context.Context, userID int64) (bool, error) {
+	var isPro bool
+	err := r.db.QueryRow(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM subscriptions s
+			JOIN plans p ON p.id = s.plan_id
+			WHERE s.user_id = $1 AND p.code = 'PRO' AND s.status = 'active'
+			  AND (s.current_period_end IS NULL OR s.current_period_end > NOW())
+		)
+	`, userID).Scan(&isPro)
+	return isPro, err
+}
@@ -0,0 +1,216 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"linkbio/internal/cache"
+	"linkbio/internal/cdn"
+	"linkbio/internal/model"
+	"linkbio/internal/repo"
+	"linkbio/internal/targeting"
+)
+
+const (
+	// SourceQR marks traffic from printed QR codes.
+	SourceQR = "qr"
+	// SourceShort marks traffic through short links.
+	SourceShort = "short"
+)
+
+var sourceRegex = regexp.MustCompile(`^[a-z0-9_-]{1,32}$`)
+
+// AnalyticsService counts views of published pages and clicks through
+// their tracked link redirects.
+type AnalyticsService struct {
+	analyticsRepo  repo.AnalyticsStore
+	pageRepo       repo.PageStore
+	bioRepo        repo.BioStore
+	experimentRepo repo.ExperimentStore
+	geo            *targeting.GeoIP
+	renderCache    *cache.RenderCache
+	purger         cdn.Purger
+}
+
+func NewAnalyticsService(analyticsRepo repo.AnalyticsStore, pageRepo repo.PageStore, bioRepo repo.BioStore, experimentRepo repo.ExperimentStore, geo *targeting.GeoIP, renderCache *cache.RenderCache, purger cdn.Purger) *AnalyticsService {
+	return &AnalyticsService{
+		analyticsRepo:  analyticsRepo,
+		pageRepo:       pageRepo,
+		bioRepo:        bioRepo,
+		experimentRepo: experimentRepo,
+		geo:            geo,
+		renderCache:    renderCache,
+		purger:         purger,
+	}
+}
+
+// LinkPath is the tracked redirect to a link's destination.
+func LinkPath(linkID int64) string {
+	return "/l/" + strconv.FormatInt(linkID, 10)
+}
+
+// Source normalizes a ?src= marker. Anything other than a short lowercase
+// slug is dropped rather than stored.
+func Source(src string) string {
+	src = strings.ToLower(strings.TrimSpace(src))
+	if !sourceRegex.MatchString(src) {
+		return ""
+	}
+	return src
+}
+
+// RecordView counts a view of a published public page. variant is the
+// experiment variant the page was served as, if any.
+func (s *AnalyticsService) RecordView(ctx context.Context, pageID int64, source, variant string) error {
+	page, err := s.pageRepo.GetByID(ctx, pageID)
+	if err != nil {
+		return notFound(err)
+	}
+	if !servesPublicly(page) {
+		return ErrNotFound
+	}
+	return s.analyticsRepo.RecordView(ctx, pageID, Source(source), s.variantTag(ctx, pageID, variant))
+}
+
+// Click returns the destination of a link as last published and counts the
+// click. Links that are hidden, inactive or not yet published are not
+// found. A link with targeting rules sends the visitor to the first rule
+// they match, or its own URL. An exhausted limited link is gone, or sends
+// every visitor to its fallback. A click that fails to record is logged;
+// the visitor still goes through.
+func (s *AnalyticsService) Click(ctx context.Context, linkID int64, source, variant string, visitor targeting.Request) (string, error) {
+	pageID, err := s.bioRepo.GetLinkPageID(ctx, linkID)
+	if err != nil {
+		return "", notFound(err)
+	}
+	page, err := s.pageRepo.GetByID(ctx, pageID)
+	if err != nil {
+		return "", notFound(err)
+	}
+	if !servesPublicly(page) {
+		return "", ErrNotFound
+	}
+	cache, err := s.pageRepo.GetPublishCache(ctx, pageID)
+	if err != nil {
+		return "", notFound(err)
+	}
+	var compiled CompiledPage
+	if err := json.Unmarshal(cache.CompiledJSON, &compiled); err != nil {
+		return "", err
+	}
+	link := compiled.findLink(linkID)
+	if link == nil {
+		return "", ErrNotFound
+	}
+	if link.limited() {
+		exhausted, err := s.claim(ctx, pageID, link)
+		if err != nil {
+			return "", err
+		}
+		if exhausted && link.FallbackURL == "" {
+			return "", ErrLinkGone
+		}
+		if exhausted {
+			link = &CompiledLink{ID: link.ID, URL: link.FallbackURL}
+		}
+	}
+
+	if err := s.analyticsRepo.RecordClick(ctx, pageID, linkID, Source(source), s.variantTag(ctx, pageID, variant)); err != nil {
+		log.Printf("[Analytics] click %d: %v", linkID, err)
+	}
+	if len(link.Rules) > 0 {
+		if url, ok := targeting.Match(link.Rules, s.geo.Visitor(visitor)); ok {
+			return url, nil
+		}
+	}
+	return link.URL, nil
+}
+
+// Summary counts the views and clicks of the user's page over the last
+// days.
+func (s *AnalyticsService) Summary(ctx context.Context, userID, pageID int64, days int) (*model.AnalyticsSummary, error) {
+	if days == 0 {
+		days = 30
+	}
+	if days < 1 || days > 365 {
+		return nil, ErrAnalyticsRange
+	}
+	page, err := s.pageRepo.GetByID(ctx, pageID)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	if page.UserID != userID {
+		return nil, ErrForbidden
+	}
+	return s.analyticsRepo.Summary(ctx, pageID, time.Now().AddDate(0, 0, -days))
+}
+
+// variantTag tags a hit with a variant of the page's running experiment.
+// Variants of other or ended experiments are dropped, like bad sources.
+func (s *AnalyticsService) variantTag(ctx context.Context, pageID int64, variant string) model.VariantTag {
+	if variant == "" {
+		return model.VariantTag{}
+	}
+	experiment, err := s.experimentRepo.GetRunning(ctx, pageID)
+	if err != nil {
+		return model.VariantTag{}
+	}
+	for _, v := range experiment.Variants {
+		if v.Key == variant {
+			return model.VariantTag{ExperimentID: experiment.ID, Variant: v.Key}
+		}
+	}
+	return model.VariantTag{}
+}
+
+// claim counts a click on a limited link and reports whether the link is
+// exhausted. The click that reaches the cap invalidates the page on every
+// instance's render cache and purges the CDN.
+func (s *AnalyticsService) claim(ctx context.Context, pageID int64, link *CompiledLink) (bool, error) {
+	if link.expired(time.Now()) {
+		return true, nil
+	}
+	if link.MaxClicks == nil {
+		return false, nil
+	}
+	count, err := s.bioRepo.ClaimLinkClick(ctx, link.ID, *link.MaxClicks)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if count == *link.MaxClicks {
+		s.renderCache.InvalidatePage(pageID)
+		purge(ctx, s.purger, cdn.PageKey(pageID))
+	}
+	return false, nil
+}
+
+// servesPublicly reports whether anyone may see the page's published
+// content.
+func servesPublicly(page *model.BioPage) bool {
+	return page.Status == "published" && page.AccessType == "public"
+}
+
+// findLink returns the visible, active link with the given id.
+func (p *CompiledPage) findLink(id int64) *CompiledLink {
+	for _, b := range p.Blocks {
+		if !b.IsVisible || b.Group == nil {
+			continue
+		}
+		for i, l := range b.Group.Links {
+			if l.ID == id && l.IsActive {
+				return &b.Group.Links[i]
+			}
+		}
+	}
+	return nil
+}
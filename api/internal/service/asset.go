@@ -0,0 +1,127 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"net/http"
+
+	"linkbio/internal/model"
+	"linkbio/internal/repo"
+	"linkbio/internal/theme"
+	"linkbio/internal/util"
+)
+
+// MaxUpload caps uploaded images, below the server's request body limit;
+// maxUploadPixels keeps a small file from decoding into a huge image.
+const (
+	MaxUpload       = 2 << 20
+	maxUploadPixels = 40 << 20
+)
+
+var uploadTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+type AssetService struct {
+	assetRepo repo.AssetStore
+	images    *imageLoader
+}
+
+func NewAssetService(assetRepo repo.AssetStore) *AssetService {
+	return &AssetService{assetRepo: assetRepo, images: newImageLoader(assetRepo)}
+}
+
+// AssetPath is where an uploaded image is served.
+func AssetPath(key string) string {
+	return "/assets/" + key
+}
+
+// UploadImage stores an image in the database with its size and dominant
+// color, which theme lint uses to judge text drawn over a wallpaper.
+func (s *AssetService) UploadImage(ctx context.Context, userID int64, data []byte) (*model.Asset, error) {
+	if len(data) > MaxUpload {
+		return nil, ErrAssetTooLarge
+	}
+	mime := http.DetectContentType(data)
+	if !uploadTypes[mime] {
+		return nil, ErrAssetType
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrAssetType
+	}
+	if config.Width*config.Height > maxUploadPixels {
+		return nil, ErrAssetTooLarge
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrAssetType
+	}
+
+	key := util.SHA256(string(data))
+	if err := s.assetRepo.SaveBlob(ctx, key, data); err != nil {
+		return nil, err
+	}
+	url, color := AssetPath(key), theme.DominantColor(img)
+	size, width, height := int64(len(data)), img.Bounds().Dx(), img.Bounds().Dy()
+	return s.assetRepo.Create(ctx, &model.Asset{
+		UserID:        &userID,
+		Scope:         "user_upload",
+		Type:          "image",
+		Provider:      "db",
+		StorageKey:    key,
+		URL:           &url,
+		MimeType:      &mime,
+		SizeBytes:     &size,
+		Width:         &width,
+		Height:        &height,
+		DominantColor: &color,
+	})
+}
+
+// GetUpload returns an uploaded image stored in the database by its key.
+func (s *AssetService) GetUpload(ctx context.Context, key string) (*model.Asset, []byte, error) {
+	asset, err := s.assetRepo.GetByStorageKey(ctx, "user_upload", key)
+	if err != nil {
+		return nil, nil, notFound(err)
+	}
+	if asset.Provider != "db" {
+		return nil, nil, ErrNotFound
+	}
+	data, err := s.assetRepo.GetBlob(ctx, key)
+	if err != nil {
+		return nil, nil, notFound(err)
+	}
+	return asset, data, nil
+}
+
+// BackfillDominantColors measures images stored before colors were
+// computed on upload. Images that fail to load are logged and skipped.
+func (s *AssetService) BackfillDominantColors(ctx context.Context) (int, error) {
+	var after int64
+	filled := 0
+	for {
+		batch, err := s.assetRepo.ListMissingColor(ctx, after, 100)
+		if err != nil {
+			return filled, err
+		}
+		if len(batch) == 0 {
+			return filled, nil
+		}
+		for _, asset := range batch {
+			after = asset.ID
+			img := s.images.load(ctx, asset)
+			if img == nil {
+				continue
+			}
+			if err := s.assetRepo.SetDominantColor(ctx, asset.ID, theme.DominantColor(img)); err != nil {
+				return filled, err
+			}
+			filled++
+		}
+	}
+}
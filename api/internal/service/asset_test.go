@@ -0,0 +1,78 @@
+package service_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"linkbio/internal/model"
+	"linkbio/internal/service"
+)
+
+func solidPNG(t *testing.T, c color.Color) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 8, 4))
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 8; x++ {
+			img.Set(x, y, c)
+		}
+	}
+	var buf bytes.Buffer
+	must(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+func TestUploadImage(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t)
+	alice := f.user(t, "alice@example.com")
+
+	asset, err := f.assetSvc.UploadImage(ctx, alice.ID, solidPNG(t, color.RGBA{0x33, 0x66, 0x99, 0xff}))
+	must(t, err)
+	if asset.DominantColor == nil || *asset.DominantColor != "#336699" {
+		t.Errorf("dominant color = %v", asset.DominantColor)
+	}
+	if *asset.Width != 8 || *asset.Height != 4 || *asset.MimeType != "image/png" {
+		t.Errorf("asset = %+v", asset)
+	}
+
+	got, data, err := f.assetSvc.GetUpload(ctx, asset.StorageKey)
+	must(t, err)
+	if got.ID != asset.ID || len(data) != int(*asset.SizeBytes) {
+		t.Errorf("GetUpload = %d, %d bytes", got.ID, len(data))
+	}
+
+	_, err = f.assetSvc.UploadImage(ctx, alice.ID, []byte("<svg></svg>"))
+	if !errors.Is(err, service.ErrAssetType) {
+		t.Errorf("svg upload: %v, want ErrAssetType", err)
+	}
+}
+
+func TestBackfillDominantColors(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t)
+	alice := f.user(t, "alice@example.com")
+
+	data := solidPNG(t, color.RGBA{0xff, 0x00, 0x00, 0xff})
+	must(t, f.assets.SaveBlob(ctx, "old", data))
+	old, err := f.assets.Create(ctx, &model.Asset{UserID: &alice.ID, Scope: "user_upload", Type: "image", Provider: "db", StorageKey: "old"})
+	must(t, err)
+	// A blob that is gone is skipped, not fatal.
+	_, err = f.assets.Create(ctx, &model.Asset{UserID: &alice.ID, Scope: "user_upload", Type: "image", Provider: "db", StorageKey: "gone"})
+	must(t, err)
+
+	filled, err := f.assetSvc.BackfillDominantColors(ctx)
+	must(t, err)
+	if filled != 1 {
+		t.Errorf("filled = %d, want 1", filled)
+	}
+	got, err := f.assets.GetByID(ctx, old.ID)
+	must(t, err)
+	if got.DominantColor == nil || *got.DominantColor != "#ff0000" {
+		t.Errorf("dominant color = %v", got.DominantColor)
+	}
+}
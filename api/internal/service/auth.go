@@ -2,7 +2,6 @@ package service
 
 import (
 	"context"
-	"errors"
 	"regexp"
 	"time"
 
@@ -12,21 +11,14 @@ import (
 	"linkbio/internal/util"
 )
 
-var (
-	ErrInvalidCredentials = errors.New("invalid credentials")
-	ErrEmailExists        = errors.New("email already exists")
-	ErrUsernameExists     = errors.New("username already exists")
-	ErrInvalidUsername    = errors.New("invalid username")
-)
-
 var usernameRegex = regexp.MustCompile(`^[a-z0-9_]{3,30}$`)
 
 type AuthService struct {
-	userRepo  *repo.UserRepo
+	userRepo  repo.UserStore
 	jwtSecret string
 }
 
-func NewAuthService(userRepo *repo.UserRepo, jwtSecret string) *AuthService {
+func NewAuthService(userRepo repo.UserStore, jwtSecret string) *AuthService {
 	return &AuthService{userRepo: userRepo, jwtSecret: jwtSecret}
 }
 
@@ -73,7 +65,11 @@ func (s *AuthService) Login(ctx context.Context, email, password string) (*model
 }
 
 func (s *AuthService) GetUser(ctx context.Context, userID int64) (*model.User, error) {
-	return s.userRepo.GetByID(ctx, userID)
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return user, nil
 }
 
 func (s *AuthService) SetUsername(ctx context.Context, userID int64, username string) (*model.User, error) {
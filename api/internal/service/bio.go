@@ -4,30 +4,32 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 
 	"linkbio/internal/model"
 	"linkbio/internal/repo"
+	"linkbio/internal/social"
+	"linkbio/internal/urlpolicy"
 	"linkbio/internal/util"
 )
 
-var (
-	ErrNotFound  = errors.New("not found")
-	ErrForbidden = errors.New("forbidden")
-)
-
 type BioService struct {
-	bioRepo   *repo.BioRepo
-	pageRepo  *repo.PageRepo
-	blockRepo *repo.BlockRepo
-	userRepo  *repo.UserRepo
+	bioRepo       repo.BioStore
+	pageRepo      repo.PageStore
+	blockRepo     repo.BlockStore
+	userRepo      repo.UserStore
+	aggregateRepo repo.PageAggregateStore
+	urlPolicy     *urlpolicy.Policy
 }
 
-func NewBioService(bioRepo *repo.BioRepo, pageRepo *repo.PageRepo, blockRepo *repo.BlockRepo, userRepo *repo.UserRepo) *BioService {
+func NewBioService(bioRepo repo.BioStore, pageRepo repo.PageStore, blockRepo repo.BlockStore, userRepo repo.UserStore, aggregateRepo repo.PageAggregateStore, urlPolicy *urlpolicy.Policy) *BioService {
 	return &BioService{
-		bioRepo:   bioRepo,
-		pageRepo:  pageRepo,
-		blockRepo: blockRepo,
-		userRepo:  userRepo,
+		bioRepo:       bioRepo,
+		pageRepo:      pageRepo,
+		blockRepo:     blockRepo,
+		userRepo:      userRepo,
+		aggregateRepo: aggregateRepo,
+		urlPolicy:     urlPolicy,
 	}
 }
 
@@ -54,37 +56,24 @@ func (s *BioService) GetBio(ctx context.Context, userID int64) (*BioData, error)
 		return nil, err
 	}
 
-	// Get blocks
-	blocks, err := s.blockRepo.GetBlocksByPage(ctx, page.ID)
-	if err != nil {
-		return nil, err
-	}
-
-	// Get groups
-	groups, err := s.blockRepo.GetLinkGroupsByPage(ctx, page.ID)
+	// Get blocks, groups and links
+	agg, err := s.aggregateRepo.LoadForPage(ctx, page)
 	if err != nil {
 		return nil, err
 	}
 
 	// Build group map with links
 	groupMap := make(map[int64]*GroupWithLinks)
-	for _, g := range groups {
-		links, err := s.blockRepo.GetLinksByGroup(ctx, g.ID)
-		if err != nil {
-			return nil, err
-		}
-		if links == nil {
-			links = []*model.Link{}
-		}
+	for _, g := range agg.Groups {
 		groupMap[g.ID] = &GroupWithLinks{
 			LinkGroup: g,
-			Links:     links,
+			Links:     agg.Links[g.ID],
 		}
 	}
 
 	// Build blocks with groups
-	blocksWithGroups := make([]*BlockWithGroup, 0, len(blocks))
-	for _, b := range blocks {
+	blocksWithGroups := make([]*BlockWithGroup, 0, len(agg.Blocks))
+	for _, b := range agg.Blocks {
 		bwg := &BlockWithGroup{Block: b}
 		if b.Type == "link_group" && b.RefID != nil {
 			if g, ok := groupMap[*b.RefID]; ok {
@@ -213,6 +202,11 @@ func (s *BioService) AddLink(ctx context.Context, userID, groupID int64, title,
 		return nil, ErrForbidden
 	}
 
+	url, err = checkLinkURL(s.urlPolicy, "url", url)
+	if err != nil {
+		return nil, err
+	}
+
 	// Get last sort key
 	lastKey, _ := s.bioRepo.GetLastLinkSortKey(ctx, groupID)
 	sortKey := util.GenerateSortKey(lastKey, "")
@@ -238,7 +232,9 @@ func (s *BioService) UpdateLink(ctx context.Context, userID, linkID int64, title
 		link.Title = title
 	}
 	if url != "" {
-		link.URL = url
+		if link.URL, err = checkLinkURL(s.urlPolicy, "url", url); err != nil {
+			return nil, err
+		}
 	}
 	if isActive != nil {
 		link.IsActive = *isActive
@@ -266,19 +262,22 @@ func (s *BioService) DeleteLink(ctx context.Context, userID, linkID int64) error
 
 func (s *BioService) ReorderBlocks(ctx context.Context, userID int64, blockIDs []int64) error {
 	for i, blockID := range blockIDs {
-		// Generate sort key based on position
-		sortKey := string(rune('A' + i))
-		if i >= 26 {
-			sortKey = string(rune('A'+i/26-1)) + string(rune('A'+i%26))
-		}
-
-		if err := s.bioRepo.UpdateBlockSortKey(ctx, blockID, sortKey); err != nil {
+		if err := s.bioRepo.UpdateBlockSortKey(ctx, blockID, positionSortKey(i)); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
+// positionSortKey is the sort key of the i-th block when a whole page is
+// reordered.
+func positionSortKey(i int) string {
+	if i >= 26 {
+		return string(rune('A'+i/26-1)) + string(rune('A'+i%26))
+	}
+	return string(rune('A' + i))
+}
+
 // UpdateProfile updates user display name and bio in page settings
 func (s *BioService) UpdateProfile(ctx context.Context, userID int64, displayName, bio string) error {
 	// Update display name in users table
@@ -315,12 +314,41 @@ func (s *BioService) UpdateProfile(ctx context.Context, userID int64, displayNam
 	return s.pageRepo.UpdateSettings(ctx, page.ID, newSettings)
 }
 
-// UpdateSocialLinks updates social media links in page settings
-func (s *BioService) UpdateSocialLinks(ctx context.Context, userID int64, req interface{}) error {
-	// Get page
+const maxSocialLinks = 20
+
+// SocialLinkInput is one entry of the social row as the user typed it: a
+// handle or a profile URL.
+type SocialLinkInput struct {
+	Platform string `json:"platform"`
+	Value    string `json:"value"`
+}
+
+// GetSocialLinks returns the social row of the user's page.
+func (s *BioService) GetSocialLinks(ctx context.Context, userID int64) ([]social.Link, error) {
 	page, err := s.bioRepo.GetOrCreatePage(ctx, userID)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	var settings map[string]json.RawMessage
+	_ = json.Unmarshal(page.Settings, &settings)
+	links := social.Decode(settings["social"])
+	if links == nil {
+		links = []social.Link{}
+	}
+	return links, nil
+}
+
+// UpdateSocialLinks normalizes each entry against the platform registry and
+// stores the row, in order, in page settings.
+func (s *BioService) UpdateSocialLinks(ctx context.Context, userID int64, inputs []SocialLinkInput) ([]social.Link, error) {
+	links, err := normalizeSocialLinks(s.urlPolicy, inputs)
+	if err != nil {
+		return nil, err
+	}
+
+	page, err := s.bioRepo.GetOrCreatePage(ctx, userID)
+	if err != nil {
+		return nil, err
 	}
 
 	// Parse current settings
@@ -333,15 +361,60 @@ func (s *BioService) UpdateSocialLinks(ctx context.Context, userID int64, req in
 		settings = make(map[string]interface{})
 	}
 
-	// Update social links
-	settings["social"] = req
+	settings["social"] = links
 
-	// Marshal back to JSON
 	newSettings, err := json.Marshal(settings)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if err := s.pageRepo.UpdateSettings(ctx, page.ID, newSettings); err != nil {
+		return nil, err
 	}
+	return links, nil
+}
 
-	// Update page
-	return s.pageRepo.UpdateSettings(ctx, page.ID, newSettings)
+// normalizeSocial normalizes one social entry. Website URLs go through the
+// link policy too, so they obey the same hosts and blocklist as links.
+func normalizeSocial(policy *urlpolicy.Policy, platform *social.Platform, value string) (social.Link, error) {
+	link, err := platform.Normalize(value)
+	if err != nil || !platform.Website() {
+		return link, err
+	}
+	link.URL, err = policy.Normalize(link.URL)
+	return link, err
+}
+
+func normalizeSocialLinks(policy *urlpolicy.Policy, inputs []SocialLinkInput) ([]social.Link, error) {
+	if len(inputs) > maxSocialLinks {
+		return nil, ErrSocialTooMany
+	}
+	links := make([]social.Link, 0, len(inputs))
+	seen := make(map[string]bool, len(inputs))
+	e := ErrSocialInvalid
+	for i, in := range inputs {
+		field := fmt.Sprintf("links[%d]", i)
+		platform, ok := social.Lookup(in.Platform)
+		if !ok {
+			e = e.WithField(field+".platform", "enum", "social.unknown_platform")
+			continue
+		}
+		link, err := normalizeSocial(policy, platform, in.Value)
+		switch {
+		case errors.Is(err, social.ErrWrongDomain):
+			e = e.WithField(field+".value", "domain", "social.wrong_domain")
+		case errors.Is(err, urlpolicy.ErrBlocked):
+			e = e.WithField(field+".value", "blocked", "link.blocked_host")
+		case err != nil:
+			e = e.WithField(field+".value", "format", "social.invalid_value")
+		case seen[link.URL]:
+			e = e.WithField(field+".value", "duplicate", "social.duplicate")
+		default:
+			seen[link.URL] = true
+			links = append(links, link)
+		}
+	}
+	if len(e.Fields) > 0 {
+		return nil, e
+	}
+	return links, nil
 }
@@ -0,0 +1,117 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"linkbio/internal/service"
+	"linkbio/internal/urlpolicy"
+)
+
+func TestBioLinks(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t)
+	alice := f.user(t, "alice@example.com")
+	f.page(t, alice)
+
+	block, err := f.bioSvc.AddBlock(ctx, alice.ID, "link_group", nil)
+	must(t, err)
+	if block.Group == nil || block.RefID == nil || *block.RefID != block.Group.ID {
+		t.Fatalf("AddBlock = %+v, want a block on a new group", block)
+	}
+
+	first, err := f.bioSvc.AddLink(ctx, alice.ID, block.Group.ID, "First", "example.com")
+	must(t, err)
+	if first.URL != "https://example.com" {
+		t.Errorf("URL = %q, want it normalized", first.URL)
+	}
+	second, err := f.bioSvc.AddLink(ctx, alice.ID, block.Group.ID, "Second", "https://example.org")
+	must(t, err)
+	if second.SortKey <= first.SortKey {
+		t.Errorf("sort keys %q, %q: new links must sort last", first.SortKey, second.SortKey)
+	}
+
+	off := false
+	updated, err := f.bioSvc.UpdateLink(ctx, alice.ID, first.ID, "Renamed", "", &off)
+	must(t, err)
+	if updated.Title != "Renamed" || updated.IsActive || updated.URL != first.URL {
+		t.Errorf("UpdateLink = %+v", updated)
+	}
+	must(t, f.bioSvc.DeleteLink(ctx, alice.ID, second.ID))
+
+	bio, err := f.bioSvc.GetBio(ctx, alice.ID)
+	must(t, err)
+	if len(bio.Blocks) != 1 || bio.Blocks[0].Group == nil {
+		t.Fatalf("GetBio blocks = %+v", bio.Blocks)
+	}
+	links := bio.Blocks[0].Group.Links
+	if len(links) != 1 || links[0].ID != first.ID || links[0].Title != "Renamed" {
+		t.Errorf("GetBio links = %+v, want only the renamed link", links)
+	}
+}
+
+func TestBioOwnership(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t)
+	alice := f.user(t, "alice@example.com")
+	bob := f.user(t, "bob@example.com")
+	f.page(t, alice)
+
+	block, err := f.bioSvc.AddBlock(ctx, alice.ID, "link_group", nil)
+	must(t, err)
+	link, err := f.bioSvc.AddLink(ctx, alice.ID, block.Group.ID, "Site", "https://example.com")
+	must(t, err)
+
+	if _, err := f.bioSvc.AddLink(ctx, bob.ID, block.Group.ID, "Spam", "https://example.net"); !errors.Is(err, service.ErrForbidden) {
+		t.Errorf("AddLink by another user = %v, want ErrForbidden", err)
+	}
+	if _, err := f.bioSvc.UpdateLink(ctx, bob.ID, link.ID, "Spam", "", nil); !errors.Is(err, service.ErrForbidden) {
+		t.Errorf("UpdateLink by another user = %v, want ErrForbidden", err)
+	}
+	if err := f.bioSvc.DeleteBlock(ctx, bob.ID, block.ID); !errors.Is(err, service.ErrForbidden) {
+		t.Errorf("DeleteBlock by another user = %v, want ErrForbidden", err)
+	}
+	if err := f.bioSvc.DeleteLink(ctx, alice.ID, link.ID+100); !errors.Is(err, service.ErrNotFound) {
+		t.Errorf("DeleteLink of a missing link = %v, want ErrNotFound", err)
+	}
+}
+
+func TestBioRejectsBadURL(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t)
+	alice := f.user(t, "alice@example.com")
+	f.page(t, alice)
+
+	block, err := f.bioSvc.AddBlock(ctx, alice.ID, "link_group", nil)
+	must(t, err)
+	if _, err := f.bioSvc.AddLink(ctx, alice.ID, block.Group.ID, "Script", "javascript:alert(1)"); !errors.Is(err, service.ErrLinkURL) {
+		t.Errorf("AddLink = %v, want ErrLinkURL", err)
+	}
+}
+
+func TestSocialWebsiteUsesURLPolicy(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t)
+	alice := f.user(t, "alice@example.com")
+	f.page(t, alice)
+
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	must(t, os.WriteFile(path, []byte("evil.example\n"), 0o644))
+	blocklist, err := urlpolicy.LoadBlocklist(path)
+	must(t, err)
+	bio := service.NewBioService(f.bio, f.pages, f.blocks, f.users, f.aggregates, urlpolicy.New(blocklist))
+
+	links, err := bio.UpdateSocialLinks(ctx, alice.ID, []service.SocialLinkInput{{Platform: "website", Value: "Bücher.example/shop"}})
+	must(t, err)
+	if links[0].URL != "https://xn--bcher-kva.example/shop" {
+		t.Errorf("website = %q", links[0].URL)
+	}
+
+	_, err = bio.UpdateSocialLinks(ctx, alice.ID, []service.SocialLinkInput{{Platform: "website", Value: "https://www.evil.example/"}})
+	if !errors.Is(err, service.ErrSocialInvalid) {
+		t.Errorf("blocked website: %v, want ErrSocialInvalid", err)
+	}
+}
@@ -3,32 +3,87 @@ package service
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
+	"time"
 
+	"github.com/jackc/pgx/v5"
+	"linkbio/internal/apperr"
+	"linkbio/internal/cache"
+	"linkbio/internal/cdn"
+	"linkbio/internal/model"
 	"linkbio/internal/repo"
+	"linkbio/internal/social"
+	"linkbio/internal/targeting"
+	"linkbio/internal/theme"
+	"linkbio/internal/urlpolicy"
+	"linkbio/internal/util"
 )
 
 type CompilerService struct {
-	pageRepo  *repo.PageRepo
-	blockRepo *repo.BlockRepo
-	themeRepo *repo.ThemeRepo
-	userRepo  *repo.UserRepo
+	pageRepo      repo.PageStore
+	aggregateRepo repo.PageAggregateStore
+	themeRepo     repo.ThemeStore
+	userRepo      repo.UserStore
+	assetRepo     repo.AssetStore
+	ogImages      *OGImageService
+	urlPolicy     *urlpolicy.Policy
+	renderCache   *cache.RenderCache
+	purger        cdn.Purger
 }
 
-func NewCompilerService(pageRepo *repo.PageRepo, blockRepo *repo.BlockRepo, themeRepo *repo.ThemeRepo, userRepo *repo.UserRepo) *CompilerService {
+func NewCompilerService(pageRepo repo.PageStore, aggregateRepo repo.PageAggregateStore, themeRepo repo.ThemeStore, userRepo repo.UserStore, assetRepo repo.AssetStore, ogImages *OGImageService, urlPolicy *urlpolicy.Policy, renderCache *cache.RenderCache, purger cdn.Purger) *CompilerService {
 	return &CompilerService{
-		pageRepo:  pageRepo,
-		blockRepo: blockRepo,
-		themeRepo: themeRepo,
-		userRepo:  userRepo,
+		pageRepo:      pageRepo,
+		aggregateRepo: aggregateRepo,
+		themeRepo:     themeRepo,
+		userRepo:      userRepo,
+		assetRepo:     assetRepo,
+		ogImages:      ogImages,
+		urlPolicy:     urlPolicy,
+		renderCache:   renderCache,
+		purger:        purger,
 	}
 }
 
 type CompiledPage struct {
-	Page   CompiledPageInfo   `json:"page"`
-	User   *CompiledUserInfo  `json:"user,omitempty"`
-	Theme  json.RawMessage    `json:"theme"`
-	Blocks []CompiledBlock    `json:"blocks"`
+	Page       CompiledPageInfo  `json:"page"`
+	User       *CompiledUserInfo `json:"user,omitempty"`
+	Theme      json.RawMessage   `json:"theme"`
+	Stylesheet string            `json:"stylesheet"` // immutable URL of the theme's CSS
+	SEO        CompiledSEO       `json:"seo"`
+	Social     []CompiledSocial  `json:"social"`
+	Blocks     []CompiledBlock   `json:"blocks"`
+	// Experiment is set on the variants of a page under an A/B experiment.
+	Experiment *CompiledExperiment `json:"experiment,omitempty"`
+}
+
+// SkippedLink is an active link left out of a compiled page because one
+// of its URLs no longer passes the URL policy.
+type SkippedLink struct {
+	LinkID int64  `json:"link_id"`
+	Title  string `json:"title"`
+	Field  string `json:"field"`
+	Reason string `json:"reason"`
+}
+
+func skipLink(pageID int64, l *model.Link, err error) SkippedLink {
+	skip := SkippedLink{LinkID: l.ID, Title: l.Title, Reason: "invalid"}
+	var appErr *apperr.Error
+	if errors.As(err, &appErr) {
+		for _, f := range appErr.Fields {
+			skip.Field, skip.Reason = f.Field, f.Code
+		}
+	}
+	log.Printf("[Compiler] page %d: skipped link %d: %v", pageID, l.ID, err)
+	return skip
+}
+
+// PublishResult reports what a publish left for the owner to look at.
+type PublishResult struct {
+	Lint         theme.LintReport `json:"lint"`
+	SkippedLinks []SkippedLink    `json:"skipped_links"`
 }
 
 type CompiledPageInfo struct {
@@ -37,6 +92,11 @@ type CompiledPageInfo struct {
 	Locale   string          `json:"locale"`
 	Mode     string          `json:"mode"`
 	Settings json.RawMessage `json:"settings"`
+	// ThemePresetID and ThemeCustomID name the theme the page was
+	// published with, so later edits to it reach the page; Public drops
+	// them.
+	ThemePresetID int64  `json:"theme_preset_id,omitempty"`
+	ThemeCustomID *int64 `json:"theme_custom_id,omitempty"`
 }
 
 type CompiledUserInfo struct {
@@ -44,6 +104,14 @@ type CompiledUserInfo struct {
 	DisplayName *string `json:"display_name"`
 }
 
+// CompiledSocial is one icon of the social row, in the order the user set.
+type CompiledSocial struct {
+	Platform string `json:"platform"`
+	Name     string `json:"name"`
+	Icon     string `json:"icon"`
+	URL      string `json:"url"`
+}
+
 type CompiledBlock struct {
 	ID        int64           `json:"id"`
 	Type      string          `json:"type"`
@@ -67,77 +135,114 @@ type CompiledLink struct {
 	Title    string  `json:"title"`
 	URL      string  `json:"url"`
 	IsActive bool    `json:"is_active"`
+	// Rules stay in the publish cache for the click redirect; Public
+	// drops them.
+	Rules []targeting.Rule `json:"rules,omitempty"`
+	// Limits are applied when the page is served and on each click.
+	// FallbackURL is set only for links that redirect once exhausted.
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	MaxClicks   *int       `json:"max_clicks,omitempty"`
+	FallbackURL string     `json:"fallback_url,omitempty"`
+}
+
+// Public returns the page as served to visitors, without the links'
+// targeting rules, click caps or fallbacks.
+func (p CompiledPage) Public() *CompiledPage {
+	blocks := make([]CompiledBlock, len(p.Blocks))
+	for i, b := range p.Blocks {
+		if b.Group != nil {
+			group := *b.Group
+			group.Links = make([]CompiledLink, len(b.Group.Links))
+			for j, l := range b.Group.Links {
+				l.Rules, l.MaxClicks, l.FallbackURL = nil, nil, ""
+				group.Links[j] = l
+			}
+			b.Group = &group
+		}
+		blocks[i] = b
+	}
+	p.Blocks = blocks
+	p.Page.ThemePresetID, p.Page.ThemeCustomID = 0, nil
+	return &p
 }
 
 func (s *CompilerService) Compile(ctx context.Context, pageID int64) (*CompiledPage, error) {
-	// Get page
-	page, err := s.pageRepo.GetByID(ctx, pageID)
+	// Get page with blocks, groups and links
+	agg, err := s.aggregateRepo.Load(ctx, pageID)
 	if err != nil {
 		return nil, err
 	}
+	compiled, _, err := s.compile(ctx, agg)
+	return compiled, err
+}
+
+func (s *CompilerService) compile(ctx context.Context, agg *model.PageAggregate) (*CompiledPage, []SkippedLink, error) {
+	page := agg.Page
 
 	// Get user info
 	user, err := s.userRepo.GetByID(ctx, page.UserID)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	
 	// Debug log
-	fmt.Printf("[Compiler] Compiling page %d for user %d\n", pageID, page.UserID)
+	fmt.Printf("[Compiler] Compiling page %d for user %d\n", page.ID, page.UserID)
 	fmt.Printf("[Compiler] User: username=%v, display_name=%v\n", user.Username, user.DisplayName)
 	fmt.Printf("[Compiler] Page settings: %s\n", string(page.Settings))
 
-	// Get theme config
-	var themeConfig json.RawMessage
-	if page.ThemeCustomID != nil {
-		custom, err := s.themeRepo.GetCustomByID(ctx, *page.ThemeCustomID)
-		if err != nil {
-			return nil, err
-		}
-		if custom.CompiledConfig != nil {
-			themeConfig = custom.CompiledConfig
-		} else {
-			// Fallback to preset + patch merge (simplified)
-			preset, err := s.themeRepo.GetPresetByID(ctx, custom.BasedOnPresetID)
-			if err != nil {
-				return nil, err
-			}
-			themeConfig = preset.Config // TODO: merge with patch
-		}
-	} else {
-		preset, err := s.themeRepo.GetPresetByID(ctx, page.ThemePresetID)
-		if err != nil {
-			return nil, err
-		}
-		themeConfig = preset.Config
-	}
-
-	// Get blocks sorted
-	blocks, err := s.blockRepo.GetBlocksByPage(ctx, pageID)
+	themeConfig, err := s.themeConfig(ctx, page.ThemePresetID, page.ThemeCustomID)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	// Get link groups
-	groups, err := s.blockRepo.GetLinkGroupsByPage(ctx, pageID)
+	stylesheet, err := s.saveStylesheet(ctx, themeConfig)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
+
+	// Link groups
+	utm := newUTMTemplates(page, user)
+	var skipped []SkippedLink
 	groupMap := make(map[int64]*CompiledLinkGroup)
-	for _, g := range groups {
-		links, err := s.blockRepo.GetLinksByGroup(ctx, g.ID)
-		if err != nil {
-			return nil, err
-		}
+	for _, g := range agg.Groups {
+		links := agg.Links[g.ID]
 
 		compiledLinks := make([]CompiledLink, 0, len(links))
 		for _, l := range links {
 			if l.IsActive {
+				// Checked again here: the blocklist may have grown since
+				// the link was saved. A link that no longer passes is left
+				// out rather than failing the whole page.
+				url, err := checkLinkURL(s.urlPolicy, fmt.Sprintf("links.%d.url", l.ID), l.URL)
+				if err != nil {
+					skipped = append(skipped, skipLink(page.ID, l, err))
+					continue
+				}
+				rules, err := s.compileRules(l)
+				if err != nil {
+					skipped = append(skipped, skipLink(page.ID, l, err))
+					continue
+				}
+				for i := range rules {
+					rules[i].URL = utm.apply(rules[i].URL, g, l)
+				}
+				fallback, err := s.compileFallback(l)
+				if err != nil {
+					skipped = append(skipped, skipLink(page.ID, l, err))
+					continue
+				}
+				if fallback != "" {
+					fallback = utm.apply(fallback, g, l)
+				}
 				compiledLinks = append(compiledLinks, CompiledLink{
-					ID:       l.ID,
-					Title:    l.Title,
-					URL:      l.URL,
-					IsActive: l.IsActive,
+					ID:          l.ID,
+					Title:       l.Title,
+					URL:         utm.apply(url, g, l),
+					IsActive:    l.IsActive,
+					Rules:       rules,
+					ExpiresAt:   l.ExpiresAt,
+					MaxClicks:   l.MaxClicks,
+					FallbackURL: fallback,
 				})
 			}
 		}
@@ -159,8 +264,8 @@ func (s *CompilerService) Compile(ctx context.Context, pageID int64) (*CompiledP
 	}
 
 	// Build compiled blocks
-	compiledBlocks := make([]CompiledBlock, 0, len(blocks))
-	for _, b := range blocks {
+	compiledBlocks := make([]CompiledBlock, 0, len(agg.Blocks))
+	for _, b := range agg.Blocks {
 		if !b.IsVisible {
 			continue
 		}
@@ -188,42 +293,227 @@ func (s *CompilerService) Compile(ctx context.Context, pageID int64) (*CompiledP
 			Locale:   page.Locale,
 			Mode:     page.ThemeMode,
 			Settings: page.Settings,
+
+			ThemePresetID: page.ThemePresetID,
+			ThemeCustomID: page.ThemeCustomID,
 		},
 		User: &CompiledUserInfo{
 			Username:    user.Username,
 			DisplayName: user.DisplayName,
 		},
-		Theme:  themeConfig,
-		Blocks: compiledBlocks,
+		Theme:      themeConfig,
+		Stylesheet: stylesheet,
+		SEO:        compileSEO(ctx, s.assetRepo, page, user),
+		Social:     compileSocial(s.urlPolicy, page.Settings),
+		Blocks:     compiledBlocks,
 	}
 	
 	fmt.Printf("[Compiler] Compiled user info: username=%v, display_name=%v\n", compiled.User.Username, compiled.User.DisplayName)
 	fmt.Printf("[Compiler] Compiled %d blocks\n", len(compiledBlocks))
 
-	return compiled, nil
+	return compiled, skipped, nil
+}
+
+// themeConfig returns the compiled config of a custom theme, or of the
+// preset when customID is nil.
+func (s *CompilerService) themeConfig(ctx context.Context, presetID int64, customID *int64) (json.RawMessage, error) {
+	if customID != nil {
+		custom, err := s.themeRepo.GetCustomByID(ctx, *customID)
+		if err != nil {
+			return nil, err
+		}
+		return customThemeConfig(ctx, s.themeRepo, custom)
+	}
+	preset, err := s.themeRepo.GetPresetByID(ctx, presetID)
+	if err != nil {
+		return nil, err
+	}
+	return preset.Config, nil
 }
 
-func (s *CompilerService) Publish(ctx context.Context, pageID int64) error {
-	compiled, err := s.Compile(ctx, pageID)
+// compileSocial resolves the stored social row against the registry, so
+// URLs follow the platform's current canonical form. Entries whose platform
+// is gone or that no longer validate, such as a website since blocked, are
+// left out.
+func compileSocial(policy *urlpolicy.Policy, settings json.RawMessage) []CompiledSocial {
+	var raw map[string]json.RawMessage
+	_ = json.Unmarshal(settings, &raw)
+	out := []CompiledSocial{}
+	for _, link := range social.Decode(raw["social"]) {
+		platform, ok := social.Lookup(link.Platform)
+		if !ok {
+			continue
+		}
+		value := link.Handle
+		if value == "" {
+			value = link.URL
+		}
+		normalized, err := normalizeSocial(policy, platform, value)
+		if err != nil {
+			continue
+		}
+		out = append(out, CompiledSocial{
+			Platform: platform.Key,
+			Name:     platform.Name,
+			Icon:     platform.Icon,
+			URL:      normalized.URL,
+		})
+	}
+	return out
+}
+
+// Retheme brings the published copy of a page up to date with the custom
+// theme customID after that theme was edited, upgraded or deleted. Only the
+// theme, stylesheet, mode and generated share image change; draft edits to
+// the page stay unpublished. Pages not published with customID are left
+// alone. A deleted theme is replaced by its preset.
+func (s *CompilerService) Retheme(ctx context.Context, pageID, customID int64) error {
+	published, err := s.pageRepo.GetPublishCache(ctx, pageID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var compiled CompiledPage
+	if err := json.Unmarshal(published.CompiledJSON, &compiled); err != nil {
+		return err
+	}
+	page, err := s.pageRepo.GetByID(ctx, pageID)
+	if err != nil {
+		return err
+	}
+	info := &compiled.Page
+	if info.ThemeCustomID == nil && info.ThemePresetID == 0 {
+		// Published before the theme was recorded; assume it is unchanged.
+		info.ThemePresetID, info.ThemeCustomID = page.ThemePresetID, page.ThemeCustomID
+	}
+	if info.ThemeCustomID == nil || *info.ThemeCustomID != customID {
+		return nil
+	}
+
+	custom, err := s.themeRepo.GetCustomByID(ctx, customID)
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		info.ThemeCustomID = nil
+	case err != nil:
+		return err
+	default:
+		info.ThemePresetID = custom.BasedOnPresetID
+	}
+	themeConfig, err := s.themeConfig(ctx, info.ThemePresetID, info.ThemeCustomID)
+	if err != nil {
+		return err
+	}
+	// The published mode stays unless the theme dropped it.
+	mode, err := selectMode(themeConfig, "", info.Mode)
+	if err != nil {
+		return err
+	}
+	stylesheet, err := s.saveStylesheet(ctx, themeConfig)
 	if err != nil {
 		return err
 	}
+	compiled.Theme, compiled.Stylesheet, info.Mode = themeConfig, stylesheet, mode
+
+	// A generated share image is drawn in the theme's colors.
+	if compiled.SEO.ImageWidth != 0 {
+		user, err := s.userRepo.GetByID(ctx, page.UserID)
+		if err != nil {
+			return err
+		}
+		compiled.SEO.Image, compiled.SEO.ImageWidth, compiled.SEO.ImageHeight = "", 0, 0
+		compiled.SEO.Meta = compiled.SEO.metaTags()
+		shown := *page
+		shown.Settings, shown.ThemeMode = info.Settings, mode
+		s.ogImages.Attach(ctx, &shown, user, &compiled)
+	}
 
 	compiledJSON, err := json.Marshal(compiled)
 	if err != nil {
 		return err
 	}
+	if err := s.pageRepo.SavePublishCache(ctx, pageID, compiledJSON, util.SHA256(string(compiledJSON))); err != nil {
+		return err
+	}
+	s.renderCache.InvalidatePage(pageID)
+	purge(ctx, s.purger, cdn.PageKey(pageID))
+	return nil
+}
 
-	// Update page status
-	page, err := s.pageRepo.GetByID(ctx, pageID)
-	if err != nil {
+// Unpublish takes the page offline until it is published again.
+func (s *CompilerService) Unpublish(ctx context.Context, pageID int64) error {
+	if err := s.pageRepo.Unpublish(ctx, pageID); err != nil {
 		return err
 	}
+	s.renderCache.InvalidatePage(pageID)
+	purge(ctx, s.purger, cdn.PageKey(pageID))
+	return nil
+}
+
+// saveStylesheet renders the theme's CSS, stores it under its content hash
+// and returns its URL.
+func (s *CompilerService) saveStylesheet(ctx context.Context, config json.RawMessage) (string, error) {
+	css, err := theme.CSS(config)
+	if err != nil {
+		return "", err
+	}
+	hash := util.SHA256(string(css))
+	if err := s.themeRepo.SaveStylesheet(ctx, hash, css); err != nil {
+		return "", err
+	}
+	return StylesheetPath(hash), nil
+}
+
+// StylesheetPath is the public URL of a stored stylesheet.
+func StylesheetPath(hash string) string {
+	return "/themes/" + hash + ".css"
+}
+
+// Publish compiles the page into its publish cache and returns the
+// readability problems of its theme and the links left out, neither of
+// which block publishing.
+func (s *CompilerService) Publish(ctx context.Context, pageID int64) (*PublishResult, error) {
+	agg, err := s.aggregateRepo.Load(ctx, pageID)
+	if err != nil {
+		return nil, err
+	}
+
+	compiled, skipped, err := s.compile(ctx, agg)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.userRepo.GetByID(ctx, agg.Page.UserID)
+	if err != nil {
+		return nil, err
+	}
+	s.ogImages.Attach(ctx, agg.Page, user, compiled)
+
+	compiledJSON, err := json.Marshal(compiled)
+	if err != nil {
+		return nil, err
+	}
+
+	// Update page status
+	page := agg.Page
 	page.Status = "published"
 	if err := s.pageRepo.Update(ctx, page); err != nil {
-		return err
+		return nil, err
 	}
 
 	// Save to cache
-	return s.pageRepo.SavePublishCache(ctx, pageID, compiledJSON)
+	if err := s.pageRepo.SavePublishCache(ctx, pageID, compiledJSON, util.SHA256(string(compiledJSON))); err != nil {
+		return nil, err
+	}
+
+	s.renderCache.InvalidatePage(pageID)
+	purge(ctx, s.purger, cdn.PageKey(pageID))
+
+	if skipped == nil {
+		skipped = []SkippedLink{}
+	}
+	return &PublishResult{
+		Lint:         lintTheme(ctx, s.assetRepo, compiled.Theme),
+		SkippedLinks: skipped,
+	}, nil
 }
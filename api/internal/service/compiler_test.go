@@ -0,0 +1,104 @@
+package service_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"linkbio/internal/service"
+	"linkbio/internal/util"
+)
+
+func TestPublish(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t)
+	user := f.user(t, "alice@example.com")
+	page := f.page(t, user)
+
+	group, err := f.blocks.CreateLinkGroup(ctx, page.ID, nil, "list")
+	must(t, err)
+	_, err = f.blocks.CreateBlock(ctx, page.ID, "link_group", "a", &group.ID, nil)
+	must(t, err)
+	hidden, err := f.blocks.CreateBlock(ctx, page.ID, "text", "b", nil, json.RawMessage(`{"text":"hidden"}`))
+	must(t, err)
+	hidden.IsVisible = false
+	must(t, f.blocks.UpdateBlock(ctx, hidden))
+	site, err := f.blocks.CreateLink(ctx, group.ID, "Site", "https://example.com", "a")
+	must(t, err)
+	off, err := f.blocks.CreateLink(ctx, group.ID, "Off", "https://example.org", "b")
+	must(t, err)
+	off.IsActive = false
+	must(t, f.blocks.UpdateLink(ctx, off))
+
+	if _, err := f.compiler.Publish(ctx, page.ID); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+
+	got, err := f.pages.GetByID(ctx, page.ID)
+	must(t, err)
+	if got.Status != "published" {
+		t.Errorf("status = %q, want published", got.Status)
+	}
+
+	cached, err := f.pages.GetPublishCache(ctx, page.ID)
+	must(t, err)
+	if cached.Hash != util.SHA256(string(cached.CompiledJSON)) {
+		t.Errorf("publish cache hash does not match its JSON")
+	}
+	var compiled service.CompiledPage
+	must(t, json.Unmarshal(cached.CompiledJSON, &compiled))
+	if len(compiled.Blocks) != 1 || compiled.Blocks[0].Group == nil {
+		t.Fatalf("blocks = %+v, want only the visible link group", compiled.Blocks)
+	}
+	links := compiled.Blocks[0].Group.Links
+	if len(links) != 1 || links[0].ID != site.ID || links[0].URL != "https://example.com" {
+		t.Errorf("links = %+v, want only the active link", links)
+	}
+	if compiled.Stylesheet == "" {
+		t.Error("stylesheet not set")
+	}
+	if compiled.SEO.Image == "" {
+		t.Error("share image not generated")
+	}
+}
+
+func TestPublishSkipsLinksThePolicyRejects(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t)
+	user := f.user(t, "alice@example.com")
+	page := f.page(t, user)
+
+	group, err := f.blocks.CreateLinkGroup(ctx, page.ID, nil, "list")
+	must(t, err)
+	_, err = f.blocks.CreateBlock(ctx, page.ID, "link_group", "a", &group.ID, nil)
+	must(t, err)
+	site, err := f.blocks.CreateLink(ctx, group.ID, "Site", "https://example.com", "a")
+	must(t, err)
+	// Saved straight to the repo, as if the scheme was allowed back then.
+	script, err := f.blocks.CreateLink(ctx, group.ID, "Script", "javascript:alert(1)", "b")
+	must(t, err)
+
+	result, err := f.compiler.Publish(ctx, page.ID)
+	if err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	want := service.SkippedLink{LinkID: script.ID, Title: "Script", Field: fmt.Sprintf("links.%d.url", script.ID), Reason: "scheme"}
+	if len(result.SkippedLinks) != 1 || result.SkippedLinks[0] != want {
+		t.Errorf("skipped = %+v, want [%+v]", result.SkippedLinks, want)
+	}
+
+	cached, err := f.pages.GetPublishCache(ctx, page.ID)
+	must(t, err)
+	var compiled service.CompiledPage
+	must(t, json.Unmarshal(cached.CompiledJSON, &compiled))
+	links := compiled.Blocks[0].Group.Links
+	if len(links) != 1 || links[0].ID != site.ID {
+		t.Errorf("links = %+v, want only the allowed link", links)
+	}
+
+	// Previews compile the same way.
+	if _, err := f.compiler.Compile(ctx, page.ID); err != nil {
+		t.Errorf("Compile: %v", err)
+	}
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"regexp"
+
+	"linkbio/internal/cache"
+	"linkbio/internal/cdn"
+	"linkbio/internal/model"
+	"linkbio/internal/repo"
+	"linkbio/internal/util"
+)
+
+var routePathRegex = regexp.MustCompile(`^/([a-z0-9_-]+(/[a-z0-9_-]+)*)?$`)
+
+type DomainService struct {
+	domainRepo  repo.DomainStore
+	renderCache *cache.RenderCache
+	purger      cdn.Purger
+}
+
+func NewDomainService(domainRepo repo.DomainStore, renderCache *cache.RenderCache, purger cdn.Purger) *DomainService {
+	return &DomainService{domainRepo: domainRepo, renderCache: renderCache, purger: purger}
+}
+
+// ChangeRoute moves a page to a new path. domainID nil means the system
+// domain. Custom domains serve a single page at the root.
+func (s *DomainService) ChangeRoute(ctx context.Context, userID, pageID int64, domainID *int64, path string) (*model.PageRoute, error) {
+	var domain *model.Domain
+	var err error
+	if domainID == nil {
+		domain, err = s.domainRepo.GetSystemDomain(ctx)
+	} else {
+		domain, err = s.domainRepo.GetByID(ctx, *domainID)
+	}
+	if err != nil {
+		return nil, ErrNotFound
+	}
+
+	path = util.CanonicalPath(path)
+	if !domain.IsSystem {
+		if domain.UserID == nil || *domain.UserID != userID {
+			return nil, ErrForbidden
+		}
+		path = "/"
+	} else if path == "/" || !routePathRegex.MatchString(path) {
+		return nil, ErrInvalidPath
+	}
+
+	if existing, err := s.domainRepo.GetRouteByDomainAndPath(ctx, domain.ID, path); err == nil {
+		if existing.PageID == pageID {
+			return existing, nil
+		}
+		return nil, ErrPathTaken
+	}
+
+	// A concurrent move can take the path between the check and the insert.
+	route, err := s.domainRepo.ReplaceCurrentRoute(ctx, pageID, domain.ID, path)
+	if pgCode(err) == pgUniqueViolation {
+		return nil, ErrPathTaken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.renderCache.InvalidatePage(pageID)
+	purge(ctx, s.purger, cdn.PageKey(pageID))
+	return route, nil
+}
+
+// DisableDomain stops a custom domain from serving pages.
+func (s *DomainService) DisableDomain(ctx context.Context, userID, domainID int64) error {
+	domain, err := s.domainRepo.GetByID(ctx, domainID)
+	if err != nil {
+		return ErrNotFound
+	}
+	if domain.IsSystem || domain.UserID == nil || *domain.UserID != userID {
+		return ErrForbidden
+	}
+
+	if err := s.domainRepo.UpdateStatus(ctx, domainID, "disabled"); err != nil {
+		return err
+	}
+
+	s.renderCache.InvalidateDomain(domainID)
+	purge(ctx, s.purger, cdn.DomainKey(domainID))
+	return nil
+}
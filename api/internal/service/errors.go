@@ -0,0 +1,130 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"linkbio/internal/apperr"
+	"linkbio/internal/urlpolicy"
+)
+
+var (
+	ErrNotFound  = apperr.NotFound("common.not_found", "not found")
+	ErrForbidden = apperr.Forbidden("common.forbidden", "forbidden")
+
+	ErrInvalidCredentials = apperr.Unauthorized("auth.invalid_credentials", "invalid email or password")
+	ErrEmailExists        = apperr.Conflict("auth.email_exists", "email already exists").WithField("email", "taken", "auth.email_exists")
+	ErrUsernameExists     = apperr.Conflict("auth.username_taken", "username already taken").WithField("username", "taken", "auth.username_taken")
+	ErrInvalidUsername    = apperr.Validation("auth.invalid_username", "username must be 3-30 characters, lowercase letters, numbers, underscore only").WithField("username", "format", "auth.invalid_username")
+
+	ErrInvalidPath = apperr.Validation("route.invalid_path", "path may only contain lowercase letters, numbers, '-', '_' and '/'").WithField("path", "format", "route.invalid_path")
+	ErrPathTaken   = apperr.Conflict("route.path_taken", "path already taken").WithField("path", "taken", "route.path_taken")
+
+	ErrInvalidTheme      = apperr.Validation("theme.invalid", "theme does not match the theme schema")
+	ErrPresetPublished   = apperr.Conflict("theme.preset_published", "submitted or published presets cannot be changed")
+	ErrPresetReviewState = apperr.Conflict("theme.invalid_review_state", "preset is not in a state that allows this action")
+	ErrPresetVersion     = apperr.Conflict("theme.invalid_version", "meta.version must be a semver newer than the current version").WithField("config.meta.version", "version", "theme.invalid_version")
+	ErrThemeUpToDate     = apperr.Conflict("theme.up_to_date", "theme already uses the latest preset version")
+
+	ErrProRequired          = apperr.Forbidden("theme.pro_required", "this theme requires a Pro subscription")
+	ErrModeUnsupported      = apperr.Validation("theme.mode_unsupported", "the theme does not support this mode").WithField("mode", "enum", "theme.mode_unsupported")
+	ErrCustomThemeInUse     = apperr.Conflict("theme.custom_in_use", "theme is used by a page; switch those pages first or delete with fallback")
+	ErrCustomPresetMismatch = apperr.Validation("theme.custom_preset_mismatch", "custom theme is based on another preset").WithField("custom_id", "mismatch", "theme.custom_preset_mismatch")
+
+	ErrPreviewInvalid = apperr.NotFound("preview.invalid", "preview link is invalid")
+	ErrPreviewExpired = apperr.NotFound("preview.expired", "preview link has expired")
+	ErrPreviewTTL     = apperr.Validation("preview.invalid_ttl", "ttl must be between 1 minute and 7 days").WithField("ttl_minutes", "range", "preview.invalid_ttl")
+
+	ErrSEOTitle       = apperr.Validation("seo.invalid_title", "title must be at most 70 characters").WithField("title", "length", "seo.invalid_title")
+	ErrSEODescription = apperr.Validation("seo.invalid_description", "description must be at most 200 characters").WithField("description", "length", "seo.invalid_description")
+	ErrSEOImage       = apperr.Validation("seo.invalid_image", "image must be one of your uploaded images").WithField("image_asset_id", "invalid", "seo.invalid_image")
+
+	ErrScheduleAction  = apperr.Validation("schedule.invalid_action", "action must be publish or unpublish").WithField("action", "enum", "schedule.invalid_action")
+	ErrScheduleTime    = apperr.Validation("schedule.invalid_time", "run_at must be in the future and within a year").WithField("run_at", "range", "schedule.invalid_time")
+	ErrScheduleRunning = apperr.Conflict("schedule.running", "schedule is running and can no longer be canceled")
+
+	ErrUTMTemplate = apperr.Validation("utm.invalid_template", "template must be a query string like utm_source=linkbio&utm_campaign={page}; placeholders are {page}, {group}, {link}, {link_id} and {username}")
+
+	ErrLinkURL = apperr.Validation("link.invalid_url", "url must be a valid http, https, mailto, tel or sms link")
+
+	ErrLinkRules = apperr.Validation("link.invalid_rules", "rules must be at most 10 entries, each with a url and at least one country, os, device or language condition")
+
+	ErrLinkLimits = apperr.Validation("link.invalid_limits", "max_clicks must be positive and fallback must be hide, or redirect with a fallback_url")
+	ErrLinkGone   = apperr.New(http.StatusGone, apperr.CodeNotFound, "link.gone", "this link has expired")
+
+	ErrSocialInvalid = apperr.Validation("social.invalid", "social links are invalid")
+	ErrSocialTooMany = apperr.Validation("social.too_many", "at most 20 social links").WithField("links", "length", "social.too_many")
+
+	ErrExperimentInvalid = apperr.Validation("experiment.invalid", "an experiment needs a name and 2 to 4 variants, each with a name, a weight from 1 to 100 and only this page's links and blocks")
+	ErrExperimentRunning = apperr.Conflict("experiment.running", "the page already has a running experiment; end it first")
+	ErrExperimentEnded   = apperr.Conflict("experiment.ended", "experiment has already ended")
+	ErrExperimentWinner  = apperr.Validation("experiment.invalid_winner", "winner must be the key of one of the experiment's variants").WithField("winner", "enum", "experiment.invalid_winner")
+
+	ErrShortLinkAlias   = apperr.Validation("short_link.invalid_alias", "alias must be 3 to 32 lowercase letters, digits or dashes, and not start or end with a dash").WithField("alias", "format", "short_link.invalid_alias")
+	ErrShortLinkTaken   = apperr.Conflict("short_link.taken", "that alias is already taken").WithField("alias", "unique", "short_link.taken")
+	ErrShortLinkTooMany = apperr.Validation("short_link.too_many", "a link can have at most 10 short links")
+
+	ErrAssetTooLarge = apperr.Validation("asset.too_large", "images must be at most 2 MB and 40 megapixels").WithField("file", "size", "asset.too_large")
+	ErrAssetType     = apperr.Validation("asset.invalid_type", "file must be a PNG, JPEG, GIF or WebP image").WithField("file", "type", "asset.invalid_type")
+
+	ErrAnalyticsRange = apperr.Validation("analytics.invalid_range", "days must be between 1 and 365").WithField("days", "range", "analytics.invalid_range")
+
+	ErrQRFormat   = apperr.Validation("qr.invalid_format", "format must be png or svg").WithField("format", "enum", "qr.invalid_format")
+	ErrQRSize     = apperr.Validation("qr.invalid_size", "size must be between 128 and 2048 pixels").WithField("size", "range", "qr.invalid_size")
+	ErrQRMargin   = apperr.Validation("qr.invalid_margin", "margin must be between 0 and 16 modules").WithField("margin", "range", "qr.invalid_margin")
+	ErrQRLevel    = apperr.Validation("qr.invalid_level", "level must be L, M, Q or H").WithField("level", "enum", "qr.invalid_level")
+	ErrQRColor    = apperr.Validation("qr.invalid_color", "colors must be hex, rgb() or rgba() colors")
+	ErrQRContrast = apperr.Validation("qr.low_contrast", "foreground must be darker than the background with a contrast of at least 4:1")
+	ErrQRTooDense = apperr.Validation("qr.too_dense", "size is too small for this URL; use a larger size or smaller margin").WithField("size", "range", "qr.too_dense")
+	ErrQRNoURL    = apperr.Conflict("qr.no_url", "page has no public URL yet")
+)
+
+const (
+	pgForeignKeyViolation = "23503"
+	pgUniqueViolation     = "23505"
+)
+
+// pgCode returns the SQLSTATE of a Postgres error, or "".
+func pgCode(err error) string {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code
+	}
+	return ""
+}
+
+// notFoundOr maps a missing row to ErrNotFound and passes anything else,
+// including nil, through.
+func notFoundOr(err error) error {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// notFound maps a missing row to ErrNotFound and wraps anything else as an
+// internal error.
+func notFound(err error) error {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrNotFound
+	}
+	return apperr.Internal(err)
+}
+
+// checkLinkURL normalizes a link URL, reporting a rejected one under field.
+func checkLinkURL(policy *urlpolicy.Policy, field, raw string) (string, error) {
+	url, err := policy.Normalize(raw)
+	switch {
+	case err == nil:
+		return url, nil
+	case errors.Is(err, urlpolicy.ErrEmpty):
+		return "", ErrLinkURL.WithField(field, "required", "request.required")
+	case errors.Is(err, urlpolicy.ErrScheme):
+		return "", ErrLinkURL.WithField(field, "scheme", "link.unsupported_scheme")
+	case errors.Is(err, urlpolicy.ErrBlocked):
+		return "", ErrLinkURL.WithField(field, "blocked", "link.blocked_host")
+	}
+	return "", ErrLinkURL.WithField(field, "format", "link.invalid_url")
+}
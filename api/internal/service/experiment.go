@@ -0,0 +1,411 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"linkbio/internal/cache"
+	"linkbio/internal/cdn"
+	"linkbio/internal/model"
+	"linkbio/internal/repo"
+)
+
+const (
+	minExperimentVariants = 2
+	maxExperimentVariants = 4
+	maxExperimentName     = 80
+	maxVariantTitle       = 200
+	maxVariantWeight      = 100
+	// minExperimentViews is how many views every compared variant needs
+	// before a difference is called significant.
+	minExperimentViews = 100
+	// experimentAlpha is the p-value below which a difference is
+	// significant.
+	experimentAlpha = 0.05
+)
+
+// ExperimentService runs A/B experiments on a page's published link titles
+// and block order. Visitors are assigned a variant when the page is served;
+// views and clicks are tagged with it.
+type ExperimentService struct {
+	experimentRepo repo.ExperimentStore
+	pageRepo       repo.PageStore
+	aggregateRepo  repo.PageAggregateStore
+	renderCache    *cache.RenderCache
+	purger         cdn.Purger
+}
+
+func NewExperimentService(experimentRepo repo.ExperimentStore, pageRepo repo.PageStore, aggregateRepo repo.PageAggregateStore, renderCache *cache.RenderCache, purger cdn.Purger) *ExperimentService {
+	return &ExperimentService{
+		experimentRepo: experimentRepo,
+		pageRepo:       pageRepo,
+		aggregateRepo:  aggregateRepo,
+		renderCache:    renderCache,
+		purger:         purger,
+	}
+}
+
+type CreateExperimentReq struct {
+	Name     string         `json:"name"`
+	Variants []VariantInput `json:"variants"` // the first is the baseline
+}
+
+type VariantInput struct {
+	Name       string            `json:"name"`
+	Weight     int               `json:"weight"`
+	LinkTitles []model.LinkTitle `json:"link_titles"`
+	BlockOrder []int64           `json:"block_order"`
+}
+
+// ExperimentResults compares each variant with the baseline.
+type ExperimentResults struct {
+	Experiment *model.Experiment `json:"experiment"`
+	Variants   []VariantResult   `json:"variants"`
+}
+
+type VariantResult struct {
+	Key    string  `json:"key"`
+	Name   string  `json:"name"`
+	Weight int     `json:"weight"`
+	Views  int64   `json:"views"`
+	Clicks int64   `json:"clicks"`
+	CTR    float64 `json:"ctr"` // clicks per view
+	// Lift is the CTR change relative to the baseline; nil for the
+	// baseline itself and while the baseline has no clicks.
+	Lift *float64 `json:"lift"`
+	// PValue is the two-sided p-value of the difference from the
+	// baseline; nil while either has no views.
+	PValue      *float64 `json:"p_value"`
+	Significant bool     `json:"significant"`
+}
+
+type EndExperimentReq struct {
+	Winner  string `json:"winner"`  // variant key; empty ends without one
+	Promote bool   `json:"promote"` // copy the winner's titles and order into the draft
+}
+
+// Create starts an experiment on the page. It shows on the published page
+// right away; variants name draft links and blocks by ID, and ones that are
+// not published are skipped.
+func (s *ExperimentService) Create(ctx context.Context, userID, pageID int64, req CreateExperimentReq) (*model.Experiment, error) {
+	if _, err := s.ownPage(ctx, userID, pageID); err != nil {
+		return nil, err
+	}
+	agg, err := s.aggregateRepo.Load(ctx, pageID)
+	if err != nil {
+		return nil, err
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" || len(name) > maxExperimentName {
+		return nil, ErrExperimentInvalid.WithField("name", "length", "experiment.invalid")
+	}
+	if len(req.Variants) < minExperimentVariants || len(req.Variants) > maxExperimentVariants {
+		return nil, ErrExperimentInvalid.WithField("variants", "length", "experiment.invalid")
+	}
+	variants := make([]model.ExperimentVariant, len(req.Variants))
+	for i, v := range req.Variants {
+		variant, err := checkVariant(agg, fmt.Sprintf("variants[%d]", i), v)
+		if err != nil {
+			return nil, err
+		}
+		variant.Key = string(rune('a' + i))
+		variants[i] = *variant
+	}
+
+	experiment, err := s.experimentRepo.Create(ctx, pageID, name, variants)
+	if pgCode(err) == pgUniqueViolation {
+		return nil, ErrExperimentRunning
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.invalidate(ctx, pageID)
+	return experiment, nil
+}
+
+func checkVariant(agg *model.PageAggregate, field string, v VariantInput) (*model.ExperimentVariant, error) {
+	name := strings.TrimSpace(v.Name)
+	if name == "" || len(name) > maxExperimentName {
+		return nil, ErrExperimentInvalid.WithField(field+".name", "length", "experiment.invalid")
+	}
+	if v.Weight < 1 || v.Weight > maxVariantWeight {
+		return nil, ErrExperimentInvalid.WithField(field+".weight", "range", "experiment.invalid")
+	}
+
+	links := map[int64]bool{}
+	for _, groupLinks := range agg.Links {
+		for _, l := range groupLinks {
+			links[l.ID] = true
+		}
+	}
+	titles := make([]model.LinkTitle, 0, len(v.LinkTitles))
+	for j, t := range v.LinkTitles {
+		at := fmt.Sprintf("%s.link_titles[%d]", field, j)
+		if !links[t.LinkID] {
+			return nil, ErrExperimentInvalid.WithField(at+".link_id", "invalid", "experiment.invalid")
+		}
+		links[t.LinkID] = false // each link once
+		t.Title = strings.TrimSpace(t.Title)
+		if t.Title == "" || len(t.Title) > maxVariantTitle {
+			return nil, ErrExperimentInvalid.WithField(at+".title", "length", "experiment.invalid")
+		}
+		titles = append(titles, t)
+	}
+
+	blocks := map[int64]bool{}
+	for _, b := range agg.Blocks {
+		blocks[b.ID] = true
+	}
+	for j, id := range v.BlockOrder {
+		if !blocks[id] {
+			return nil, ErrExperimentInvalid.WithField(fmt.Sprintf("%s.block_order[%d]", field, j), "invalid", "experiment.invalid")
+		}
+		blocks[id] = false
+	}
+
+	return &model.ExperimentVariant{
+		Name:       name,
+		Weight:     v.Weight,
+		LinkTitles: titles,
+		BlockOrder: append([]int64(nil), v.BlockOrder...),
+	}, nil
+}
+
+// List returns the page's experiments, newest first.
+func (s *ExperimentService) List(ctx context.Context, userID, pageID int64) ([]*model.Experiment, error) {
+	if _, err := s.ownPage(ctx, userID, pageID); err != nil {
+		return nil, err
+	}
+	experiments, err := s.experimentRepo.ListByPage(ctx, pageID)
+	if err != nil {
+		return nil, err
+	}
+	if experiments == nil {
+		experiments = []*model.Experiment{}
+	}
+	return experiments, nil
+}
+
+// Results counts each variant's views and clicks and tests its CTR against
+// the baseline's.
+func (s *ExperimentService) Results(ctx context.Context, userID, pageID, id int64) (*ExperimentResults, error) {
+	experiment, err := s.get(ctx, userID, pageID, id)
+	if err != nil {
+		return nil, err
+	}
+	counts, err := s.experimentRepo.Results(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	byKey := map[string]model.VariantCount{}
+	for _, c := range counts {
+		byKey[c.Variant] = c
+	}
+
+	results := &ExperimentResults{Experiment: experiment, Variants: make([]VariantResult, len(experiment.Variants))}
+	for i, v := range experiment.Variants {
+		c := byKey[v.Key]
+		r := VariantResult{Key: v.Key, Name: v.Name, Weight: v.Weight, Views: c.Views, Clicks: c.Clicks}
+		if c.Views > 0 {
+			r.CTR = float64(c.Clicks) / float64(c.Views)
+		}
+		results.Variants[i] = r
+	}
+	base := results.Variants[0]
+	for i := 1; i < len(results.Variants); i++ {
+		r := &results.Variants[i]
+		if base.CTR > 0 {
+			lift := r.CTR/base.CTR - 1
+			r.Lift = &lift
+		}
+		if p, ok := rateTest(base.Clicks, base.Views, r.Clicks, r.Views); ok {
+			r.PValue = &p
+			r.Significant = p < experimentAlpha && base.Views >= minExperimentViews && r.Views >= minExperimentViews
+		}
+	}
+	return results, nil
+}
+
+// rateTest is a two-sided z-test on the clicks-per-view rates of two
+// variants. A view can lead to several clicks, so clicks are treated as
+// Poisson counts over views rather than as successes of each view.
+func rateTest(clicksA, viewsA, clicksB, viewsB int64) (float64, bool) {
+	if viewsA == 0 || viewsB == 0 {
+		return 0, false
+	}
+	rateA := float64(clicksA) / float64(viewsA)
+	rateB := float64(clicksB) / float64(viewsB)
+	se := math.Sqrt(rateA/float64(viewsA) + rateB/float64(viewsB))
+	if se == 0 {
+		return 1, true
+	}
+	z := (rateB - rateA) / se
+	return math.Erfc(math.Abs(z) / math.Sqrt2), true
+}
+
+// End stops a running experiment. With a winner and Promote, the winner's
+// link titles and block order are written into the draft, to go live on
+// the next publish.
+func (s *ExperimentService) End(ctx context.Context, userID, pageID, id int64, req EndExperimentReq) (*model.Experiment, error) {
+	experiment, err := s.get(ctx, userID, pageID, id)
+	if err != nil {
+		return nil, err
+	}
+	if experiment.Status != model.ExperimentRunning {
+		return nil, ErrExperimentEnded
+	}
+
+	var winner *model.ExperimentVariant
+	if req.Winner != "" {
+		for i := range experiment.Variants {
+			if experiment.Variants[i].Key == req.Winner {
+				winner = &experiment.Variants[i]
+			}
+		}
+		if winner == nil {
+			return nil, ErrExperimentWinner
+		}
+	} else if req.Promote {
+		return nil, ErrExperimentWinner
+	}
+
+	var promote *model.Promotion
+	if req.Promote {
+		if promote, err = s.promotion(ctx, pageID, winner); err != nil {
+			return nil, err
+		}
+	}
+	var key *string
+	if winner != nil {
+		key = &winner.Key
+	}
+	if err := s.experimentRepo.End(ctx, id, key, promote); errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrExperimentEnded
+	} else if err != nil {
+		return nil, err
+	}
+	s.invalidate(ctx, pageID)
+	return s.experimentRepo.GetByID(ctx, id)
+}
+
+// promotion works out the draft changes that promote a variant: its link
+// titles and block order. Links and blocks deleted since the experiment
+// started are skipped.
+func (s *ExperimentService) promotion(ctx context.Context, pageID int64, v *model.ExperimentVariant) (*model.Promotion, error) {
+	agg, err := s.aggregateRepo.Load(ctx, pageID)
+	if err != nil {
+		return nil, err
+	}
+
+	links := map[int64]bool{}
+	for _, groupLinks := range agg.Links {
+		for _, l := range groupLinks {
+			links[l.ID] = true
+		}
+	}
+	p := &model.Promotion{SortKeys: map[int64]string{}}
+	for _, t := range v.LinkTitles {
+		if links[t.LinkID] {
+			p.LinkTitles = append(p.LinkTitles, t)
+		}
+	}
+	if len(v.BlockOrder) > 0 {
+		for i, b := range orderBlocks(agg.Blocks, v.BlockOrder, func(b *model.Block) int64 { return b.ID }) {
+			p.SortKeys[b.ID] = positionSortKey(i)
+		}
+	}
+	return p, nil
+}
+
+func (s *ExperimentService) get(ctx context.Context, userID, pageID, id int64) (*model.Experiment, error) {
+	if _, err := s.ownPage(ctx, userID, pageID); err != nil {
+		return nil, err
+	}
+	experiment, err := s.experimentRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	if experiment.PageID != pageID {
+		return nil, ErrNotFound
+	}
+	return experiment, nil
+}
+
+func (s *ExperimentService) ownPage(ctx context.Context, userID, pageID int64) (*model.BioPage, error) {
+	page, err := s.pageRepo.GetByID(ctx, pageID)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	if page.UserID != userID {
+		return nil, ErrForbidden
+	}
+	return page, nil
+}
+
+// invalidate drops the page's rendered responses, which carry the running
+// experiment's variants.
+func (s *ExperimentService) invalidate(ctx context.Context, pageID int64) {
+	s.renderCache.InvalidatePage(pageID)
+	purge(ctx, s.purger, cdn.PageKey(pageID))
+}
+
+// CompiledExperiment tells the public page which variant it shows, so its
+// view beacon and clicks can be tagged with it.
+type CompiledExperiment struct {
+	ID      int64  `json:"id"`
+	Variant string `json:"variant"`
+}
+
+// WithVariant returns the page as the variant shows it.
+func (p CompiledPage) WithVariant(experimentID int64, v model.ExperimentVariant) *CompiledPage {
+	titles := map[int64]string{}
+	for _, t := range v.LinkTitles {
+		titles[t.LinkID] = t.Title
+	}
+
+	blocks := make([]CompiledBlock, len(p.Blocks))
+	for i, b := range p.Blocks {
+		if b.Group != nil {
+			group := *b.Group
+			group.Links = make([]CompiledLink, len(b.Group.Links))
+			for j, l := range b.Group.Links {
+				if title, ok := titles[l.ID]; ok {
+					l.Title = title
+				}
+				group.Links[j] = l
+			}
+			b.Group = &group
+		}
+		blocks[i] = b
+	}
+	p.Blocks = orderBlocks(blocks, v.BlockOrder, func(b CompiledBlock) int64 { return b.ID })
+	p.Experiment = &CompiledExperiment{ID: experimentID, Variant: v.Key}
+	return &p
+}
+
+// orderBlocks puts the blocks listed in order first, in that order, and
+// the rest after them in the order they had.
+func orderBlocks[B any](blocks []B, order []int64, id func(B) int64) []B {
+	rank := make(map[int64]int, len(order))
+	for i, blockID := range order {
+		rank[blockID] = i
+	}
+	out := append([]B(nil), blocks...)
+	sort.SliceStable(out, func(i, j int) bool {
+		ri, iListed := rank[id(out[i])]
+		rj, jListed := rank[id(out[j])]
+		switch {
+		case iListed && jListed:
+			return ri < rj
+		default:
+			return iListed && !jListed
+		}
+	})
+	return out
+}
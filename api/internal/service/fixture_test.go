@@ -0,0 +1,93 @@
+package service_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"linkbio/internal/cache"
+	"linkbio/internal/cdn"
+	"linkbio/internal/model"
+	"linkbio/internal/ogimage"
+	"linkbio/internal/repo/memory"
+	"linkbio/internal/service"
+	"linkbio/internal/urlpolicy"
+)
+
+// fixture wires the services under test to one set of in-memory repos.
+type fixture struct {
+	db         *memory.DB
+	users      *memory.UserRepo
+	pages      *memory.PageRepo
+	blocks     *memory.BlockRepo
+	bio        *memory.BioRepo
+	themes     *memory.ThemeRepo
+	assets     *memory.AssetRepo
+	aggregates *memory.PageAggregateRepo
+
+	renderCache *cache.RenderCache
+	policy      *urlpolicy.Policy
+
+	compiler *service.CompilerService
+	pageSvc  *service.PageService
+	bioSvc   *service.BioService
+	themeSvc *service.ThemeService
+	assetSvc *service.AssetService
+}
+
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+	db := memory.NewDB()
+	f := &fixture{
+		db:          db,
+		users:       memory.NewUserRepo(db),
+		pages:       memory.NewPageRepo(db),
+		blocks:      memory.NewBlockRepo(db),
+		bio:         memory.NewBioRepo(db),
+		themes:      memory.NewThemeRepo(db),
+		assets:      memory.NewAssetRepo(db),
+		aggregates:  memory.NewPageAggregateRepo(db),
+		renderCache: cache.NewRenderCache(16, time.Minute, time.Minute),
+	}
+	blocklist, err := urlpolicy.LoadBlocklist("")
+	must(t, err)
+	f.policy = urlpolicy.New(blocklist)
+
+	renderer, err := ogimage.New(nil, nil)
+	must(t, err)
+	ogImages := service.NewOGImageService(renderer, f.assets)
+	f.compiler = service.NewCompilerService(f.pages, f.aggregates, f.themes, f.users, f.assets, ogImages, f.policy, f.renderCache, cdn.NoopPurger{})
+	f.pageSvc = service.NewPageService(f.pages, f.blocks, f.aggregates, f.assets, f.policy)
+	f.bioSvc = service.NewBioService(f.bio, f.pages, f.blocks, f.users, f.aggregates, f.policy)
+	f.themeSvc = service.NewThemeService(f.themes, f.pages, f.users, f.assets, f.compiler)
+	f.assetSvc = service.NewAssetService(f.assets)
+	return f
+}
+
+func (f *fixture) user(t *testing.T, email string) *model.User {
+	t.Helper()
+	u, err := f.users.Create(context.Background(), email, "hash")
+	must(t, err)
+	return u
+}
+
+// page creates a page on a fresh free preset for the user.
+func (f *fixture) page(t *testing.T, user *model.User) *model.BioPage {
+	t.Helper()
+	preset, err := f.db.SeedPreset(model.ThemePreset{
+		Key: fmt.Sprintf("preset_%d", user.ID), Name: "Preset", Tier: "free", Config: json.RawMessage(`{}`),
+	})
+	must(t, err)
+	page, err := f.pages.Create(context.Background(), user.ID, preset.ID, "Page")
+	must(t, err)
+	return page
+}
+
+func must(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	_ "golang.org/x/image/webp"
+	"linkbio/internal/model"
+	"linkbio/internal/repo"
+)
+
+// maxSourceImage caps the avatar and wallpaper downloads drawn into
+// generated images.
+const maxSourceImage = 10 << 20
+
+// imageLoader decodes image assets to draw them into share images and QR
+// codes.
+type imageLoader struct {
+	assetRepo repo.AssetStore
+	client    *http.Client
+}
+
+func newImageLoader(assetRepo repo.AssetStore) *imageLoader {
+	return &imageLoader{assetRepo: assetRepo, client: &http.Client{Timeout: 5 * time.Second}}
+}
+
+// source returns the image asset with the given id, or nil.
+func (l *imageLoader) source(ctx context.Context, id *int64) *model.Asset {
+	if id == nil {
+		return nil
+	}
+	asset, err := l.assetRepo.GetByID(ctx, *id)
+	if err != nil || asset.Type != "image" {
+		return nil
+	}
+	return asset
+}
+
+// load decodes an asset's image from the database or its URL.
+func (l *imageLoader) load(ctx context.Context, asset *model.Asset) image.Image {
+	data, err := l.read(ctx, asset)
+	if err == nil {
+		var img image.Image
+		if img, _, err = image.Decode(bytes.NewReader(data)); err == nil {
+			return img
+		}
+	}
+	log.Printf("[Images] asset %d: %v", asset.ID, err)
+	return nil
+}
+
+func (l *imageLoader) read(ctx context.Context, asset *model.Asset) ([]byte, error) {
+	if asset.Provider == "db" {
+		return l.assetRepo.GetBlob(ctx, asset.StorageKey)
+	}
+	if asset.URL == nil || !(strings.HasPrefix(*asset.URL, "https://") || strings.HasPrefix(*asset.URL, "http://")) {
+		return nil, fmt.Errorf("no URL to fetch")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, *asset.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch %s: %s", *asset.URL, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSourceImage+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxSourceImage {
+		return nil, fmt.Errorf("fetch %s: larger than %d bytes", *asset.URL, maxSourceImage)
+	}
+	return data, nil
+}
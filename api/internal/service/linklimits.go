@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"linkbio/internal/model"
+	"linkbio/internal/repo"
+	"linkbio/internal/urlpolicy"
+)
+
+const maxLinkClicks = 100_000_000
+
+// checkLinkLimits validates a link's expiry, click cap and fallback,
+// reporting problems under field. An empty fallback means hide; hidden
+// links keep no fallback URL.
+func checkLinkLimits(policy *urlpolicy.Policy, field string, l *SaveLinkReq) error {
+	if l.MaxClicks != nil && (*l.MaxClicks < 1 || *l.MaxClicks > maxLinkClicks) {
+		return ErrLinkLimits.WithField(field+".max_clicks", "range", "link.invalid_limits")
+	}
+	switch l.Fallback {
+	case "", model.LinkFallbackHide:
+		l.Fallback = model.LinkFallbackHide
+		l.FallbackURL = nil
+	case model.LinkFallbackRedirect:
+		if l.FallbackURL == nil {
+			return ErrLinkLimits.WithField(field+".fallback_url", "required", "request.required")
+		}
+		url, err := checkLinkURL(policy, field+".fallback_url", *l.FallbackURL)
+		if err != nil {
+			return err
+		}
+		l.FallbackURL = &url
+	default:
+		return ErrLinkLimits.WithField(field+".fallback", "enum", "link.invalid_limits")
+	}
+	return nil
+}
+
+// compileFallback checks a redirecting link's fallback URL against the
+// policy again. Hidden links have none.
+func (s *CompilerService) compileFallback(l *model.Link) (string, error) {
+	if l.Fallback != model.LinkFallbackRedirect || l.FallbackURL == nil {
+		return "", nil
+	}
+	return checkLinkURL(s.urlPolicy, fmt.Sprintf("links.%d.fallback_url", l.ID), *l.FallbackURL)
+}
+
+func (l *CompiledLink) limited() bool {
+	return l.ExpiresAt != nil || l.MaxClicks != nil
+}
+
+func (l *CompiledLink) expired(now time.Time) bool {
+	return l.ExpiresAt != nil && !now.Before(*l.ExpiresAt)
+}
+
+// ApplyLinkLimits returns the page as it stands at now: exhausted links are
+// dropped, or point at their fallback when they redirect. It also returns
+// when the next remaining link expires, zero if none does.
+func ApplyLinkLimits(ctx context.Context, links repo.BioStore, p *CompiledPage, now time.Time) (*CompiledPage, time.Time, error) {
+	var capped []int64
+	for _, b := range p.Blocks {
+		if b.Group == nil {
+			continue
+		}
+		for _, l := range b.Group.Links {
+			if l.MaxClicks != nil {
+				capped = append(capped, l.ID)
+			}
+		}
+	}
+	var clicks map[int64]int
+	if len(capped) > 0 {
+		var err error
+		if clicks, err = links.GetLinkClickCounts(ctx, capped); err != nil {
+			return nil, time.Time{}, err
+		}
+	}
+
+	var next time.Time
+	out := *p
+	out.Blocks = make([]CompiledBlock, len(p.Blocks))
+	for i, b := range p.Blocks {
+		if b.Group != nil && b.Group.Links != nil {
+			group := *b.Group
+			group.Links = make([]CompiledLink, 0, len(b.Group.Links))
+			for _, l := range b.Group.Links {
+				exhausted := l.expired(now) || (l.MaxClicks != nil && clicks[l.ID] >= *l.MaxClicks)
+				switch {
+				case exhausted && l.FallbackURL == "":
+					continue
+				case exhausted:
+					l.URL, l.Rules = l.FallbackURL, nil
+					l.ExpiresAt, l.MaxClicks = nil, nil
+				case l.ExpiresAt != nil && (next.IsZero() || l.ExpiresAt.Before(next)):
+					next = *l.ExpiresAt
+				}
+				group.Links = append(group.Links, l)
+			}
+			b.Group = &group
+		}
+		out.Blocks[i] = b
+	}
+	return &out, next, nil
+}
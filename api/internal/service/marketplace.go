@@ -0,0 +1,338 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jackc/pgx/v5"
+	"linkbio/internal/apperr"
+	"linkbio/internal/model"
+	"linkbio/internal/repo"
+	"linkbio/internal/theme"
+	"linkbio/internal/util"
+)
+
+const (
+	defaultPresetPageSize = 20
+	maxPresetPageSize     = 50
+	maxPresetNameLength   = 60
+)
+
+// MarketplaceService lets users publish their own theme presets. A preset
+// is written as a draft, submitted for review and, once approved, listed
+// in the marketplace and never changed again.
+type MarketplaceService struct {
+	themeRepo repo.ThemeStore
+	userRepo  repo.UserStore
+}
+
+func NewMarketplaceService(themeRepo repo.ThemeStore, userRepo repo.UserStore) *MarketplaceService {
+	return &MarketplaceService{themeRepo: themeRepo, userRepo: userRepo}
+}
+
+// PresetInput is what an author controls on a preset.
+type PresetInput struct {
+	Name       string
+	Tier       string
+	Visibility string
+	Config     json.RawMessage
+}
+
+// PresetPage is one page of marketplace results.
+type PresetPage struct {
+	Items []*model.ThemePreset `json:"items"`
+	Total int                  `json:"total"`
+	Page  int                  `json:"page"`
+	Limit int                  `json:"limit"`
+}
+
+// Browse searches published public presets. page is 1-based.
+func (s *MarketplaceService) Browse(ctx context.Context, search, tier, sort string, page, limit int) (*PresetPage, error) {
+	if tier != "" && tier != "free" && tier != "pro" {
+		return nil, apperr.Validation("theme.invalid_tier", "tier must be free or pro").WithField("tier", "enum", "theme.invalid_tier")
+	}
+	switch sort {
+	case "":
+		sort = repo.PresetSortPopular
+	case repo.PresetSortPopular, repo.PresetSortNewest, repo.PresetSortName:
+	default:
+		return nil, apperr.Validation("theme.invalid_sort", "sort must be popular, newest or name").WithField("sort", "enum", "theme.invalid_sort")
+	}
+	if limit <= 0 {
+		limit = defaultPresetPageSize
+	}
+	if limit > maxPresetPageSize {
+		limit = maxPresetPageSize
+	}
+	if page < 1 {
+		page = 1
+	}
+
+	items, total, err := s.themeRepo.SearchPresets(ctx, repo.PresetQuery{
+		Search: strings.TrimSpace(search),
+		Tier:   tier,
+		Sort:   sort,
+		Limit:  limit,
+		Offset: (page - 1) * limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if items == nil {
+		items = []*model.ThemePreset{}
+	}
+	return &PresetPage{Items: items, Total: total, Page: page, Limit: limit}, nil
+}
+
+// Get returns a preset the user may see: published public or unlisted
+// presets, their own presets, or anything for moderators.
+func (s *MarketplaceService) Get(ctx context.Context, userID, id int64) (*model.ThemePreset, error) {
+	preset, err := s.themeRepo.GetPresetByID(ctx, id)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	if presetVisibleTo(preset, userID) {
+		return preset, nil
+	}
+	if admin, err := s.userRepo.IsAdmin(ctx, userID); err == nil && admin {
+		return preset, nil
+	}
+	return nil, ErrNotFound
+}
+
+func (s *MarketplaceService) ListMine(ctx context.Context, userID int64) ([]*model.ThemePreset, error) {
+	return s.themeRepo.ListPresetsByAuthor(ctx, userID)
+}
+
+func (s *MarketplaceService) CreateDraft(ctx context.Context, userID int64, in PresetInput) (*model.ThemePreset, error) {
+	if err := validatePresetInput(&in); err != nil {
+		return nil, err
+	}
+
+	suffix, err := util.RandomHex(6)
+	if err != nil {
+		return nil, err
+	}
+	key := fmt.Sprintf("user_%d_%s", userID, suffix)
+	return s.themeRepo.CreatePreset(ctx, userID, key, in.Name, in.Tier, in.Visibility, in.Config)
+}
+
+// UpdateDraft edits a draft or rejected preset. Submitted and published
+// presets are locked.
+func (s *MarketplaceService) UpdateDraft(ctx context.Context, userID, id int64, in PresetInput) (*model.ThemePreset, error) {
+	if err := validatePresetInput(&in); err != nil {
+		return nil, err
+	}
+
+	err := s.themeRepo.UpdatePresetDraft(ctx, id, userID, in.Name, in.Tier, in.Visibility, in.Config)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, s.lockedOrMissing(ctx, userID, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.themeRepo.GetPresetByID(ctx, id)
+}
+
+// Submit sends a draft or rejected preset to review.
+func (s *MarketplaceService) Submit(ctx context.Context, userID, id int64) (*model.ThemePreset, error) {
+	preset, err := s.themeRepo.GetPresetByID(ctx, id)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	if !isAuthor(preset, userID) {
+		return nil, ErrForbidden
+	}
+	// Re-validate: the schema may have tightened since the draft was saved.
+	if fields := theme.Validate(preset.Config); len(fields) > 0 {
+		return nil, invalidTheme(fields)
+	}
+
+	return s.transition(ctx, id, []string{model.PresetDraft, model.PresetRejected}, model.PresetSubmitted, nil, nil)
+}
+
+// ReviewQueue lists presets waiting for a moderator.
+func (s *MarketplaceService) ReviewQueue(ctx context.Context, adminID int64) ([]*model.ThemePreset, error) {
+	if err := s.requireAdmin(ctx, adminID); err != nil {
+		return nil, err
+	}
+	return s.themeRepo.ListPresetsByStatus(ctx, model.PresetSubmitted)
+}
+
+// Approve publishes a submitted preset. From here on it is immutable.
+func (s *MarketplaceService) Approve(ctx context.Context, adminID, id int64, note string) (*model.ThemePreset, error) {
+	if err := s.requireAdmin(ctx, adminID); err != nil {
+		return nil, err
+	}
+	return s.transition(ctx, id, []string{model.PresetSubmitted}, model.PresetApproved, &adminID, optionalNote(note))
+}
+
+// Reject sends a submitted preset back to its author with a note.
+func (s *MarketplaceService) Reject(ctx context.Context, adminID, id int64, note string) (*model.ThemePreset, error) {
+	if err := s.requireAdmin(ctx, adminID); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(note) == "" {
+		return nil, apperr.Validation("request.required", "required fields missing").WithField("note", "required", "request.required")
+	}
+	return s.transition(ctx, id, []string{model.PresetSubmitted}, model.PresetRejected, &adminID, optionalNote(note))
+}
+
+// ListVersions lists the published versions of a preset the user may see,
+// newest first.
+func (s *MarketplaceService) ListVersions(ctx context.Context, userID, id int64) ([]*model.ThemePresetVersion, error) {
+	if _, err := s.Get(ctx, userID, id); err != nil {
+		return nil, err
+	}
+	versions, err := s.themeRepo.ListPresetVersions(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if versions == nil {
+		versions = []*model.ThemePresetVersion{}
+	}
+	return versions, nil
+}
+
+// PublishVersion releases a new version of a published preset. Only
+// moderators can do this, since the new config skips the review queue.
+// config.meta.version must be newer than the current version; custom
+// themes stay on their version until their owners upgrade.
+func (s *MarketplaceService) PublishVersion(ctx context.Context, adminID, id int64, config json.RawMessage) (*model.ThemePresetVersion, error) {
+	if err := s.requireAdmin(ctx, adminID); err != nil {
+		return nil, err
+	}
+	preset, err := s.themeRepo.GetPresetByID(ctx, id)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	if preset.Status != model.PresetApproved {
+		return nil, ErrPresetReviewState
+	}
+	if fields := theme.Validate(config); len(fields) > 0 {
+		return nil, invalidTheme(fields)
+	}
+
+	meta, err := theme.ReadMeta(config)
+	if err != nil {
+		return nil, apperr.Internal(err)
+	}
+	next, err := theme.ParseVersion(meta.Version)
+	if err != nil {
+		return nil, ErrPresetVersion
+	}
+	if preset.Version != nil {
+		if current, err := theme.ParseVersion(*preset.Version); err == nil && next.Compare(current) <= 0 {
+			return nil, ErrPresetVersion
+		}
+	}
+
+	version, err := s.themeRepo.PublishPresetVersion(ctx, id, meta.Version, meta.SchemaVersion, config)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrPresetReviewState
+	}
+	return version, err
+}
+
+func (s *MarketplaceService) transition(ctx context.Context, id int64, from []string, to string, reviewerID *int64, note *string) (*model.ThemePreset, error) {
+	err := s.themeRepo.TransitionPreset(ctx, id, from, to, reviewerID, note)
+	if errors.Is(err, pgx.ErrNoRows) {
+		if _, err := s.themeRepo.GetPresetByID(ctx, id); err != nil {
+			return nil, notFound(err)
+		}
+		return nil, ErrPresetReviewState
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.themeRepo.GetPresetByID(ctx, id)
+}
+
+// lockedOrMissing explains why an edit matched no row.
+func (s *MarketplaceService) lockedOrMissing(ctx context.Context, userID, id int64) error {
+	preset, err := s.themeRepo.GetPresetByID(ctx, id)
+	if err != nil {
+		return notFound(err)
+	}
+	if !isAuthor(preset, userID) {
+		return ErrForbidden
+	}
+	return ErrPresetPublished
+}
+
+func (s *MarketplaceService) requireAdmin(ctx context.Context, userID int64) error {
+	admin, err := s.userRepo.IsAdmin(ctx, userID)
+	if err != nil {
+		return notFound(err)
+	}
+	if !admin {
+		return ErrForbidden
+	}
+	return nil
+}
+
+func validatePresetInput(in *PresetInput) error {
+	in.Name = strings.TrimSpace(in.Name)
+	if in.Tier == "" {
+		in.Tier = "free"
+	}
+	if in.Visibility == "" {
+		in.Visibility = "public"
+	}
+
+	e := apperr.Validation("theme.invalid_preset", "invalid preset")
+	if in.Name == "" {
+		e = e.WithField("name", "required", "request.required")
+	} else if utf8.RuneCountInString(in.Name) > maxPresetNameLength {
+		e = e.WithField("name", "too_long", "theme.name_too_long")
+	}
+	if in.Tier != "free" && in.Tier != "pro" {
+		e = e.WithField("tier", "enum", "theme.invalid_tier")
+	}
+	if in.Visibility != "public" && in.Visibility != "unlisted" && in.Visibility != "private" {
+		e = e.WithField("visibility", "enum", "theme.invalid_visibility")
+	}
+	if len(e.Fields) > 0 {
+		return e
+	}
+
+	if fields := theme.Validate(in.Config); len(fields) > 0 {
+		return invalidTheme(fields)
+	}
+	return nil
+}
+
+// invalidTheme reports schema errors under the "config." prefix.
+func invalidTheme(fields []apperr.FieldError) error {
+	e := ErrInvalidTheme
+	for _, f := range fields {
+		field := "config"
+		if f.Field != "config" {
+			field += "." + f.Field
+		}
+		e = e.WithField(field, f.Code, f.MessageKey)
+	}
+	return e
+}
+
+// presetVisibleTo reports whether userID may see and use the preset:
+// published presets that are not private, and the user's own.
+func presetVisibleTo(p *model.ThemePreset, userID int64) bool {
+	return (p.Status == model.PresetApproved && p.Visibility != "private") || isAuthor(p, userID)
+}
+
+func isAuthor(p *model.ThemePreset, userID int64) bool {
+	return p.AuthorUserID != nil && *p.AuthorUserID == userID
+}
+
+func optionalNote(note string) *string {
+	note = strings.TrimSpace(note)
+	if note == "" {
+		return nil
+	}
+	return &note
+}
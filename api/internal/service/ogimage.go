@@ -0,0 +1,128 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"linkbio/internal/model"
+	"linkbio/internal/ogimage"
+	"linkbio/internal/repo"
+	"linkbio/internal/theme"
+	"linkbio/internal/util"
+)
+
+// OGImageService draws each published page's share image and stores it as
+// an asset, keyed by the hash of everything drawn.
+type OGImageService struct {
+	renderer  *ogimage.Renderer
+	assetRepo repo.AssetStore
+	images    *imageLoader
+}
+
+func NewOGImageService(renderer *ogimage.Renderer, assetRepo repo.AssetStore) *OGImageService {
+	return &OGImageService{
+		renderer:  renderer,
+		assetRepo: assetRepo,
+		images:    newImageLoader(assetRepo),
+	}
+}
+
+// OGImagePath is the public URL of a generated share image.
+func OGImagePath(key string) string {
+	return "/og/" + key + ".png"
+}
+
+// Attach points the compiled page's share metadata at its generated image,
+// unless the page names an image of its own. A failure leaves the page
+// without an image rather than blocking the publish.
+func (s *OGImageService) Attach(ctx context.Context, page *model.BioPage, user *model.User, compiled *CompiledPage) {
+	if compiled.SEO.Image != "" {
+		return
+	}
+	asset, err := s.generate(ctx, page, user, compiled.Theme)
+	if err != nil {
+		log.Printf("[OGImage] page %d: %v", page.ID, err)
+		return
+	}
+	compiled.SEO.Image = *asset.URL
+	compiled.SEO.ImageWidth = ogimage.Width
+	compiled.SEO.ImageHeight = ogimage.Height
+	compiled.SEO.Meta = compiled.SEO.metaTags()
+}
+
+func (s *OGImageService) generate(ctx context.Context, page *model.BioPage, user *model.User, themeConfig json.RawMessage) (*model.Asset, error) {
+	var settings struct {
+		Bio string `json:"bio"`
+	}
+	_ = json.Unmarshal(page.Settings, &settings)
+
+	card := ogimage.Card{
+		Name:    firstNonEmpty(user.DisplayName, user.Username),
+		Bio:     settings.Bio,
+		Palette: theme.ResolvePalette(themeConfig, page.ThemeMode),
+	}
+	if user.Username != nil {
+		card.Username = *user.Username
+	}
+	avatar := s.images.source(ctx, user.AvatarAssetID)
+	var wallpaper *model.Asset
+	if id := card.Palette.WallpaperAssetID; id != 0 {
+		wallpaper = s.images.source(ctx, &id)
+	}
+
+	key := util.SHA256(strings.Join([]string{
+		strconv.Itoa(ogimage.Version),
+		card.Name, card.Username, card.Bio,
+		fmt.Sprintf("%v", card.Palette),
+		sourceKey(avatar), sourceKey(wallpaper),
+	}, "\x00"))
+	if asset, err := s.assetRepo.GetByStorageKey(ctx, "generated", key); err == nil {
+		return asset, nil
+	}
+
+	// An image that fails to load is left out, as if it was never set.
+	if avatar != nil {
+		card.Avatar = s.images.load(ctx, avatar)
+	}
+	if wallpaper != nil {
+		card.Wallpaper = s.images.load(ctx, wallpaper)
+	}
+	data, err := s.renderer.Render(card)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.assetRepo.SaveBlob(ctx, key, data); err != nil {
+		return nil, err
+	}
+
+	url, mime := OGImagePath(key), "image/png"
+	size, width, height := int64(len(data)), ogimage.Width, ogimage.Height
+	asset, err := s.assetRepo.Create(ctx, &model.Asset{
+		UserID:     &user.ID,
+		Scope:      "generated",
+		Type:       "image",
+		Provider:   "db",
+		StorageKey: key,
+		URL:        &url,
+		MimeType:   &mime,
+		SizeBytes:  &size,
+		Width:      &width,
+		Height:     &height,
+	})
+	if pgCode(err) == pgUniqueViolation {
+		// Published concurrently with identical content.
+		return s.assetRepo.GetByStorageKey(ctx, "generated", key)
+	}
+	return asset, err
+}
+
+func sourceKey(asset *model.Asset) string {
+	if asset == nil {
+		return ""
+	}
+	return strconv.FormatInt(asset.ID, 10) + ":" + asset.StorageKey
+}
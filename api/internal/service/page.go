@@ -3,19 +3,25 @@ package service
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"time"
 
 	"linkbio/internal/model"
 	"linkbio/internal/repo"
+	"linkbio/internal/urlpolicy"
 	"linkbio/internal/util"
 )
 
 type PageService struct {
-	pageRepo  *repo.PageRepo
-	blockRepo *repo.BlockRepo
+	pageRepo      repo.PageStore
+	blockRepo     repo.BlockStore
+	aggregateRepo repo.PageAggregateStore
+	assetRepo     repo.AssetStore
+	urlPolicy     *urlpolicy.Policy
 }
 
-func NewPageService(pageRepo *repo.PageRepo, blockRepo *repo.BlockRepo) *PageService {
-	return &PageService{pageRepo: pageRepo, blockRepo: blockRepo}
+func NewPageService(pageRepo repo.PageStore, blockRepo repo.BlockStore, aggregateRepo repo.PageAggregateStore, assetRepo repo.AssetStore, urlPolicy *urlpolicy.Policy) *PageService {
+	return &PageService{pageRepo: pageRepo, blockRepo: blockRepo, aggregateRepo: aggregateRepo, assetRepo: assetRepo, urlPolicy: urlPolicy}
 }
 
 func (s *PageService) Create(ctx context.Context, userID int64, title string, themePresetID int64) (*model.BioPage, error) {
@@ -23,7 +29,11 @@ func (s *PageService) Create(ctx context.Context, userID int64, title string, th
 }
 
 func (s *PageService) Get(ctx context.Context, pageID int64) (*model.BioPage, error) {
-	return s.pageRepo.GetByID(ctx, pageID)
+	page, err := s.pageRepo.GetByID(ctx, pageID)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return page, nil
 }
 
 func (s *PageService) List(ctx context.Context, userID int64) ([]*model.BioPage, error) {
@@ -47,35 +57,16 @@ type DraftData struct {
 }
 
 func (s *PageService) GetDraft(ctx context.Context, pageID int64) (*DraftData, error) {
-	page, err := s.pageRepo.GetByID(ctx, pageID)
-	if err != nil {
-		return nil, err
-	}
-
-	blocks, err := s.blockRepo.GetBlocksByPage(ctx, pageID)
-	if err != nil {
-		return nil, err
-	}
-
-	groups, err := s.blockRepo.GetLinkGroupsByPage(ctx, pageID)
+	agg, err := s.aggregateRepo.Load(ctx, pageID)
 	if err != nil {
-		return nil, err
-	}
-
-	links := make(map[int64][]*model.Link)
-	for _, g := range groups {
-		groupLinks, err := s.blockRepo.GetLinksByGroup(ctx, g.ID)
-		if err != nil {
-			return nil, err
-		}
-		links[g.ID] = groupLinks
+		return nil, notFound(err)
 	}
 
 	return &DraftData{
-		Page:       page,
-		Blocks:     blocks,
-		LinkGroups: groups,
-		Links:      links,
+		Page:       agg.Page,
+		Blocks:     agg.Blocks,
+		LinkGroups: agg.Groups,
+		Links:      agg.Links,
 	}, nil
 }
 
@@ -96,17 +87,24 @@ type SaveLinkGroupReq struct {
 	LayoutType    string          `json:"layout_type"`
 	LayoutConfig  json.RawMessage `json:"layout_config"`
 	StyleOverride json.RawMessage `json:"style_override"`
+	UTMTemplate   *string         `json:"utm_template"`
 	Delete        bool            `json:"delete"`
 }
 
 type SaveLinkReq struct {
-	ID       *int64 `json:"id"`
-	GroupID  int64  `json:"group_id"`
-	Title    string `json:"title"`
-	URL      string `json:"url"`
-	SortKey  string `json:"sort_key"`
-	IsActive bool   `json:"is_active"`
-	Delete   bool   `json:"delete"`
+	ID          *int64          `json:"id"`
+	GroupID     int64           `json:"group_id"`
+	Title       string          `json:"title"`
+	URL         string          `json:"url"`
+	SortKey     string          `json:"sort_key"`
+	IsActive    bool            `json:"is_active"`
+	UTMTemplate *string         `json:"utm_template"`
+	Rules       json.RawMessage `json:"rules"`
+	ExpiresAt   *time.Time      `json:"expires_at"`
+	MaxClicks   *int            `json:"max_clicks"`
+	Fallback    string          `json:"fallback"` // hide (default) or redirect
+	FallbackURL *string         `json:"fallback_url"`
+	Delete      bool            `json:"delete"`
 }
 
 type SaveRequest struct {
@@ -117,6 +115,37 @@ type SaveRequest struct {
 }
 
 func (s *PageService) Save(ctx context.Context, pageID int64, req *SaveRequest) error {
+	// Check every link before writing anything
+	for i, l := range req.Links {
+		if l.Delete {
+			continue
+		}
+		url, err := checkLinkURL(s.urlPolicy, fmt.Sprintf("links[%d].url", i), l.URL)
+		if err != nil {
+			return err
+		}
+		req.Links[i].URL = url
+		if req.Links[i].UTMTemplate, err = checkUTMTemplate(fmt.Sprintf("links[%d].utm_template", i), l.UTMTemplate); err != nil {
+			return err
+		}
+		if req.Links[i].Rules, err = checkLinkRules(s.urlPolicy, fmt.Sprintf("links[%d].rules", i), l.Rules); err != nil {
+			return err
+		}
+		if err := checkLinkLimits(s.urlPolicy, fmt.Sprintf("links[%d]", i), &req.Links[i]); err != nil {
+			return err
+		}
+	}
+	for i, g := range req.LinkGroups {
+		if g.Delete {
+			continue
+		}
+		template, err := checkUTMTemplate(fmt.Sprintf("link_groups[%d].utm_template", i), g.UTMTemplate)
+		if err != nil {
+			return err
+		}
+		req.LinkGroups[i].UTMTemplate = template
+	}
+
 	// Update page - merge with existing data
 	if req.Page != nil {
 		existing, err := s.pageRepo.GetByID(ctx, pageID)
@@ -173,6 +202,7 @@ func (s *PageService) Save(ctx context.Context, pageID int64, req *SaveRequest)
 				LayoutType:    g.LayoutType,
 				LayoutConfig:  g.LayoutConfig,
 				StyleOverride: g.StyleOverride,
+				UTMTemplate:   g.UTMTemplate,
 			}
 			if err := s.blockRepo.UpdateLinkGroup(ctx, group); err != nil {
 				return err
@@ -183,6 +213,12 @@ func (s *PageService) Save(ctx context.Context, pageID int64, req *SaveRequest)
 			if err != nil {
 				return err
 			}
+			if g.UTMTemplate != nil {
+				group.UTMTemplate = g.UTMTemplate
+				if err := s.blockRepo.UpdateLinkGroup(ctx, group); err != nil {
+					return err
+				}
+			}
 			if g.ID != nil {
 				groupIDMap[*g.ID] = group.ID
 			}
@@ -251,21 +287,38 @@ func (s *PageService) Save(ctx context.Context, pageID int64, req *SaveRequest)
 		if l.ID != nil && *l.ID > 0 {
 			// Update existing
 			link := &model.Link{
-				ID:       *l.ID,
-				Title:    l.Title,
-				URL:      l.URL,
-				SortKey:  sortKey,
-				IsActive: l.IsActive,
+				ID:          *l.ID,
+				Title:       l.Title,
+				URL:         l.URL,
+				SortKey:     sortKey,
+				IsActive:    l.IsActive,
+				UTMTemplate: l.UTMTemplate,
+				Rules:       l.Rules,
+				ExpiresAt:   l.ExpiresAt,
+				MaxClicks:   l.MaxClicks,
+				Fallback:    l.Fallback,
+				FallbackURL: l.FallbackURL,
 			}
 			if err := s.blockRepo.UpdateLink(ctx, link); err != nil {
 				return err
 			}
 		} else {
 			// Create new
-			_, err := s.blockRepo.CreateLink(ctx, groupID, l.Title, l.URL, sortKey)
+			link, err := s.blockRepo.CreateLink(ctx, groupID, l.Title, l.URL, sortKey)
 			if err != nil {
 				return err
 			}
+			if l.UTMTemplate != nil || l.Rules != nil || l.ExpiresAt != nil || l.MaxClicks != nil || l.Fallback != model.LinkFallbackHide {
+				link.UTMTemplate = l.UTMTemplate
+				link.Rules = l.Rules
+				link.ExpiresAt = l.ExpiresAt
+				link.MaxClicks = l.MaxClicks
+				link.Fallback = l.Fallback
+				link.FallbackURL = l.FallbackURL
+				if err := s.blockRepo.UpdateLink(ctx, link); err != nil {
+					return err
+				}
+			}
 		}
 	}
 
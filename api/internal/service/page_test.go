@@ -0,0 +1,112 @@
+package service_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"linkbio/internal/model"
+	"linkbio/internal/service"
+)
+
+func TestSaveCreatesWithTempIDs(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t)
+	user := f.user(t, "alice@example.com")
+	page := f.page(t, user)
+
+	tempGroup := int64(-1)
+	utm := "utm_source=bio"
+	err := f.pageSvc.Save(ctx, page.ID, &service.SaveRequest{
+		LinkGroups: []service.SaveLinkGroupReq{{ID: &tempGroup, LayoutType: "list", UTMTemplate: &utm}},
+		Blocks:     []service.SaveBlockReq{{Type: "link_group", SortKey: "a", RefID: &tempGroup, IsVisible: true}},
+		Links: []service.SaveLinkReq{
+			{GroupID: tempGroup, Title: "Site", URL: "Example.com/path", SortKey: "a", IsActive: true},
+		},
+	})
+	must(t, err)
+
+	draft, err := f.pageSvc.GetDraft(ctx, page.ID)
+	must(t, err)
+	if len(draft.LinkGroups) != 1 || len(draft.Blocks) != 1 {
+		t.Fatalf("draft = %d groups, %d blocks, want 1 and 1", len(draft.LinkGroups), len(draft.Blocks))
+	}
+	group := draft.LinkGroups[0]
+	if draft.Blocks[0].RefID == nil || *draft.Blocks[0].RefID != group.ID {
+		t.Errorf("block ref = %v, want group %d", draft.Blocks[0].RefID, group.ID)
+	}
+	if group.UTMTemplate == nil || *group.UTMTemplate != utm {
+		t.Errorf("group utm = %v, want %q", group.UTMTemplate, utm)
+	}
+	links := draft.Links[group.ID]
+	if len(links) != 1 || links[0].URL != "https://example.com/path" {
+		t.Errorf("links = %+v, want one normalized link in the new group", links)
+	}
+}
+
+func TestSaveUpdatesAndDeletes(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t)
+	user := f.user(t, "alice@example.com")
+	page := f.page(t, user)
+
+	group, err := f.blocks.CreateLinkGroup(ctx, page.ID, nil, "list")
+	must(t, err)
+	text, err := f.blocks.CreateBlock(ctx, page.ID, "text", "a", nil, json.RawMessage(`{"text":"old"}`))
+	must(t, err)
+	keep, err := f.blocks.CreateLink(ctx, group.ID, "Keep", "https://example.com", "a")
+	must(t, err)
+	drop, err := f.blocks.CreateLink(ctx, group.ID, "Drop", "https://example.org", "b")
+	must(t, err)
+
+	title := "New title"
+	err = f.pageSvc.Save(ctx, page.ID, &service.SaveRequest{
+		Page:   &model.BioPage{Title: &title},
+		Blocks: []service.SaveBlockReq{{ID: &text.ID, SortKey: "a", Content: json.RawMessage(`{"text":"new"}`), IsVisible: true}},
+		Links: []service.SaveLinkReq{
+			{ID: &keep.ID, GroupID: group.ID, Title: "Kept", URL: "https://example.com/new", SortKey: "a", IsActive: true},
+			{ID: &drop.ID, Delete: true},
+		},
+	})
+	must(t, err)
+
+	draft, err := f.pageSvc.GetDraft(ctx, page.ID)
+	must(t, err)
+	if draft.Page.Title == nil || *draft.Page.Title != title {
+		t.Errorf("title = %v, want %q", draft.Page.Title, title)
+	}
+	if string(draft.Blocks[0].Content) != `{"text":"new"}` {
+		t.Errorf("block content = %s", draft.Blocks[0].Content)
+	}
+	links := draft.Links[group.ID]
+	if len(links) != 1 || links[0].Title != "Kept" || links[0].URL != "https://example.com/new" {
+		t.Errorf("links = %+v, want only the updated link", links)
+	}
+}
+
+func TestSaveRejectsBadLinkBeforeWriting(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t)
+	user := f.user(t, "alice@example.com")
+	page := f.page(t, user)
+
+	title := "Not saved"
+	err := f.pageSvc.Save(ctx, page.ID, &service.SaveRequest{
+		Page:       &model.BioPage{Title: &title},
+		LinkGroups: []service.SaveLinkGroupReq{{LayoutType: "list"}},
+		Links:      []service.SaveLinkReq{{GroupID: 1, Title: "Bad", URL: "javascript:alert(1)"}},
+	})
+	if !errors.Is(err, service.ErrLinkURL) {
+		t.Fatalf("Save = %v, want ErrLinkURL", err)
+	}
+
+	draft, err := f.pageSvc.GetDraft(ctx, page.ID)
+	must(t, err)
+	if draft.Page.Title != nil && *draft.Page.Title == title {
+		t.Error("page updated despite the rejected link")
+	}
+	if len(draft.LinkGroups) != 0 {
+		t.Error("link group created despite the rejected link")
+	}
+}
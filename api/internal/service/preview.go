@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"linkbio/internal/apperr"
+	"linkbio/internal/repo"
+)
+
+const (
+	previewAudience   = "preview"
+	DefaultPreviewTTL = 24 * time.Hour
+	MaxPreviewTTL     = 7 * 24 * time.Hour
+)
+
+// PreviewService shares unpublished drafts through signed, expiring links.
+// Previews are compiled on every request and never touch the publish cache.
+type PreviewService struct {
+	compiler *CompilerService
+	pageRepo repo.PageStore
+	secret   []byte
+	appURL   string
+}
+
+// NewPreviewService signs links with secret, which must not be the session
+// signing key.
+func NewPreviewService(compiler *CompilerService, pageRepo repo.PageStore, secret []byte, appURL string) *PreviewService {
+	return &PreviewService{
+		compiler: compiler,
+		pageRepo: pageRepo,
+		secret:   secret,
+		appURL:   strings.TrimRight(appURL, "/"),
+	}
+}
+
+type PreviewLink struct {
+	Token     string    `json:"token"`
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Create signs a preview link for one of the user's pages. A zero ttl
+// means DefaultPreviewTTL.
+func (s *PreviewService) Create(ctx context.Context, userID, pageID int64, ttl time.Duration) (*PreviewLink, error) {
+	if ttl == 0 {
+		ttl = DefaultPreviewTTL
+	}
+	if ttl < time.Minute || ttl > MaxPreviewTTL {
+		return nil, ErrPreviewTTL
+	}
+
+	page, err := s.pageRepo.GetByID(ctx, pageID)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	if page.UserID != userID {
+		return nil, ErrForbidden
+	}
+
+	expires := time.Now().Add(ttl).Truncate(time.Second)
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"aud":     previewAudience,
+		"page_id": page.ID,
+		"exp":     expires.Unix(),
+	}).SignedString(s.secret)
+	if err != nil {
+		return nil, apperr.Internal(err)
+	}
+
+	return &PreviewLink{
+		Token:     token,
+		URL:       s.appURL + "/preview/" + token,
+		ExpiresAt: expires,
+	}, nil
+}
+
+// Render compiles the current draft of the page a token was signed for.
+func (s *PreviewService) Render(ctx context.Context, token string) (*CompiledPage, error) {
+	pageID, err := s.verify(token)
+	if err != nil {
+		return nil, err
+	}
+	compiled, err := s.compiler.Compile(ctx, pageID)
+	if err != nil {
+		// The page was deleted after the link was shared.
+		return nil, notFound(err)
+	}
+	return compiled, nil
+}
+
+func (s *PreviewService) verify(token string) (int64, error) {
+	claims := jwt.MapClaims{}
+	key := func(t *jwt.Token) (interface{}, error) { return s.secret, nil }
+	_, err := jwt.ParseWithClaims(token, claims, key,
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithAudience(previewAudience),
+		jwt.WithExpirationRequired())
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return 0, ErrPreviewExpired
+		}
+		return 0, ErrPreviewInvalid
+	}
+
+	pageID, ok := claims["page_id"].(float64)
+	if !ok || pageID <= 0 {
+		return 0, ErrPreviewInvalid
+	}
+	return int64(pageID), nil
+}
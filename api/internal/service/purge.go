@@ -0,0 +1,17 @@
+package service
+
+import (
+	"context"
+	"log"
+
+	"linkbio/internal/cdn"
+)
+
+// purge asks the CDN to drop responses tagged with keys. Failures are only
+// logged: stale CDN objects expire on their own and must not fail the
+// write that triggered the purge.
+func purge(ctx context.Context, purger cdn.Purger, keys ...string) {
+	if err := purger.Purge(ctx, keys); err != nil {
+		log.Printf("[CDN] purge %v: %v", keys, err)
+	}
+}
@@ -0,0 +1,240 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"image"
+	"image/color"
+
+	"linkbio/internal/model"
+	"linkbio/internal/qr"
+	"linkbio/internal/repo"
+	"linkbio/internal/theme"
+)
+
+const (
+	minQRSize     = 128
+	maxQRSize     = 2048
+	maxQRMargin   = 16
+	minQRContrast = 4.0
+)
+
+// QROptions describe how to draw a QR code. Zero values take the defaults:
+// a 512px PNG with a 4-module margin at level M, black on white.
+type QROptions struct {
+	Format string // png or svg
+	Size   int
+	Margin *int
+	Level  string
+	// Foreground and Background are CSS colors. ThemeColors takes them
+	// from the page's published theme instead, when they scan well.
+	Foreground  string
+	Background  string
+	ThemeColors bool
+	// Avatar puts the user's avatar in the middle of the code.
+	Avatar bool
+}
+
+// QRCode is a drawn code and the URL it encodes.
+type QRCode struct {
+	Data        []byte
+	ContentType string
+	URL         string
+}
+
+// QRService draws QR codes for a page's canonical URL and for its links'
+// tracked redirects. Both carry ?src=qr so scans show up as their own
+// source in analytics.
+type QRService struct {
+	pageRepo   repo.PageStore
+	bioRepo    repo.BioStore
+	domainRepo repo.DomainStore
+	userRepo   repo.UserStore
+	images     *imageLoader
+}
+
+func NewQRService(pageRepo repo.PageStore, bioRepo repo.BioStore, domainRepo repo.DomainStore, userRepo repo.UserStore, assetRepo repo.AssetStore) *QRService {
+	return &QRService{
+		pageRepo:   pageRepo,
+		bioRepo:    bioRepo,
+		domainRepo: domainRepo,
+		userRepo:   userRepo,
+		images:     newImageLoader(assetRepo),
+	}
+}
+
+// PageCode draws a code for the page's canonical URL.
+func (s *QRService) PageCode(ctx context.Context, userID, pageID int64, opts QROptions) (*QRCode, error) {
+	page, err := s.ownPage(ctx, userID, pageID)
+	if err != nil {
+		return nil, err
+	}
+	url, _, err := CanonicalURL(ctx, s.domainRepo, pageID)
+	if err != nil {
+		return nil, err
+	}
+	if url == "" {
+		return nil, ErrQRNoURL
+	}
+	return s.draw(ctx, page, url+"?src="+SourceQR, opts)
+}
+
+// LinkCode draws a code for the link's tracked redirect on the page's
+// canonical domain, which the web app's /l/[id] route hands to the API.
+func (s *QRService) LinkCode(ctx context.Context, userID, linkID int64, opts QROptions) (*QRCode, error) {
+	pageID, err := s.bioRepo.GetLinkPageID(ctx, linkID)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	page, err := s.ownPage(ctx, userID, pageID)
+	if err != nil {
+		return nil, err
+	}
+	url, _, err := CanonicalURL(ctx, s.domainRepo, pageID)
+	if err != nil {
+		return nil, err
+	}
+	if url == "" {
+		return nil, ErrQRNoURL
+	}
+	return s.draw(ctx, page, origin(url)+LinkPath(linkID)+"?src="+SourceQR, opts)
+}
+
+func (s *QRService) ownPage(ctx context.Context, userID, pageID int64) (*model.BioPage, error) {
+	page, err := s.pageRepo.GetByID(ctx, pageID)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	if page.UserID != userID {
+		return nil, ErrForbidden
+	}
+	return page, nil
+}
+
+func (s *QRService) draw(ctx context.Context, page *model.BioPage, url string, opts QROptions) (*QRCode, error) {
+	o := qr.Options{Size: opts.Size, Margin: 4, Level: qr.Level(opts.Level)}
+	if opts.Format == "" {
+		opts.Format = "png"
+	}
+	if opts.Format != "png" && opts.Format != "svg" {
+		return nil, ErrQRFormat
+	}
+	if o.Size == 0 {
+		o.Size = 512
+	}
+	if o.Size < minQRSize || o.Size > maxQRSize {
+		return nil, ErrQRSize
+	}
+	if opts.Margin != nil {
+		o.Margin = *opts.Margin
+	}
+	if o.Margin < 0 || o.Margin > maxQRMargin {
+		return nil, ErrQRMargin
+	}
+	if o.Level == "" {
+		o.Level = qr.LevelM
+	}
+	if !qr.ValidLevel(o.Level) {
+		return nil, ErrQRLevel
+	}
+
+	var err error
+	if o.Foreground, o.Background, err = s.colors(ctx, page, opts); err != nil {
+		return nil, err
+	}
+	if opts.Avatar {
+		o.Logo = s.avatar(ctx, page.UserID)
+	}
+
+	code, err := qr.Encode(url, o)
+	if err != nil {
+		return nil, err
+	}
+	out := &QRCode{URL: url}
+	if opts.Format == "svg" {
+		out.ContentType = "image/svg+xml"
+		out.Data, err = code.SVG()
+	} else {
+		out.ContentType = "image/png"
+		out.Data, err = code.PNG()
+	}
+	if errors.Is(err, qr.ErrTooSmall) {
+		return nil, ErrQRTooDense
+	}
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// colors picks the code's colors. Scanners need dark modules on a light
+// background with enough contrast, so explicit colors that fail that are
+// rejected and theme colors that fail it fall back to black on white.
+func (s *QRService) colors(ctx context.Context, page *model.BioPage, opts QROptions) (fg, bg color.NRGBA, err error) {
+	black, white := color.NRGBA{0, 0, 0, 255}, color.NRGBA{255, 255, 255, 255}
+	fg, bg = black, white
+
+	if opts.ThemeColors {
+		if p, ok := s.palette(ctx, page.ID); ok {
+			for _, c := range []color.NRGBA{p.Primary, p.Text} {
+				if scannable(c, p.Background) {
+					fg, bg = c, p.Background
+					break
+				}
+			}
+		}
+	}
+
+	if opts.Foreground != "" {
+		if fg, err = parseQRColor(opts.Foreground, "fg"); err != nil {
+			return
+		}
+	}
+	if opts.Background != "" {
+		if bg, err = parseQRColor(opts.Background, "bg"); err != nil {
+			return
+		}
+	}
+	if !scannable(fg, bg) {
+		err = ErrQRContrast
+	}
+	return
+}
+
+// palette reads the colors of the page's published theme.
+func (s *QRService) palette(ctx context.Context, pageID int64) (theme.Palette, bool) {
+	cache, err := s.pageRepo.GetPublishCache(ctx, pageID)
+	if err != nil {
+		return theme.Palette{}, false
+	}
+	var compiled CompiledPage
+	if err := json.Unmarshal(cache.CompiledJSON, &compiled); err != nil {
+		return theme.Palette{}, false
+	}
+	return theme.ResolvePalette(compiled.Theme, compiled.Page.Mode), true
+}
+
+func (s *QRService) avatar(ctx context.Context, userID int64) image.Image {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil
+	}
+	asset := s.images.source(ctx, user.AvatarAssetID)
+	if asset == nil {
+		return nil
+	}
+	return s.images.load(ctx, asset)
+}
+
+func parseQRColor(value, field string) (color.NRGBA, error) {
+	c, ok := theme.ParseColor(value)
+	if !ok {
+		return c, ErrQRColor.WithField(field, "format", "qr.invalid_color")
+	}
+	return c, nil
+}
+
+func scannable(fg, bg color.NRGBA) bool {
+	return theme.Luminance(fg) < theme.Luminance(bg) && theme.Contrast(fg, bg) >= minQRContrast
+}
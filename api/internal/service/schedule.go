@@ -0,0 +1,164 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"linkbio/internal/model"
+	"linkbio/internal/repo"
+)
+
+const (
+	maxScheduleAhead    = 366 * 24 * time.Hour
+	maxScheduleAttempts = 5
+	scheduleLease       = 5 * time.Minute
+)
+
+// ScheduleService queues publishes and unpublishes for later and runs them
+// when due. The queue lives in the database, so schedules survive restarts
+// and any number of instances may run the worker.
+type ScheduleService struct {
+	scheduleRepo repo.ScheduleStore
+	pageRepo     repo.PageStore
+	compiler     *CompilerService
+}
+
+func NewScheduleService(scheduleRepo repo.ScheduleStore, pageRepo repo.PageStore, compiler *CompilerService) *ScheduleService {
+	return &ScheduleService{scheduleRepo: scheduleRepo, pageRepo: pageRepo, compiler: compiler}
+}
+
+func (s *ScheduleService) Create(ctx context.Context, userID, pageID int64, action string, runAt time.Time) (*model.PageSchedule, error) {
+	if action != model.SchedulePublish && action != model.ScheduleUnpublish {
+		return nil, ErrScheduleAction
+	}
+	if now := time.Now(); !runAt.After(now) || runAt.After(now.Add(maxScheduleAhead)) {
+		return nil, ErrScheduleTime
+	}
+	if _, err := s.ownPage(ctx, userID, pageID); err != nil {
+		return nil, err
+	}
+	return s.scheduleRepo.Create(ctx, pageID, userID, action, runAt)
+}
+
+// List returns the page's pending schedules, soonest first.
+func (s *ScheduleService) List(ctx context.Context, userID, pageID int64) ([]*model.PageSchedule, error) {
+	if _, err := s.ownPage(ctx, userID, pageID); err != nil {
+		return nil, err
+	}
+	schedules, err := s.scheduleRepo.ListPendingByPage(ctx, pageID)
+	if err != nil {
+		return nil, err
+	}
+	if schedules == nil {
+		schedules = []*model.PageSchedule{}
+	}
+	return schedules, nil
+}
+
+func (s *ScheduleService) Cancel(ctx context.Context, userID, pageID, id int64) error {
+	pending, err := s.List(ctx, userID, pageID)
+	if err != nil {
+		return err
+	}
+	for _, p := range pending {
+		if p.ID != id {
+			continue
+		}
+		// Still pending but leased: a worker is running it right now.
+		if err := s.scheduleRepo.Cancel(ctx, id, userID); errors.Is(err, pgx.ErrNoRows) {
+			return ErrScheduleRunning
+		} else if err != nil {
+			return err
+		}
+		return nil
+	}
+	return ErrNotFound
+}
+
+func (s *ScheduleService) ownPage(ctx context.Context, userID, pageID int64) (*model.BioPage, error) {
+	page, err := s.pageRepo.GetByID(ctx, pageID)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	if page.UserID != userID {
+		return nil, ErrForbidden
+	}
+	return page, nil
+}
+
+// Run executes due schedules every interval until ctx is done.
+func (s *ScheduleService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := s.RunDue(ctx); err != nil {
+			log.Printf("[Schedule] run: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunDue claims and executes the schedules that are due, one at a time so
+// each runs under a fresh lease, and reports how many ran. A publish
+// compiles the draft as it is when the schedule runs. Failures are retried
+// with backoff up to maxScheduleAttempts.
+func (s *ScheduleService) RunDue(ctx context.Context) (int, error) {
+	ran := 0
+	for ctx.Err() == nil {
+		sch, err := s.scheduleRepo.ClaimDue(ctx, scheduleLease)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ran, nil
+		}
+		if err != nil {
+			return ran, err
+		}
+		s.execute(ctx, sch)
+		ran++
+	}
+	return ran, ctx.Err()
+}
+
+func (s *ScheduleService) execute(ctx context.Context, sch *model.PageSchedule) {
+	// Finish well within the lease so no other worker picks the row up.
+	runCtx, cancel := context.WithTimeout(ctx, scheduleLease/2)
+	defer cancel()
+
+	var err error
+	switch sch.Action {
+	case model.SchedulePublish:
+		_, err = s.compiler.Publish(runCtx, sch.PageID)
+	case model.ScheduleUnpublish:
+		err = s.compiler.Unpublish(runCtx, sch.PageID)
+	}
+
+	if err == nil {
+		s.finish("complete", sch, s.scheduleRepo.Complete(ctx, sch.ID, sch.Attempts))
+		return
+	}
+
+	log.Printf("[Schedule] %s page %d (attempt %d): %v", sch.Action, sch.PageID, sch.Attempts, err)
+	var retryAt *time.Time
+	if sch.Attempts < maxScheduleAttempts && !errors.Is(err, pgx.ErrNoRows) {
+		at := time.Now().Add(time.Duration(sch.Attempts*sch.Attempts) * time.Minute)
+		retryAt = &at
+	}
+	s.finish("fail", sch, s.scheduleRepo.Fail(ctx, sch.ID, sch.Attempts, err.Error(), retryAt))
+}
+
+// finish logs a Complete or Fail that did not apply. A missing row means
+// the claim was lost, to a cancel after the lease ran out or to another
+// worker, and the newer state stands.
+func (s *ScheduleService) finish(op string, sch *model.PageSchedule, err error) {
+	if errors.Is(err, pgx.ErrNoRows) {
+		log.Printf("[Schedule] %s %d: lease lost (attempt %d)", op, sch.ID, sch.Attempts)
+	} else if err != nil {
+		log.Printf("[Schedule] %s %d: %v", op, sch.ID, err)
+	}
+}
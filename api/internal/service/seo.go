@@ -0,0 +1,241 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/jackc/pgx/v5"
+	"linkbio/internal/model"
+	"linkbio/internal/repo"
+)
+
+const (
+	maxSEOTitle       = 70
+	maxSEODescription = 200
+)
+
+// SEOSettings are the page's search and share metadata, kept under "seo"
+// in the page settings. Empty fields fall back to the page itself.
+type SEOSettings struct {
+	Title        string `json:"title,omitempty"`
+	Description  string `json:"description,omitempty"`
+	ImageAssetID *int64 `json:"image_asset_id,omitempty"`
+	NoIndex      bool   `json:"noindex,omitempty"`
+}
+
+// CompiledSEO is the metadata the public renderer puts in the page head.
+type CompiledSEO struct {
+	Title        string    `json:"title"`
+	Description  string    `json:"description,omitempty"`
+	Image        string    `json:"image,omitempty"`
+	ImageWidth   int       `json:"image_width,omitempty"` // known for generated images
+	ImageHeight  int       `json:"image_height,omitempty"`
+	NoIndex      bool      `json:"noindex"`
+	CanonicalURL string    `json:"canonical_url,omitempty"`
+	Meta         []MetaTag `json:"meta"`
+}
+
+// MetaTag is a <meta> element; Open Graph tags use Property, the rest Name.
+type MetaTag struct {
+	Name     string `json:"name,omitempty"`
+	Property string `json:"property,omitempty"`
+	Content  string `json:"content"`
+}
+
+// UpdateSEO validates and stores the page's SEO settings. They take effect
+// on the next publish.
+func (s *PageService) UpdateSEO(ctx context.Context, userID, pageID int64, seo SEOSettings) (*SEOSettings, error) {
+	page, err := s.pageRepo.GetByID(ctx, pageID)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	if page.UserID != userID {
+		return nil, ErrForbidden
+	}
+
+	seo.Title = strings.TrimSpace(seo.Title)
+	seo.Description = strings.TrimSpace(seo.Description)
+	if utf8.RuneCountInString(seo.Title) > maxSEOTitle || hasControl(seo.Title) {
+		return nil, ErrSEOTitle
+	}
+	if utf8.RuneCountInString(seo.Description) > maxSEODescription || hasControl(seo.Description) {
+		return nil, ErrSEODescription
+	}
+	if seo.ImageAssetID != nil {
+		if _, err := shareImage(ctx, s.assetRepo, userID, *seo.ImageAssetID); errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrSEOImage
+		} else if err != nil {
+			return nil, err
+		}
+	}
+
+	settings := map[string]json.RawMessage{}
+	if len(page.Settings) > 0 {
+		if err := json.Unmarshal(page.Settings, &settings); err != nil {
+			settings = map[string]json.RawMessage{}
+		}
+	}
+	raw, err := json.Marshal(seo)
+	if err != nil {
+		return nil, err
+	}
+	settings["seo"] = raw
+	newSettings, err := json.Marshal(settings)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.pageRepo.UpdateSettings(ctx, pageID, newSettings); err != nil {
+		return nil, err
+	}
+	return &seo, nil
+}
+
+// compileSEO resolves the page's SEO settings against its fallbacks. Drafts
+// saved wholesale skip UpdateSEO, so everything is checked again here.
+func compileSEO(ctx context.Context, assets repo.AssetStore, page *model.BioPage, user *model.User) CompiledSEO {
+	var settings struct {
+		Bio string      `json:"bio"`
+		SEO SEOSettings `json:"seo"`
+	}
+	_ = json.Unmarshal(page.Settings, &settings)
+
+	out := CompiledSEO{
+		Title:       truncateRunes(strings.TrimSpace(settings.SEO.Title), maxSEOTitle),
+		Description: truncateRunes(strings.TrimSpace(settings.SEO.Description), maxSEODescription),
+		NoIndex:     settings.SEO.NoIndex,
+	}
+	if out.Title == "" {
+		out.Title = truncateRunes(firstNonEmpty(page.Title, user.DisplayName, user.Username), maxSEOTitle)
+	}
+	if out.Description == "" {
+		out.Description = truncateRunes(strings.Join(strings.Fields(settings.Bio), " "), maxSEODescription)
+	}
+	if id := settings.SEO.ImageAssetID; id != nil {
+		if asset, err := shareImage(ctx, assets, page.UserID, *id); err == nil {
+			out.Image = *asset.URL
+		}
+	}
+	out.Meta = out.metaTags()
+	return out
+}
+
+// SetCanonical records the URL the page is canonically served at and
+// resolves a relative share image against it.
+func (s *CompiledSEO) SetCanonical(url string) {
+	s.CanonicalURL = url
+	if strings.HasPrefix(s.Image, "/") && !strings.HasPrefix(s.Image, "//") && url != "" {
+		s.Image = origin(url) + s.Image
+	}
+	s.Meta = s.metaTags()
+}
+
+func (s *CompiledSEO) metaTags() []MetaTag {
+	tags := []MetaTag{}
+	add := func(tag MetaTag) {
+		if tag.Content != "" {
+			tags = append(tags, tag)
+		}
+	}
+	card := "summary"
+	if s.Image != "" {
+		card = "summary_large_image"
+	}
+	if s.NoIndex {
+		add(MetaTag{Name: "robots", Content: "noindex, nofollow"})
+	}
+	add(MetaTag{Name: "description", Content: s.Description})
+	add(MetaTag{Property: "og:type", Content: "profile"})
+	add(MetaTag{Property: "og:title", Content: s.Title})
+	add(MetaTag{Property: "og:description", Content: s.Description})
+	add(MetaTag{Property: "og:image", Content: s.Image})
+	if s.Image != "" && s.ImageWidth > 0 && s.ImageHeight > 0 {
+		add(MetaTag{Property: "og:image:width", Content: strconv.Itoa(s.ImageWidth)})
+		add(MetaTag{Property: "og:image:height", Content: strconv.Itoa(s.ImageHeight)})
+	}
+	add(MetaTag{Property: "og:url", Content: s.CanonicalURL})
+	add(MetaTag{Name: "twitter:card", Content: card})
+	add(MetaTag{Name: "twitter:title", Content: s.Title})
+	add(MetaTag{Name: "twitter:description", Content: s.Description})
+	add(MetaTag{Name: "twitter:image", Content: s.Image})
+	return tags
+}
+
+// shareImage returns one of the user's image assets that has a URL.
+func shareImage(ctx context.Context, assets repo.AssetStore, userID, assetID int64) (*model.Asset, error) {
+	asset, err := assets.GetByID(ctx, assetID)
+	if err != nil {
+		return nil, err
+	}
+	if asset.UserID == nil || *asset.UserID != userID || asset.Type != "image" || asset.URL == nil || *asset.URL == "" {
+		return nil, ErrSEOImage
+	}
+	return asset, nil
+}
+
+// CanonicalURL returns the URL a page should be indexed under and the
+// domain it is on: its route on an active custom domain if it has one,
+// otherwise its route on the system domain. A page with neither returns "".
+func CanonicalURL(ctx context.Context, domains repo.DomainStore, pageID int64) (string, int64, error) {
+	routes, err := domains.ListCurrentRoutesByPage(ctx, pageID)
+	if err != nil {
+		return "", 0, err
+	}
+
+	var system *model.Domain
+	var systemPath string
+	for _, route := range routes {
+		domain, err := domains.GetByID(ctx, route.DomainID)
+		if err != nil {
+			return "", 0, err
+		}
+		switch {
+		case domain.IsSystem && system == nil:
+			system, systemPath = domain, route.Path
+		case !domain.IsSystem && domain.Status == "active":
+			return PublicURL(domain.Hostname, route.Path), domain.ID, nil
+		}
+	}
+	if system == nil || system.Status == "disabled" {
+		return "", 0, nil
+	}
+	return PublicURL(system.Hostname, systemPath), system.ID, nil
+}
+
+// PublicURL is the address of path on a domain.
+func PublicURL(hostname, path string) string {
+	return "https://" + hostname + path
+}
+
+func origin(url string) string {
+	scheme, rest, ok := strings.Cut(url, "://")
+	if !ok {
+		return ""
+	}
+	host, _, _ := strings.Cut(rest, "/")
+	return scheme + "://" + host
+}
+
+func firstNonEmpty(values ...*string) string {
+	for _, v := range values {
+		if v != nil && strings.TrimSpace(*v) != "" {
+			return strings.TrimSpace(*v)
+		}
+	}
+	return ""
+}
+
+func truncateRunes(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return strings.TrimSpace(string([]rune(s)[:n-1])) + "…"
+}
+
+func hasControl(s string) bool {
+	return strings.IndexFunc(s, unicode.IsControl) >= 0
+}
@@ -0,0 +1,202 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"linkbio/internal/model"
+	"linkbio/internal/repo"
+	"linkbio/internal/util"
+)
+
+const (
+	// Lowercase letters and digits without the easily confused 0, o, 1, l
+	// and i; six of them give about 887 million codes.
+	shortCodeAlphabet    = "abcdefghjkmnpqrstuvwxyz23456789"
+	shortCodeLength      = 6
+	shortCodeAttempts    = 5
+	maxShortLinksPerLink = 10
+)
+
+var aliasRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{1,30}[a-z0-9]$`)
+
+// ShortLinkService manages the short codes that redirect to a user's links.
+type ShortLinkService struct {
+	shortLinkRepo repo.ShortLinkStore
+	bioRepo       repo.BioStore
+	domainRepo    repo.DomainStore
+}
+
+func NewShortLinkService(shortLinkRepo repo.ShortLinkStore, bioRepo repo.BioStore, domainRepo repo.DomainStore) *ShortLinkService {
+	return &ShortLinkService{shortLinkRepo: shortLinkRepo, bioRepo: bioRepo, domainRepo: domainRepo}
+}
+
+// ShortLinkInfo is a short link with the address to share, on the page's
+// custom domain when it has an active one. URL is empty while the page has
+// no route.
+type ShortLinkInfo struct {
+	*model.ShortLink
+	URL string `json:"url"`
+}
+
+type CreateShortLinkReq struct {
+	// Alias is a custom code; empty generates one.
+	Alias string `json:"alias"`
+}
+
+// ShortPath is where a short code is served.
+func ShortPath(code string) string {
+	return "/s/" + code
+}
+
+func (s *ShortLinkService) List(ctx context.Context, userID, linkID int64) ([]ShortLinkInfo, error) {
+	pageID, err := s.ownLink(ctx, userID, linkID)
+	if err != nil {
+		return nil, err
+	}
+	links, err := s.shortLinkRepo.ListByLink(ctx, linkID)
+	if err != nil {
+		return nil, err
+	}
+	base, err := s.origin(ctx, pageID)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]ShortLinkInfo, len(links))
+	for i, l := range links {
+		out[i] = shortLinkInfo(l, base)
+	}
+	return out, nil
+}
+
+// Create adds a short link to one of the user's links. Generated codes are
+// retried on collision; a taken alias is a conflict.
+func (s *ShortLinkService) Create(ctx context.Context, userID, linkID int64, req CreateShortLinkReq) (*ShortLinkInfo, error) {
+	pageID, err := s.ownLink(ctx, userID, linkID)
+	if err != nil {
+		return nil, err
+	}
+	alias := strings.ToLower(strings.TrimSpace(req.Alias))
+	if alias != "" && !aliasRegex.MatchString(alias) {
+		return nil, ErrShortLinkAlias
+	}
+	existing, err := s.shortLinkRepo.ListByLink(ctx, linkID)
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) >= maxShortLinksPerLink {
+		return nil, ErrShortLinkTooMany
+	}
+
+	var link *model.ShortLink
+	if alias != "" {
+		link, err = s.shortLinkRepo.Create(ctx, linkID, alias, true)
+		if pgCode(err) == pgUniqueViolation {
+			return nil, ErrShortLinkTaken
+		}
+	} else {
+		for i := 0; i < shortCodeAttempts; i++ {
+			code, genErr := util.RandomString(shortCodeLength, shortCodeAlphabet)
+			if genErr != nil {
+				return nil, genErr
+			}
+			link, err = s.shortLinkRepo.Create(ctx, linkID, code, false)
+			if pgCode(err) != pgUniqueViolation {
+				break
+			}
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	base, err := s.origin(ctx, pageID)
+	if err != nil {
+		return nil, err
+	}
+	info := shortLinkInfo(link, base)
+	return &info, nil
+}
+
+func (s *ShortLinkService) Delete(ctx context.Context, userID, linkID, id int64) error {
+	if _, err := s.ownLink(ctx, userID, linkID); err != nil {
+		return err
+	}
+	link, err := s.shortLinkRepo.GetByID(ctx, id)
+	if err != nil {
+		return notFound(err)
+	}
+	if link.LinkID != linkID {
+		return ErrNotFound
+	}
+	return s.shortLinkRepo.Delete(ctx, id)
+}
+
+// Resolve returns the link a short code served on host points to. Codes
+// work on the system domain and unknown hosts; on a custom domain only
+// while it is active and only for its owner's links.
+func (s *ShortLinkService) Resolve(ctx context.Context, host, code string) (int64, error) {
+	link, err := s.shortLinkRepo.GetByCode(ctx, strings.ToLower(code))
+	if err != nil {
+		return 0, notFound(err)
+	}
+
+	domain, err := s.domainRepo.GetByHostname(ctx, host)
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return link.LinkID, nil
+	case err != nil:
+		return 0, err
+	case domain.Status == "disabled":
+		return 0, ErrNotFound
+	case domain.IsSystem:
+		return link.LinkID, nil
+	case domain.Status != "active" || domain.UserID == nil:
+		return 0, ErrNotFound
+	}
+	ownerID, err := s.bioRepo.GetLinkOwnerID(ctx, link.LinkID)
+	if err != nil {
+		return 0, notFound(err)
+	}
+	if ownerID != *domain.UserID {
+		return 0, ErrNotFound
+	}
+	return link.LinkID, nil
+}
+
+// ownLink checks the user owns the link and returns its page.
+func (s *ShortLinkService) ownLink(ctx context.Context, userID, linkID int64) (int64, error) {
+	ownerID, err := s.bioRepo.GetLinkOwnerID(ctx, linkID)
+	if err != nil {
+		return 0, notFound(err)
+	}
+	if ownerID != userID {
+		return 0, ErrForbidden
+	}
+	pageID, err := s.bioRepo.GetLinkPageID(ctx, linkID)
+	if err != nil {
+		return 0, notFound(err)
+	}
+	return pageID, nil
+}
+
+// origin is the scheme and host the page is served from, or "" while it
+// has no route.
+func (s *ShortLinkService) origin(ctx context.Context, pageID int64) (string, error) {
+	url, _, err := CanonicalURL(ctx, s.domainRepo, pageID)
+	if err != nil || url == "" {
+		return "", err
+	}
+	return origin(url), nil
+}
+
+func shortLinkInfo(link *model.ShortLink, base string) ShortLinkInfo {
+	info := ShortLinkInfo{ShortLink: link}
+	if base != "" {
+		info.URL = base + ShortPath(link.Code)
+	}
+	return info
+}
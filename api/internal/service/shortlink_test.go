@@ -0,0 +1,45 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"linkbio/internal/repo/memory"
+	"linkbio/internal/service"
+)
+
+func TestResolveChecksDomainOwner(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t)
+	domains := memory.NewDomainRepo(f.db)
+	shortLinks := memory.NewShortLinkRepo(f.db)
+	svc := service.NewShortLinkService(shortLinks, f.bio, domains)
+
+	alice := f.user(t, "alice@example.com")
+	bob := f.user(t, "bob@example.com")
+	f.page(t, alice)
+	block, err := f.bioSvc.AddBlock(ctx, alice.ID, "link_group", nil)
+	must(t, err)
+	link, err := f.bioSvc.AddLink(ctx, alice.ID, block.Group.ID, "Shop", "https://example.com/shop")
+	must(t, err)
+	_, err = shortLinks.Create(ctx, link.ID, "shop", true)
+	must(t, err)
+
+	_, err = domains.Create(ctx, nil, "linkbio.test", true)
+	must(t, err)
+	_, err = domains.Create(ctx, &alice.ID, "alice.example", false)
+	must(t, err)
+	_, err = domains.Create(ctx, &bob.ID, "bob.example", false)
+	must(t, err)
+
+	for _, host := range []string{"linkbio.test", "alice.example", "api.unknown.test"} {
+		got, err := svc.Resolve(ctx, host, "SHOP")
+		if err != nil || got != link.ID {
+			t.Errorf("Resolve on %s = %d, %v; want %d", host, got, err, link.ID)
+		}
+	}
+	if _, err := svc.Resolve(ctx, "bob.example", "shop"); !errors.Is(err, service.ErrNotFound) {
+		t.Errorf("Resolve on another user's domain = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"linkbio/internal/model"
+	"linkbio/internal/targeting"
+	"linkbio/internal/urlpolicy"
+)
+
+const (
+	maxLinkRules  = 10
+	maxRuleValues = 50
+)
+
+var (
+	countryRegex  = regexp.MustCompile(`^[A-Z]{2}$`)
+	languageRegex = regexp.MustCompile(`^[a-z]{2,3}$`)
+)
+
+// checkLinkRules validates a link's targeting rules, reporting problems
+// under field. Values are normalized to the case they are matched in and
+// rule URLs pass the same policy as link URLs. No rules are stored as nil.
+func checkLinkRules(policy *urlpolicy.Policy, field string, raw json.RawMessage) (json.RawMessage, error) {
+	if len(bytes.TrimSpace(raw)) == 0 || bytes.Equal(bytes.TrimSpace(raw), []byte("null")) {
+		return nil, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	var rules []targeting.Rule
+	if err := dec.Decode(&rules); err != nil {
+		return nil, ErrLinkRules.WithField(field, "format", "link.invalid_rules")
+	}
+	if len(rules) == 0 {
+		return nil, nil
+	}
+	if len(rules) > maxLinkRules {
+		return nil, ErrLinkRules.WithField(field, "length", "link.too_many_rules")
+	}
+
+	for i := range rules {
+		r := &rules[i]
+		at := fmt.Sprintf("%s[%d]", field, i)
+		if len(r.Countries)+len(r.OS)+len(r.Devices)+len(r.Languages) == 0 {
+			return nil, ErrLinkRules.WithField(at, "required", "link.rule_without_condition")
+		}
+		checks := []struct {
+			name   string
+			values []string
+			valid  func(string) bool
+			norm   func(string) string
+		}{
+			{"country", r.Countries, countryRegex.MatchString, strings.ToUpper},
+			{"os", r.OS, oneOf(targeting.OSes), strings.ToLower},
+			{"device", r.Devices, oneOf(targeting.Devices), strings.ToLower},
+			{"language", r.Languages, languageRegex.MatchString, strings.ToLower},
+		}
+		for _, c := range checks {
+			if len(c.values) > maxRuleValues {
+				return nil, ErrLinkRules.WithField(at+"."+c.name, "length", "link.invalid_rules")
+			}
+			for j, v := range c.values {
+				v = c.norm(strings.TrimSpace(v))
+				if !c.valid(v) {
+					return nil, ErrLinkRules.WithField(fmt.Sprintf("%s.%s[%d]", at, c.name, j), "format", "link.invalid_rules")
+				}
+				c.values[j] = v
+			}
+		}
+		url, err := checkLinkURL(policy, at+".url", r.URL)
+		if err != nil {
+			return nil, err
+		}
+		r.URL = url
+	}
+	return json.Marshal(rules)
+}
+
+// compileRules checks a link's rule URLs against the policy again, as
+// compile does for the link's own URL.
+func (s *CompilerService) compileRules(l *model.Link) ([]targeting.Rule, error) {
+	rules := decodeLinkRules(l.Rules)
+	for i := range rules {
+		url, err := checkLinkURL(s.urlPolicy, fmt.Sprintf("links.%d.rules[%d].url", l.ID, i), rules[i].URL)
+		if err != nil {
+			return nil, err
+		}
+		rules[i].URL = url
+	}
+	return rules, nil
+}
+
+// decodeLinkRules reads stored rules; rules that no longer decode are
+// ignored and the link goes to its own URL.
+func decodeLinkRules(raw json.RawMessage) []targeting.Rule {
+	if len(raw) == 0 {
+		return nil
+	}
+	var rules []targeting.Rule
+	if err := json.Unmarshal(raw, &rules); err != nil {
+		return nil
+	}
+	return rules
+}
+
+func oneOf(values []string) func(string) bool {
+	return func(v string) bool {
+		for _, want := range values {
+			if v == want {
+				return true
+			}
+		}
+		return false
+	}
+}
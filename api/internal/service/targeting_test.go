@@ -0,0 +1,119 @@
+package service_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"linkbio/internal/apperr"
+	"linkbio/internal/service"
+)
+
+// saveRules saves one link with the given rules and returns its stored
+// rules.
+func saveRules(t *testing.T, rules string) (json.RawMessage, error) {
+	t.Helper()
+	ctx := context.Background()
+	f := newFixture(t)
+	page := f.page(t, f.user(t, "alice@example.com"))
+	group, err := f.blocks.CreateLinkGroup(ctx, page.ID, nil, "list")
+	must(t, err)
+
+	err = f.pageSvc.Save(ctx, page.ID, &service.SaveRequest{
+		Links: []service.SaveLinkReq{{
+			GroupID: group.ID, Title: "App", URL: "https://example.com", SortKey: "a", IsActive: true,
+			Rules: json.RawMessage(rules),
+		}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	draft, err := f.pageSvc.GetDraft(ctx, page.ID)
+	must(t, err)
+	return draft.Links[group.ID][0].Rules, nil
+}
+
+func TestSaveNormalizesLinkRules(t *testing.T) {
+	cases := []struct {
+		in, want string
+	}{
+		{``, ``},
+		{`null`, ``},
+		{`[]`, ``},
+		{
+			`[{"country":[" vn ","th"],"os":["IOS"],"url":"apps.apple.com/vn/app"}]`,
+			`[{"country":["VN","TH"],"os":["ios"],"url":"https://apps.apple.com/vn/app"}]`,
+		},
+		{
+			`[{"device":["Mobile"],"language":["VI"],"url":"tel:+84 912 345 678"},{"os":["android"],"url":"https://play.google.com"}]`,
+			`[{"device":["mobile"],"language":["vi"],"url":"tel:+84912345678"},{"os":["android"],"url":"https://play.google.com"}]`,
+		},
+	}
+	for _, c := range cases {
+		got, err := saveRules(t, c.in)
+		if err != nil {
+			t.Errorf("rules %s: Save = %v", c.in, err)
+			continue
+		}
+		if string(got) != c.want {
+			t.Errorf("rules %s stored as %s, want %s", c.in, got, c.want)
+		}
+	}
+}
+
+func TestSaveRejectsBadLinkRules(t *testing.T) {
+	tooMany := make([]string, 11)
+	for i := range tooMany {
+		tooMany[i] = `{"country":["VN"],"url":"https://example.vn"}`
+	}
+	cases := []struct {
+		rules string
+		err   error
+		field string
+	}{
+		{`{"country":["VN"]}`, service.ErrLinkRules, "links[0].rules"},
+		{`[{"region":["VN"],"url":"https://example.vn"}]`, service.ErrLinkRules, "links[0].rules"},
+		{"[" + strings.Join(tooMany, ",") + "]", service.ErrLinkRules, "links[0].rules"},
+		{`[{"url":"https://example.vn"}]`, service.ErrLinkRules, "links[0].rules[0]"},
+		{`[{"country":["VNM"],"url":"https://example.vn"}]`, service.ErrLinkRules, "links[0].rules[0].country[0]"},
+		{`[{"os":["symbian"],"url":"https://example.vn"}]`, service.ErrLinkRules, "links[0].rules[0].os[0]"},
+		{`[{"device":["mobile","tv"],"url":"https://example.vn"}]`, service.ErrLinkRules, "links[0].rules[0].device[1]"},
+		{`[{"language":["vi-VN"],"url":"https://example.vn"}]`, service.ErrLinkRules, "links[0].rules[0].language[0]"},
+		{`[{"country":["VN"],"url":"https://example.vn"},{"os":["ios"],"url":"javascript:alert(1)"}]`, service.ErrLinkURL, "links[0].rules[1].url"},
+		{`[{"os":["ios"]}]`, service.ErrLinkURL, "links[0].rules[0].url"},
+	}
+	for _, c := range cases {
+		_, err := saveRules(t, c.rules)
+		if !errors.Is(err, c.err) {
+			t.Errorf("rules %s: Save = %v, want %v", c.rules, err, c.err)
+			continue
+		}
+		if field := errField(err); field != c.field {
+			t.Errorf("rules %s: field %q, want %q", c.rules, field, c.field)
+		}
+	}
+}
+
+func TestSaveRejectsTooManyRuleValues(t *testing.T) {
+	countries := make([]string, 51)
+	for i := range countries {
+		countries[i] = fmt.Sprintf(`"%c%c"`, 'A'+i/26, 'A'+i%26)
+	}
+	rules := `[{"country":[` + strings.Join(countries, ",") + `],"url":"https://example.vn"}]`
+	_, err := saveRules(t, rules)
+	if field := errField(err); !errors.Is(err, service.ErrLinkRules) || field != "links[0].rules[0].country" {
+		t.Errorf("Save = %v at %q, want ErrLinkRules at links[0].rules[0].country", err, field)
+	}
+}
+
+// errField returns the first field an application error points at.
+func errField(err error) string {
+	var appErr *apperr.Error
+	if !errors.As(err, &appErr) || len(appErr.Fields) == 0 {
+		return ""
+	}
+	return appErr.Fields[0].Field
+}
@@ -1,21 +1,31 @@
 package service
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"log"
+	"strings"
+	"unicode/utf8"
 
+	"linkbio/internal/apperr"
 	"linkbio/internal/model"
 	"linkbio/internal/repo"
+	"linkbio/internal/theme"
 	"linkbio/internal/util"
 )
 
 type ThemeService struct {
-	themeRepo *repo.ThemeRepo
+	themeRepo repo.ThemeStore
+	pageRepo  repo.PageStore
+	userRepo  repo.UserStore
+	assetRepo repo.AssetStore
+	compiler  *CompilerService
 }
 
-func NewThemeService(themeRepo *repo.ThemeRepo) *ThemeService {
-	return &ThemeService{themeRepo: themeRepo}
+func NewThemeService(themeRepo repo.ThemeStore, pageRepo repo.PageStore, userRepo repo.UserStore, assetRepo repo.AssetStore, compiler *CompilerService) *ThemeService {
+	return &ThemeService{themeRepo: themeRepo, pageRepo: pageRepo, userRepo: userRepo, assetRepo: assetRepo, compiler: compiler}
 }
 
 func (s *ThemeService) ListPresets(ctx context.Context, tier string) ([]*model.ThemePreset, error) {
@@ -26,65 +36,520 @@ func (s *ThemeService) GetPreset(ctx context.Context, id int64) (*model.ThemePre
 	return s.themeRepo.GetPresetByID(ctx, id)
 }
 
-func (s *ThemeService) CreateOrUpdateCustom(ctx context.Context, userID, presetID int64, patch json.RawMessage) (*model.ThemeCustom, error) {
-	// Generate hash for deduplication
-	hashInput := fmt.Sprintf("%d:%s", presetID, string(patch))
-	hash := util.SHA256(hashInput)
+// CustomInput is a custom theme as the editor saves it.
+type CustomInput struct {
+	PresetID int64
+	Name     string
+	Patch    json.RawMessage
+}
+
+func (s *ThemeService) ListCustom(ctx context.Context, userID int64) ([]*model.ThemeCustom, error) {
+	themes, err := s.themeRepo.ListCustomByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if themes == nil {
+		themes = []*model.ThemeCustom{}
+	}
+	return themes, nil
+}
+
+func (s *ThemeService) GetCustom(ctx context.Context, userID, id int64) (*model.ThemeCustom, error) {
+	custom, err := s.themeRepo.GetCustomByID(ctx, id)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	if custom.UserID != userID {
+		return nil, ErrNotFound
+	}
+	return custom, nil
+}
+
+// SavedCustom is a saved custom theme with the readability problems of
+// its compiled config. Saving is never blocked by them.
+type SavedCustom struct {
+	*model.ThemeCustom
+	Lint theme.LintReport `json:"lint"`
+}
+
+func (s *ThemeService) saved(ctx context.Context, custom *model.ThemeCustom) *SavedCustom {
+	return &SavedCustom{ThemeCustom: custom, Lint: lintTheme(ctx, s.assetRepo, custom.CompiledConfig)}
+}
+
+// lintTheme checks a compiled theme's contrast, measuring a wallpaper by
+// the dominant color stored with its asset.
+func lintTheme(ctx context.Context, assets repo.AssetStore, config json.RawMessage) theme.LintReport {
+	return theme.Lint(config, func(id int64) (string, bool) {
+		asset, err := assets.GetByID(ctx, id)
+		if err != nil || asset.DominantColor == nil {
+			return "", false
+		}
+		return *asset.DominantColor, true
+	})
+}
 
-	// Check if user already has a custom theme
-	existing, err := s.themeRepo.GetCustomByUserID(ctx, userID)
-	if err == nil && existing != nil {
-		// Update existing custom theme
-		compiled := s.compileTheme(ctx, presetID, patch)
-		err = s.themeRepo.UpdateCustom(ctx, existing.ID, patch, compiled, hash)
+// CreateCustom adds a theme to the user's library, pinned to the preset's
+// current version. Saving content the user already has returns that theme
+// instead, so applying the same theme to several pages shares one row,
+// unless a different name is asked for.
+func (s *ThemeService) CreateCustom(ctx context.Context, userID int64, in CustomInput) (*SavedCustom, error) {
+	preset, err := s.themeRepo.GetPresetByID(ctx, in.PresetID)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	if !presetVisibleTo(preset, userID) {
+		return nil, ErrNotFound
+	}
+	name, err := customName(in.Name, preset.Name)
+	if err != nil {
+		return nil, err
+	}
+	if len(in.Patch) == 0 {
+		in.Patch = json.RawMessage(`{}`)
+	}
+
+	hash := customHash(preset.ID, preset.CurrentVersionID, in.Patch)
+	if existing, err := s.themeRepo.GetCustomByHash(ctx, userID, hash); err == nil {
+		if strings.TrimSpace(in.Name) == "" || (existing.Name != nil && *existing.Name == name) {
+			return s.saved(ctx, existing), nil
+		}
+	}
+
+	compiled := compileTheme(preset.Config, in.Patch)
+	custom, err := s.themeRepo.CreateCustom(ctx, userID, preset.ID, preset.CurrentVersionID, name, in.Patch, compiled, hash)
+	if err != nil {
+		return nil, err
+	}
+	return s.saved(ctx, custom), nil
+}
+
+// UpdateCustom changes a theme's patch and, if given, its name. The theme
+// keeps its preset version until the user upgrades it, and every page
+// using it picks up the change.
+func (s *ThemeService) UpdateCustom(ctx context.Context, userID, id int64, name *string, patch json.RawMessage) (*SavedCustom, error) {
+	custom, err := s.GetCustom(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(patch) > 0 {
+		base, err := customBase(ctx, s.themeRepo, custom)
 		if err != nil {
 			return nil, err
 		}
-		// Return updated theme
-		return s.themeRepo.GetCustomByID(ctx, existing.ID)
+		compiled := compileTheme(base, patch)
+		hash := customHash(custom.BasedOnPresetID, custom.PresetVersionID, patch)
+		if err := s.themeRepo.UpdateCustom(ctx, custom.ID, patch, compiled, hash); err != nil {
+			return nil, err
+		}
+		s.retheme(ctx, userID, custom.ID)
 	}
+	if name != nil {
+		if err := s.rename(ctx, userID, custom.ID, *name); err != nil {
+			return nil, err
+		}
+	}
+	custom, err = s.themeRepo.GetCustomByID(ctx, custom.ID)
+	if err != nil {
+		return nil, err
+	}
+	return s.saved(ctx, custom), nil
+}
 
-	// Create new custom theme
-	compiled := s.compileTheme(ctx, presetID, patch)
-	custom, err := s.themeRepo.CreateCustom(ctx, userID, presetID, patch, hash)
+// DuplicateCustom copies a theme into the library under a new name, so it
+// can diverge from the original.
+func (s *ThemeService) DuplicateCustom(ctx context.Context, userID, id int64, name string) (*model.ThemeCustom, error) {
+	custom, err := s.GetCustom(ctx, userID, id)
 	if err != nil {
 		return nil, err
 	}
-	
-	// Update compiled config
-	if compiled != nil {
-		_ = s.themeRepo.UpdateCustom(ctx, custom.ID, patch, compiled, hash)
+	fallback := "Copy"
+	if custom.Name != nil {
+		fallback = *custom.Name + " (copy)"
 	}
-	
-	return custom, nil
+	name, err = customName(name, fallback)
+	if err != nil {
+		return nil, err
+	}
+	return s.themeRepo.CreateCustom(ctx, userID, custom.BasedOnPresetID, custom.PresetVersionID, name,
+		custom.Patch, custom.CompiledConfig, custom.Hash)
 }
 
-func (s *ThemeService) GetUserCustomTheme(ctx context.Context, userID int64) (*model.ThemeCustom, error) {
-	return s.themeRepo.GetCustomByUserID(ctx, userID)
+func (s *ThemeService) rename(ctx context.Context, userID, id int64, name string) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return apperr.Validation("request.required", "required fields missing").WithField("name", "required", "request.required")
+	}
+	name, err := customName(name, "")
+	if err != nil {
+		return err
+	}
+	return notFoundOr(s.themeRepo.RenameCustom(ctx, id, userID, name))
 }
 
-func (s *ThemeService) DeleteCustomTheme(ctx context.Context, id, userID int64) error {
-	return s.themeRepo.DeleteCustom(ctx, id, userID)
+// DeleteCustom removes a theme from the library. A theme that pages still
+// use is kept unless fallback is set, which switches those pages back to
+// the theme's preset; the ids of those pages are returned.
+func (s *ThemeService) DeleteCustom(ctx context.Context, userID, id int64, fallback bool) ([]int64, error) {
+	pageIDs, err := s.themeRepo.DeleteCustom(ctx, id, userID, fallback)
+	if pgCode(err) == pgForeignKeyViolation {
+		return nil, ErrCustomThemeInUse
+	}
+	if err != nil {
+		return nil, notFound(err)
+	}
+	for _, pageID := range pageIDs {
+		if err := s.fitMode(ctx, userID, pageID); err != nil {
+			log.Printf("[Theme] page %d mode: %v", pageID, err)
+		}
+	}
+	s.retheme(ctx, userID, id)
+	if pageIDs == nil {
+		pageIDs = []int64{}
+	}
+	return pageIDs, nil
 }
 
-// compileTheme merges preset with patch (simplified version)
-func (s *ThemeService) compileTheme(ctx context.Context, presetID int64, patch json.RawMessage) json.RawMessage {
-	preset, err := s.themeRepo.GetPresetByID(ctx, presetID)
+// fitMode switches a page that fell back to its preset to a mode the
+// preset supports, if its mode is not one.
+func (s *ThemeService) fitMode(ctx context.Context, userID, pageID int64) error {
+	page, err := s.pageRepo.GetByID(ctx, pageID)
 	if err != nil {
-		return nil
+		return err
+	}
+	preset, err := s.themeRepo.GetPresetByID(ctx, page.ThemePresetID)
+	if err != nil {
+		return err
+	}
+	mode, err := selectMode(preset.Config, "", page.ThemeMode)
+	if err != nil || mode == page.ThemeMode {
+		return err
+	}
+	return s.pageRepo.ApplyTheme(ctx, page.ID, userID, preset.ID, nil, mode)
+}
+
+// retheme brings the user's pages published with a custom theme up to
+// date with it. The theme change itself is already saved, so failures are
+// only logged; the next publish fixes those pages.
+func (s *ThemeService) retheme(ctx context.Context, userID, customID int64) {
+	pages, err := s.pageRepo.ListByUser(ctx, userID)
+	if err != nil {
+		log.Printf("[Theme] retheme %d: %v", customID, err)
+		return
+	}
+	for _, p := range pages {
+		if err := s.compiler.Retheme(ctx, p.ID, customID); err != nil {
+			log.Printf("[Theme] retheme %d on page %d: %v", customID, p.ID, err)
+		}
+	}
+}
+
+// customName trims name, defaulting to fallback, and checks its length.
+func customName(name, fallback string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = fallback
+	}
+	if utf8.RuneCountInString(name) > maxPresetNameLength {
+		return "", apperr.Validation("theme.name_too_long", "name is too long").WithField("name", "too_long", "theme.name_too_long")
+	}
+	return name, nil
+}
+
+// ApplyTheme is a page's new theme selection. PresetID may be left zero
+// when CustomID is set; Mode defaults to the page's current mode when the
+// theme supports it.
+type ApplyTheme struct {
+	PageID   int64
+	PresetID int64
+	CustomID *int64
+	Mode     string
+}
+
+// AppliedTheme is the saved selection with the compiled theme, so the
+// editor can preview it without another round trip.
+type AppliedTheme struct {
+	PageID   int64           `json:"page_id"`
+	PresetID int64           `json:"preset_id"`
+	CustomID *int64          `json:"custom_id"`
+	Mode     string          `json:"mode"`
+	Theme    json.RawMessage `json:"theme"`
+}
+
+// Apply switches a page to a preset or one of the user's custom themes.
+// Pro presets, and custom themes based on them, need a Pro subscription.
+func (s *ThemeService) Apply(ctx context.Context, userID int64, in ApplyTheme) (*AppliedTheme, error) {
+	page, err := s.pageRepo.GetByID(ctx, in.PageID)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	if page.UserID != userID {
+		return nil, ErrForbidden
+	}
+
+	var custom *model.ThemeCustom
+	if in.CustomID != nil {
+		custom, err = s.themeRepo.GetCustomByID(ctx, *in.CustomID)
+		if err != nil {
+			return nil, notFound(err)
+		}
+		if custom.UserID != userID {
+			return nil, ErrForbidden
+		}
+		if in.PresetID == 0 {
+			in.PresetID = custom.BasedOnPresetID
+		}
+		if custom.BasedOnPresetID != in.PresetID {
+			return nil, ErrCustomPresetMismatch
+		}
+	}
+	if in.PresetID == 0 {
+		return nil, apperr.Validation("request.required", "required fields missing").WithField("preset_id", "required", "request.required")
 	}
-	
-	// Deep merge preset.Config with patch
+
+	preset, err := s.themeRepo.GetPresetByID(ctx, in.PresetID)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	if !presetVisibleTo(preset, userID) {
+		return nil, ErrNotFound
+	}
+	if preset.Tier == "pro" {
+		isPro, err := s.userRepo.IsPro(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		if !isPro {
+			return nil, ErrProRequired
+		}
+	}
+
+	config := preset.Config
+	if custom != nil {
+		if config, err = customThemeConfig(ctx, s.themeRepo, custom); err != nil {
+			return nil, err
+		}
+	}
+	mode, err := selectMode(config, in.Mode, page.ThemeMode)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.pageRepo.ApplyTheme(ctx, page.ID, userID, preset.ID, in.CustomID, mode)
+	if err != nil {
+		// The page or custom theme changed hands or vanished meanwhile.
+		return nil, notFound(err)
+	}
+
+	return &AppliedTheme{
+		PageID:   page.ID,
+		PresetID: preset.ID,
+		CustomID: in.CustomID,
+		Mode:     mode,
+		Theme:    config,
+	}, nil
+}
+
+// selectMode checks mode against the theme's meta.supports.modes. An
+// empty mode keeps current if the theme supports it, else picks the
+// theme's first mode. "auto" needs both light and dark.
+func selectMode(config json.RawMessage, mode, current string) (string, error) {
+	supported := []string{"light", "dark", "compact"}
+	if meta, err := theme.ReadMeta(config); err == nil && len(meta.Supports.Modes) > 0 {
+		supported = meta.Supports.Modes
+	}
+	if containsString(supported, "light") && containsString(supported, "dark") {
+		supported = append(supported[:len(supported):len(supported)], theme.ModeAuto)
+	}
+	if mode == "" {
+		mode = current
+		if !containsString(supported, mode) {
+			mode = supported[0]
+		}
+	}
+	if !containsString(supported, mode) {
+		return "", ErrModeUnsupported
+	}
+	return mode, nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// ThemeUpgrade describes moving a custom theme onto the latest version of
+// its preset.
+type ThemeUpgrade struct {
+	CustomID    int64           `json:"custom_id"`
+	FromVersion *string         `json:"from_version"`
+	ToVersion   *string         `json:"to_version"`
+	Available   bool            `json:"upgrade_available"`
+	MissingKeys []string        `json:"missing_keys"` // dropped from the patch
+	Patch       json.RawMessage `json:"patch"`        // the patch after upgrading
+
+	custom *model.ThemeCustom
+	target *model.ThemePresetVersion
+}
+
+// PreviewUpgrade reports what upgrading a custom theme would change
+// without saving anything.
+func (s *ThemeService) PreviewUpgrade(ctx context.Context, userID, customID int64) (*ThemeUpgrade, error) {
+	return s.planUpgrade(ctx, userID, customID)
+}
+
+// Upgrade re-bases a custom theme's patch onto the preset's current
+// version and recompiles it.
+func (s *ThemeService) Upgrade(ctx context.Context, userID, customID int64) (*ThemeUpgrade, error) {
+	plan, err := s.planUpgrade(ctx, userID, customID)
+	if err != nil {
+		return nil, err
+	}
+	if !plan.Available {
+		return nil, ErrThemeUpToDate
+	}
+
+	custom := plan.custom
+	compiled := compileTheme(plan.target.Config, plan.Patch)
+	hash := customHash(custom.BasedOnPresetID, &plan.target.ID, plan.Patch)
+	err = s.themeRepo.RebaseCustom(ctx, custom.ID, plan.target.ID, plan.Patch, compiled, hash)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	s.retheme(ctx, userID, custom.ID)
+	return plan, nil
+}
+
+func (s *ThemeService) planUpgrade(ctx context.Context, userID, customID int64) (*ThemeUpgrade, error) {
+	custom, err := s.themeRepo.GetCustomByID(ctx, customID)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	if custom.UserID != userID {
+		return nil, ErrNotFound
+	}
+	preset, err := s.themeRepo.GetPresetByID(ctx, custom.BasedOnPresetID)
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	plan := &ThemeUpgrade{
+		CustomID:    custom.ID,
+		ToVersion:   preset.Version,
+		MissingKeys: []string{},
+		Patch:       custom.Patch,
+		custom:      custom,
+	}
+	if custom.PresetVersionID != nil {
+		from, err := s.themeRepo.GetPresetVersion(ctx, *custom.PresetVersionID)
+		if err != nil {
+			return nil, notFound(err)
+		}
+		plan.FromVersion = &from.Version
+	}
+	if preset.CurrentVersionID == nil ||
+		(custom.PresetVersionID != nil && *custom.PresetVersionID == *preset.CurrentVersionID) {
+		return plan, nil
+	}
+
+	base, err := customBase(ctx, s.themeRepo, custom)
+	if err != nil {
+		return nil, err
+	}
+	target, err := s.themeRepo.GetPresetVersion(ctx, *preset.CurrentVersionID)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	rebased, err := theme.Rebase(custom.Patch, base, target.Config)
+	if err != nil {
+		return nil, apperr.Internal(err)
+	}
+
+	plan.Available = true
+	plan.MissingKeys = rebased.Missing
+	plan.Patch = rebased.Patch
+	plan.target = target
+	return plan, nil
+}
+
+// customBase returns the preset config a custom theme's patch applies to:
+// its pinned version, or the preset itself for themes saved before
+// versioning.
+func customBase(ctx context.Context, themeRepo repo.ThemeStore, custom *model.ThemeCustom) (json.RawMessage, error) {
+	if custom.PresetVersionID != nil {
+		v, err := themeRepo.GetPresetVersion(ctx, *custom.PresetVersionID)
+		if err != nil {
+			return nil, notFound(err)
+		}
+		return v.Config, nil
+	}
+	preset, err := themeRepo.GetPresetByID(ctx, custom.BasedOnPresetID)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return preset.Config, nil
+}
+
+// customThemeConfig returns a custom theme's compiled config, compiling
+// it from its preset version when it has not been stored yet.
+func customThemeConfig(ctx context.Context, themeRepo repo.ThemeStore, custom *model.ThemeCustom) (json.RawMessage, error) {
+	if custom.CompiledConfig != nil {
+		return custom.CompiledConfig, nil
+	}
+	base, err := customBase(ctx, themeRepo, custom)
+	if err != nil {
+		return nil, err
+	}
+	return compileTheme(base, custom.Patch), nil
+}
+
+// customHash identifies a patch on a preset version for deduplication.
+// The patch is canonicalized first, so whitespace and key order do not
+// matter.
+func customHash(presetID int64, versionID *int64, patch json.RawMessage) string {
+	canonical := canonicalJSON(patch)
+	if versionID == nil {
+		return util.SHA256(fmt.Sprintf("%d:%s", presetID, canonical))
+	}
+	return util.SHA256(fmt.Sprintf("%d@%d:%s", presetID, *versionID, canonical))
+}
+
+// canonicalJSON re-encodes doc compactly with sorted keys, keeping numbers
+// as written. Invalid JSON is returned unchanged.
+func canonicalJSON(doc json.RawMessage) string {
+	dec := json.NewDecoder(bytes.NewReader(doc))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return string(doc)
+	}
+	out, err := json.Marshal(v)
+	if err != nil {
+		return string(doc)
+	}
+	return string(out)
+}
+
+// compileTheme merges a preset config with a patch (simplified version)
+func compileTheme(config, patch json.RawMessage) json.RawMessage {
+	// Deep merge preset config with patch
 	var presetMap map[string]interface{}
 	var patchMap map[string]interface{}
-	
-	if err := json.Unmarshal(preset.Config, &presetMap); err != nil {
+
+	if err := json.Unmarshal(config, &presetMap); err != nil {
 		return nil
 	}
+	if len(patch) == 0 {
+		return config
+	}
 	if err := json.Unmarshal(patch, &patchMap); err != nil {
 		return nil
 	}
-	
+
 	merged := deepMerge(presetMap, patchMap)
 	compiled, _ := json.Marshal(merged)
 	return compiled
@@ -0,0 +1,159 @@
+package service_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"linkbio/internal/model"
+	"linkbio/internal/service"
+)
+
+func TestCreateCustomDedup(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t)
+	alice := f.user(t, "alice@example.com")
+	page := f.page(t, alice)
+
+	first, err := f.themeSvc.CreateCustom(ctx, alice.ID, service.CustomInput{
+		PresetID: page.ThemePresetID, Patch: json.RawMessage(`{"a":1,"b":{"c":2}}`),
+	})
+	must(t, err)
+
+	// Same content written differently is the same theme.
+	again, err := f.themeSvc.CreateCustom(ctx, alice.ID, service.CustomInput{
+		PresetID: page.ThemePresetID, Patch: json.RawMessage("{ \"b\": {\"c\": 2},\n \"a\": 1 }"),
+	})
+	must(t, err)
+	if again.ID != first.ID {
+		t.Errorf("reordered patch created theme %d, want %d", again.ID, first.ID)
+	}
+
+	// Asking for another name keeps both.
+	named, err := f.themeSvc.CreateCustom(ctx, alice.ID, service.CustomInput{
+		PresetID: page.ThemePresetID, Name: "Evening", Patch: json.RawMessage(`{"a":1,"b":{"c":2}}`),
+	})
+	must(t, err)
+	if named.ID == first.ID || named.Name == nil || *named.Name != "Evening" {
+		t.Errorf("named copy = %+v, want a new theme called Evening", named.ThemeCustom)
+	}
+}
+
+func TestDeleteCustomMissing(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t)
+	alice := f.user(t, "alice@example.com")
+	bob := f.user(t, "bob@example.com")
+	page := f.page(t, alice)
+
+	custom, err := f.themeSvc.CreateCustom(ctx, alice.ID, service.CustomInput{PresetID: page.ThemePresetID})
+	must(t, err)
+	if _, err := f.themeSvc.DeleteCustom(ctx, bob.ID, custom.ID, false); !errors.Is(err, service.ErrNotFound) {
+		t.Errorf("DeleteCustom by another user = %v, want ErrNotFound", err)
+	}
+	_, err = f.themeSvc.DeleteCustom(ctx, alice.ID, custom.ID, false)
+	must(t, err)
+	if _, err := f.themeSvc.DeleteCustom(ctx, alice.ID, custom.ID, false); !errors.Is(err, service.ErrNotFound) {
+		t.Errorf("second DeleteCustom = %v, want ErrNotFound", err)
+	}
+}
+
+// published reads a page's publish cache.
+func (f *fixture) published(t *testing.T, pageID int64) (*service.CompiledPage, string) {
+	t.Helper()
+	cached, err := f.pages.GetPublishCache(context.Background(), pageID)
+	must(t, err)
+	var compiled service.CompiledPage
+	must(t, json.Unmarshal(cached.CompiledJSON, &compiled))
+	return &compiled, cached.Hash
+}
+
+func TestUpdateCustomReachesPublishedPages(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t)
+	alice := f.user(t, "alice@example.com")
+	page := f.page(t, alice)
+	other, err := f.pages.Create(ctx, alice.ID, page.ThemePresetID, "Other")
+	must(t, err)
+
+	custom, err := f.themeSvc.CreateCustom(ctx, alice.ID, service.CustomInput{
+		PresetID: page.ThemePresetID, Patch: json.RawMessage(`{"colors":{"primary":"#111111"}}`),
+	})
+	must(t, err)
+	_, err = f.themeSvc.Apply(ctx, alice.ID, service.ApplyTheme{PageID: page.ID, CustomID: &custom.ID})
+	must(t, err)
+	_, err = f.compiler.Publish(ctx, page.ID)
+	must(t, err)
+	_, err = f.compiler.Publish(ctx, other.ID)
+	must(t, err)
+	_, otherHash := f.published(t, other.ID)
+
+	// A draft edit made after publishing stays unpublished.
+	draft, err := f.pages.GetByID(ctx, page.ID)
+	must(t, err)
+	title := "Draft title"
+	draft.Title = &title
+	must(t, f.pages.Update(ctx, draft))
+
+	_, err = f.themeSvc.UpdateCustom(ctx, alice.ID, custom.ID, nil, json.RawMessage(`{"colors":{"primary":"#222222"}}`))
+	must(t, err)
+
+	compiled, _ := f.published(t, page.ID)
+	if !strings.Contains(string(compiled.Theme), "#222222") {
+		t.Errorf("published theme = %s, want the updated patch", compiled.Theme)
+	}
+	if compiled.Page.Title == nil || *compiled.Page.Title != "Page" {
+		t.Errorf("published title = %v, want the published one", compiled.Page.Title)
+	}
+	if compiled.Page.ThemeCustomID == nil || *compiled.Page.ThemeCustomID != custom.ID {
+		t.Errorf("published custom theme = %v, want %d", compiled.Page.ThemeCustomID, custom.ID)
+	}
+	if public := compiled.Public(); public.Page.ThemeCustomID != nil || public.Page.ThemePresetID != 0 {
+		t.Error("Public kept the theme ids")
+	}
+	if _, hash := f.published(t, other.ID); hash != otherHash {
+		t.Error("page published with another theme was rewritten")
+	}
+}
+
+func TestDeleteCustomFallbackFitsMode(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t)
+	alice := f.user(t, "alice@example.com")
+	preset, err := f.db.SeedPreset(model.ThemePreset{
+		Key: "light_only", Name: "Light", Tier: "free",
+		Config: json.RawMessage(`{"meta":{"supports":{"modes":["light"]}}}`),
+	})
+	must(t, err)
+	page, err := f.pages.Create(ctx, alice.ID, preset.ID, "Page")
+	must(t, err)
+
+	custom, err := f.themeSvc.CreateCustom(ctx, alice.ID, service.CustomInput{
+		PresetID: preset.ID, Patch: json.RawMessage(`{"meta":{"supports":{"modes":["light","dark"]}}}`),
+	})
+	must(t, err)
+	_, err = f.themeSvc.Apply(ctx, alice.ID, service.ApplyTheme{PageID: page.ID, CustomID: &custom.ID, Mode: "dark"})
+	must(t, err)
+	_, err = f.compiler.Publish(ctx, page.ID)
+	must(t, err)
+
+	pageIDs, err := f.themeSvc.DeleteCustom(ctx, alice.ID, custom.ID, true)
+	must(t, err)
+	if len(pageIDs) != 1 || pageIDs[0] != page.ID {
+		t.Fatalf("fell back = %v, want [%d]", pageIDs, page.ID)
+	}
+
+	got, err := f.pages.GetByID(ctx, page.ID)
+	must(t, err)
+	if got.ThemeCustomID != nil || got.ThemePresetID != preset.ID || got.ThemeMode != "light" {
+		t.Errorf("page theme = preset %d custom %v mode %q, want preset %d in light mode",
+			got.ThemePresetID, got.ThemeCustomID, got.ThemeMode, preset.ID)
+	}
+	compiled, _ := f.published(t, page.ID)
+	if compiled.Page.ThemeCustomID != nil || compiled.Page.Mode != "light" || strings.Contains(string(compiled.Theme), "dark") {
+		t.Errorf("published theme = custom %v mode %q %s, want the preset in light mode",
+			compiled.Page.ThemeCustomID, compiled.Page.Mode, compiled.Theme)
+	}
+}
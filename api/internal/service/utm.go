@@ -0,0 +1,240 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+	"linkbio/internal/model"
+)
+
+const maxUTMTemplate = 512
+
+var (
+	utmKeyRegex = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,64}$`)
+	utmVarRegex = regexp.MustCompile(`\{([a-z_]*)\}`)
+)
+
+// utmVars are the placeholders a template may use.
+var utmVars = map[string]bool{"page": true, "group": true, "link": true, "link_id": true, "username": true}
+
+// UTMSettings is the page's UTM template, kept under "utm" in the page
+// settings. Link groups and links carry templates of their own.
+type UTMSettings struct {
+	Template string `json:"template"`
+}
+
+type utmParam struct {
+	key, value string
+}
+
+// UpdateUTM validates and stores the page's UTM template. It applies to
+// links on the next publish.
+func (s *PageService) UpdateUTM(ctx context.Context, userID, pageID int64, utm UTMSettings) (*UTMSettings, error) {
+	page, err := s.pageRepo.GetByID(ctx, pageID)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	if page.UserID != userID {
+		return nil, ErrForbidden
+	}
+
+	utm.Template = strings.TrimSpace(utm.Template)
+	if _, err := parseUTMTemplate(utm.Template); err != nil {
+		return nil, ErrUTMTemplate.WithField("template", "format", "utm.invalid_template")
+	}
+
+	settings := map[string]json.RawMessage{}
+	if len(page.Settings) > 0 {
+		if err := json.Unmarshal(page.Settings, &settings); err != nil {
+			settings = map[string]json.RawMessage{}
+		}
+	}
+	raw, err := json.Marshal(utm)
+	if err != nil {
+		return nil, err
+	}
+	settings["utm"] = raw
+	newSettings, err := json.Marshal(settings)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.pageRepo.UpdateSettings(ctx, pageID, newSettings); err != nil {
+		return nil, err
+	}
+	return &utm, nil
+}
+
+// checkUTMTemplate trims a group or link template, reporting a bad one
+// under field. Empty templates are stored as nil.
+func checkUTMTemplate(field string, template *string) (*string, error) {
+	if template == nil {
+		return nil, nil
+	}
+	t := strings.TrimSpace(*template)
+	if t == "" {
+		return nil, nil
+	}
+	if _, err := parseUTMTemplate(t); err != nil {
+		return nil, ErrUTMTemplate.WithField(field, "format", "utm.invalid_template")
+	}
+	return &t, nil
+}
+
+// parseUTMTemplate reads a query-string template such as
+// "utm_source=linkbio&utm_campaign={page}", keeping the parameter order.
+// An empty value removes a parameter set at a broader level.
+func parseUTMTemplate(template string) ([]utmParam, error) {
+	if len(template) > maxUTMTemplate {
+		return nil, ErrUTMTemplate
+	}
+	var params []utmParam
+	for _, pair := range strings.Split(strings.TrimPrefix(template, "?"), "&") {
+		if pair == "" {
+			continue
+		}
+		rawKey, rawValue, _ := strings.Cut(pair, "=")
+		key, err := url.QueryUnescape(rawKey)
+		if err != nil || !utmKeyRegex.MatchString(key) {
+			return nil, ErrUTMTemplate
+		}
+		value, err := url.QueryUnescape(rawValue)
+		if err != nil {
+			return nil, ErrUTMTemplate
+		}
+		for _, m := range utmVarRegex.FindAllStringSubmatch(value, -1) {
+			if !utmVars[m[1]] {
+				return nil, ErrUTMTemplate
+			}
+		}
+		params = append(params, utmParam{key, value})
+	}
+	return params, nil
+}
+
+// utmTemplates resolves the UTM parameters of a page's links. Templates
+// merge parameter by parameter, page first, then group, then link.
+// Templates that no longer parse are skipped.
+type utmTemplates struct {
+	page []utmParam
+	vars map[string]string
+}
+
+func newUTMTemplates(page *model.BioPage, user *model.User) *utmTemplates {
+	var settings struct {
+		UTM UTMSettings `json:"utm"`
+	}
+	_ = json.Unmarshal(page.Settings, &settings)
+	t := &utmTemplates{vars: map[string]string{}}
+	t.page, _ = parseUTMTemplate(settings.UTM.Template)
+	t.vars["page"] = utmSlug(page.Title, "page-"+strconv.FormatInt(page.ID, 10))
+	if user.Username != nil {
+		t.vars["username"] = *user.Username
+	}
+	return t
+}
+
+// apply adds the merged parameters to an http(s) URL. Parameters the URL
+// already has are left as they are; other schemes are returned unchanged.
+func (t *utmTemplates) apply(rawURL string, group *model.LinkGroup, link *model.Link) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return rawURL
+	}
+
+	params := append([]utmParam(nil), t.page...)
+	for _, template := range []*string{group.UTMTemplate, link.UTMTemplate} {
+		if template == nil {
+			continue
+		}
+		override, err := parseUTMTemplate(*template)
+		if err != nil {
+			continue
+		}
+		params = mergeUTM(params, override)
+	}
+	if len(params) == 0 {
+		return rawURL
+	}
+
+	vars := map[string]string{
+		"page":     t.vars["page"],
+		"username": t.vars["username"],
+		"group":    utmSlug(group.Title, "group-"+strconv.FormatInt(group.ID, 10)),
+		"link":     utmSlug(&link.Title, "link-"+strconv.FormatInt(link.ID, 10)),
+		"link_id":  strconv.FormatInt(link.ID, 10),
+	}
+	existing := u.Query()
+	var add []string
+	for _, p := range params {
+		if p.value == "" || existing.Has(p.key) {
+			continue
+		}
+		value := utmVarRegex.ReplaceAllStringFunc(p.value, func(m string) string {
+			return vars[m[1:len(m)-1]]
+		})
+		add = append(add, url.QueryEscape(p.key)+"="+url.QueryEscape(value))
+	}
+	if len(add) == 0 {
+		return rawURL
+	}
+	if u.RawQuery != "" {
+		u.RawQuery += "&"
+	}
+	u.RawQuery += strings.Join(add, "&")
+	return u.String()
+}
+
+// mergeUTM overrides base with the parameters of override, keeping base's
+// order and appending new keys.
+func mergeUTM(base, override []utmParam) []utmParam {
+	out := append([]utmParam(nil), base...)
+	for _, o := range override {
+		found := false
+		for i := range out {
+			if out[i].key == o.key {
+				out[i].value = o.value
+				found = true
+			}
+		}
+		if !found {
+			out = append(out, o)
+		}
+	}
+	return out
+}
+
+// utmSlug turns a title into a lowercase ASCII slug, e.g. "Khuyến mãi Tết"
+// becomes "khuyen-mai-tet". Titles with nothing to slug give fallback.
+func utmSlug(title *string, fallback string) string {
+	if title == nil {
+		return fallback
+	}
+	stripMarks := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	s, _, err := transform.String(stripMarks, strings.NewReplacer("đ", "d", "Đ", "D").Replace(*title))
+	if err != nil {
+		return fallback
+	}
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(s) {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	if slug := strings.TrimSuffix(b.String(), "-"); slug != "" {
+		return slug
+	}
+	return fallback
+}
@@ -0,0 +1,124 @@
+package service
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"linkbio/internal/model"
+)
+
+func TestUTMSlug(t *testing.T) {
+	cases := []struct {
+		title, want string
+	}{
+		{"Khuyến mãi Tết", "khuyen-mai-tet"},
+		{"Đặt hàng ĐÀ NẴNG", "dat-hang-da-nang"},
+		{"Cửa hàng - Ưu đãi 50%!", "cua-hang-uu-dai-50"},
+		{"  Summer   Sale 2026  ", "summer-sale-2026"},
+		{"café", "cafe"},
+		{"🎉 New", "new"},
+		{"中文", "fallback"},
+		{"", "fallback"},
+	}
+	for _, c := range cases {
+		if got := utmSlug(&c.title, "fallback"); got != c.want {
+			t.Errorf("utmSlug(%q) = %q, want %q", c.title, got, c.want)
+		}
+	}
+	if got := utmSlug(nil, "fallback"); got != "fallback" {
+		t.Errorf("utmSlug(nil) = %q, want the fallback", got)
+	}
+}
+
+func TestMergeUTM(t *testing.T) {
+	base := []utmParam{{"utm_source", "bio"}, {"utm_medium", "social"}}
+	cases := []struct {
+		name     string
+		override []utmParam
+		want     []utmParam
+	}{
+		{"nothing", nil, base},
+		{"override keeps order", []utmParam{{"utm_source", "shop"}}, []utmParam{{"utm_source", "shop"}, {"utm_medium", "social"}}},
+		{"new keys appended", []utmParam{{"utm_campaign", "{page}"}}, []utmParam{{"utm_source", "bio"}, {"utm_medium", "social"}, {"utm_campaign", "{page}"}}},
+		{"empty value kept to remove", []utmParam{{"utm_medium", ""}}, []utmParam{{"utm_source", "bio"}, {"utm_medium", ""}}},
+	}
+	for _, c := range cases {
+		if got := mergeUTM(base, c.override); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: mergeUTM = %v, want %v", c.name, got, c.want)
+		}
+	}
+	if base[0].value != "bio" {
+		t.Error("mergeUTM changed its base")
+	}
+}
+
+func TestUTMApply(t *testing.T) {
+	str := func(s string) *string { return &s }
+	username := "linh"
+	page := &model.BioPage{
+		ID:       7,
+		Title:    str("Tiệm Bánh Mì"),
+		Settings: json.RawMessage(`{"utm":{"template":"utm_source=bio&utm_medium=social&utm_campaign={page}"}}`),
+	}
+	templates := newUTMTemplates(page, &model.User{Username: &username})
+
+	cases := []struct {
+		name  string
+		url   string
+		group *string
+		link  *string
+		want  string
+	}{
+		{
+			name: "page defaults",
+			url:  "https://shop.example/menu",
+			want: "https://shop.example/menu?utm_source=bio&utm_medium=social&utm_campaign=tiem-banh-mi",
+		},
+		{
+			name:  "group then link override the page",
+			url:   "https://shop.example",
+			group: str("utm_source=group&utm_content={group}"),
+			link:  str("utm_source={username}&utm_term={link}-{link_id}"),
+			want:  "https://shop.example?utm_source=linh&utm_medium=social&utm_campaign=tiem-banh-mi&utm_content=do-uong&utm_term=ca-phe-sua-da-42",
+		},
+		{
+			name: "empty value removes a parameter",
+			url:  "https://shop.example",
+			link: str("utm_medium=&utm_campaign="),
+			want: "https://shop.example?utm_source=bio",
+		},
+		{
+			name: "existing query params kept",
+			url:  "https://shop.example/p?id=5&utm_source=zalo",
+			want: "https://shop.example/p?id=5&utm_source=zalo&utm_medium=social&utm_campaign=tiem-banh-mi",
+		},
+		{
+			name: "every parameter already set",
+			url:  "https://shop.example/?utm_source=a&utm_medium=b&utm_campaign=c",
+			want: "https://shop.example/?utm_source=a&utm_medium=b&utm_campaign=c",
+		},
+		{
+			name: "bad link template skipped",
+			url:  "https://shop.example",
+			link: str("utm_source={unknown}"),
+			want: "https://shop.example?utm_source=bio&utm_medium=social&utm_campaign=tiem-banh-mi",
+		},
+		{name: "mailto skipped", url: "mailto:hi@shop.example", want: "mailto:hi@shop.example"},
+		{name: "tel skipped", url: "tel:+84912345678", want: "tel:+84912345678"},
+		{name: "sms skipped", url: "sms:+84912345678?body=hi", want: "sms:+84912345678?body=hi"},
+	}
+	for _, c := range cases {
+		group := &model.LinkGroup{ID: 3, Title: str("Đồ uống"), UTMTemplate: c.group}
+		link := &model.Link{ID: 42, Title: "Cà phê sữa đá", UTMTemplate: c.link}
+		if got := templates.apply(c.url, group, link); got != c.want {
+			t.Errorf("%s: apply(%q) = %q, want %q", c.name, c.url, got, c.want)
+		}
+	}
+
+	none := newUTMTemplates(&model.BioPage{ID: 1}, &model.User{})
+	url := "https://shop.example/menu"
+	if got := none.apply(url, &model.LinkGroup{}, &model.Link{}); got != url {
+		t.Errorf("apply without templates = %q, want %q", got, url)
+	}
+}
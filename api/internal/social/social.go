@@ -0,0 +1,283 @@
+// Package social is the registry of social platforms a page can link to in
+// its social row. Each platform turns a handle, or a pasted profile URL,
+// into one canonical profile URL. Adding a platform is adding an entry to
+// registry.
+package social
+
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Platform describes one social network.
+type Platform struct {
+	Key         string `json:"key"`
+	Name        string `json:"name"`
+	Icon        string `json:"icon"`
+	Order       int    `json:"order"`
+	Placeholder string `json:"placeholder"`
+
+	// profile is the canonical profile URL with %s for the handle. Empty
+	// for platforms whose value is a URL of its own, like a website.
+	profile string
+	// hosts are the domains a pasted profile URL may come from; "www." and
+	// "m." are stripped before matching.
+	hosts []string
+	// path extracts the handle from a pasted URL's path.
+	path *regexp.Regexp
+	// handle matches a valid handle after clean.
+	handle *regexp.Regexp
+	clean  func(string) string
+}
+
+// Link is one entry of a page's social row as stored in its settings.
+type Link struct {
+	Platform string `json:"platform"`
+	Handle   string `json:"handle,omitempty"`
+	URL      string `json:"url"`
+}
+
+var (
+	ErrInvalid     = errors.New("social: invalid handle or URL")
+	ErrWrongDomain = errors.New("social: URL is not on the platform's domain")
+)
+
+var (
+	firstSegment = regexp.MustCompile(`^/([^/]+)/?$`)
+	atSegment    = regexp.MustCompile(`^/@([^/]+)/?$`)
+)
+
+var registry = []Platform{
+	{
+		Key: "instagram", Name: "Instagram", Icon: "instagram", Order: 10, Placeholder: "@username",
+		profile: "https://www.instagram.com/%s/",
+		hosts:   []string{"instagram.com", "instagr.am"},
+		path:    firstSegment,
+		handle:  regexp.MustCompile(`^[A-Za-z0-9._]{1,30}$`),
+	},
+	{
+		Key: "facebook", Name: "Facebook", Icon: "facebook", Order: 20, Placeholder: "username",
+		profile: "https://www.facebook.com/%s",
+		hosts:   []string{"facebook.com", "fb.com"},
+		path:    firstSegment,
+		handle:  regexp.MustCompile(`^[A-Za-z0-9.]{5,50}$`),
+	},
+	{
+		Key: "twitter", Name: "X", Icon: "twitter", Order: 30, Placeholder: "@username",
+		profile: "https://x.com/%s",
+		hosts:   []string{"x.com", "twitter.com"},
+		path:    firstSegment,
+		handle:  regexp.MustCompile(`^[A-Za-z0-9_]{1,15}$`),
+	},
+	{
+		Key: "threads", Name: "Threads", Icon: "threads", Order: 35, Placeholder: "@username",
+		profile: "https://www.threads.net/@%s",
+		hosts:   []string{"threads.net", "threads.com"},
+		path:    atSegment,
+		handle:  regexp.MustCompile(`^[A-Za-z0-9._]{1,30}$`),
+	},
+	{
+		Key: "tiktok", Name: "TikTok", Icon: "tiktok", Order: 40, Placeholder: "@username",
+		profile: "https://www.tiktok.com/@%s",
+		hosts:   []string{"tiktok.com"},
+		path:    atSegment,
+		handle:  regexp.MustCompile(`^[A-Za-z0-9._]{2,24}$`),
+	},
+	{
+		Key: "youtube", Name: "YouTube", Icon: "youtube", Order: 50, Placeholder: "@channel",
+		profile: "https://www.youtube.com/@%s",
+		hosts:   []string{"youtube.com"},
+		path:    atSegment,
+		handle:  regexp.MustCompile(`^[A-Za-z0-9._-]{3,30}$`),
+	},
+	{
+		Key: "linkedin", Name: "LinkedIn", Icon: "linkedin", Order: 60, Placeholder: "profile-name",
+		profile: "https://www.linkedin.com/in/%s",
+		hosts:   []string{"linkedin.com"},
+		path:    regexp.MustCompile(`^/in/([^/]+)/?$`),
+		handle:  regexp.MustCompile(`^[A-Za-z0-9-]{3,100}$`),
+	},
+	{
+		Key: "github", Name: "GitHub", Icon: "github", Order: 70, Placeholder: "username",
+		profile: "https://github.com/%s",
+		hosts:   []string{"github.com"},
+		path:    firstSegment,
+		handle:  regexp.MustCompile(`^[A-Za-z0-9](?:[A-Za-z0-9-]{0,37}[A-Za-z0-9])?$`),
+	},
+	{
+		Key: "bluesky", Name: "Bluesky", Icon: "bluesky", Order: 80, Placeholder: "name.bsky.social",
+		profile: "https://bsky.app/profile/%s",
+		hosts:   []string{"bsky.app"},
+		path:    regexp.MustCompile(`^/profile/([^/]+)/?$`),
+		handle:  regexp.MustCompile(`^([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z][a-z0-9-]*[a-z0-9]$`),
+		// Handles are domains; a bare name is on the default bsky.social.
+		clean: func(h string) string {
+			h = strings.ToLower(h)
+			if !strings.Contains(h, ".") {
+				h += ".bsky.social"
+			}
+			return h
+		},
+	},
+	{
+		Key: "telegram", Name: "Telegram", Icon: "telegram", Order: 90, Placeholder: "@username",
+		profile: "https://t.me/%s",
+		hosts:   []string{"t.me", "telegram.me"},
+		path:    firstSegment,
+		handle:  regexp.MustCompile(`^[A-Za-z0-9_]{5,32}$`),
+	},
+	{
+		Key: "zalo", Name: "Zalo", Icon: "zalo", Order: 100, Placeholder: "0912345678",
+		profile: "https://zalo.me/%s",
+		hosts:   []string{"zalo.me"},
+		path:    firstSegment,
+		handle:  regexp.MustCompile(`^[0-9]{9,20}$`),
+		// Phone numbers are written with spaces, dots and the +84 prefix.
+		clean: func(h string) string {
+			h = strings.NewReplacer(" ", "", ".", "", "-", "").Replace(h)
+			if strings.HasPrefix(h, "+84") {
+				h = "0" + h[3:]
+			}
+			return h
+		},
+	},
+	{
+		Key: "website", Name: "Website", Icon: "globe", Order: 1000, Placeholder: "https://example.com",
+	},
+}
+
+var byKey = func() map[string]*Platform {
+	m := make(map[string]*Platform, len(registry))
+	for i := range registry {
+		m[registry[i].Key] = &registry[i]
+	}
+	return m
+}()
+
+// legacyOrder is the order of the fixed fields social settings were stored
+// as before they became a list.
+var legacyOrder = []string{"instagram", "facebook", "twitter", "tiktok", "youtube", "linkedin", "github", "website"}
+
+// Platforms lists the registry in display order.
+func Platforms() []Platform {
+	out := make([]Platform, len(registry))
+	copy(out, registry)
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Order < out[j].Order })
+	return out
+}
+
+// Lookup finds a platform by key.
+func Lookup(key string) (*Platform, bool) {
+	p, ok := byKey[key]
+	return p, ok
+}
+
+// Website reports whether the platform's value is a URL of its own rather
+// than a handle. Normalize only checks such URLs are http(s); callers run
+// them through their link policy as well.
+func (p *Platform) Website() bool {
+	return p.profile == ""
+}
+
+// Normalize turns a handle or a profile URL into a Link with the
+// platform's canonical URL.
+func (p *Platform) Normalize(value string) (Link, error) {
+	value = strings.TrimSpace(value)
+	if p.profile == "" {
+		u, err := websiteURL(value)
+		if err != nil {
+			return Link{}, err
+		}
+		return Link{Platform: p.Key, URL: u}, nil
+	}
+
+	handle := value
+	if u, ok := p.parseURL(value); ok {
+		host := strings.TrimPrefix(strings.TrimPrefix(strings.ToLower(u.Hostname()), "www."), "m.")
+		if !p.onHost(host) {
+			return Link{}, ErrWrongDomain
+		}
+		m := p.path.FindStringSubmatch(u.EscapedPath())
+		if m == nil {
+			return Link{}, ErrInvalid
+		}
+		handle = m[1]
+	}
+	handle = strings.TrimPrefix(handle, "@")
+	if p.clean != nil {
+		handle = p.clean(handle)
+	}
+	if !p.handle.MatchString(handle) {
+		return Link{}, ErrInvalid
+	}
+	return Link{Platform: p.Key, Handle: handle, URL: strings.Replace(p.profile, "%s", handle, 1)}, nil
+}
+
+// parseURL reports whether value is a URL rather than a handle: it has a
+// scheme, or starts with one of the platform's hosts.
+func (p *Platform) parseURL(value string) (*url.URL, bool) {
+	lower := strings.ToLower(value)
+	if !strings.Contains(lower, "://") {
+		bare := strings.TrimPrefix(strings.TrimPrefix(lower, "www."), "m.")
+		if !p.onHost(strings.SplitN(bare, "/", 2)[0]) {
+			return nil, false
+		}
+		value = "https://" + value
+	}
+	u, err := url.Parse(value)
+	if err != nil || u.Host == "" {
+		return nil, false
+	}
+	return u, true
+}
+
+func (p *Platform) onHost(host string) bool {
+	for _, h := range p.hosts {
+		if host == h {
+			return true
+		}
+	}
+	return false
+}
+
+// websiteURL accepts an http(s) URL, adding https:// when the scheme is
+// left out.
+func websiteURL(value string) (string, error) {
+	if !strings.Contains(value, "://") {
+		value = "https://" + value
+	}
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || !strings.Contains(u.Hostname(), ".") {
+		return "", ErrInvalid
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	return u.String(), nil
+}
+
+// Decode reads a page's stored social row. Rows saved before the registry
+// were an object of fixed fields; those are read in their old order and
+// entries that no longer normalize are dropped.
+func Decode(raw json.RawMessage) []Link {
+	var links []Link
+	if err := json.Unmarshal(raw, &links); err == nil {
+		return links
+	}
+	var legacy map[string]string
+	if err := json.Unmarshal(raw, &legacy); err != nil {
+		return nil
+	}
+	for _, key := range legacyOrder {
+		if legacy[key] == "" {
+			continue
+		}
+		if link, err := byKey[key].Normalize(legacy[key]); err == nil {
+			links = append(links, link)
+		}
+	}
+	return links
+}
@@ -0,0 +1,127 @@
+package social
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	cases := []struct {
+		platform, in string
+		handle, url  string
+		err          error
+	}{
+		{platform: "instagram", in: "@linh.bakery", handle: "linh.bakery", url: "https://www.instagram.com/linh.bakery/"},
+		{platform: "instagram", in: "https://www.instagram.com/linh.bakery/", handle: "linh.bakery", url: "https://www.instagram.com/linh.bakery/"},
+		{platform: "instagram", in: "instagram.com/linh.bakery", handle: "linh.bakery", url: "https://www.instagram.com/linh.bakery/"},
+		{platform: "instagram", in: "https://instagram.com/linh/reels", err: ErrInvalid},
+		{platform: "instagram", in: "https://evil.com/linh", err: ErrWrongDomain},
+		{platform: "instagram", in: "https://instagram.com.evil.com/linh", err: ErrWrongDomain},
+		{platform: "twitter", in: "https://twitter.com/linh_vn", handle: "linh_vn", url: "https://x.com/linh_vn"},
+		{platform: "twitter", in: "https://facebook.com/linh_vn", err: ErrWrongDomain},
+		{platform: "facebook", in: "https://m.facebook.com/linh.bakery", handle: "linh.bakery", url: "https://www.facebook.com/linh.bakery"},
+
+		// Threads and TikTok profiles are at /@handle.
+		{platform: "threads", in: "@linh.bakery", handle: "linh.bakery", url: "https://www.threads.net/@linh.bakery"},
+		{platform: "threads", in: "https://www.threads.net/@linh.bakery", handle: "linh.bakery", url: "https://www.threads.net/@linh.bakery"},
+		{platform: "threads", in: "threads.com/@linh.bakery/", handle: "linh.bakery", url: "https://www.threads.net/@linh.bakery"},
+		{platform: "threads", in: "https://www.threads.net/linh.bakery", err: ErrInvalid},
+		{platform: "tiktok", in: "https://www.tiktok.com/@linh.bakery?lang=vi", handle: "linh.bakery", url: "https://www.tiktok.com/@linh.bakery"},
+		{platform: "tiktok", in: "https://m.tiktok.com/@linh", handle: "linh", url: "https://www.tiktok.com/@linh"},
+		{platform: "tiktok", in: "https://www.tiktok.com/@linh/video/123", err: ErrInvalid},
+		{platform: "tiktok", in: "@a", err: ErrInvalid},
+		{platform: "tiktok", in: "https://instagram.com/@linh", err: ErrWrongDomain},
+
+		// Bluesky handles are domains.
+		{platform: "bluesky", in: "Linh", handle: "linh.bsky.social", url: "https://bsky.app/profile/linh.bsky.social"},
+		{platform: "bluesky", in: "@linh.bsky.social", handle: "linh.bsky.social", url: "https://bsky.app/profile/linh.bsky.social"},
+		{platform: "bluesky", in: "linh.vn", handle: "linh.vn", url: "https://bsky.app/profile/linh.vn"},
+		{platform: "bluesky", in: "https://bsky.app/profile/linh", handle: "linh.bsky.social", url: "https://bsky.app/profile/linh.bsky.social"},
+		{platform: "bluesky", in: "linh_vn", err: ErrInvalid},
+
+		// Zalo takes phone numbers as people write them.
+		{platform: "zalo", in: "0912345678", handle: "0912345678", url: "https://zalo.me/0912345678"},
+		{platform: "zalo", in: "+84 912 345 678", handle: "0912345678", url: "https://zalo.me/0912345678"},
+		{platform: "zalo", in: "0912.345.678", handle: "0912345678", url: "https://zalo.me/0912345678"},
+		{platform: "zalo", in: "091-234-5678", handle: "0912345678", url: "https://zalo.me/0912345678"},
+		{platform: "zalo", in: "https://zalo.me/0912345678", handle: "0912345678", url: "https://zalo.me/0912345678"},
+		{platform: "zalo", in: "https://chat.zalo.me/0912345678", err: ErrWrongDomain},
+		{platform: "zalo", in: "linh", err: ErrInvalid},
+
+		{platform: "website", in: "Example.com/shop", url: "https://example.com/shop"},
+		{platform: "website", in: "http://example.com", url: "http://example.com"},
+		{platform: "website", in: "javascript:alert(1)", err: ErrInvalid},
+		{platform: "website", in: "localhost", err: ErrInvalid},
+		{platform: "instagram", in: "", err: ErrInvalid},
+	}
+	for _, c := range cases {
+		p, ok := Lookup(c.platform)
+		if !ok {
+			t.Fatalf("Lookup(%q) failed", c.platform)
+		}
+		got, err := p.Normalize(c.in)
+		if c.err != nil {
+			if !errors.Is(err, c.err) {
+				t.Errorf("%s Normalize(%q) = %+v, %v; want error %v", c.platform, c.in, got, err, c.err)
+			}
+			continue
+		}
+		want := Link{Platform: c.platform, Handle: c.handle, URL: c.url}
+		if err != nil || got != want {
+			t.Errorf("%s Normalize(%q) = %+v, %v; want %+v", c.platform, c.in, got, err, want)
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	cases := []struct {
+		name string
+		raw  string
+		want []Link
+	}{
+		{"empty", ``, nil},
+		{"not social", `"x"`, nil},
+		{
+			"list kept as stored",
+			`[{"platform":"zalo","handle":"0912345678","url":"https://zalo.me/0912345678"},{"platform":"website","url":"https://example.com"}]`,
+			[]Link{
+				{Platform: "zalo", Handle: "0912345678", URL: "https://zalo.me/0912345678"},
+				{Platform: "website", URL: "https://example.com"},
+			},
+		},
+		{
+			"legacy fields in their old order",
+			`{"website":"example.com","github":"linh","twitter":"@linh_vn","instagram":"https://instagram.com/linh.bakery"}`,
+			[]Link{
+				{Platform: "instagram", Handle: "linh.bakery", URL: "https://www.instagram.com/linh.bakery/"},
+				{Platform: "twitter", Handle: "linh_vn", URL: "https://x.com/linh_vn"},
+				{Platform: "github", Handle: "linh", URL: "https://github.com/linh"},
+				{Platform: "website", URL: "https://example.com"},
+			},
+		},
+		{
+			"legacy entries that no longer normalize dropped",
+			`{"facebook":"abc","tiktok":"https://instagram.com/@linh","youtube":"@linhbakery","myspace":"linh"}`,
+			[]Link{{Platform: "youtube", Handle: "linhbakery", URL: "https://www.youtube.com/@linhbakery"}},
+		},
+	}
+	for _, c := range cases {
+		if got := Decode(json.RawMessage(c.raw)); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: Decode = %+v, want %+v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestPlatformsOrder(t *testing.T) {
+	platforms := Platforms()
+	for i := 1; i < len(platforms); i++ {
+		if platforms[i-1].Order >= platforms[i].Order {
+			t.Errorf("%s (%d) listed before %s (%d)", platforms[i-1].Key, platforms[i-1].Order, platforms[i].Key, platforms[i].Order)
+		}
+	}
+	if last := platforms[len(platforms)-1]; !last.Website() {
+		t.Errorf("last platform = %s, want the website", last.Key)
+	}
+}
@@ -0,0 +1,195 @@
+// Package targeting picks a link's destination for a visitor by country,
+// operating system, device type and language.
+package targeting
+
+import (
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mileusna/useragent"
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// Operating systems.
+const (
+	OSIOS      = "ios"
+	OSAndroid  = "android"
+	OSWindows  = "windows"
+	OSMacOS    = "macos"
+	OSLinux    = "linux"
+	OSChromeOS = "chromeos"
+)
+
+// Device types.
+const (
+	DeviceMobile  = "mobile"
+	DeviceTablet  = "tablet"
+	DeviceDesktop = "desktop"
+)
+
+var (
+	OSes    = []string{OSIOS, OSAndroid, OSWindows, OSMacOS, OSLinux, OSChromeOS}
+	Devices = []string{DeviceMobile, DeviceTablet, DeviceDesktop}
+)
+
+// Rule sends visitors matching all of its conditions to URL. Each
+// condition lists the values it accepts; an empty one accepts everyone.
+// Countries are ISO 3166-1 alpha-2 codes and languages ISO 639 codes.
+type Rule struct {
+	Countries []string `json:"country,omitempty"`
+	OS        []string `json:"os,omitempty"`
+	Devices   []string `json:"device,omitempty"`
+	Languages []string `json:"language,omitempty"`
+	URL       string   `json:"url"`
+}
+
+// Visitor is what the rules are matched against. Unknown fields are empty
+// and match only conditions that accept everyone.
+type Visitor struct {
+	Country  string
+	OS       string
+	Device   string
+	Language string
+}
+
+// Request is what a click tells about the visitor.
+type Request struct {
+	IP             string
+	UserAgent      string
+	AcceptLanguage string
+}
+
+// Match returns the URL of the first rule the visitor matches.
+func Match(rules []Rule, v Visitor) (string, bool) {
+	for _, r := range rules {
+		if r.Matches(v) {
+			return r.URL, true
+		}
+	}
+	return "", false
+}
+
+// Matches reports whether the visitor meets every condition of the rule.
+func (r Rule) Matches(v Visitor) bool {
+	return accepts(r.Countries, v.Country) && accepts(r.OS, v.OS) &&
+		accepts(r.Devices, v.Device) && accepts(r.Languages, v.Language)
+}
+
+func accepts(values []string, v string) bool {
+	if len(values) == 0 {
+		return true
+	}
+	for _, want := range values {
+		if want == v {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseUserAgent returns the operating system and device type of a
+// User-Agent header.
+func ParseUserAgent(header string) (os, device string) {
+	ua := useragent.Parse(header)
+	switch ua.OS {
+	case useragent.IOS:
+		os = OSIOS
+	case useragent.Android:
+		os = OSAndroid
+	case useragent.Windows, useragent.WindowsPhone:
+		os = OSWindows
+	case useragent.MacOS:
+		os = OSMacOS
+	case useragent.Linux:
+		os = OSLinux
+	case useragent.ChromeOS, useragent.CrOS:
+		os = OSChromeOS
+	}
+	switch {
+	case ua.Bot:
+	case ua.Tablet:
+		device = DeviceTablet
+	case ua.Mobile:
+		device = DeviceMobile
+	case ua.Desktop:
+		device = DeviceDesktop
+	}
+	return os, device
+}
+
+// PreferredLanguage returns the primary subtag of the most preferred
+// language in an Accept-Language header, e.g. "vi" for "vi-VN,en;q=0.8".
+func PreferredLanguage(header string) string {
+	type lang struct {
+		tag string
+		q   float64
+	}
+	var langs []lang
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+		primary, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+		if primary == "" || primary == "*" || q <= 0 {
+			continue
+		}
+		langs = append(langs, lang{primary, q})
+	}
+	if len(langs) == 0 {
+		return ""
+	}
+	sort.SliceStable(langs, func(i, j int) bool { return langs[i].q > langs[j].q })
+	return langs[0].tag
+}
+
+// GeoIP looks up countries in a local MaxMind database file, such as
+// GeoLite2-Country or GeoLite2-City. A nil GeoIP knows no countries.
+type GeoIP struct {
+	db *maxminddb.Reader
+}
+
+// OpenGeoIP opens the database at path; an empty path returns nil.
+func OpenGeoIP(path string) (*GeoIP, error) {
+	if path == "" {
+		return nil, nil
+	}
+	db, err := maxminddb.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &GeoIP{db: db}, nil
+}
+
+// Country returns the ISO code of the country ip is in, or "".
+func (g *GeoIP) Country(ip string) string {
+	parsed := net.ParseIP(ip)
+	if g == nil || parsed == nil {
+		return ""
+	}
+	var record struct {
+		Country struct {
+			ISOCode string `maxminddb:"iso_code"`
+		} `maxminddb:"country"`
+	}
+	if err := g.db.Lookup(parsed, &record); err != nil {
+		return ""
+	}
+	return record.Country.ISOCode
+}
+
+// Visitor describes the visitor behind a request.
+func (g *GeoIP) Visitor(r Request) Visitor {
+	os, device := ParseUserAgent(r.UserAgent)
+	return Visitor{
+		Country:  g.Country(r.IP),
+		OS:       os,
+		Device:   device,
+		Language: PreferredLanguage(r.AcceptLanguage),
+	}
+}
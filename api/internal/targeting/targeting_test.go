@@ -0,0 +1,94 @@
+package targeting
+
+import "testing"
+
+func TestMatch(t *testing.T) {
+	rules := []Rule{
+		{Countries: []string{"VN"}, OS: []string{OSIOS}, URL: "https://apps.apple.com/vn/app"},
+		{Countries: []string{"VN"}, Devices: []string{DeviceMobile, DeviceTablet}, URL: "https://m.example.vn"},
+		{Countries: []string{"VN", "TH"}, URL: "https://example.vn"},
+		{Languages: []string{"vi"}, URL: "https://example.com/vi"},
+		{OS: []string{OSAndroid}, URL: "https://play.google.com/app"},
+	}
+	cases := []struct {
+		name string
+		v    Visitor
+		want string
+	}{
+		{"first rule wins", Visitor{Country: "VN", OS: OSIOS, Device: DeviceMobile, Language: "vi"}, "https://apps.apple.com/vn/app"},
+		{"every condition must match", Visitor{Country: "US", OS: OSIOS}, ""},
+		{"device", Visitor{Country: "VN", OS: OSAndroid, Device: DeviceTablet}, "https://m.example.vn"},
+		{"country only", Visitor{Country: "TH", OS: OSWindows, Device: DeviceDesktop}, "https://example.vn"},
+		{"language after countries", Visitor{Country: "DE", Language: "vi"}, "https://example.com/vi"},
+		{"os", Visitor{Country: "US", OS: OSAndroid, Language: "en"}, "https://play.google.com/app"},
+		{"unknown country falls through", Visitor{OS: OSAndroid}, "https://play.google.com/app"},
+		{"no match", Visitor{Country: "US", OS: OSWindows, Device: DeviceDesktop, Language: "en"}, ""},
+		{"unknown visitor", Visitor{}, ""},
+	}
+	for _, c := range cases {
+		got, ok := Match(rules, c.v)
+		if got != c.want || ok != (c.want != "") {
+			t.Errorf("%s: Match(%+v) = %q, %v; want %q", c.name, c.v, got, ok, c.want)
+		}
+	}
+	if got, ok := Match(nil, Visitor{Country: "VN"}); ok {
+		t.Errorf("Match(nil) = %q, want no match", got)
+	}
+}
+
+func TestPreferredLanguage(t *testing.T) {
+	cases := []struct {
+		in, want string
+	}{
+		{"", ""},
+		{"vi", "vi"},
+		{"vi-VN,vi;q=0.9,en-US;q=0.8,en;q=0.7", "vi"},
+		{"en-US;q=0.5, vi-VN;q=0.9", "vi"},
+		{"EN-gb", "en"},
+		{"fr;q=0.8,de;q=0.8", "fr"},
+		{"*, ja;q=0.5", "ja"},
+		{"vi;q=0, en;q=0.1", "en"},
+		{"ko;q=abc", "ko"},
+		{" , ;q=1", ""},
+	}
+	for _, c := range cases {
+		if got := PreferredLanguage(c.in); got != c.want {
+			t.Errorf("PreferredLanguage(%q) = %q, want %q", c.in, got, c.want)
+		}
+	}
+}
+
+func TestParseUserAgent(t *testing.T) {
+	cases := []struct {
+		ua, os, device string
+	}{
+		{"Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Mobile/15E148 Safari/604.1", OSIOS, DeviceMobile},
+		{"Mozilla/5.0 (iPad; CPU OS 16_6 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/16.6 Mobile/15E148 Safari/604.1", OSIOS, DeviceTablet},
+		{"Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Mobile Safari/537.36", OSAndroid, DeviceMobile},
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36", OSWindows, DeviceDesktop},
+		{"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Safari/605.1.15", OSMacOS, DeviceDesktop},
+		{"Mozilla/5.0 (X11; Linux x86_64; rv:121.0) Gecko/20100101 Firefox/121.0", OSLinux, DeviceDesktop},
+		{"Mozilla/5.0 (X11; CrOS x86_64 14541.0.0) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36", OSChromeOS, DeviceDesktop},
+		{"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)", "", ""},
+		{"", "", ""},
+	}
+	for _, c := range cases {
+		os, device := ParseUserAgent(c.ua)
+		if os != c.os || device != c.device {
+			t.Errorf("ParseUserAgent(%q) = %q, %q; want %q, %q", c.ua, os, device, c.os, c.device)
+		}
+	}
+}
+
+func TestVisitorWithoutGeoIP(t *testing.T) {
+	var g *GeoIP
+	v := g.Visitor(Request{
+		IP:             "203.113.0.1",
+		UserAgent:      "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Mobile/15E148 Safari/604.1",
+		AcceptLanguage: "vi-VN,en;q=0.8",
+	})
+	want := Visitor{OS: OSIOS, Device: DeviceMobile, Language: "vi"}
+	if v != want {
+		t.Errorf("Visitor = %+v, want %+v", v, want)
+	}
+}
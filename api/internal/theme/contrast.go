@@ -0,0 +1,416 @@
+package theme
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// WCAG 2.x contrast minimums: AA for body text, and AA for large text,
+// which is also the floor below which a combination counts as unreadable.
+const (
+	MinContrast      = 4.5
+	MinLargeContrast = 3.0
+)
+
+// Lint levels.
+const (
+	LintError   = "error"
+	LintWarning = "warning"
+)
+
+// LintIssue is one readability problem. Field is the key path of the
+// foreground color and Against what it was measured on; Mode is empty for
+// the theme's default mode.
+type LintIssue struct {
+	Level      string  `json:"level"`
+	Code       string  `json:"code"`
+	Mode       string  `json:"mode,omitempty"`
+	Field      string  `json:"field"`
+	Against    string  `json:"against,omitempty"`
+	Foreground string  `json:"foreground,omitempty"`
+	Background string  `json:"background,omitempty"`
+	Ratio      float64 `json:"ratio,omitempty"`
+	Required   float64 `json:"required,omitempty"`
+}
+
+type LintReport struct {
+	Errors   []LintIssue `json:"errors"`
+	Warnings []LintIssue `json:"warnings"`
+}
+
+// HasErrors reports whether any check failed outright.
+func (r LintReport) HasErrors() bool { return len(r.Errors) > 0 }
+
+// DominantColorFunc looks up the dominant color of a wallpaper asset, as
+// "#rrggbb". ok is false when it is unknown.
+type DominantColorFunc func(assetID int64) (color string, ok bool)
+
+// Lint checks a compiled theme's text, card and link button colors against
+// what they are drawn on, in the default mode and in every mode override.
+// A wallpaper counts as its dominant color under the dim and overlay
+// effects; when that color is unknown both black and white are tried and
+// failures are only warnings.
+func Lint(config json.RawMessage, dominant DominantColorFunc) LintReport {
+	report := LintReport{Errors: []LintIssue{}, Warnings: []LintIssue{}}
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal(config, &doc); err != nil {
+		return report
+	}
+	modes, _ := doc["modes"].(map[string]interface{})
+	delete(doc, "modes")
+
+	base := lintMode(doc, "", dominant)
+	seen := make(map[string]bool, len(base))
+	for _, issue := range base {
+		seen[issue.key()] = true
+	}
+	issues := base
+	for _, name := range sortedKeys(modes) {
+		override, ok := modes[name].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		// Only report what the mode changes.
+		for _, issue := range lintMode(mergeMaps(doc, override), name, dominant) {
+			if !seen[issue.key()] {
+				issues = append(issues, issue)
+			}
+		}
+	}
+
+	for _, issue := range issues {
+		if issue.Level == LintError {
+			report.Errors = append(report.Errors, issue)
+		} else {
+			report.Warnings = append(report.Warnings, issue)
+		}
+	}
+	return report
+}
+
+func (i LintIssue) key() string {
+	return i.Code + "|" + i.Field + "|" + i.Against + "|" + i.Foreground + "|" + i.Background
+}
+
+// surface is a solid background color, or the candidates a wallpaper of
+// unknown color might be.
+type surface struct {
+	path    string
+	colors  []rgba
+	unknown bool
+}
+
+func lintMode(doc map[string]interface{}, mode string, dominant DominantColorFunc) []LintIssue {
+	l := &linter{doc: doc, mode: mode}
+	page, ok := l.pageSurface(dominant)
+	if !ok {
+		return l.issues
+	}
+
+	l.check("semantic.color.text.default", page, true)
+	// Muted text is secondary, so it only ever warns.
+	l.check("semantic.color.text.muted", page, false)
+
+	card := page
+	if c, ok := l.color("semantic.color.surface.card"); ok {
+		card = page.under("semantic.color.surface.card", c)
+		l.check("semantic.color.text.default", card, true)
+	}
+
+	// Link buttons default to body text on a card.
+	buttonBg := card
+	if c, ok := l.color("recipes.linkItem.base.background"); ok {
+		buttonBg = page.under("recipes.linkItem.base.background", c)
+	} else if l.has("recipes.linkItem.base.background") {
+		return l.issues // a gradient or image we cannot measure
+	}
+	if l.has("recipes.linkItem.base.color") {
+		l.check("recipes.linkItem.base.color", buttonBg, true)
+	} else if buttonBg.path != card.path {
+		l.check("semantic.color.text.default", buttonBg, true)
+	}
+	return l.issues
+}
+
+type linter struct {
+	doc    map[string]interface{}
+	mode   string
+	issues []LintIssue
+}
+
+// pageSurface is what page text sits on: the wallpaper under its effects,
+// else the background color, else semantic.color.surface.page.
+func (l *linter) pageSurface(dominant DominantColorFunc) (surface, bool) {
+	var s surface
+	if id, ok := l.wallpaperAsset(); ok {
+		s.path = "background.wallpaper"
+		if hex, ok := dominant(id); ok {
+			if c, ok := parseColor(hex); ok {
+				s.colors = []rgba{c.opaque()}
+			}
+		}
+		if s.colors == nil {
+			s.unknown = true
+			s.colors = []rgba{{0, 0, 0, 1}, {1, 1, 1, 1}}
+		}
+
+		dim, _ := l.lookup("background.effects.dim").(float64)
+		overlay, hasOverlay := l.color("background.effects.overlayColor")
+		if dim <= 0 && (!hasOverlay || overlay.a == 0) {
+			l.add(LintIssue{Level: LintWarning, Code: "theme.wallpaper_no_scrim", Field: "background.effects.dim"})
+		}
+		for i, c := range s.colors {
+			c = rgba{0, 0, 0, dim}.over(c)
+			if hasOverlay {
+				c = overlay.over(c)
+			}
+			s.colors[i] = c
+		}
+		return s, true
+	}
+
+	for _, path := range []string{"background.color", "semantic.color.surface.page"} {
+		if c, ok := l.color(path); ok {
+			return surface{path: path, colors: []rgba{c.over(white)}}, true
+		}
+	}
+	return s, false
+}
+
+func (l *linter) wallpaperAsset() (int64, bool) {
+	id, ok := l.lookup("background.wallpaper.assetId").(float64)
+	if !ok || id <= 0 || id != math.Trunc(id) {
+		return 0, false
+	}
+	return int64(id), true
+}
+
+// check measures the color at fg against every candidate of bg and records
+// the worst result. Below MinLargeContrast it is an error when strict and
+// the background is known.
+func (l *linter) check(fg string, bg surface, strict bool) {
+	c, ok := l.color(fg)
+	if !ok {
+		return
+	}
+	worst, worstBg := math.Inf(1), rgba{}
+	for _, b := range bg.colors {
+		if r := contrast(c.over(b), b); r < worst {
+			worst, worstBg = r, b
+		}
+	}
+	if worst >= MinContrast {
+		return
+	}
+
+	issue := LintIssue{
+		Level:      LintWarning,
+		Code:       "theme.low_contrast",
+		Field:      fg,
+		Against:    bg.path,
+		Foreground: c.over(worstBg).hex(),
+		Background: worstBg.hex(),
+		Ratio:      math.Floor(worst*100) / 100,
+		Required:   MinContrast,
+	}
+	if strict && worst < MinLargeContrast && !bg.unknown {
+		issue.Level = LintError
+		issue.Code = "theme.unreadable_contrast"
+	}
+	l.add(issue)
+}
+
+func (l *linter) add(issue LintIssue) {
+	issue.Mode = l.mode
+	l.issues = append(l.issues, issue)
+}
+
+func (l *linter) has(path string) bool {
+	return l.lookup(path) != nil
+}
+
+func (l *linter) lookup(path string) interface{} {
+	return lookupPath(l.doc, strings.Split(path, "."))
+}
+
+// color resolves the value at path to a color, following references into
+// tokens ("tokens.color.gray.900" or "color.gray.900") and semantic.
+func (l *linter) color(path string) (rgba, bool) {
+	v := l.lookup(path)
+	for depth := 0; depth < 8; depth++ {
+		s, ok := v.(string)
+		if !ok {
+			return rgba{}, false
+		}
+		if c, ok := parseColor(s); ok {
+			return c, true
+		}
+		switch {
+		case strings.HasPrefix(s, "tokens."), strings.HasPrefix(s, "semantic."):
+			v = l.lookup(s)
+		default:
+			v = l.lookup("tokens." + s)
+		}
+	}
+	return rgba{}, false
+}
+
+func lookupPath(node interface{}, path []string) interface{} {
+	for _, seg := range path {
+		m, ok := node.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		node = m[seg]
+	}
+	return node
+}
+
+// under composites c over the surface's colors, for translucent cards.
+func (s surface) under(path string, c rgba) surface {
+	out := surface{path: path, unknown: s.unknown, colors: make([]rgba, len(s.colors))}
+	for i, b := range s.colors {
+		out.colors[i] = c.over(b)
+	}
+	return out
+}
+
+// rgba is a color with sRGB channels and alpha in 0..1.
+type rgba struct{ r, g, b, a float64 }
+
+var (
+	hexColorRegex = regexp.MustCompile(`^#([0-9a-fA-F]{3,4}|[0-9a-fA-F]{6}|[0-9a-fA-F]{8})$`)
+	rgbColorRegex = regexp.MustCompile(`^rgba?\(\s*([\d.]+%?)\s*[, ]\s*([\d.]+%?)\s*[, ]\s*([\d.]+%?)\s*(?:[,/]\s*([\d.]+%?)\s*)?\)$`)
+)
+
+var white = rgba{1, 1, 1, 1}
+
+var namedColors = map[string]rgba{
+	"transparent": {0, 0, 0, 0},
+	"black":       {0, 0, 0, 1},
+	"white":       {1, 1, 1, 1},
+}
+
+// parseColor understands hex, rgb() and rgba() colors and a few keywords.
+func parseColor(s string) (rgba, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if c, ok := namedColors[s]; ok {
+		return c, true
+	}
+	if hexColorRegex.MatchString(s) {
+		h := s[1:]
+		if len(h) <= 4 {
+			var long strings.Builder
+			for _, ch := range h {
+				long.WriteRune(ch)
+				long.WriteRune(ch)
+			}
+			h = long.String()
+		}
+		if len(h) == 6 {
+			h += "ff"
+		}
+		n, _ := strconv.ParseUint(h, 16, 32)
+		return rgba{
+			float64(n>>24&0xff) / 255,
+			float64(n>>16&0xff) / 255,
+			float64(n>>8&0xff) / 255,
+			float64(n&0xff) / 255,
+		}, true
+	}
+	m := rgbColorRegex.FindStringSubmatch(s)
+	if m == nil {
+		return rgba{}, false
+	}
+	c := rgba{channel(m[1], 255), channel(m[2], 255), channel(m[3], 255), 1}
+	if m[4] != "" {
+		c.a = channel(m[4], 1)
+	}
+	return c, true
+}
+
+// channel parses a channel value or percentage, scaled to 0..1.
+func channel(s string, max float64) float64 {
+	if strings.HasSuffix(s, "%") {
+		max, s = 100, strings.TrimSuffix(s, "%")
+	}
+	v, _ := strconv.ParseFloat(s, 64)
+	return math.Max(0, math.Min(1, v/max))
+}
+
+// over composites c over an opaque background.
+func (c rgba) over(bg rgba) rgba {
+	return rgba{
+		c.r*c.a + bg.r*(1-c.a),
+		c.g*c.a + bg.g*(1-c.a),
+		c.b*c.a + bg.b*(1-c.a),
+		1,
+	}
+}
+
+func (c rgba) opaque() rgba {
+	c.a = 1
+	return c
+}
+
+func (c rgba) hex() string {
+	to := func(v float64) int { return int(math.Round(v * 255)) }
+	return fmt.Sprintf("#%02x%02x%02x", to(c.r), to(c.g), to(c.b))
+}
+
+// luminance is the WCAG relative luminance of an opaque color.
+func (c rgba) luminance() float64 {
+	lin := func(v float64) float64 {
+		if v <= 0.03928 {
+			return v / 12.92
+		}
+		return math.Pow((v+0.055)/1.055, 2.4)
+	}
+	return 0.2126*lin(c.r) + 0.7152*lin(c.g) + 0.0722*lin(c.b)
+}
+
+func contrast(a, b rgba) float64 {
+	la, lb := a.luminance(), b.luminance()
+	if la < lb {
+		la, lb = lb, la
+	}
+	return (la + 0.05) / (lb + 0.05)
+}
+
+// DominantColor is the average color of an image sampled on a grid, as
+// "#rrggbb". Uploads store it so themes can be linted against their
+// wallpaper without decoding the image again.
+func DominantColor(img image.Image) string {
+	const grid = 64
+	bounds := img.Bounds()
+	if bounds.Empty() {
+		return "#000000"
+	}
+	var sum rgba
+	var n float64
+	for y := 0; y < grid; y++ {
+		for x := 0; x < grid; x++ {
+			px := bounds.Min.X + x*bounds.Dx()/grid
+			py := bounds.Min.Y + y*bounds.Dy()/grid
+			r, g, b, a := img.At(px, py).RGBA()
+			if a == 0 {
+				continue
+			}
+			// RGBA() is alpha-premultiplied 16-bit.
+			sum.r += float64(r) / float64(a)
+			sum.g += float64(g) / float64(a)
+			sum.b += float64(b) / float64(a)
+			n++
+		}
+	}
+	if n == 0 {
+		return "#000000"
+	}
+	return rgba{sum.r / n, sum.g / n, sum.b / n, 1}.hex()
+}
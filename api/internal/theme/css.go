@@ -0,0 +1,278 @@
+package theme
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// CSS custom property names:
+//
+//	tokens.color.gray.900          --t-color-gray-900
+//	semantic.color.text.default    --color-text-default
+//	page.layout.maxWidth           --page-layout-max-width
+//	background.effects.blur        --background-effects-blur
+//
+// Values that reference a token ("tokens.color.gray.900", or the short
+// "color.gray.900") become var() references, so a mode that overrides a
+// token also changes every semantic value built on it.
+
+// Renderers set data-mode on the root element to the page's mode; "auto"
+// follows prefers-color-scheme between the light and dark modes.
+const ModeAuto = "auto"
+
+var (
+	cssNameRegex  = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+	cssUnsafe     = regexp.MustCompile(`(?i)[;{}<>\\"\n\r]|url\(|expression\(|@import|/\*`)
+	camelBoundary = regexp.MustCompile(`([a-z0-9])([A-Z])`)
+)
+
+// pxKeys are numeric settings measured in pixels; other numbers (weights,
+// line heights, opacity, z-index) stay unitless.
+var pxKeys = map[string]bool{
+	"maxWidth": true, "pagePadding": true, "blockGap": true, "gap": true,
+	"padding": true, "margin": true, "radius": true, "borderRadius": true,
+	"borderWidth": true, "blur": true, "fontSize": true, "size": true,
+	"width": true, "height": true,
+}
+
+// pxTokenGroups are token groups whose numbers are pixels.
+var pxTokenGroups = map[string]bool{"space": true, "size": true, "radius": true, "breakpoint": true}
+
+// recipeProps maps recipe keys to CSS properties. Keys not listed become
+// custom properties on the recipe class instead.
+var recipeProps = map[string]string{
+	"background": "background", "backgroundColor": "background-color",
+	"color": "color", "padding": "padding", "margin": "margin", "gap": "gap",
+	"radius": "border-radius", "borderRadius": "border-radius",
+	"border": "border", "borderColor": "border-color", "borderWidth": "border-width",
+	"shadow": "box-shadow", "boxShadow": "box-shadow",
+	"backdropFilter": "backdrop-filter", "opacity": "opacity",
+	"fontFamily": "font-family", "fontSize": "font-size", "fontWeight": "font-weight",
+	"lineHeight": "line-height", "textAlign": "text-align", "letterSpacing": "letter-spacing",
+	"transition": "transition",
+}
+
+// CSS renders a compiled theme as a stylesheet of custom properties, mode
+// overrides and recipe classes. The output depends only on config, so its
+// hash can name an immutable URL.
+func CSS(config json.RawMessage) ([]byte, error) {
+	var doc map[string]interface{}
+	if err := json.Unmarshal(config, &doc); err != nil {
+		return nil, err
+	}
+	tokens, _ := doc["tokens"].(map[string]interface{})
+	r := &cssRenderer{tokens: tokens}
+
+	var b bytes.Buffer
+	b.WriteString("/* generated from theme config; do not edit */\n")
+	writeRule(&b, "", ":root", r.variables(doc))
+
+	modes, _ := doc["modes"].(map[string]interface{})
+	for _, name := range sortedKeys(modes) {
+		override, ok := modes[name].(map[string]interface{})
+		if !ok || !cssNameRegex.MatchString(name) {
+			continue
+		}
+		if t, ok := override["tokens"].(map[string]interface{}); ok {
+			// Token references in the mode resolve against the merged
+			// tokens, so a mode may introduce new ones.
+			r.tokens = mergeMaps(tokens, t)
+		}
+		decls := r.variables(override)
+		r.tokens = tokens
+		writeRule(&b, "", fmt.Sprintf(`:root[data-mode="%s"]`, name), decls)
+		if name == "light" || name == "dark" {
+			b.WriteString("@media (prefers-color-scheme: " + name + ") {\n")
+			writeRule(&b, "  ", `:root[data-mode="`+ModeAuto+`"]`, decls)
+			b.WriteString("}\n")
+		}
+	}
+
+	recipes, _ := doc["recipes"].(map[string]interface{})
+	for _, name := range sortedKeys(recipes) {
+		recipe, ok := recipes[name].(map[string]interface{})
+		if !ok || !cssNameRegex.MatchString(name) {
+			continue
+		}
+		class := ".lb-" + kebab(name)
+		if base, ok := recipe["base"].(map[string]interface{}); ok {
+			writeRule(&b, "", class, r.recipeDecls(name, base))
+		}
+		variants, _ := recipe["variants"].(map[string]interface{})
+		for _, axis := range sortedKeys(variants) {
+			values, ok := variants[axis].(map[string]interface{})
+			if !ok || !cssNameRegex.MatchString(axis) {
+				continue
+			}
+			for _, value := range sortedKeys(values) {
+				style, ok := values[value].(map[string]interface{})
+				if !ok || !cssNameRegex.MatchString(value) {
+					continue
+				}
+				writeRule(&b, "", class+"--"+kebab(axis)+"-"+kebab(value), r.recipeDecls(name, style))
+			}
+		}
+	}
+	return b.Bytes(), nil
+}
+
+type cssRenderer struct {
+	tokens map[string]interface{}
+}
+
+// variables flattens the token, semantic, page and background sections.
+func (r *cssRenderer) variables(doc map[string]interface{}) []string {
+	var decls []string
+	sections := []struct{ key, prefix string }{
+		{"tokens", "--t"},
+		{"semantic", "-"},
+		{"page", "--page"},
+		{"background", "--background"},
+	}
+	for _, s := range sections {
+		section, _ := doc[s.key].(map[string]interface{})
+		r.flatten(&decls, s.prefix, s.key, nil, section)
+	}
+	return decls
+}
+
+func (r *cssRenderer) flatten(decls *[]string, prefix, section string, path []string, node map[string]interface{}) {
+	for _, key := range sortedKeys(node) {
+		if !cssNameRegex.MatchString(key) {
+			continue
+		}
+		p := append(path[:len(path):len(path)], key)
+		if child, ok := node[key].(map[string]interface{}); ok {
+			r.flatten(decls, prefix, section, p, child)
+			continue
+		}
+		px := pxKeys[key] || (section == "tokens" && pxTokenGroups[p[0]])
+		value, ok := r.value(node[key], px)
+		if !ok {
+			continue
+		}
+		*decls = append(*decls, prefix+"-"+kebabPath(p)+": "+value)
+	}
+}
+
+func (r *cssRenderer) recipeDecls(recipe string, style map[string]interface{}) []string {
+	var decls []string
+	for _, key := range sortedKeys(style) {
+		if !cssNameRegex.MatchString(key) {
+			continue
+		}
+		if key == "columns" {
+			if n, ok := style[key].(float64); ok && n >= 1 && n <= 12 && n == float64(int(n)) {
+				decls = append(decls, fmt.Sprintf("grid-template-columns: repeat(%d, minmax(0, 1fr))", int(n)))
+			}
+			continue
+		}
+		value, ok := r.value(style[key], pxKeys[key])
+		if !ok {
+			continue
+		}
+		if prop, ok := recipeProps[key]; ok {
+			decls = append(decls, prop+": "+value)
+		} else {
+			decls = append(decls, "--"+kebab(recipe)+"-"+kebab(key)+": "+value)
+		}
+	}
+	return decls
+}
+
+// value renders a leaf, dropping anything that could escape the
+// declaration.
+func (r *cssRenderer) value(v interface{}, px bool) (string, bool) {
+	switch v := v.(type) {
+	case float64:
+		s := strconv.FormatFloat(v, 'f', -1, 64)
+		if px && v != 0 {
+			s += "px"
+		}
+		return s, true
+	case string:
+		if v == "" || cssUnsafe.MatchString(v) {
+			return "", false
+		}
+		if ref, ok := r.tokenRef(v); ok {
+			return ref, true
+		}
+		return v, true
+	}
+	return "", false
+}
+
+// tokenRef turns "tokens.a.b" or "a.b" into var(--t-a-b) when that token
+// exists.
+func (r *cssRenderer) tokenRef(s string) (string, bool) {
+	path := strings.Split(strings.TrimPrefix(s, "tokens."), ".")
+	var node interface{} = r.tokens
+	for _, seg := range path {
+		m, ok := node.(map[string]interface{})
+		if !ok || !cssNameRegex.MatchString(seg) {
+			return "", false
+		}
+		if node, ok = m[seg]; !ok {
+			return "", false
+		}
+	}
+	if _, isMap := node.(map[string]interface{}); isMap {
+		return "", false
+	}
+	return "var(--t-" + kebabPath(path) + ")", true
+}
+
+func writeRule(b *bytes.Buffer, indent, selector string, decls []string) {
+	if len(decls) == 0 {
+		return
+	}
+	b.WriteString(indent + selector + " {\n")
+	for _, d := range decls {
+		b.WriteString(indent + "  " + d + ";\n")
+	}
+	b.WriteString(indent + "}\n")
+}
+
+func kebab(s string) string {
+	return strings.ToLower(camelBoundary.ReplaceAllString(s, "$1-$2"))
+}
+
+func kebabPath(path []string) string {
+	parts := make([]string, len(path))
+	for i, p := range path {
+		parts[i] = kebab(p)
+	}
+	return strings.Join(parts, "-")
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// mergeMaps deep-merges src over dst without modifying either.
+func mergeMaps(dst, src map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(dst)+len(src))
+	for k, v := range dst {
+		out[k] = v
+	}
+	for k, v := range src {
+		if d, ok := out[k].(map[string]interface{}); ok {
+			if s, ok := v.(map[string]interface{}); ok {
+				out[k] = mergeMaps(d, s)
+				continue
+			}
+		}
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,185 @@
+package theme
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SchemaMigration upgrades theme JSON written for schema From to From+1.
+// Renames map old key paths to new ones; Removed key paths are dropped.
+type SchemaMigration struct {
+	From    int
+	Renames map[string]string
+	Removed []string
+}
+
+// schemaMigrations is ordered by From. Schema 1 is current, so there is
+// nothing to apply yet; add an entry here with every SchemaVersion bump.
+var schemaMigrations []SchemaMigration
+
+// MigrateDoc applies the schema migrations from..to to a theme config or
+// patch in place.
+func MigrateDoc(doc map[string]interface{}, from, to int) error {
+	if from > to {
+		return fmt.Errorf("cannot migrate theme schema %d down to %d", from, to)
+	}
+	for v := from; v < to; v++ {
+		m, ok := findMigration(v)
+		if !ok {
+			return fmt.Errorf("no migration from theme schema %d", v)
+		}
+		for oldPath, newPath := range m.Renames {
+			if val, ok := deletePath(doc, oldPath); ok {
+				setPath(doc, newPath, val)
+			}
+		}
+		for _, p := range m.Removed {
+			deletePath(doc, p)
+		}
+	}
+	return nil
+}
+
+// RebaseResult is a custom theme patch moved onto another preset version.
+type RebaseResult struct {
+	Patch json.RawMessage `json:"patch"`
+	// Missing lists patch key paths the target version no longer has.
+	// They are dropped from Patch.
+	Missing []string `json:"missing_keys"`
+}
+
+// Rebase moves patch, written against the from config, onto the to
+// config: it migrates the patch to the target schema, then drops keys
+// that the old version had but the new one removed. Keys neither version
+// defines are kept, since patches may add optional settings.
+func Rebase(patch, from, to json.RawMessage) (*RebaseResult, error) {
+	fromMeta, err := ReadMeta(from)
+	if err != nil {
+		return nil, err
+	}
+	toMeta, err := ReadMeta(to)
+	if err != nil {
+		return nil, err
+	}
+
+	var patchDoc, fromDoc, toDoc map[string]interface{}
+	if len(patch) > 0 {
+		if err := json.Unmarshal(patch, &patchDoc); err != nil {
+			return nil, err
+		}
+	}
+	if patchDoc == nil {
+		patchDoc = map[string]interface{}{}
+	}
+	if err := json.Unmarshal(from, &fromDoc); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(to, &toDoc); err != nil {
+		return nil, err
+	}
+
+	if err := MigrateDoc(patchDoc, fromMeta.SchemaVersion, toMeta.SchemaVersion); err != nil {
+		return nil, err
+	}
+	if err := MigrateDoc(fromDoc, fromMeta.SchemaVersion, toMeta.SchemaVersion); err != nil {
+		return nil, err
+	}
+
+	result := &RebaseResult{Missing: []string{}}
+	for _, p := range leafPaths(patchDoc, "") {
+		if hasPath(fromDoc, p) && !hasPath(toDoc, p) {
+			deletePath(patchDoc, p)
+			result.Missing = append(result.Missing, p)
+		}
+	}
+	sort.Strings(result.Missing)
+
+	result.Patch, err = json.Marshal(patchDoc)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func findMigration(from int) (SchemaMigration, bool) {
+	for _, m := range schemaMigrations {
+		if m.From == from {
+			return m, true
+		}
+	}
+	return SchemaMigration{}, false
+}
+
+// leafPaths lists the dotted paths of every non-object value.
+func leafPaths(doc map[string]interface{}, prefix string) []string {
+	var paths []string
+	for k, v := range doc {
+		p := k
+		if prefix != "" {
+			p = prefix + "." + k
+		}
+		if child, ok := v.(map[string]interface{}); ok && len(child) > 0 {
+			paths = append(paths, leafPaths(child, p)...)
+			continue
+		}
+		paths = append(paths, p)
+	}
+	return paths
+}
+
+func hasPath(doc map[string]interface{}, path string) bool {
+	_, ok := getPath(doc, path)
+	return ok
+}
+
+func getPath(doc map[string]interface{}, path string) (interface{}, bool) {
+	var cur interface{} = doc
+	for _, seg := range strings.Split(path, ".") {
+		m, ok := cur.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if cur, ok = m[seg]; !ok {
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+func setPath(doc map[string]interface{}, path string, val interface{}) {
+	segs := strings.Split(path, ".")
+	cur := doc
+	for _, seg := range segs[:len(segs)-1] {
+		next, ok := cur[seg].(map[string]interface{})
+		if !ok {
+			next = map[string]interface{}{}
+			cur[seg] = next
+		}
+		cur = next
+	}
+	cur[segs[len(segs)-1]] = val
+}
+
+// deletePath removes path and any objects it leaves empty.
+func deletePath(doc map[string]interface{}, path string) (interface{}, bool) {
+	segs := strings.SplitN(path, ".", 2)
+	val, ok := doc[segs[0]]
+	if !ok {
+		return nil, false
+	}
+	if len(segs) == 1 {
+		delete(doc, segs[0])
+		return val, true
+	}
+	child, ok := val.(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	removed, ok := deletePath(child, segs[1])
+	if ok && len(child) == 0 {
+		delete(doc, segs[0])
+	}
+	return removed, ok
+}
@@ -0,0 +1,88 @@
+package theme
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// withMigrations swaps in a schema 1 -> 2 migration for the test.
+func withMigrations(t *testing.T) {
+	saved := schemaMigrations
+	schemaMigrations = []SchemaMigration{{
+		From:    1,
+		Renames: map[string]string{"colors.bg": "palette.background"},
+		Removed: []string{"legacy.shadow"},
+	}}
+	t.Cleanup(func() { schemaMigrations = saved })
+}
+
+func TestMigrateDoc(t *testing.T) {
+	withMigrations(t)
+
+	var doc map[string]interface{}
+	must(t, json.Unmarshal([]byte(`{
+		"colors": {"bg": "#fff", "text": "#000"},
+		"legacy": {"shadow": true}
+	}`), &doc))
+	must(t, MigrateDoc(doc, 1, 2))
+
+	var want map[string]interface{}
+	must(t, json.Unmarshal([]byte(`{
+		"colors": {"text": "#000"},
+		"palette": {"background": "#fff"}
+	}`), &want))
+	if !reflect.DeepEqual(doc, want) {
+		t.Errorf("MigrateDoc = %v, want %v", doc, want)
+	}
+
+	if err := MigrateDoc(doc, 2, 3); err == nil {
+		t.Error("MigrateDoc without a migration from 2 succeeded")
+	}
+	if err := MigrateDoc(doc, 2, 1); err == nil {
+		t.Error("MigrateDoc down succeeded")
+	}
+}
+
+func TestRebaseAcrossSchemas(t *testing.T) {
+	withMigrations(t)
+
+	from := json.RawMessage(`{
+		"meta": {"schemaVersion": 1},
+		"colors": {"bg": "#fff", "accent": "#00f"},
+		"legacy": {"shadow": false}
+	}`)
+	to := json.RawMessage(`{
+		"meta": {"schemaVersion": 2},
+		"palette": {"background": "#eee"}
+	}`)
+	patch := json.RawMessage(`{
+		"colors": {"bg": "#111", "accent": "#f00"},
+		"legacy": {"shadow": true},
+		"custom": {"note": "kept"}
+	}`)
+
+	got, err := Rebase(patch, from, to)
+	must(t, err)
+
+	var doc map[string]interface{}
+	must(t, json.Unmarshal(got.Patch, &doc))
+	var want map[string]interface{}
+	must(t, json.Unmarshal([]byte(`{
+		"palette": {"background": "#111"},
+		"custom": {"note": "kept"}
+	}`), &want))
+	if !reflect.DeepEqual(doc, want) {
+		t.Errorf("patch = %s, want the renamed key, the removed ones dropped", got.Patch)
+	}
+	if !reflect.DeepEqual(got.Missing, []string{"colors.accent"}) {
+		t.Errorf("missing = %v, want [colors.accent]", got.Missing)
+	}
+}
+
+func must(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,95 @@
+package theme
+
+import (
+	"encoding/json"
+	"image/color"
+	"math"
+)
+
+// Palette is what a theme looks like outside the browser: the colors and
+// wallpaper used to draw share images.
+type Palette struct {
+	Background color.NRGBA
+	Text       color.NRGBA
+	Muted      color.NRGBA
+	Primary    color.NRGBA
+	// WallpaperAssetID is 0 without a wallpaper. Dim and Overlay are drawn
+	// over it, as on the page.
+	WallpaperAssetID int64
+	Dim              float64
+	Overlay          color.NRGBA
+}
+
+// ResolvePalette reads a compiled theme's palette in the given mode. Modes
+// the theme does not override, including auto, use the default colors.
+func ResolvePalette(config json.RawMessage, mode string) Palette {
+	var doc map[string]interface{}
+	if err := json.Unmarshal(config, &doc); err != nil {
+		doc = map[string]interface{}{}
+	}
+	modes, _ := doc["modes"].(map[string]interface{})
+	delete(doc, "modes")
+	if override, ok := modes[mode].(map[string]interface{}); ok {
+		doc = mergeMaps(doc, override)
+	}
+	l := &linter{doc: doc}
+
+	pick := func(fallback rgba, paths ...string) rgba {
+		for _, path := range paths {
+			if c, ok := l.color(path); ok {
+				return c
+			}
+		}
+		return fallback
+	}
+	bg := pick(white, "background.color", "semantic.color.surface.page").over(white)
+	text := pick(rgba{0.11, 0.11, 0.12, 1}, "semantic.color.text.default").over(bg)
+	muted := pick(rgba{text.r, text.g, text.b, 0.65}, "semantic.color.text.muted").over(bg)
+	primary := pick(rgba{0, 0.48, 1, 1}, "semantic.color.primary").over(bg)
+
+	p := Palette{
+		Background: bg.toNRGBA(),
+		Text:       text.toNRGBA(),
+		Muted:      muted.toNRGBA(),
+		Primary:    primary.toNRGBA(),
+	}
+	if id, ok := l.wallpaperAsset(); ok {
+		p.WallpaperAssetID = id
+		dim, _ := l.lookup("background.effects.dim").(float64)
+		p.Dim = math.Max(0, math.Min(1, dim))
+		if c, ok := l.color("background.effects.overlayColor"); ok {
+			p.Overlay = c.toNRGBA()
+		}
+	}
+	return p
+}
+
+// toNRGBA converts to an 8-bit color with straight alpha.
+func (c rgba) toNRGBA() color.NRGBA {
+	to := func(v float64) uint8 { return uint8(math.Round(v * 255)) }
+	return color.NRGBA{to(c.r), to(c.g), to(c.b), to(c.a)}
+}
+
+// ParseColor reads a color written the way themes write them: hex, rgb()
+// or rgba(). Translucent colors are flattened onto white.
+func ParseColor(s string) (color.NRGBA, bool) {
+	c, ok := parseColor(s)
+	if !ok {
+		return color.NRGBA{}, false
+	}
+	return c.over(white).toNRGBA(), true
+}
+
+// Contrast is the WCAG contrast ratio between two opaque colors.
+func Contrast(a, b color.NRGBA) float64 {
+	return contrast(fromNRGBA(a), fromNRGBA(b))
+}
+
+func fromNRGBA(c color.NRGBA) rgba {
+	return rgba{float64(c.R) / 255, float64(c.G) / 255, float64(c.B) / 255, float64(c.A) / 255}
+}
+
+// Luminance is the WCAG relative luminance of an opaque color.
+func Luminance(c color.NRGBA) float64 {
+	return fromNRGBA(c).luminance()
+}
@@ -0,0 +1,223 @@
+// Package theme holds the theme schema: validation of preset configs as
+// described in docs/theme_system_spec_10_10_final.md.
+package theme
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+
+	"linkbio/internal/apperr"
+)
+
+// MaxConfigBytes caps a submitted preset config.
+const MaxConfigBytes = 64 << 10
+
+// SchemaVersion is the newest theme schema the API understands.
+const SchemaVersion = 1
+
+var (
+	semverRegex  = regexp.MustCompile(`^(0|[1-9]\d*)\.(0|[1-9]\d*)\.(0|[1-9]\d*)(-[0-9A-Za-z.-]+)?$`)
+	keyPathRegex = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9]*(\.[A-Za-z0-9]+)*$`)
+
+	sections      = []string{"meta", "tokens", "semantic", "recipes", "page", "background", "modes"}
+	controlTypes  = map[string]bool{"select": true, "slider": true, "color": true, "toggle": true, "number": true}
+	layoutVariant = []string{"list", "cards", "grid"}
+)
+
+// Validate checks a full theme config against the schema and returns one
+// field error per problem, with dotted paths such as "meta.version".
+func Validate(config json.RawMessage) []apperr.FieldError {
+	v := &validator{}
+	if len(config) > MaxConfigBytes {
+		v.add("config", "too_large", "theme.config_too_large")
+		return v.errs
+	}
+
+	var root map[string]interface{}
+	if err := json.Unmarshal(config, &root); err != nil || root == nil {
+		v.add("config", "invalid", "theme.config_invalid")
+		return v.errs
+	}
+
+	for key := range root {
+		if !contains(sections, key) {
+			v.add(key, "unknown", "theme.unknown_section")
+		}
+	}
+	for _, s := range []string{"meta", "semantic", "recipes", "page", "background"} {
+		v.object(root, s, true)
+	}
+	v.object(root, "tokens", false)
+	v.object(root, "modes", false)
+
+	if meta, ok := root["meta"].(map[string]interface{}); ok {
+		v.validateMeta(meta)
+	}
+	if recipes, ok := root["recipes"].(map[string]interface{}); ok {
+		v.validateRecipes(recipes)
+	}
+	if page, ok := root["page"].(map[string]interface{}); ok {
+		v.object(page, "page.layout", true)
+		if defaults := v.object(page, "page.defaults", true); defaults != nil {
+			v.object(defaults, "page.defaults.linkGroup", true)
+		}
+	}
+	if bg, ok := root["background"].(map[string]interface{}); ok {
+		if effects := v.object(bg, "background.effects", false); effects != nil {
+			v.number(effects, "background.effects.blur", 0, 12)
+			v.number(effects, "background.effects.dim", 0, 0.8)
+		}
+	}
+	return v.errs
+}
+
+func (v *validator) validateMeta(meta map[string]interface{}) {
+	v.str(meta, "meta.name", true)
+	v.str(meta, "meta.description", true)
+	if version := v.str(meta, "meta.version", true); version != "" && !semverRegex.MatchString(version) {
+		v.add("meta.version", "format", "theme.invalid_semver")
+	}
+
+	switch sv := meta["schemaVersion"].(type) {
+	case nil:
+		v.add("meta.schemaVersion", "required", "request.required")
+	case float64:
+		if sv != float64(int(sv)) || sv < 1 || sv > SchemaVersion {
+			v.add("meta.schemaVersion", "unsupported", "theme.unsupported_schema_version")
+		}
+	default:
+		v.add("meta.schemaVersion", "type", "theme.invalid_type")
+	}
+
+	if supports := v.object(meta, "meta.supports", false); supports != nil {
+		if modes, ok := supports["modes"]; ok {
+			list, ok := modes.([]interface{})
+			if !ok {
+				v.add("meta.supports.modes", "type", "theme.invalid_type")
+			}
+			for _, m := range list {
+				if s, _ := m.(string); s != "light" && s != "dark" && s != "compact" {
+					v.add("meta.supports.modes", "enum", "theme.invalid_mode")
+					break
+				}
+			}
+		}
+	}
+
+	contract := v.object(meta, "meta.contract", false)
+	if contract == nil {
+		return
+	}
+	controls, ok := contract["controls"]
+	if !ok {
+		return
+	}
+	list, ok := controls.([]interface{})
+	if !ok {
+		v.add("meta.contract.controls", "type", "theme.invalid_type")
+		return
+	}
+	for _, c := range list {
+		ctrl, ok := c.(map[string]interface{})
+		if !ok {
+			v.add("meta.contract.controls", "type", "theme.invalid_type")
+			continue
+		}
+		if kp, _ := ctrl["keyPath"].(string); !keyPathRegex.MatchString(kp) || !contains(sections, strings.SplitN(kp, ".", 2)[0]) {
+			v.add("meta.contract.controls.keyPath", "format", "theme.invalid_key_path")
+		}
+		if t, _ := ctrl["type"].(string); !controlTypes[t] {
+			v.add("meta.contract.controls.type", "enum", "theme.invalid_control_type")
+		}
+	}
+}
+
+func (v *validator) validateRecipes(recipes map[string]interface{}) {
+	v.object(recipes, "recipes.linkItem", true)
+	group := v.object(recipes, "recipes.linkGroup", true)
+	if group == nil {
+		return
+	}
+	variants := v.object(group, "recipes.linkGroup.variants", true)
+	if variants == nil {
+		return
+	}
+	layout := v.object(variants, "recipes.linkGroup.variants.layout", true)
+	if layout == nil {
+		return
+	}
+	for _, name := range layoutVariant {
+		v.object(layout, "recipes.linkGroup.variants.layout."+name, true)
+	}
+}
+
+type validator struct {
+	errs []apperr.FieldError
+}
+
+func (v *validator) add(field, code, messageKey string) {
+	v.errs = append(v.errs, apperr.FieldError{Field: field, Code: code, MessageKey: messageKey})
+}
+
+// object returns parent[last segment of path] as an object, recording an
+// error when it is missing (and required) or not an object.
+func (v *validator) object(parent map[string]interface{}, path string, required bool) map[string]interface{} {
+	raw, ok := parent[lastSegment(path)]
+	if !ok || raw == nil {
+		if required {
+			v.add(path, "required", "request.required")
+		}
+		return nil
+	}
+	obj, ok := raw.(map[string]interface{})
+	if !ok {
+		v.add(path, "type", "theme.invalid_type")
+		return nil
+	}
+	return obj
+}
+
+func (v *validator) str(parent map[string]interface{}, path string, required bool) string {
+	raw, ok := parent[lastSegment(path)]
+	if !ok {
+		if required {
+			v.add(path, "required", "request.required")
+		}
+		return ""
+	}
+	s, ok := raw.(string)
+	if !ok || (required && strings.TrimSpace(s) == "") {
+		v.add(path, "type", "theme.invalid_type")
+		return ""
+	}
+	return s
+}
+
+func (v *validator) number(parent map[string]interface{}, path string, min, max float64) {
+	raw, ok := parent[lastSegment(path)]
+	if !ok {
+		return
+	}
+	n, ok := raw.(float64)
+	if !ok {
+		v.add(path, "type", "theme.invalid_type")
+		return
+	}
+	if n < min || n > max {
+		v.add(path, "range", "theme.out_of_range")
+	}
+}
+
+func lastSegment(path string) string {
+	return path[strings.LastIndex(path, ".")+1:]
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,119 @@
+package theme
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version is a parsed semver version. Pre-release tags sort before the
+// release they precede.
+type Version struct {
+	Major, Minor, Patch int
+	Pre                 string
+}
+
+func ParseVersion(s string) (Version, error) {
+	if !semverRegex.MatchString(s) {
+		return Version{}, fmt.Errorf("invalid semver %q", s)
+	}
+	core, pre, _ := strings.Cut(s, "-")
+	parts := strings.Split(core, ".")
+	var v Version
+	v.Major, _ = strconv.Atoi(parts[0])
+	v.Minor, _ = strconv.Atoi(parts[1])
+	v.Patch, _ = strconv.Atoi(parts[2])
+	v.Pre = pre
+	return v, nil
+}
+
+// Compare returns -1, 0 or 1 as v is older than, equal to or newer than o.
+func (v Version) Compare(o Version) int {
+	for _, d := range []int{v.Major - o.Major, v.Minor - o.Minor, v.Patch - o.Patch} {
+		if d != 0 {
+			return sign(d)
+		}
+	}
+	switch {
+	case v.Pre == o.Pre:
+		return 0
+	case v.Pre == "":
+		return 1
+	case o.Pre == "":
+		return -1
+	}
+	return comparePre(v.Pre, o.Pre)
+}
+
+// comparePre orders pre-release tags by semver precedence: identifier by
+// identifier, numeric ones by value and below alphanumeric ones, and a
+// tag that runs out first is older.
+func comparePre(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		an, aErr := strconv.ParseUint(as[i], 10, 64)
+		bn, bErr := strconv.ParseUint(bs[i], 10, 64)
+		switch {
+		case aErr == nil && bErr == nil:
+			if an != bn {
+				if an < bn {
+					return -1
+				}
+				return 1
+			}
+		case aErr == nil:
+			return -1
+		case bErr == nil:
+			return 1
+		default:
+			if c := strings.Compare(as[i], bs[i]); c != 0 {
+				return c
+			}
+		}
+	}
+	if len(as) == len(bs) {
+		return 0
+	}
+	return sign(len(as) - len(bs))
+}
+
+func (v Version) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.Pre != "" {
+		s += "-" + v.Pre
+	}
+	return s
+}
+
+// Meta is the part of config.meta the API relies on.
+type Meta struct {
+	Name          string `json:"name"`
+	Version       string `json:"version"`
+	SchemaVersion int    `json:"schemaVersion"`
+	Supports      struct {
+		Modes []string `json:"modes"`
+	} `json:"supports"`
+}
+
+// ReadMeta decodes config.meta, defaulting schemaVersion to 1 for the
+// presets written before it was required.
+func ReadMeta(config json.RawMessage) (Meta, error) {
+	var doc struct {
+		Meta Meta `json:"meta"`
+	}
+	if err := json.Unmarshal(config, &doc); err != nil {
+		return Meta{}, err
+	}
+	if doc.Meta.SchemaVersion == 0 {
+		doc.Meta.SchemaVersion = 1
+	}
+	return doc.Meta, nil
+}
+
+func sign(d int) int {
+	if d < 0 {
+		return -1
+	}
+	return 1
+}
@@ -0,0 +1,126 @@
+package urlpolicy
+
+import (
+	"bufio"
+	"context"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Blocklist is a set of blocked domains read from a file, one per line.
+// Blocking a domain blocks its subdomains too. Blank lines and lines
+// starting with # are ignored, as is a leading "*.".
+type Blocklist struct {
+	path string
+
+	mu      sync.RWMutex
+	domains map[string]bool
+	modTime time.Time
+}
+
+// LoadBlocklist reads the file at path. An empty path blocks nothing.
+func LoadBlocklist(path string) (*Blocklist, error) {
+	b := &Blocklist{path: path, domains: map[string]bool{}}
+	if path == "" {
+		return b, nil
+	}
+	if _, err := b.Reload(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Blocks reports whether host or one of its parent domains is listed.
+func (b *Blocklist) Blocks(host string) bool {
+	if b == nil {
+		return false
+	}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for {
+		if b.domains[host] {
+			return true
+		}
+		i := strings.IndexByte(host, '.')
+		if i < 0 {
+			return false
+		}
+		host = host[i+1:]
+	}
+}
+
+// Len is the number of listed domains.
+func (b *Blocklist) Len() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.domains)
+}
+
+// Reload rereads the file if it changed since the last read, and reports
+// whether it did.
+func (b *Blocklist) Reload() (bool, error) {
+	info, err := os.Stat(b.path)
+	if err != nil {
+		return false, err
+	}
+	b.mu.RLock()
+	unchanged := info.ModTime().Equal(b.modTime)
+	b.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	f, err := os.Open(b.path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	domains := make(map[string]bool)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(strings.ToLower(line), "*.")
+		// Entries may be written in Unicode; hosts are checked in ASCII.
+		if ascii, err := profile.ToASCII(strings.TrimSuffix(line, ".")); err == nil {
+			domains[ascii] = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return false, err
+	}
+
+	b.mu.Lock()
+	b.domains = domains
+	b.modTime = info.ModTime()
+	b.mu.Unlock()
+	return true, nil
+}
+
+// Watch rereads the file every interval until ctx is done. A file that
+// fails to read leaves the last good list in place.
+func (b *Blocklist) Watch(ctx context.Context, interval time.Duration) {
+	if b.path == "" {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		reloaded, err := b.Reload()
+		if err != nil {
+			log.Printf("[URLPolicy] reload %s: %v", b.path, err)
+		} else if reloaded {
+			log.Printf("[URLPolicy] loaded %d blocked domains", b.Len())
+		}
+	}
+}
@@ -0,0 +1,189 @@
+// Package urlpolicy decides which URLs links may point to. It normalizes
+// what users type into one canonical form, allows only a few schemes and
+// refuses hosts on a blocklist.
+package urlpolicy
+
+import (
+	"errors"
+	"net"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+var (
+	ErrEmpty     = errors.New("urlpolicy: empty URL")
+	ErrMalformed = errors.New("urlpolicy: malformed URL")
+	ErrScheme    = errors.New("urlpolicy: scheme not allowed")
+	ErrHost      = errors.New("urlpolicy: invalid host")
+	ErrBlocked   = errors.New("urlpolicy: host is blocked")
+)
+
+// Schemes are the schemes links may use.
+var Schemes = []string{"http", "https", "mailto", "tel", "sms"}
+
+const maxLength = 2048
+
+var (
+	schemePrefix = regexp.MustCompile(`^([A-Za-z][A-Za-z0-9+.-]*):`)
+	label        = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+	tld          = regexp.MustCompile(`^([a-z]{2,63}|xn--[a-z0-9-]{1,59})$`)
+	phone        = regexp.MustCompile(`^\+?[0-9]{3,20}$`)
+	phoneFormat  = strings.NewReplacer(" ", "", "-", "", ".", "", "(", "", ")", "")
+)
+
+var profile = idna.New(idna.MapForLookup(), idna.BidiRule(), idna.Transitional(false))
+
+// Policy checks URLs against the allowed schemes and a blocklist.
+type Policy struct {
+	blocklist *Blocklist
+}
+
+// New returns a policy; a nil blocklist blocks nothing.
+func New(blocklist *Blocklist) *Policy {
+	return &Policy{blocklist: blocklist}
+}
+
+// Normalize returns the canonical form of raw, or why it is not allowed.
+// A URL without a scheme is taken as https; hosts are lowercased and
+// internationalized names are converted to punycode.
+func (p *Policy) Normalize(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", ErrEmpty
+	}
+	if len(raw) > maxLength || strings.ContainsFunc(raw, func(r rune) bool { return r < 0x20 || r == 0x7f }) {
+		return "", ErrMalformed
+	}
+
+	scheme := ""
+	if m := schemePrefix.FindStringSubmatch(raw); m != nil && !looksLikeHostPort(raw, m[1]) {
+		scheme = strings.ToLower(m[1])
+	}
+	switch scheme {
+	case "":
+		return p.web("https://" + raw)
+	case "http", "https":
+		return p.web(raw)
+	case "mailto":
+		return p.mailto(raw[len("mailto:"):])
+	case "tel", "sms":
+		return phoneURL(scheme, raw[len(scheme)+1:])
+	}
+	return "", ErrScheme
+}
+
+// looksLikeHostPort tells "example.com:8080/x" apart from a scheme.
+func looksLikeHostPort(raw, prefix string) bool {
+	for _, s := range Schemes {
+		if strings.EqualFold(prefix, s) {
+			return false
+		}
+	}
+	rest := raw[len(prefix)+1:]
+	return strings.Contains(prefix, ".") && !strings.HasPrefix(rest, "//") ||
+		rest != "" && rest[0] >= '0' && rest[0] <= '9'
+}
+
+func (p *Policy) web(raw string) (string, error) {
+	u, err := url.Parse(raw)
+	if err != nil || u.Opaque != "" {
+		return "", ErrMalformed
+	}
+	// user@host is how phishing links disguise their real host.
+	if u.User != nil {
+		return "", ErrHost
+	}
+	host, err := p.host(u.Hostname())
+	if err != nil {
+		return "", err
+	}
+	if port := u.Port(); port != "" {
+		host = net.JoinHostPort(host, port)
+	} else if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = host
+	return u.String(), nil
+}
+
+func (p *Policy) mailto(rest string) (string, error) {
+	addrs, query, _ := strings.Cut(rest, "?")
+	addrs, err := url.PathUnescape(addrs)
+	if err != nil || addrs == "" {
+		return "", ErrMalformed
+	}
+	out := make([]string, 0, 1)
+	for _, addr := range strings.Split(addrs, ",") {
+		parsed, err := mail.ParseAddress(strings.TrimSpace(addr))
+		if err != nil {
+			return "", ErrMalformed
+		}
+		local, domain, _ := strings.Cut(parsed.Address, "@")
+		if domain, err = p.host(domain); err != nil {
+			return "", err
+		}
+		out = append(out, local+"@"+domain)
+	}
+	s := "mailto:" + strings.Join(out, ",")
+	if query != "" {
+		if _, err := url.ParseQuery(query); err != nil {
+			return "", ErrMalformed
+		}
+		s += "?" + query
+	}
+	return s, nil
+}
+
+func phoneURL(scheme, rest string) (string, error) {
+	number, query, _ := strings.Cut(rest, "?")
+	number, err := url.PathUnescape(number)
+	if err != nil {
+		return "", ErrMalformed
+	}
+	number = phoneFormat.Replace(number)
+	if !phone.MatchString(number) {
+		return "", ErrMalformed
+	}
+	s := scheme + ":" + number
+	// Only sms: takes a prefilled body.
+	if query != "" && scheme == "sms" {
+		if _, err := url.ParseQuery(query); err != nil {
+			return "", ErrMalformed
+		}
+		s += "?" + query
+	}
+	return s, nil
+}
+
+// host validates a hostname, converts it to its ASCII form and checks it
+// against the blocklist.
+func (p *Policy) host(h string) (string, error) {
+	if ip := net.ParseIP(h); ip != nil {
+		if p.blocklist.Blocks(ip.String()) {
+			return "", ErrBlocked
+		}
+		return ip.String(), nil
+	}
+	h, err := profile.ToASCII(strings.TrimSuffix(h, "."))
+	if err != nil {
+		return "", ErrHost
+	}
+	labels := strings.Split(h, ".")
+	if len(labels) < 2 || len(h) > 253 || !tld.MatchString(labels[len(labels)-1]) {
+		return "", ErrHost
+	}
+	for _, l := range labels {
+		if !label.MatchString(l) {
+			return "", ErrHost
+		}
+	}
+	if p.blocklist.Blocks(h) {
+		return "", ErrBlocked
+	}
+	return h, nil
+}
@@ -0,0 +1,11 @@
+package util
+
+import "strings"
+
+// CanonicalPath normalises a route path: leading '/', no trailing '/'
+// (except root) and lower-case.
+func CanonicalPath(path string) string {
+	path = strings.ToLower(strings.TrimSpace(path))
+	path = "/" + strings.Trim(path, "/")
+	return path
+}
@@ -1,11 +1,26 @@
 package util
 
-import "github.com/gofiber/fiber/v2"
+import (
+	"math"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"linkbio/internal/apperr"
+)
 
 type Response struct {
-	Success bool        `json:"success"`
-	Data    interface{} `json:"data,omitempty"`
-	Error   string      `json:"error,omitempty"`
+	Success   bool        `json:"success"`
+	Data      interface{} `json:"data,omitempty"`
+	Error     *ErrorBody  `json:"error,omitempty"`
+	RequestID string      `json:"request_id,omitempty"`
+}
+
+// ErrorBody is the client-facing part of an apperr.Error.
+type ErrorBody struct {
+	Code       apperr.Code         `json:"code"`
+	Message    string              `json:"message"`
+	MessageKey string              `json:"message_key"`
+	Fields     []apperr.FieldError `json:"fields,omitempty"`
 }
 
 func OK(c *fiber.Ctx, data interface{}) error {
@@ -16,26 +31,25 @@ func Created(c *fiber.Ctx, data interface{}) error {
 	return c.Status(201).JSON(Response{Success: true, Data: data})
 }
 
-func Err(c *fiber.Ctx, status int, msg string) error {
-	return c.Status(status).JSON(Response{Success: false, Error: msg})
-}
-
-func BadRequest(c *fiber.Ctx, msg string) error {
-	return Err(c, 400, msg)
-}
-
-func Unauthorized(c *fiber.Ctx) error {
-	return Err(c, 401, "unauthorized")
-}
-
-func Forbidden(c *fiber.Ctx) error {
-	return Err(c, 403, "forbidden")
-}
-
-func NotFound(c *fiber.Ctx) error {
-	return Err(c, 404, "not found")
-}
-
-func InternalError(c *fiber.Ctx) error {
-	return Err(c, 500, "internal server error")
+// Fail writes e as the error envelope. The cause is never included.
+func Fail(c *fiber.Ctx, e *apperr.Error, requestID string) error {
+	return c.Status(e.Status).JSON(Response{
+		Success: false,
+		Error: &ErrorBody{
+			Code:       e.Code,
+			Message:    e.Message,
+			MessageKey: e.MessageKey,
+			Fields:     e.Fields,
+		},
+		RequestID: requestID,
+	})
+}
+
+// Seconds rounds d up to whole seconds for use in HTTP headers.
+func Seconds(d time.Duration) int {
+	s := int(math.Ceil(d.Seconds()))
+	if s < 0 {
+		return 0
+	}
+	return s
 }
@@ -1,5 +1,9 @@
 -- Link-in-Bio Builder — Initial Migration (Postgres) (Updated)
 -- One-shot schema creation. Run once on an empty database.
+-- Frozen at the baseline schema and kept for reference only; do not edit.
+-- Later changes exist only as versioned migrations in
+-- api/internal/database/migrations, which the API applies on top of this
+-- (`go run cmd/main.go migrate up`).
 -- Updated decisions:
 -- - Plans: Free/Pro. Custom domain + password page are Pro-only (enforced in app).
 -- - Custom domain: one domain maps to a single page at root '/' (enforced in app/infra).
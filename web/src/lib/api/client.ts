@@ -1,9 +1,23 @@
-const API_URL = 'http://localhost:8080';
+export const API_URL = 'http://localhost:8080';
+
+interface ApiFieldError {
+	field: string;
+	code: string;
+	message_key: string;
+}
+
+interface ApiError {
+	code: string;
+	message: string;
+	message_key: string;
+	fields?: ApiFieldError[];
+}
 
 interface ApiResponse<T> {
 	success: boolean;
 	data?: T;
-	error?: string;
+	error?: ApiError;
+	request_id?: string;
 }
 
 async function request<T>(
@@ -22,12 +36,39 @@ async function request<T>(
 	const json: ApiResponse<T> = await res.json();
 
 	if (!json.success) {
-		throw new Error(json.error || 'Request failed');
+		throw new Error(json.error?.message || 'Request failed');
 	}
 
 	return json.data as T;
 }
 
+// The public renderer answers with the compiled page itself, not the API
+// envelope; only errors use the envelope. Cookies go along so a visitor
+// keeps their experiment variant.
+async function render(endpoint: string): Promise<CompiledPage> {
+	const res = await fetch(`${API_URL}${endpoint}`, { credentials: 'include' });
+	if (!res.ok) {
+		const json: ApiResponse<never> = await res.json().catch(() => ({ success: false }));
+		throw new Error(json.error?.message || 'Page not found');
+	}
+	return res.json();
+}
+
+export const publicPages = {
+	byPath: (path: string) => render(`/r?path=${encodeURIComponent(path)}`),
+
+	preview: (token: string) => render(`/r/preview/${encodeURIComponent(token)}`),
+
+	// Counting views must never break the page.
+	recordView: (pageId: number, src: string, variant = '') =>
+		fetch(`${API_URL}/r/views`, {
+			method: 'POST',
+			headers: { 'Content-Type': 'application/json' },
+			body: JSON.stringify({ page_id: pageId, src, variant }),
+			keepalive: true
+		}).catch(() => {})
+};
+
 // Auth
 export const auth = {
 	register: (email: string, password: string) =>
@@ -119,17 +160,38 @@ export const bio = {
 	deleteLink: (id: number) =>
 		request(`/api/bio/links/${id}`, { method: 'DELETE' }),
 
+	shortLinks: (linkId: number) =>
+		request<ShortLink[]>(`/api/bio/links/${linkId}/short-links`),
+
+	// An empty alias gets a generated code.
+	createShortLink: (linkId: number, alias = '') =>
+		request<ShortLink>(`/api/bio/links/${linkId}/short-links`, {
+			method: 'POST',
+			body: JSON.stringify({ alias })
+		}),
+
+	deleteShortLink: (linkId: number, id: number) =>
+		request(`/api/bio/links/${linkId}/short-links/${id}`, { method: 'DELETE' }),
+
 	updateProfile: (displayName: string, bio: string) =>
 		request('/api/bio/profile', {
 			method: 'PUT',
 			body: JSON.stringify({ display_name: displayName, bio })
 		}),
 
-	updateSocial: (social: SocialLinks) =>
-		request('/api/bio/social', {
+	getSocial: () =>
+		request<{ links: SocialLink[] }>('/api/bio/social').then((r) => r.links),
+
+	// Each value is a handle or a profile URL; the saved row comes back with
+	// canonical URLs.
+	updateSocial: (links: SocialLinkInput[]) =>
+		request<{ links: SocialLink[] }>('/api/bio/social', {
 			method: 'PUT',
-			body: JSON.stringify(social)
-		})
+			body: JSON.stringify({ links })
+		}).then((r) => r.links),
+
+	socialPlatforms: () =>
+		request<SocialPlatform[]>('/api/social/platforms')
 };
 
 // Pages
@@ -156,30 +218,146 @@ export const pages = {
 		}),
 
 	publish: (id: number) =>
-		request(`/api/pages/${id}/publish`, { method: 'POST' }),
+		request<{ published: boolean; lint: ThemeLint; skipped_links: SkippedLink[] }>(`/api/pages/${id}/publish`, { method: 'POST' }),
+
+	unpublish: (id: number) =>
+		request<{ published: boolean }>(`/api/pages/${id}/unpublish`, { method: 'POST' }),
+
+	updateSEO: (id: number, seo: SEOSettings) =>
+		request<SEOSettings>(`/api/pages/${id}/seo`, {
+			method: 'PUT',
+			body: JSON.stringify(seo)
+		}),
+
+	// Query-string template such as utm_source=linkbio&utm_campaign={page};
+	// groups and links override it per parameter.
+	updateUTM: (id: number, template: string) =>
+		request<{ template: string }>(`/api/pages/${id}/utm`, {
+			method: 'PUT',
+			body: JSON.stringify({ template })
+		}),
+
+	listSchedules: (id: number) =>
+		request<PageSchedule[]>(`/api/pages/${id}/schedules`),
+
+	// runAt is an ISO 8601 timestamp
+	createSchedule: (id: number, action: 'publish' | 'unpublish', runAt: string) =>
+		request<PageSchedule>(`/api/pages/${id}/schedules`, {
+			method: 'POST',
+			body: JSON.stringify({ action, run_at: runAt })
+		}),
+
+	cancelSchedule: (id: number, scheduleId: number) =>
+		request(`/api/pages/${id}/schedules/${scheduleId}`, { method: 'DELETE' }),
+
+	createPreview: (id: number, ttlMinutes?: number) =>
+		request<PreviewLink>(`/api/pages/${id}/preview`, {
+			method: 'POST',
+			body: JSON.stringify({ ttl_minutes: ttlMinutes })
+		}),
+
+	analytics: (id: number, days?: number) =>
+		request<AnalyticsSummary>(`/api/pages/${id}/analytics${days ? `?days=${days}` : ''}`),
+
+	experiments: (id: number) =>
+		request<Experiment[]>(`/api/pages/${id}/experiments`),
+
+	// One experiment runs per page at a time; variants are weighed against
+	// the first one.
+	createExperiment: (id: number, name: string, variants: ExperimentVariantInput[]) =>
+		request<Experiment>(`/api/pages/${id}/experiments`, {
+			method: 'POST',
+			body: JSON.stringify({ name, variants })
+		}),
+
+	experimentResults: (id: number, experimentId: number) =>
+		request<ExperimentResults>(`/api/pages/${id}/experiments/${experimentId}`),
+
+	// Promoting copies the winner's titles and order into the draft.
+	endExperiment: (id: number, experimentId: number, winner = '', promote = false) =>
+		request<Experiment>(`/api/pages/${id}/experiments/${experimentId}/end`, {
+			method: 'POST',
+			body: JSON.stringify({ winner, promote })
+		}),
+
+	// Image URLs rather than requests, for <img> and download links.
+	qrUrl: (id: number, opts: QROptions = {}) =>
+		`${API_URL}/api/pages/${id}/qr${qrQuery(opts)}`,
+
+	linkQrUrl: (linkId: number, opts: QROptions = {}) =>
+		`${API_URL}/api/bio/links/${linkId}/qr${qrQuery(opts)}`,
 
 	delete: (id: number) =>
 		request(`/api/pages/${id}`, { method: 'DELETE' })
 };
 
+function qrQuery(opts: QROptions): string {
+	const params = new URLSearchParams();
+	for (const [key, value] of Object.entries(opts)) {
+		if (value !== undefined && value !== '' && value !== false) params.set(key, String(value));
+	}
+	const query = params.toString();
+	return query ? `?${query}` : '';
+}
+
 // Themes
 export const themes = {
 	listPresets: (tier?: string) =>
 		request<ThemePreset[]>(`/api/themes/presets${tier ? `?tier=${tier}` : ''}`),
 
-	getUserCustom: () =>
-		request<ThemeCustom | null>('/api/themes/custom'),
+	listCustom: () =>
+		request<ThemeCustom[]>('/api/themes/custom'),
 
-	createCustom: (presetId: number, patch: object) =>
-		request<ThemeCustom>('/api/themes/custom', {
+	createCustom: (presetId: number, patch: object, name?: string) =>
+		request<SavedThemeCustom>('/api/themes/custom', {
 			method: 'POST',
-			body: JSON.stringify({ preset_id: presetId, patch })
+			body: JSON.stringify({ preset_id: presetId, patch, name })
 		}),
 
-	deleteCustom: (id: number) =>
-		request<{ deleted: boolean }>(`/api/themes/custom/${id}`, {
-			method: 'DELETE'
-		})
+	updateCustom: (id: number, data: { name?: string; patch?: object }) =>
+		request<SavedThemeCustom>(`/api/themes/custom/${id}`, {
+			method: 'PUT',
+			body: JSON.stringify(data)
+		}),
+
+	duplicateCustom: (id: number, name?: string) =>
+		request<ThemeCustom>(`/api/themes/custom/${id}/duplicate`, {
+			method: 'POST',
+			body: JSON.stringify({ name })
+		}),
+
+	// What upgrading onto the preset's latest version would change.
+	previewUpgrade: (id: number) =>
+		request<ThemeUpgrade>(`/api/themes/custom/${id}/upgrade`),
+
+	upgradeCustom: (id: number) =>
+		request<ThemeUpgrade>(`/api/themes/custom/${id}/upgrade`, { method: 'POST' }),
+
+	// fallback switches pages still using the theme back to its preset
+	deleteCustom: (id: number, fallback = false) =>
+		request<{ deleted: boolean; fallback_page_ids: number[] }>(
+			`/api/themes/custom/${id}${fallback ? '?fallback=true' : ''}`,
+			{ method: 'DELETE' }
+		)
+};
+
+// Assets
+export const assets = {
+	// Multipart, so the JSON Content-Type of request() must not be sent.
+	upload: async (file: File): Promise<Asset> => {
+		const body = new FormData();
+		body.append('file', file);
+		const res = await fetch(`${API_URL}/api/assets`, {
+			method: 'POST',
+			credentials: 'include',
+			body
+		});
+		const json: ApiResponse<Asset> = await res.json();
+		if (!json.success) {
+			throw new Error(json.error?.message || 'Upload failed');
+		}
+		return json.data as Asset;
+	}
 };
 
 // Types
@@ -201,10 +379,40 @@ export interface Link {
 	url: string;
 	sort_key: string;
 	is_active: boolean;
+	utm_template?: string | null;
+	rules?: LinkRule[] | null;
+	// Once expires_at passes or click_count reaches max_clicks the link is
+	// hidden, or with fallback 'redirect' sends clicks to fallback_url.
+	expires_at?: string | null;
+	max_clicks?: number | null;
+	click_count?: number;
+	fallback?: 'hide' | 'redirect';
+	fallback_url?: string | null;
 	created_at: string;
 	updated_at: string;
 }
 
+// url is on the page's custom domain when it has one, and empty while the
+// page has no route.
+export interface ShortLink {
+	id: number;
+	link_id: number;
+	code: string;
+	is_custom: boolean;
+	url: string;
+	created_at: string;
+}
+
+export interface Asset {
+	id: number;
+	url: string;
+	mime_type: string;
+	size_bytes: number;
+	width: number;
+	height: number;
+	dominant_color: string | null;
+}
+
 export interface Page {
 	id: number;
 	user_id: number;
@@ -230,6 +438,16 @@ export interface Block {
 	is_visible: boolean;
 }
 
+// A targeting rule sends visitors matching every condition it sets to url.
+// The first matching rule wins; the link's own url is the fallback.
+export interface LinkRule {
+	country?: string[];
+	os?: string[];
+	device?: string[];
+	language?: string[];
+	url: string;
+}
+
 export interface LinkGroup {
 	id: number;
 	page_id: number;
@@ -237,6 +455,7 @@ export interface LinkGroup {
 	layout_type: string;
 	layout_config: object;
 	style_override?: object;
+	utm_template?: string | null;
 }
 
 export interface GroupWithLinks extends LinkGroup {
@@ -252,15 +471,30 @@ export interface BioData {
 	blocks: BlockWithGroup[];
 }
 
-export interface SocialLinks {
-	instagram?: string;
-	facebook?: string;
-	twitter?: string;
-	tiktok?: string;
-	youtube?: string;
-	linkedin?: string;
-	github?: string;
-	website?: string;
+export interface SocialPlatform {
+	key: string;
+	name: string;
+	icon: string;
+	order: number;
+	placeholder: string;
+}
+
+export interface SocialLinkInput {
+	platform: string;
+	value: string;
+}
+
+export interface SocialLink {
+	platform: string;
+	handle?: string;
+	url: string;
+}
+
+export interface CompiledSocial {
+	platform: string;
+	name: string;
+	icon: string;
+	url: string;
 }
 
 export interface DraftData {
@@ -317,6 +551,180 @@ export interface ThemePreset {
 export interface ThemeCustom {
 	id: number;
 	based_on_preset_id: number;
+	name?: string;
 	patch: object;
 	compiled_config?: object;
 }
+
+// missing_keys are patch settings the new preset version dropped.
+export interface ThemeUpgrade {
+	custom_id: number;
+	from_version: string | null;
+	to_version: string | null;
+	upgrade_available: boolean;
+	missing_keys: string[];
+	patch: object;
+}
+
+// Contrast problems found in a compiled theme; they never block a save.
+export interface ThemeLintIssue {
+	level: 'error' | 'warning';
+	code: string;
+	mode?: string;
+	field: string;
+	against?: string;
+	foreground?: string;
+	background?: string;
+	ratio?: number;
+	required?: number;
+}
+
+export interface ThemeLint {
+	errors: ThemeLintIssue[];
+	warnings: ThemeLintIssue[];
+}
+
+// An active link left off the published page because one of its URLs no
+// longer passes the URL policy.
+export interface SkippedLink {
+	link_id: number;
+	title: string;
+	field: string;
+	reason: string;
+}
+
+export interface SavedThemeCustom extends ThemeCustom {
+	lint: ThemeLint;
+}
+
+// Stored under settings.seo; empty fields fall back to the page itself.
+export interface SEOSettings {
+	title?: string;
+	description?: string;
+	image_asset_id?: number;
+	noindex?: boolean;
+}
+
+export interface CompiledSEO {
+	title: string;
+	description?: string;
+	image?: string;
+	image_width?: number;
+	image_height?: number;
+	noindex: boolean;
+	canonical_url?: string;
+	meta: { name?: string; property?: string; content: string }[];
+}
+
+export interface QROptions {
+	format?: 'png' | 'svg';
+	size?: number;
+	margin?: number;
+	level?: 'L' | 'M' | 'Q' | 'H';
+	fg?: string;
+	bg?: string;
+	theme?: boolean;
+	avatar?: boolean;
+	download?: boolean;
+}
+
+export interface AnalyticsSummary {
+	since: string;
+	views: number;
+	clicks: number;
+	sources: { source: string; views: number; clicks: number }[];
+	links: { link_id: number; clicks: number }[];
+}
+
+export interface ExperimentVariantInput {
+	name: string;
+	weight: number;
+	link_titles?: { link_id: number; title: string }[];
+	block_order?: number[];
+}
+
+export interface ExperimentVariant extends ExperimentVariantInput {
+	key: string;
+}
+
+export interface Experiment {
+	id: number;
+	page_id: number;
+	name: string;
+	status: 'running' | 'ended';
+	variants: ExperimentVariant[];
+	winner: string | null;
+	started_at: string;
+	ended_at: string | null;
+	created_at: string;
+	updated_at: string;
+}
+
+export interface ExperimentResults {
+	experiment: Experiment;
+	variants: {
+		key: string;
+		name: string;
+		weight: number;
+		views: number;
+		clicks: number;
+		ctr: number;
+		// Against the first variant; null for the first itself.
+		lift: number | null;
+		p_value: number | null;
+		significant: boolean;
+	}[];
+}
+
+export interface PageSchedule {
+	id: number;
+	page_id: number;
+	action: 'publish' | 'unpublish';
+	run_at: string;
+	status: 'pending' | 'done' | 'failed' | 'canceled';
+	attempts: number;
+	last_error?: string;
+	executed_at?: string;
+	created_at: string;
+}
+
+export interface PreviewLink {
+	token: string;
+	url: string;
+	expires_at: string;
+}
+
+export interface CompiledPage {
+	page: {
+		id: number;
+		title?: string;
+		locale: string;
+		mode: string;
+		settings: {
+			bio?: string;
+		};
+	};
+	user?: {
+		username?: string;
+		display_name?: string;
+	};
+	theme: object;
+	stylesheet: string;
+	seo?: CompiledSEO;
+	social?: CompiledSocial[];
+	blocks: CompiledBlock[];
+	experiment?: { id: number; variant: string };
+}
+
+export interface CompiledBlock {
+	id: number;
+	type: string;
+	content: object;
+	is_visible: boolean;
+	group?: {
+		id: number;
+		title?: string;
+		layout_type: string;
+		links: { id: number; title: string; url: string; expires_at?: string }[];
+	};
+}
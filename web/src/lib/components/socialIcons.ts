@@ -0,0 +1,22 @@
+import { Instagram, Facebook, Twitter, Music, Youtube, Linkedin, Github, Globe, AtSign, Cloud, Send, MessageCircle } from 'lucide-svelte';
+
+// Icons for the registry's icon keys; unknown keys fall back to a globe so
+// new platforms render before they get an icon of their own.
+const icons: Record<string, typeof Globe> = {
+	instagram: Instagram,
+	facebook: Facebook,
+	twitter: Twitter,
+	threads: AtSign,
+	tiktok: Music,
+	youtube: Youtube,
+	linkedin: Linkedin,
+	github: Github,
+	bluesky: Cloud,
+	telegram: Send,
+	zalo: MessageCircle,
+	globe: Globe
+};
+
+export function socialIcon(key: string) {
+	return icons[key] ?? Globe;
+}
@@ -0,0 +1,30 @@
+import { error, type RequestEvent } from '@sveltejs/kit';
+import { API_URL } from '$lib/api/client';
+
+// forwardClick asks the API where a tracked or short link leads and sends
+// the visitor there. The API must see the domain the link was opened on,
+// to check a custom domain owns the link, and the visitor's address and
+// headers, to target and rate-limit clicks. It believes the forwarded
+// headers only from proxies in its TRUSTED_PROXIES.
+export async function forwardClick(event: RequestEvent, path: string): Promise<Response> {
+	const headers: Record<string, string> = {
+		'x-forwarded-host': event.url.host,
+		'x-forwarded-for': event.getClientAddress()
+	};
+	for (const name of ['user-agent', 'accept-language']) {
+		const value = event.request.headers.get(name);
+		if (value) headers[name] = value;
+	}
+
+	const res = await event.fetch(`${API_URL}${path}${event.url.search}`, { headers, redirect: 'manual' });
+	const location = res.headers.get('location');
+	if (res.status >= 300 && res.status < 400 && location) {
+		// Every click must reach the API to be counted.
+		return new Response(null, {
+			status: res.status,
+			headers: { location, 'cache-control': 'private, no-store', 'x-robots-tag': 'noindex' }
+		});
+	}
+	const json = await res.json().catch(() => null);
+	error(res.status >= 400 ? res.status : 502, json?.error?.message || 'Link not found');
+}
@@ -1,4 +1,4 @@
-import { themes, pages, type ThemePreset, type Page, type ThemeCustom } from '$lib/api/client';
+import { themes, pages, type ThemePreset, type Page, type ThemeCustom, type ThemeLint, type ThemeUpgrade } from '$lib/api/client';
 
 // Appearance settings state
 let loading = $state(true);
@@ -12,6 +12,8 @@ let customPatch = $state<Record<string, any>>({});
 let originalPatch = $state<Record<string, any>>({});
 let dirty = $state(false);
 let isUsingCustom = $state(false); // Track if currently using custom theme
+let lint = $state<ThemeLint | null>(null); // contrast report of the last save
+let upgrade = $state<ThemeUpgrade | null>(null); // newer preset version for the custom theme
 
 // Default appearance values (fallback khi preset không có)
 const defaultAppearance = {
@@ -383,6 +385,8 @@ export function getAppearance() {
 		get customPatch() { return customPatch; },
 		get dirty() { return dirty; },
 		get isUsingCustom() { return isUsingCustom; },
+		get lint() { return lint; },
+		get upgrade() { return upgrade; },
 		get settings(): AppearanceSettings {
 			return computeSettings();
 		},
@@ -396,11 +400,14 @@ export function getAppearance() {
 export async function loadAppearance(pageId: number) {
 	loading = true;
 	try {
-		const [presetsData, customThemeData, pageData] = await Promise.all([
+		const [presetsData, library, pageData] = await Promise.all([
 			themes.listPresets(),
-			themes.getUserCustom(),
+			themes.listCustom(),
 			pages.get(pageId)
 		]);
+		// The page's own theme, else the most recently edited one
+		const customThemeData =
+			library.find(t => t.id === pageData.theme_custom_id) ?? library[0] ?? null;
 		
 		presets = presetsData;
 		customTheme = customThemeData;
@@ -421,6 +428,7 @@ export async function loadAppearance(pageId: number) {
 		}
 		
 		dirty = false;
+		upgrade = customThemeData ? await themes.previewUpgrade(customThemeData.id).catch(() => null) : null;
 	} catch (e) {
 		console.error('Failed to load appearance:', e);
 	} finally {
@@ -527,12 +535,19 @@ export async function saveAppearance(): Promise<boolean> {
 		
 		// Only create custom theme when using custom with changes
 		if (isUsingCustom && Object.keys(customPatch).length > 0) {
-			const result = await themes.createCustom(selectedPresetId, customPatch);
+			// Edit the page's theme in place; otherwise add one to the library
+			const result =
+				customTheme && customTheme.id === currentPage.theme_custom_id &&
+				customTheme.based_on_preset_id === selectedPresetId
+					? await themes.updateCustom(customTheme.id, { patch: customPatch })
+					: await themes.createCustom(selectedPresetId, customPatch);
 			customThemeId = result.id;
 			customTheme = result;
+			lint = result.lint;
 		} else {
 			// Using preset only → set custom_id = null
 			customThemeId = null;
+			lint = null;
 		}
 		
 		// Update page with theme settings
@@ -562,13 +577,30 @@ export async function saveAppearance(): Promise<boolean> {
 	}
 }
 
+// Move the custom theme onto its preset's latest version
+export async function upgradeCustomTheme(): Promise<boolean> {
+	if (!customTheme || !currentPage) return false;
+
+	saving = true;
+	try {
+		await themes.upgradeCustom(customTheme.id);
+		await loadAppearance(currentPage.id);
+		return true;
+	} catch (e) {
+		console.error('Failed to upgrade custom theme:', e);
+		return false;
+	} finally {
+		saving = false;
+	}
+}
+
 // Delete custom theme
 export async function deleteCustomTheme(): Promise<boolean> {
 	if (!customTheme || !currentPage) return false;
 	
 	saving = true;
 	try {
-		await themes.deleteCustom(customTheme.id);
+		await themes.deleteCustom(customTheme.id, true);
 		
 		// Switch to preset
 		await pages.save(currentPage.id, {
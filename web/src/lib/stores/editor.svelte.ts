@@ -175,7 +175,7 @@ export function updateLink(id: number, updates: Partial<Link>) {
 	for (const groupLinks of Object.values(draft.links)) {
 		const link = groupLinks.find(l => l.id === id);
 		if (link) {
-			const updated = { ...link, ...updates };
+			const updated = { ...(pendingLinks.get(id) || link), ...updates };
 			pendingLinks.set(id, updated);
 			dirty = true;
 			break;
@@ -244,7 +244,16 @@ export async function save() {
 	}
 }
 
+// Returns the links the server left off the page, if any.
 export async function publish() {
-	if (!draft) return;
-	await pages.publish(draft.page.id);
+	if (!draft) return [];
+	const result = await pages.publish(draft.page.id);
+	return result.skipped_links ?? [];
+}
+
+// Signs a link that shows the saved draft without publishing it.
+export async function sharePreview() {
+	if (!draft) return null;
+	if (dirty) await save();
+	return pages.createPreview(draft.page.id);
 }
@@ -0,0 +1,43 @@
+import { error } from '@sveltejs/kit';
+import { API_URL, type CompiledPage } from '$lib/api/client';
+import type { PageServerLoad } from './$types';
+
+// How long a visitor keeps their experiment variant, as the API sets it.
+const experimentCookieAge = 90 * 24 * 60 * 60;
+
+// The page is loaded on the server so link unfurlers, which do not run
+// scripts, see its title, Open Graph, Twitter, canonical and robots tags.
+export const load: PageServerLoad = async ({ params, cookies, fetch, setHeaders }) => {
+	// Only the experiment cookies concern the API; they keep a visitor on
+	// the variant they were first shown.
+	const experiments = cookies
+		.getAll()
+		.filter((c) => c.name.startsWith('lb_exp_'))
+		.map((c) => `${c.name}=${encodeURIComponent(c.value)}`)
+		.join('; ');
+
+	const res = await fetch(`${API_URL}/r?path=${encodeURIComponent(`/${params.username}`)}`, {
+		headers: experiments ? { cookie: experiments } : {}
+	});
+	if (!res.ok) {
+		const json = await res.json().catch(() => null);
+		error(res.status, json?.error?.message || 'Page not found');
+	}
+	const data: CompiledPage = await res.json();
+
+	if (data.experiment) {
+		cookies.set(`lb_exp_${data.experiment.id}`, data.experiment.variant, {
+			path: '/',
+			maxAge: experimentCookieAge,
+			httpOnly: true,
+			sameSite: 'lax'
+		});
+	}
+	const headers: Record<string, string> = {};
+	const cacheControl = res.headers.get('cache-control');
+	if (cacheControl) headers['cache-control'] = cacheControl;
+	if (data.seo?.noindex) headers['x-robots-tag'] = 'noindex, nofollow';
+	setHeaders(headers);
+
+	return { compiled: data };
+};
@@ -0,0 +1,7 @@
+import { forwardClick } from '$lib/server/forward';
+import type { RequestHandler } from './$types';
+
+// Tracked links printed on QR codes carry the page's domain; the API
+// counts the click and redirects.
+export const GET: RequestHandler = (event) =>
+	forwardClick(event, `/l/${encodeURIComponent(event.params.id)}`);
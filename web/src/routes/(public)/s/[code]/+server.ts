@@ -0,0 +1,6 @@
+import { forwardClick } from '$lib/server/forward';
+import type { RequestHandler } from './$types';
+
+// Short links are counted and redirected by the API.
+export const GET: RequestHandler = (event) =>
+	forwardClick(event, `/s/${encodeURIComponent(event.params.code)}`);